@@ -3,61 +3,291 @@ package activitylog
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/middleware"
+	"github.com/scottmckendry/beam/money"
 )
 
 type ActivityType string
 
 const (
-	ActivityTypeCustomer ActivityType = "customer"
-	ActivityTypeContact  ActivityType = "contact"
+	ActivityTypeCustomer     ActivityType = "customer"
+	ActivityTypeContact      ActivityType = "contact"
+	ActivityTypeSubscription ActivityType = "subscription"
+	ActivityTypeFile         ActivityType = "file"
+	ActivityTypeAuth         ActivityType = "auth"
+	ActivityTypeRetention    ActivityType = "retention"
 )
 
+// ActivityTypes lists every activity type, in the order they are offered as filters.
+var ActivityTypes = []ActivityType{
+	ActivityTypeCustomer,
+	ActivityTypeContact,
+	ActivityTypeSubscription,
+	ActivityTypeFile,
+	ActivityTypeAuth,
+	ActivityTypeRetention,
+}
+
 // LogCustomerCreated logs a customer creation event.
 func LogCustomerCreated(ctx context.Context, queries *db.Queries, customer db.Customer) {
-	logActivity(ctx, queries, customer.ID, ActivityTypeCustomer, "customer_created", fmt.Sprintf("Customer %s created", customer.Name))
+	logActivity(ctx, queries, customer.ID, ActivityTypeCustomer, "customer_created", fmt.Sprintf("Customer %s created", customer.Name), nil)
 }
 
-// LogCustomerUpdated logs a customer update event.
-func LogCustomerUpdated(ctx context.Context, queries *db.Queries, customer db.GetCustomerRow) {
-	logActivity(ctx, queries, customer.ID, ActivityTypeCustomer, "customer_updated", fmt.Sprintf("Customer %s updated", customer.Name))
+// LogCustomerImported logs a customer created by a CSV import, along with the file it came from.
+func LogCustomerImported(ctx context.Context, queries *db.Queries, customer db.Customer, fileName string) {
+	logActivity(ctx, queries, customer.ID, ActivityTypeCustomer, "customer_imported", fmt.Sprintf("Customer %s imported from %s", customer.Name, fileName), nil)
+}
+
+// LogCustomerUpdated logs a customer update event along with the fields that changed.
+func LogCustomerUpdated(ctx context.Context, queries *db.Queries, before db.GetCustomerRow, after db.Customer) {
+	logActivity(ctx, queries, after.ID, ActivityTypeCustomer, "customer_updated", fmt.Sprintf("Customer %s updated", after.Name), Diff(before, after))
 }
 
 // LogCustomerDeleted logs a customer deletion event.
 func LogCustomerDeleted(ctx context.Context, queries *db.Queries, customer db.Customer) {
-	logActivity(ctx, queries, customer.ID, ActivityTypeCustomer, "customer_deleted", fmt.Sprintf("Customer %s deleted", customer.Name))
+	logActivity(ctx, queries, customer.ID, ActivityTypeCustomer, "customer_deleted", fmt.Sprintf("Customer %s deleted", customer.Name), nil)
+}
+
+// LogCustomerStatusChanged logs a customer status change made by a bulk action, along with the saved filter used.
+func LogCustomerStatusChanged(ctx context.Context, queries *db.Queries, before, after db.Customer, filterName string) {
+	logActivity(ctx, queries, after.ID, ActivityTypeCustomer, "customer_updated", fmt.Sprintf("Customer %s set to %s%s", after.Name, after.Status, usingFilter(filterName)), Diff(before, after))
+}
+
+// LogCustomerTagged logs a tag being added to a customer, along with the saved filter used if it was a bulk action.
+func LogCustomerTagged(ctx context.Context, queries *db.Queries, customerID uuid.UUID, customerName, tagName, filterName string) {
+	logActivity(ctx, queries, customerID, ActivityTypeCustomer, "customer_tagged", fmt.Sprintf("Customer %s tagged %s%s", customerName, tagName, usingFilter(filterName)), nil)
+}
+
+// LogCustomerUntagged logs a tag being removed from a customer, along with the saved filter used if it was a bulk action.
+func LogCustomerUntagged(ctx context.Context, queries *db.Queries, customerID uuid.UUID, customerName, tagName, filterName string) {
+	logActivity(ctx, queries, customerID, ActivityTypeCustomer, "customer_untagged", fmt.Sprintf("Tag %s removed from customer %s%s", tagName, customerName, usingFilter(filterName)), nil)
+}
+
+// usingFilter describes the saved filter a bulk action was applied through, or nothing for single changes.
+func usingFilter(filterName string) string {
+	if filterName == "" {
+		return ""
+	}
+	return fmt.Sprintf(" using the %s filter", filterName)
 }
 
 // LogContactAdded logs a contact creation event.
 func LogContactAdded(ctx context.Context, queries *db.Queries, customerID uuid.UUID, contactName string) {
-	logActivity(ctx, queries, customerID, ActivityTypeContact, "contact_added", fmt.Sprintf("Contact %s added", contactName))
+	logActivity(ctx, queries, customerID, ActivityTypeContact, "contact_added", fmt.Sprintf("Contact %s added", contactName), nil)
 }
 
-// LogContactUpdated logs a contact update event.
-func LogContactUpdated(ctx context.Context, queries *db.Queries, customerID uuid.UUID, contactName string) {
-	logActivity(ctx, queries, customerID, ActivityTypeContact, "contact_updated", fmt.Sprintf("Contact %s updated", contactName))
+// LogContactImported logs a contact created by a CSV import, along with the file it came from.
+func LogContactImported(ctx context.Context, queries *db.Queries, customerID uuid.UUID, contactName, fileName string) {
+	logActivity(ctx, queries, customerID, ActivityTypeContact, "contact_imported", fmt.Sprintf("Contact %s imported from %s", contactName, fileName), nil)
+}
+
+// LogContactUpdated logs a contact update event along with the fields that changed.
+func LogContactUpdated(ctx context.Context, queries *db.Queries, before, after db.Contact) {
+	logActivity(ctx, queries, after.CustomerID, ActivityTypeContact, "contact_updated", fmt.Sprintf("Contact %s updated", after.Name), Diff(before, after))
+}
+
+// LogContactMerged logs a contact updated from a vCard import, along with the file it came from and the fields that
+// changed. Nothing is logged when the card matched the contact.
+func LogContactMerged(ctx context.Context, queries *db.Queries, before, after db.Contact, fileName string) {
+	changes := Diff(before, after)
+	if len(changes) == 0 {
+		return
+	}
+	logActivity(ctx, queries, after.CustomerID, ActivityTypeContact, "contact_updated", fmt.Sprintf("Contact %s updated from %s", after.Name, fileName), changes)
 }
 
 // LogContactDeleted logs a contact deletion event.
 func LogContactDeleted(ctx context.Context, queries *db.Queries, customerID uuid.UUID, contactName string) {
-	logActivity(ctx, queries, customerID, ActivityTypeContact, "contact_deleted", fmt.Sprintf("Contact %s deleted", contactName))
+	logActivity(ctx, queries, customerID, ActivityTypeContact, "contact_deleted", fmt.Sprintf("Contact %s deleted", contactName), nil)
+}
+
+// LogCustomFieldsUpdated logs changes to the custom field values of a customer or contact. Nothing is logged when
+// no values changed.
+func LogCustomFieldsUpdated(ctx context.Context, queries *db.Queries, customerID uuid.UUID, activityType ActivityType, recordName string, changes []FieldChange) {
+	if len(changes) == 0 {
+		return
+	}
+	logActivity(ctx, queries, customerID, activityType, "custom_fields_updated", fmt.Sprintf("Custom fields updated for %s %s", activityType, recordName), changes)
+}
+
+// LogSubscriptionAdded logs a subscription creation event.
+func LogSubscriptionAdded(ctx context.Context, queries *db.Queries, subscription db.Subscription) {
+	logActivity(ctx, queries, subscription.CustomerID, ActivityTypeSubscription, "subscription_added", fmt.Sprintf("Subscription %s added", subscription.Description), nil)
+}
+
+// LogSubscriptionUpdated logs a subscription update event along with the fields that changed.
+func LogSubscriptionUpdated(ctx context.Context, queries *db.Queries, before, after db.Subscription) {
+	logActivity(ctx, queries, after.CustomerID, ActivityTypeSubscription, "subscription_updated", fmt.Sprintf("Subscription %s updated", after.Description), Diff(before, after))
+}
+
+// LogSubscriptionRepriced logs an amendment to the price of a subscription, with the prorated charges and credits it
+// left owing on the next invoice.
+func LogSubscriptionRepriced(ctx context.Context, queries *db.Queries, before, after db.Subscription, effective time.Time, adjustments []db.SubscriptionAdjustment) {
+	message := fmt.Sprintf("Subscription %s repriced from %s to %s, effective %s", after.Description, money.New(before.Amount, before.Currency), money.New(after.Amount, after.Currency), effective.Format("Jan 2, 2006"))
+	if before.Quantity != after.Quantity {
+		message += fmt.Sprintf(", seats changed from %d to %d", before.Quantity, after.Quantity)
+	}
+	for _, a := range adjustments {
+		message += fmt.Sprintf(", %s owed on the next invoice", money.New(a.Amount, a.Currency))
+	}
+	logActivity(ctx, queries, after.CustomerID, ActivityTypeSubscription, "subscription_repriced", message, nil)
+}
+
+// LogSubscriptionDeleted logs a subscription deletion event.
+func LogSubscriptionDeleted(ctx context.Context, queries *db.Queries, subscription db.Subscription) {
+	logActivity(ctx, queries, subscription.CustomerID, ActivityTypeSubscription, "subscription_deleted", fmt.Sprintf("Subscription %s deleted", subscription.Description), nil)
+}
+
+// LogSubscriptionStatusChanged logs a change of a subscription's status, made on or after the day it took effect.
+func LogSubscriptionStatusChanged(ctx context.Context, queries *db.Queries, before, after db.Subscription, effective time.Time) {
+	logActivity(ctx, queries, after.CustomerID, ActivityTypeSubscription, "subscription_status_changed", fmt.Sprintf("Subscription %s changed from %s to %s, effective %s", after.Description, before.Status, after.Status, effective.Format("Jan 2, 2006")), Diff(before, after))
+}
+
+// LogSubscriptionStatusScheduled logs a status change scheduled for a later day.
+func LogSubscriptionStatusScheduled(ctx context.Context, queries *db.Queries, subscription db.Subscription, status string, effective time.Time) {
+	logActivity(ctx, queries, subscription.CustomerID, ActivityTypeSubscription, "subscription_status_scheduled", fmt.Sprintf("Subscription %s scheduled to change to %s on %s", subscription.Description, status, effective.Format("Jan 2, 2006")), nil)
 }
 
-// logActivity inserts a new activity log entry for a customer or contact
-func logActivity(ctx context.Context, queries *db.Queries, customerID uuid.UUID, activityType ActivityType, action, description string) {
-	activity := db.LogActivityParams{
-		CustomerID:   customerID,
+// LogSubscriptionStatusWithdrawn logs a scheduled status change being withdrawn before it took effect.
+func LogSubscriptionStatusWithdrawn(ctx context.Context, queries *db.Queries, subscription db.Subscription, status string, effective time.Time) {
+	logActivity(ctx, queries, subscription.CustomerID, ActivityTypeSubscription, "subscription_status_withdrawn", fmt.Sprintf("Scheduled change of subscription %s to %s on %s withdrawn", subscription.Description, status, effective.Format("Jan 2, 2006")), nil)
+}
+
+// LogSubscriptionStatusSkipped logs a scheduled status change that could no longer be made when it came due.
+func LogSubscriptionStatusSkipped(ctx context.Context, queries *db.Queries, subscription db.Subscription, status, reason string) {
+	logActivity(ctx, queries, subscription.CustomerID, ActivityTypeSubscription, "subscription_status_skipped", fmt.Sprintf("Scheduled change of subscription %s to %s skipped: %s", subscription.Description, status, reason), nil)
+}
+
+// LogSubscriptionDiscounted logs a discount given on a subscription.
+func LogSubscriptionDiscounted(ctx context.Context, queries *db.Queries, subscription db.Subscription, discount string) {
+	logActivity(ctx, queries, subscription.CustomerID, ActivityTypeSubscription, "subscription_discounted", fmt.Sprintf("Subscription %s discounted: %s", subscription.Description, discount), nil)
+}
+
+// LogSubscriptionDiscountRemoved logs a discount taken off a subscription.
+func LogSubscriptionDiscountRemoved(ctx context.Context, queries *db.Queries, subscription db.Subscription, discount string) {
+	logActivity(ctx, queries, subscription.CustomerID, ActivityTypeSubscription, "subscription_discount_removed", fmt.Sprintf("Discount removed from subscription %s: %s", subscription.Description, discount), nil)
+}
+
+// LogLogoUploaded logs a customer logo upload.
+func LogLogoUploaded(ctx context.Context, queries *db.Queries, customerID uuid.UUID, customerName string) {
+	logActivity(ctx, queries, customerID, ActivityTypeFile, "logo_uploaded", fmt.Sprintf("Logo uploaded for %s", customerName), nil)
+}
+
+// LogLogoDeleted logs a customer logo removal.
+func LogLogoDeleted(ctx context.Context, queries *db.Queries, customerID uuid.UUID, customerName string) {
+	logActivity(ctx, queries, customerID, ActivityTypeFile, "logo_deleted", fmt.Sprintf("Logo removed for %s", customerName), nil)
+}
+
+// LogAvatarUploaded logs a contact avatar upload.
+func LogAvatarUploaded(ctx context.Context, queries *db.Queries, customerID uuid.UUID, contactName string) {
+	logActivity(ctx, queries, customerID, ActivityTypeFile, "avatar_uploaded", fmt.Sprintf("Avatar uploaded for %s", contactName), nil)
+}
+
+// LogAvatarDeleted logs a contact avatar removal.
+func LogAvatarDeleted(ctx context.Context, queries *db.Queries, customerID uuid.UUID, contactName string) {
+	logActivity(ctx, queries, customerID, ActivityTypeFile, "avatar_deleted", fmt.Sprintf("Avatar removed for %s", contactName), nil)
+}
+
+// LogUserSignedIn logs a successful sign-in. Sign-ins are not tied to a customer, and the user
+// is recorded as the actor explicitly as the request has not been authenticated yet.
+func LogUserSignedIn(ctx context.Context, queries *db.Queries, githubID string) {
+	record(ctx, queries, db.LogActivityParams{
+		ActivityType: string(ActivityTypeAuth),
+		Action:       "user_signed_in",
+		Description:  fmt.Sprintf("%s signed in", githubID),
+		Actor:        sql.NullString{String: githubID, Valid: githubID != ""},
+	}, nil)
+}
+
+// LogAppPasswordCreated logs an app password being issued to the current user.
+func LogAppPasswordCreated(ctx context.Context, queries *db.Queries, name string) {
+	record(ctx, queries, db.LogActivityParams{
+		ActivityType: string(ActivityTypeAuth),
+		Action:       "app_password_created",
+		Description:  fmt.Sprintf("App password %s created", name),
+	}, nil)
+}
+
+// LogAppPasswordRevoked logs one of the current user's app passwords being revoked.
+func LogAppPasswordRevoked(ctx context.Context, queries *db.Queries, name string) {
+	record(ctx, queries, db.LogActivityParams{
+		ActivityType: string(ActivityTypeAuth),
+		Action:       "app_password_revoked",
+		Description:  fmt.Sprintf("App password %s revoked", name),
+	}, nil)
+}
+
+// LogCalendarFeedCreated logs the current user creating their calendar feed token.
+func LogCalendarFeedCreated(ctx context.Context, queries *db.Queries) {
+	record(ctx, queries, db.LogActivityParams{
+		ActivityType: string(ActivityTypeAuth),
+		Action:       "calendar_feed_created",
+		Description:  "Calendar feed created",
+	}, nil)
+}
+
+// LogCalendarFeedReset logs the current user replacing their calendar feed token.
+func LogCalendarFeedReset(ctx context.Context, queries *db.Queries) {
+	record(ctx, queries, db.LogActivityParams{
+		ActivityType: string(ActivityTypeAuth),
+		Action:       "calendar_feed_reset",
+		Description:  "Calendar feed URLs reset",
+	}, nil)
+}
+
+// LogCalendarFeedDeleted logs the current user turning off their calendar feeds.
+func LogCalendarFeedDeleted(ctx context.Context, queries *db.Queries) {
+	record(ctx, queries, db.LogActivityParams{
+		ActivityType: string(ActivityTypeAuth),
+		Action:       "calendar_feed_deleted",
+		Description:  "Calendar feed turned off",
+	}, nil)
+}
+
+// Actor returns the user responsible for the current request, or an empty string for system changes.
+func Actor(ctx context.Context) string {
+	user, _ := ctx.Value(middleware.UserKey).(string)
+	return user
+}
+
+// logActivity inserts a new activity log entry for a customer or one of its records
+func logActivity(ctx context.Context, queries *db.Queries, customerID uuid.UUID, activityType ActivityType, action, description string, changes []FieldChange) {
+	record(ctx, queries, db.LogActivityParams{
+		CustomerID:   uuid.NullUUID{UUID: customerID, Valid: true},
 		ActivityType: string(activityType),
 		Action:       action,
 		Description:  description,
+	}, changes)
+}
+
+// record inserts an activity log entry, recording the acting user from the context (unless already set) and any field changes
+func record(ctx context.Context, queries *db.Queries, activity db.LogActivityParams, changes []FieldChange) {
+	if actor := Actor(ctx); actor != "" && !activity.Actor.Valid {
+		activity.Actor = sql.NullString{String: actor, Valid: true}
+	}
+	if len(changes) > 0 {
+		encoded, err := json.Marshal(changes)
+		if err != nil {
+			slog.Error("Failed to encode activity changes", "err", err)
+		} else {
+			activity.Changes = sql.NullString{String: string(encoded), Valid: true}
+		}
 	}
 	_, err := queries.LogActivity(ctx, activity)
 	if err != nil {
 		slog.Error("Failed to log activity", "err", err)
+		return
+	}
+	if _, err := Seal(ctx, queries); err != nil {
+		slog.Error("Failed to seal activity", "err", err)
 	}
 }
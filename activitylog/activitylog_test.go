@@ -1,13 +1,20 @@
 package activitylog
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"encoding/csv"
+	"encoding/json"
 	"os"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/scottmckendry/beam/db"
 	sqlc "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/middleware"
 )
 
 func setupTestDB(t *testing.T) (*sqlc.Queries, func()) {
@@ -44,7 +51,7 @@ func TestLogCustomerCreated_Integration(t *testing.T) {
 	}
 	found := false
 	for _, log := range logs {
-		if log.CustomerID == customer.ID && log.Action == "customer_created" {
+		if log.CustomerID.UUID == customer.ID && log.Action == "customer_created" {
 			found = true
 			break
 		}
@@ -53,3 +60,315 @@ func TestLogCustomerCreated_Integration(t *testing.T) {
 		t.Error("customer_created activity not found in log")
 	}
 }
+
+func TestLogCustomerUpdated_RecordsActorAndChanges_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.WithValue(context.Background(), middleware.UserKey, "octocat")
+
+	customer := createTestCustomer(t, queries)
+	before, err := queries.GetCustomer(ctx, customer.ID)
+	if err != nil {
+		t.Fatalf("GetCustomer failed: %v", err)
+	}
+	after, err := queries.UpdateCustomer(ctx, sqlc.UpdateCustomerParams{
+		ID:      customer.ID,
+		Version: before.Version,
+		Name:    "Renamed Customer",
+		Status:  "active",
+		Email:   sql.NullString{String: "hello@example.com", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("UpdateCustomer failed: %v", err)
+	}
+	LogCustomerUpdated(ctx, queries, before, after)
+
+	logs, err := queries.ListActivityByCustomer(ctx, uuid.NullUUID{UUID: customer.ID, Valid: true})
+	if err != nil || len(logs) == 0 {
+		t.Fatalf("ListActivityByCustomer failed: %v, %d entries", err, len(logs))
+	}
+	entry := logs[0]
+	if entry.Actor.String != "octocat" {
+		t.Errorf("expected actor octocat, got %q", entry.Actor.String)
+	}
+	changes := ParseChanges(entry.Changes)
+	want := map[string][2]any{
+		"name":  {"Test Customer", "Renamed Customer"},
+		"email": {nil, "hello@example.com"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), changes)
+	}
+	for _, change := range changes {
+		w, ok := want[change.Field]
+		if !ok || change.From != w[0] || change.To != w[1] {
+			t.Errorf("unexpected change %+v", change)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	id := uuid.New()
+	before := sqlc.Contact{
+		ID:        id,
+		Name:      "Jane",
+		Role:      sql.NullString{String: "CTO", Valid: true},
+		IsPrimary: sql.NullBool{Bool: false, Valid: true},
+		UpdatedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	after := before
+	after.Role = sql.NullString{}
+	after.IsPrimary = sql.NullBool{Bool: true, Valid: true}
+	after.UpdatedAt = sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true}
+
+	changes := Diff(before, after)
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %+v", changes)
+	}
+	if changes[0] != (FieldChange{Field: "role", From: "CTO", To: nil}) {
+		t.Errorf("unexpected role change %+v", changes[0])
+	}
+	if changes[1] != (FieldChange{Field: "is_primary", From: false, To: true}) {
+		t.Errorf("unexpected is_primary change %+v", changes[1])
+	}
+	if Diff(before, before) != nil {
+		t.Error("expected no changes for identical records")
+	}
+}
+
+func TestLogUserSignedIn_WithoutCustomer_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	LogUserSignedIn(context.Background(), queries, "octocat")
+	logs, err := queries.ListRecentActivity(context.Background())
+	if err != nil {
+		t.Fatalf("ListRecentActivity failed: %v", err)
+	}
+	for _, log := range logs {
+		if log.Action == "user_signed_in" && log.Actor.String == "octocat" {
+			if log.CustomerID.Valid {
+				t.Errorf("sign-in should not be tied to a customer, got %v", log.CustomerID.UUID)
+			}
+			return
+		}
+	}
+	t.Error("user_signed_in activity not found in log")
+}
+
+func TestLogSubscriptionUpdated_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	customer := createTestCustomer(t, queries)
+	before, err := queries.CreateSubscription(ctx, sqlc.CreateSubscriptionParams{
+		CustomerID:     customer.ID,
+		Description:    "Hosting",
+		Amount:         1000,
+		Quantity:       1,
+		UnitPrice:      1000,
+		Term:           "monthly",
+		BillingCadence: "monthly",
+		Status:         "active",
+		StartDate:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	after := before
+	after.Amount = 1500
+	LogSubscriptionUpdated(ctx, queries, before, after)
+
+	logs, err := queries.ListActivityByCustomer(ctx, uuid.NullUUID{UUID: customer.ID, Valid: true})
+	if err != nil || len(logs) == 0 {
+		t.Fatalf("ListActivityByCustomer failed: %v, %d entries", err, len(logs))
+	}
+	changes := ParseChanges(logs[0].Changes)
+	if logs[0].ActivityType != "subscription" || len(changes) != 1 || changes[0].Field != "amount" || changes[0].To != "15.00" {
+		t.Errorf("unexpected subscription activity %+v with changes %+v", logs[0], changes)
+	}
+}
+
+func TestParseCursor(t *testing.T) {
+	cursor := Cursor{CreatedAt: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC), ID: uuid.New()}
+	parsed, err := ParseCursor(cursor.String())
+	if err != nil || parsed != cursor {
+		t.Errorf("round trip: got %+v, %v, want %+v", parsed, err, cursor)
+	}
+	if parsed, err := ParseCursor(""); err != nil || parsed.ID != uuid.Nil {
+		t.Errorf("empty cursor: got %+v, %v", parsed, err)
+	}
+	for _, value := range []string{"nonsense", "2025-03-04|not-a-uuid", "yesterday|" + uuid.NewString()} {
+		if _, err := ParseCursor(value); err == nil {
+			t.Errorf("ParseCursor(%q): expected error", value)
+		}
+	}
+}
+
+func TestPage_KeysetPagination_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	customer := createTestCustomer(t, queries)
+	for i := 0; i < 7; i++ {
+		LogContactAdded(ctx, queries, customer.ID, "Contact")
+	}
+	LogCustomerCreated(ctx, queries, customer)
+
+	filter := Filter{Customer: customer.ID.String(), Type: string(ActivityTypeContact)}
+	seen := map[uuid.UUID]bool{}
+	cursor := Cursor{}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("pagination did not terminate")
+		}
+		rows, next, err := Page(ctx, queries, filter, cursor, 3)
+		if err != nil {
+			t.Fatalf("Page failed: %v", err)
+		}
+		for _, row := range rows {
+			if seen[row.ID] {
+				t.Errorf("entry %v returned twice", row.ID)
+			}
+			if row.ActivityType != string(ActivityTypeContact) {
+				t.Errorf("filter by type returned %q", row.ActivityType)
+			}
+			seen[row.ID] = true
+		}
+		if next.ID == uuid.Nil {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != 7 {
+		t.Errorf("expected 7 contact entries, got %d", len(seen))
+	}
+
+	if _, err := (Filter{From: "last week"}).Params(Cursor{}, 10); err == nil {
+		t.Error("expected an invalid from date to be rejected")
+	}
+}
+
+func TestExportCSV_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	customer := createTestCustomer(t, queries)
+	LogCustomerCreated(ctx, queries, customer)
+
+	var buf bytes.Buffer
+	today := time.Now().UTC().Format("2006-01-02")
+	if err := ExportCSV(ctx, queries, Filter{Customer: customer.ID.String(), From: today, To: today}, &buf); err != nil {
+		t.Fatalf("ExportCSV failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 2 || records[1][5] != "customer_created" || records[1][3] != customer.Name {
+		t.Errorf("unexpected export %v", records)
+	}
+}
+
+func TestExportJSONL_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+	customer := createTestCustomer(t, queries)
+	before, _ := queries.GetCustomer(ctx, customer.ID)
+	updated := customer
+	updated.Name = customer.Name + " Ltd"
+	LogCustomerUpdated(ctx, queries, before, updated)
+
+	var buf bytes.Buffer
+	if err := ExportJSONL(ctx, queries, Filter{Customer: customer.ID.String()}, &buf); err != nil {
+		t.Fatalf("ExportJSONL failed: %v", err)
+	}
+	var entry struct {
+		Action  string        `json:"action"`
+		Changes []FieldChange `json:"changes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON line, got %q: %v", buf.String(), err)
+	}
+	if entry.Action != "customer_updated" || len(entry.Changes) != 1 {
+		t.Errorf("expected the update with its changes nested, got %+v", entry)
+	}
+}
+
+func TestVerify_DetectsTampering_Integration(t *testing.T) {
+	os.MkdirAll("data", 0755)
+	store, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	customer := createTestCustomer(t, queries)
+	LogCustomerCreated(ctx, queries, customer)
+	LogContactAdded(ctx, queries, customer.ID, "Chained Contact")
+	LogContactDeleted(ctx, queries, customer.ID, "Chained Contact")
+
+	report, err := Verify(ctx, queries)
+	if err != nil || !report.OK() || report.Unsealed != 0 || report.Checked < 3 {
+		t.Fatalf("expected an intact chain, got %+v, %v", report, err)
+	}
+
+	var id string
+	var seq int64
+	if err := store.QueryRow("SELECT id, seq FROM activity_log WHERE action = 'contact_added' ORDER BY seq DESC LIMIT 1").Scan(&id, &seq); err != nil {
+		t.Fatalf("failed to find entry: %v", err)
+	}
+	tamper := []struct {
+		name    string
+		change  string
+		restore string
+	}{
+		{"description", "UPDATE activity_log SET description = 'Nothing happened' WHERE id = ?", "UPDATE activity_log SET description = 'Contact Chained Contact added' WHERE id = ?"},
+		{"action", "UPDATE activity_log SET action = 'contact_updated' WHERE id = ?", "UPDATE activity_log SET action = 'contact_added' WHERE id = ?"},
+	}
+	for _, tc := range tamper {
+		if _, err := store.Exec(tc.change, id); err != nil {
+			t.Fatalf("%s: tamper failed: %v", tc.name, err)
+		}
+		report, err := Verify(ctx, queries)
+		if err != nil || report.OK() || report.Broken.Seq != seq {
+			t.Errorf("%s: expected the chain to break at %d, got %+v, %v", tc.name, seq, report.Broken, err)
+		}
+		if _, err := store.Exec(tc.restore, id); err != nil {
+			t.Fatalf("%s: restore failed: %v", tc.name, err)
+		}
+	}
+
+	// blanking an entry is only accepted once retention has recorded anonymising it
+	if _, err := store.Exec("UPDATE activity_log SET description = ?, changes = NULL, anonymised_at = datetime('now') WHERE id = ?", AnonymisedDescription, id); err != nil {
+		t.Fatalf("anonymise failed: %v", err)
+	}
+	if report, err := Verify(ctx, queries); err != nil || report.OK() || report.Broken.Seq != seq {
+		t.Errorf("expected an entry anonymised without a retention record to break the chain at %d, got %+v, %v", seq, report.Broken, err)
+	}
+	if err := LogActivityAnonymised(ctx, queries, time.Now().Add(time.Second), 1); err != nil {
+		t.Fatalf("LogActivityAnonymised failed: %v", err)
+	}
+	if report, err := Verify(ctx, queries); err != nil || !report.OK() {
+		t.Errorf("expected entries covered by a retention record to verify, got %+v, %v", report.Broken, err)
+	}
+
+	// but marking an entry anonymised must not let its content be rewritten
+	anonymised := []struct {
+		name   string
+		change string
+	}{
+		{"description", "UPDATE activity_log SET description = 'Nothing happened' WHERE id = ?"},
+		{"changes", "UPDATE activity_log SET description = 'Details removed by retention policy', changes = '[]' WHERE id = ?"},
+	}
+	for _, tc := range anonymised {
+		if _, err := store.Exec(tc.change, id); err != nil {
+			t.Fatalf("%s: tamper failed: %v", tc.name, err)
+		}
+		report, err := Verify(ctx, queries)
+		if err != nil || report.OK() || report.Broken.Seq != seq {
+			t.Errorf("%s: expected the anonymised entry to break the chain at %d, got %+v, %v", tc.name, seq, report.Broken, err)
+		}
+	}
+}
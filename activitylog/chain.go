@@ -0,0 +1,249 @@
+package activitylog
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// genesisHash is the previous hash of the first entry in the chain.
+var genesisHash = strings.Repeat("0", 64)
+
+// AnonymisedDescription is the description retention leaves in place of the details of an entry it anonymises.
+const AnonymisedDescription = "Details removed by retention policy"
+
+// ActionActivityAnonymised is the action of the entry retention logs after anonymising older activity. These entries
+// are what anonymised entries verify against, so they are never anonymised themselves.
+const ActionActivityAnonymised = "activity_anonymised"
+
+// anonymisedBeforeField is the change of an anonymisation entry holding its cutoff, before which every entry that
+// had not been anonymised already was.
+const anonymisedBeforeField = "anonymised_before"
+
+// verifyBatchSize is the number of chain entries read per query while verifying.
+const verifyBatchSize = 500
+
+// chainMu serialises sealing so concurrent entries cannot claim the same position in the chain.
+var chainMu sync.Mutex
+
+// BrokenLink describes the first entry at which the chain no longer verifies.
+type BrokenLink struct {
+	Seq    int64     `json:"seq"`
+	ID     uuid.UUID `json:"id"`
+	Reason string    `json:"reason"`
+}
+
+// VerifyReport summarises a walk of the activity hash chain.
+type VerifyReport struct {
+	Checked  int64       `json:"checked"`
+	Unsealed int         `json:"unsealed"`
+	Broken   *BrokenLink `json:"broken,omitempty"`
+}
+
+// OK reports whether every sealed entry verified.
+func (r VerifyReport) OK() bool {
+	return r.Broken == nil
+}
+
+// chainedFields is the canonical form of an entry that the chain hash covers. The description and
+// changes are only covered through ContentHash so that anonymised entries still verify.
+type chainedFields struct {
+	Seq          int64  `json:"seq"`
+	PrevHash     string `json:"prev_hash"`
+	ID           string `json:"id"`
+	CustomerID   string `json:"customer_id"`
+	ActivityType string `json:"activity_type"`
+	Action       string `json:"action"`
+	Actor        string `json:"actor"`
+	CreatedAt    string `json:"created_at"`
+	ContentHash  string `json:"content_hash"`
+}
+
+// ContentHash returns the digest of an entry's description and changes.
+func ContentHash(description string, changes sql.NullString) string {
+	sum := sha256.Sum256([]byte(description + "\x00" + changes.String))
+	return hex.EncodeToString(sum[:])
+}
+
+// entryHash returns the chain hash of an entry at position seq following prevHash.
+func entryHash(entry db.ActivityLog, seq int64, prevHash, contentHash string) string {
+	fields := chainedFields{
+		Seq:          seq,
+		PrevHash:     prevHash,
+		ID:           entry.ID.String(),
+		ActivityType: entry.ActivityType,
+		Action:       entry.Action,
+		Actor:        entry.Actor.String,
+		CreatedAt:    entry.CreatedAt.Time.UTC().Format(sqliteTimestamp),
+		ContentHash:  contentHash,
+	}
+	if entry.CustomerID.Valid {
+		fields.CustomerID = entry.CustomerID.UUID.String()
+	}
+	encoded, _ := json.Marshal(fields)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// Seal appends every unsealed entry to the end of the chain in the order they were created,
+// returning the number of entries sealed. Entries are sealed as they are logged; Seal also picks up
+// entries written before the chain existed or left unsealed by an interrupted write.
+func Seal(ctx context.Context, queries *db.Queries) (int, error) {
+	chainMu.Lock()
+	defer chainMu.Unlock()
+
+	seq, prevHash := int64(0), genesisHash
+	head, err := queries.GetActivityChainHead(ctx)
+	switch {
+	case err == nil:
+		seq, prevHash = head.Seq.Int64, head.Hash.String
+	case !errors.Is(err, sql.ErrNoRows):
+		return 0, fmt.Errorf("error reading chain head: %w", err)
+	}
+
+	entries, err := queries.ListUnsealedActivity(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error listing unsealed activity: %w", err)
+	}
+	for i, entry := range entries {
+		seq++
+		contentHash := ContentHash(entry.Description, entry.Changes)
+		hash := entryHash(entry, seq, prevHash, contentHash)
+		n, err := queries.SealActivity(ctx, db.SealActivityParams{
+			Seq:         sql.NullInt64{Int64: seq, Valid: true},
+			PrevHash:    sql.NullString{String: prevHash, Valid: true},
+			ContentHash: sql.NullString{String: contentHash, Valid: true},
+			Hash:        sql.NullString{String: hash, Valid: true},
+			ID:          entry.ID,
+		})
+		if err != nil {
+			return i, fmt.Errorf("error sealing activity %s: %w", entry.ID, err)
+		}
+		if n == 0 {
+			return i, fmt.Errorf("activity %s was sealed concurrently", entry.ID)
+		}
+		prevHash = hash
+	}
+	return len(entries), nil
+}
+
+// Verify walks the chain from the first entry and reports the first broken link. An entry is broken when
+// its position is out of sequence, it does not link to the previous entry, its metadata no longer matches
+// its hash, or its description or changes no longer match its content hash. Entries anonymised by retention
+// verify only while they hold nothing but the placeholder description, and only when a later anonymisation
+// entry in the chain covers the day they were created.
+func Verify(ctx context.Context, queries *db.Queries) (VerifyReport, error) {
+	var report VerifyReport
+	unsealed, err := queries.ListUnsealedActivity(ctx)
+	if err != nil {
+		return report, fmt.Errorf("error listing unsealed activity: %w", err)
+	}
+	report.Unsealed = len(unsealed)
+
+	// anonymised entries wait here until an anonymisation entry covers them
+	var uncovered []db.ActivityLog
+	expected, prevHash := int64(1), genesisHash
+	for {
+		entries, err := queries.ListActivityChain(ctx, db.ListActivityChainParams{
+			Seq:   sql.NullInt64{Int64: expected - 1, Valid: true},
+			Limit: verifyBatchSize,
+		})
+		if err != nil {
+			return report, fmt.Errorf("error reading activity chain: %w", err)
+		}
+		for _, entry := range entries {
+			if reason := checkLink(entry, expected, prevHash); reason != "" {
+				report.Broken = &BrokenLink{Seq: expected, ID: entry.ID, Reason: reason}
+				return report, nil
+			}
+			if entry.AnonymisedAt.Valid {
+				uncovered = append(uncovered, entry)
+			}
+			if cutoff, ok := anonymisedBefore(entry); ok {
+				uncovered = slices.DeleteFunc(uncovered, func(a db.ActivityLog) bool { return a.CreatedAt.Time.Before(cutoff) })
+			}
+			report.Checked++
+			expected++
+			prevHash = entry.Hash.String
+		}
+		if len(entries) < verifyBatchSize {
+			if len(uncovered) > 0 {
+				report.Broken = &BrokenLink{Seq: uncovered[0].Seq.Int64, ID: uncovered[0].ID, Reason: "entry was anonymised without a retention record"}
+			}
+			return report, nil
+		}
+	}
+}
+
+// checkLink returns why entry does not verify at position seq after prevHash, or an empty string if it does.
+func checkLink(entry db.ActivityLog, seq int64, prevHash string) string {
+	switch {
+	case entry.Seq.Int64 != seq:
+		return fmt.Sprintf("entry %d is missing", seq)
+	case entry.PrevHash.String != prevHash:
+		return "previous hash does not match the preceding entry"
+	case entry.AnonymisedAt.Valid && (entry.Description != AnonymisedDescription || entry.Changes.Valid):
+		return "anonymised entry has been modified"
+	case !entry.AnonymisedAt.Valid && entry.ContentHash.String != ContentHash(entry.Description, entry.Changes):
+		return "description or changes have been modified"
+	case entry.Hash.String != entryHash(entry, seq, prevHash, entry.ContentHash.String):
+		return "entry has been modified"
+	}
+	return ""
+}
+
+// anonymisedBefore returns the cutoff of an anonymisation entry, reporting false for any other entry.
+func anonymisedBefore(entry db.ActivityLog) (time.Time, bool) {
+	if entry.ActivityType != string(ActivityTypeRetention) || entry.Action != ActionActivityAnonymised || entry.AnonymisedAt.Valid {
+		return time.Time{}, false
+	}
+	var changes []FieldChange
+	if err := json.Unmarshal([]byte(entry.Changes.String), &changes); err != nil {
+		return time.Time{}, false
+	}
+	for _, c := range changes {
+		if value, ok := c.To.(string); ok && c.Field == anonymisedBeforeField {
+			cutoff, err := time.Parse(sqliteTimestamp, value)
+			return cutoff, err == nil
+		}
+	}
+	return time.Time{}, false
+}
+
+// LogActivityAnonymised logs and seals that retention anonymised the activity created before cutoff. Anonymised
+// entries only verify while such an entry follows them in the chain, so unlike other entries a failure is returned
+// for the anonymisation to be rolled back with it.
+func LogActivityAnonymised(ctx context.Context, queries *db.Queries, cutoff time.Time, count int64) error {
+	changes, err := json.Marshal([]FieldChange{{Field: anonymisedBeforeField, To: cutoff.UTC().Format(sqliteTimestamp)}})
+	if err != nil {
+		return fmt.Errorf("error encoding anonymisation: %w", err)
+	}
+	description := fmt.Sprintf("%d activity entries anonymised by retention policy", count)
+	if count == 1 {
+		description = "1 activity entry anonymised by retention policy"
+	}
+	if _, err := queries.LogActivity(ctx, db.LogActivityParams{
+		ActivityType: string(ActivityTypeRetention),
+		Action:       ActionActivityAnonymised,
+		Description:  description,
+		Changes:      sql.NullString{String: string(changes), Valid: true},
+	}); err != nil {
+		return fmt.Errorf("error logging anonymisation: %w", err)
+	}
+	if _, err := Seal(ctx, queries); err != nil {
+		return err
+	}
+	return nil
+}
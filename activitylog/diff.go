@@ -0,0 +1,108 @@
+package activitylog
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/money"
+)
+
+// FieldChange describes a single field that differs between two versions of a record.
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// ignoredFields are bookkeeping columns that change on every write and are not worth recording.
+var ignoredFields = map[string]bool{
+	"ID":        true,
+	"CreatedAt": true,
+	"UpdatedAt": true,
+	"DeletedAt": true,
+	"Version":   true,
+}
+
+// Diff compares the fields shared by two structs and returns those whose values differ.
+// Before and after may be different row types (e.g. a query row and a table model);
+// only fields present in both are compared. Nullable values are unwrapped, with invalid values reported as nil.
+func Diff(before, after any) []FieldChange {
+	b := reflect.Indirect(reflect.ValueOf(before))
+	a := reflect.Indirect(reflect.ValueOf(after))
+	if b.Kind() != reflect.Struct || a.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var changes []FieldChange
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+		if !field.IsExported() || ignoredFields[field.Name] {
+			continue
+		}
+		other := b.FieldByName(field.Name)
+		if !other.IsValid() {
+			continue
+		}
+		from, to := normalise(other.Interface()), normalise(a.Field(i).Interface())
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: snakeCase(field.Name), From: from, To: to})
+	}
+	return changes
+}
+
+// ParseChanges decodes the changes column of an activity log entry.
+func ParseChanges(changes sql.NullString) []FieldChange {
+	if !changes.Valid || changes.String == "" {
+		return nil
+	}
+	var parsed []FieldChange
+	if err := json.Unmarshal([]byte(changes.String), &parsed); err != nil {
+		return nil
+	}
+	return parsed
+}
+
+// normalise converts a field value into a comparable, JSON friendly value.
+func normalise(v any) any {
+	switch value := v.(type) {
+	case uuid.UUID:
+		return value.String()
+	case time.Time:
+		return value.UTC().Format(time.RFC3339)
+	case money.Amount:
+		// amounts are stored in minor units but read in major ones
+		return value.String()
+	case driver.Valuer:
+		inner, err := value.Value()
+		if err != nil || inner == nil {
+			return nil
+		}
+		return normalise(inner)
+	}
+	return v
+}
+
+// snakeCase converts a Go field name such as IsPrimary into is_primary.
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
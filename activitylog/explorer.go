@@ -0,0 +1,210 @@
+package activitylog
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// PageSize is the number of entries loaded per page in the activity explorer.
+const PageSize = 50
+
+// exportBatchSize is the number of entries read per query while exporting.
+const exportBatchSize = 500
+
+// sqliteTimestamp matches the format SQLite uses for datetime('now') defaults.
+const sqliteTimestamp = "2006-01-02 15:04:05"
+
+// dateFormat is the format used by date inputs for the from and to filters.
+const dateFormat = "2006-01-02"
+
+// Filter narrows the activity explorer and its export. Empty fields are ignored.
+type Filter struct {
+	Customer string `json:"customer"`
+	Type     string `json:"type"`
+	Action   string `json:"action"`
+	User     string `json:"user"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+// Cursor marks the last entry of a page, keyset pagination continues from the entry after it.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// String encodes the cursor for use in signals and query strings. The zero cursor encodes as an empty string.
+func (c Cursor) String() string {
+	if c.ID == uuid.Nil {
+		return ""
+	}
+	return c.CreatedAt.UTC().Format(sqliteTimestamp) + "|" + c.ID.String()
+}
+
+// ParseCursor decodes a cursor produced by Cursor.String. An empty value is the zero cursor (the first page).
+func ParseCursor(value string) (Cursor, error) {
+	if value == "" {
+		return Cursor{}, nil
+	}
+	createdAt, id, ok := strings.Cut(value, "|")
+	if !ok {
+		return Cursor{}, fmt.Errorf("invalid cursor %q", value)
+	}
+	t, err := time.Parse(sqliteTimestamp, createdAt)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor time: %w", err)
+	}
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid cursor id: %w", err)
+	}
+	return Cursor{CreatedAt: t, ID: parsedID}, nil
+}
+
+// Params converts the filter into query parameters for the page following cursor.
+func (f Filter) Params(cursor Cursor, pageSize int64) (db.ListActivityParams, error) {
+	params := db.ListActivityParams{
+		ActivityType: nullString(f.Type),
+		Action:       nullString(f.Action),
+		Actor:        nullString(f.User),
+		PageSize:     pageSize,
+	}
+	if f.Customer != "" {
+		id, err := uuid.Parse(f.Customer)
+		if err != nil {
+			return params, fmt.Errorf("invalid customer: %w", err)
+		}
+		params.CustomerID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if f.From != "" {
+		if _, err := time.Parse(dateFormat, f.From); err != nil {
+			return params, fmt.Errorf("invalid from date: %w", err)
+		}
+		params.FromDate = f.From
+	}
+	if f.To != "" {
+		if _, err := time.Parse(dateFormat, f.To); err != nil {
+			return params, fmt.Errorf("invalid to date: %w", err)
+		}
+		params.ToDate = f.To
+	}
+	if cursor.ID != uuid.Nil {
+		params.CursorCreatedAt = cursor.CreatedAt.UTC().Format(sqliteTimestamp)
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+	return params, nil
+}
+
+// Page loads a page of activity matching the filter, returning the cursor for the next page
+// (the zero cursor when there are no more entries).
+func Page(ctx context.Context, queries *db.Queries, filter Filter, cursor Cursor, pageSize int64) ([]db.ListActivityRow, Cursor, error) {
+	params, err := filter.Params(cursor, pageSize)
+	if err != nil {
+		return nil, Cursor{}, err
+	}
+	rows, err := queries.ListActivity(ctx, params)
+	if err != nil {
+		return nil, Cursor{}, err
+	}
+	if int64(len(rows)) < pageSize {
+		return rows, Cursor{}, nil
+	}
+	last := rows[len(rows)-1]
+	return rows, Cursor{CreatedAt: last.CreatedAt.Time, ID: last.ID}, nil
+}
+
+// ExportCSV writes every entry matching the filter to w as CSV, newest first.
+func ExportCSV(ctx context.Context, queries *db.Queries, filter Filter, w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write(exportColumns)
+	return exportPages(ctx, queries, filter, func(row db.ListActivityRow) error {
+		return out.Write(exportRecord(row))
+	}, func() error {
+		out.Flush()
+		return out.Error()
+	})
+}
+
+// ExportJSONL writes every entry matching the filter to w as JSON Lines, newest first. Each line is an object keyed
+// by the CSV column names, with the field changes nested as they were recorded.
+func ExportJSONL(ctx context.Context, queries *db.Queries, filter Filter, w io.Writer) error {
+	out := bufio.NewWriter(w)
+	return exportPages(ctx, queries, filter, func(row db.ListActivityRow) error {
+		record := exportRecord(row)
+		entry := make(map[string]any, len(record))
+		for i, column := range exportColumns {
+			entry[column] = record[i]
+		}
+		if row.Changes.Valid {
+			entry["changes"] = json.RawMessage(row.Changes.String)
+		} else {
+			entry["changes"] = nil
+		}
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		out.Write(encoded)
+		return out.WriteByte('\n')
+	}, out.Flush)
+}
+
+// exportColumns are the columns of an activity export.
+var exportColumns = []string{"id", "created_at", "customer_id", "customer", "activity_type", "action", "description", "actor", "actor_name", "changes"}
+
+func exportRecord(row db.ListActivityRow) []string {
+	customerID := ""
+	if row.CustomerID.Valid {
+		customerID = row.CustomerID.UUID.String()
+	}
+	return []string{
+		row.ID.String(),
+		row.CreatedAt.Time.UTC().Format(time.RFC3339),
+		customerID,
+		row.CustomerName.String,
+		row.ActivityType,
+		row.Action,
+		row.Description,
+		row.Actor.String,
+		row.ActorName.String,
+		row.Changes.String,
+	}
+}
+
+// exportPages calls write for every entry matching the filter, a batch at a time, and flush after each batch.
+func exportPages(ctx context.Context, queries *db.Queries, filter Filter, write func(db.ListActivityRow) error, flush func() error) error {
+	cursor := Cursor{}
+	for {
+		rows, next, err := Page(ctx, queries, filter, cursor, exportBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := write(row); err != nil {
+				return err
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if next.ID == uuid.Nil {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
@@ -0,0 +1,99 @@
+// Package apppasswords issues and checks app passwords, which let users sign in to CardDAV from phones and mail
+// clients that cannot use the GitHub sign-in. Passwords are random, shown once when created, and stored only as a
+// SHA-256 hash.
+package apppasswords
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// alphabet is the characters passwords are made of, leaving out those easily confused when typed on a phone.
+const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// groups and groupSize shape passwords as four dash separated groups of five characters, about 99 bits of entropy.
+const (
+	groups    = 4
+	groupSize = 5
+)
+
+// ErrInvalid is returned when a username and password do not match an app password of an admin.
+var ErrInvalid = errors.New("invalid username or app password")
+
+// Generate returns a new random password.
+func Generate() string {
+	var b strings.Builder
+	// bytes past the last whole multiple of the alphabet are skipped so that every character is equally likely
+	limit := 256 - 256%len(alphabet)
+	buf := make([]byte, 1)
+	for n := 0; n < groups*groupSize; {
+		rand.Read(buf)
+		if int(buf[0]) >= limit {
+			continue
+		}
+		if n > 0 && n%groupSize == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(alphabet[int(buf[0])%len(alphabet)])
+		n++
+	}
+	return b.String()
+}
+
+// Hash returns the stored form of a password. Dashes, spaces and case are ignored so that passwords can be typed
+// loosely.
+func Hash(password string) string {
+	normalised := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(password))
+	sum := sha256.Sum256([]byte(normalised))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create issues a new app password for a user, returning it along with the password itself, which is not stored.
+func Create(ctx context.Context, queries *db.Queries, userID uuid.UUID, name string) (db.AppPassword, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return db.AppPassword{}, "", fmt.Errorf("an app password needs a name, such as the device it is for")
+	}
+	password := Generate()
+	created, err := queries.CreateAppPassword(ctx, db.CreateAppPasswordParams{
+		UserID:       userID,
+		Name:         name,
+		PasswordHash: Hash(password),
+	})
+	if err != nil {
+		return db.AppPassword{}, "", fmt.Errorf("error creating app password: %w", err)
+	}
+	return created, password, nil
+}
+
+// Authenticate checks a username, which is the user's GitHub ID, and app password, returning the GitHub ID. Only
+// admins can sign in, as only they can see customers in Beam. The password's last use is recorded.
+func Authenticate(ctx context.Context, queries *db.Queries, username, password string) (string, error) {
+	if username == "" || password == "" {
+		return "", ErrInvalid
+	}
+	user, err := queries.GetAppPasswordUser(ctx, Hash(password))
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrInvalid
+	}
+	if err != nil {
+		return "", fmt.Errorf("error checking app password: %w", err)
+	}
+	if !strings.EqualFold(user.GithubID, username) || !user.IsAdmin {
+		return "", ErrInvalid
+	}
+	if err := queries.TouchAppPassword(ctx, user.AppPasswordID); err != nil {
+		return "", fmt.Errorf("error recording app password use: %w", err)
+	}
+	return user.GithubID, nil
+}
@@ -0,0 +1,105 @@
+package apppasswords
+
+import (
+	"context"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+)
+
+func TestGenerate(t *testing.T) {
+	shape := regexp.MustCompile(`^[` + alphabet + `]{5}(-[` + alphabet + `]{5}){3}$`)
+	seen := make(map[string]bool)
+	for range 100 {
+		pw := Generate()
+		if !shape.MatchString(pw) {
+			t.Fatalf("expected four groups of five characters, got %q", pw)
+		}
+		if seen[pw] {
+			t.Fatalf("generated %q twice", pw)
+		}
+		seen[pw] = true
+	}
+}
+
+func TestHash(t *testing.T) {
+	want := Hash("abcde-fghjk-mnpqr-stuvw")
+	for _, typed := range []string{"abcdefghjkmnpqrstuvw", "ABCDE FGHJK MNPQR STUVW"} {
+		if got := Hash(typed); got != want {
+			t.Errorf("Hash(%q) = %q, want %q", typed, got, want)
+		}
+	}
+	if Hash("abcde-fghjk-mnpqr-stuvx") == want {
+		t.Error("expected a different password to hash differently")
+	}
+}
+
+func TestAuthenticate_Integration(t *testing.T) {
+	os.MkdirAll("data", 0755)
+	store, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	githubID := "app-" + uuid.NewString()[:8]
+	if err := queries.InsertUser(ctx, sqlc.InsertUserParams{Name: "App User", Email: githubID + "@beam.test", GithubID: githubID}); err != nil {
+		t.Fatalf("InsertUser failed: %v", err)
+	}
+	user, err := queries.GetUserByGithubID(ctx, githubID)
+	if err != nil {
+		t.Fatalf("GetUserByGithubID failed: %v", err)
+	}
+
+	if _, _, err := Create(ctx, queries, user.ID, "  "); err == nil {
+		t.Error("expected an error creating a nameless app password")
+	}
+	created, password, err := Create(ctx, queries, user.ID, "Phone")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if created.PasswordHash == password || created.PasswordHash != Hash(password) {
+		t.Errorf("expected only the hash of the password stored, got %q", created.PasswordHash)
+	}
+
+	if _, err := Authenticate(ctx, queries, githubID, password); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected users who are not admins to be refused, got %v", err)
+	}
+	if _, err := store.ExecContext(ctx, "UPDATE users SET is_admin = 1 WHERE id = ?", user.ID); err != nil {
+		t.Fatalf("making the user an admin failed: %v", err)
+	}
+
+	got, err := Authenticate(ctx, queries, strings.ToUpper(githubID), strings.ToUpper(password))
+	if err != nil || got != githubID {
+		t.Fatalf("expected to sign in as %s, got %q (%v)", githubID, got, err)
+	}
+	for name, creds := range map[string][2]string{
+		"wrong password": {githubID, Generate()},
+		"wrong user":     {"someone-else", password},
+		"empty":          {"", ""},
+	} {
+		if _, err := Authenticate(ctx, queries, creds[0], creds[1]); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: expected ErrInvalid, got %v", name, err)
+		}
+	}
+
+	passwords, err := queries.ListAppPasswordsByUser(ctx, user.ID)
+	if err != nil || len(passwords) != 1 || !passwords[0].LastUsedAt.Valid {
+		t.Errorf("expected the password's use recorded, got %+v (%v)", passwords, err)
+	}
+
+	if _, err := queries.DeleteAppPassword(ctx, sqlc.DeleteAppPasswordParams{ID: created.ID, UserID: user.ID}); err != nil {
+		t.Fatalf("DeleteAppPassword failed: %v", err)
+	}
+	if _, err := Authenticate(ctx, queries, githubID, password); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected a revoked password to be refused, got %v", err)
+	}
+}
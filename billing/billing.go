@@ -0,0 +1,272 @@
+// Package billing works out when subscriptions are billed. A schedule steps on from the start date by its cadence, and
+// every billing date is calculated from the start date rather than from the date before it, so that a date clamped to
+// the end of a short month does not pull every later date back with it.
+package billing
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/money"
+)
+
+// Cadences subscriptions can be billed on.
+const (
+	Weekly     = "weekly"
+	Monthly    = "monthly"
+	Quarterly  = "quarterly"
+	SemiAnnual = "semiannual"
+	Yearly     = "yearly"
+	// Custom cadences bill every Interval months.
+	Custom = "custom"
+)
+
+// Cadences lists every cadence in the order they are offered.
+var Cadences = []string{Weekly, Monthly, Quarterly, SemiAnnual, Yearly, Custom}
+
+// months is the number of months between billing dates of each month based cadence.
+var months = map[string]int{Monthly: 1, Quarterly: 3, SemiAnnual: 6, Yearly: 12}
+
+// MaxInterval is the most months apart the billing dates of a custom cadence can be.
+const MaxInterval = 120
+
+// PreviewCount is how many upcoming billing dates are previewed while editing a subscription.
+const PreviewCount = 6
+
+// Clock tells the current time. It is passed around rather than calling time.Now so that schedules can be checked
+// against a fixed date.
+type Clock func() time.Time
+
+// Day returns the day of t as midnight UTC, which is how days such as effective dates are stored. The day is read in
+// the location of t, so days cut from a local clock and days read back from the database compare as the same day.
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Schedule is when a subscription is billed. The first billing date is the start date.
+type Schedule struct {
+	Start   time.Time
+	Cadence string
+	// Interval is the number of months between billing dates of a custom cadence.
+	Interval int
+	// AnchorDay is the day of the month that month based cadences bill on, or zero for the day of the start date.
+	// Months shorter than the anchor day are billed on their last day.
+	AnchorDay int
+	// End is the last day that can be billed, or the zero time when billing goes on indefinitely.
+	End time.Time
+}
+
+// New returns a schedule, ignoring an interval or anchor day that the cadence does not use.
+func New(start time.Time, cadence string, interval, anchorDay sql.NullInt64) Schedule {
+	s := Schedule{Start: start, Cadence: cadence}
+	if cadence == Custom && interval.Valid {
+		s.Interval = int(interval.Int64)
+	}
+	if cadence != Weekly && anchorDay.Valid {
+		s.AnchorDay = int(anchorDay.Int64)
+	}
+	return s
+}
+
+// FromSubscription returns the schedule of a subscription.
+func FromSubscription(sub db.Subscription) Schedule {
+	s := New(sub.StartDate, sub.BillingCadence, sub.BillingInterval, sub.BillingAnchorDay)
+	if sub.EndDate.Valid {
+		s.End = sub.EndDate.Time
+	}
+	return s
+}
+
+// Columns returns the interval and anchor day to store with the subscription.
+func (s Schedule) Columns() (interval, anchorDay sql.NullInt64) {
+	if s.Interval > 0 {
+		interval = sql.NullInt64{Int64: int64(s.Interval), Valid: true}
+	}
+	if s.AnchorDay > 0 {
+		anchorDay = sql.NullInt64{Int64: int64(s.AnchorDay), Valid: true}
+	}
+	return interval, anchorDay
+}
+
+// Validate checks that the schedule can be followed.
+func (s Schedule) Validate() error {
+	if s.Start.IsZero() {
+		return fmt.Errorf("a start date is needed to work out billing dates")
+	}
+	if !slices.Contains(Cadences, s.Cadence) {
+		return fmt.Errorf("unknown billing cadence %q", s.Cadence)
+	}
+	if s.Cadence == Custom && (s.Interval < 1 || s.Interval > MaxInterval) {
+		return fmt.Errorf("a custom cadence needs between 1 and %d months between billing dates", MaxInterval)
+	}
+	if s.AnchorDay < 0 || s.AnchorDay > 31 {
+		return fmt.Errorf("the anchor day must be a day of the month, from 1 to 31")
+	}
+	return nil
+}
+
+// monthStep returns the number of months between billing dates, or zero for weekly schedules.
+func (s Schedule) monthStep() int {
+	if s.Cadence == Custom {
+		return s.Interval
+	}
+	return months[s.Cadence]
+}
+
+// Monthly returns what an amount billed on the schedule comes to each month, for monthly recurring revenue. Weekly
+// amounts are billed 52 times a year. Invalid schedules come to nothing.
+func (s Schedule) Monthly(amount money.Amount) money.Amount {
+	if s.Cadence == Weekly {
+		return amount.Mul(52.0 / 12)
+	}
+	step := s.monthStep()
+	if step == 0 {
+		return 0
+	}
+	return amount.Mul(1 / float64(step))
+}
+
+// Date returns the nth billing date, counting the start date as the zeroth.
+func (s Schedule) Date(n int) time.Time {
+	if n == 0 {
+		return s.Start
+	}
+	step := s.monthStep()
+	if step == 0 {
+		return s.Start.AddDate(0, 0, 7*n)
+	}
+	day := s.AnchorDay
+	if day == 0 {
+		day = s.Start.Day()
+	}
+	// the first of the month never overflows into the next one, unlike the start date's day
+	first := time.Date(s.Start.Year(), s.Start.Month()+time.Month(n*step), 1, s.Start.Hour(), s.Start.Minute(), s.Start.Second(), 0, s.Start.Location())
+	return first.AddDate(0, 0, min(day, daysIn(first))-1)
+}
+
+func daysIn(month time.Time) int {
+	return time.Date(month.Year(), month.Month()+1, 0, 0, 0, 0, 0, month.Location()).Day()
+}
+
+// Next returns the first billing date on or after the day of now, or the zero time when billing has ended or the
+// schedule is invalid.
+func (s Schedule) Next(now time.Time) time.Time {
+	dates := s.Upcoming(now, 1)
+	if len(dates) == 0 {
+		return time.Time{}
+	}
+	return dates[0]
+}
+
+// Upcoming lists up to count billing dates from the day of now. Invalid schedules have none, rather than guessing at
+// what was meant.
+func (s Schedule) Upcoming(now time.Time, count int) []time.Time {
+	var dates []time.Time
+	if s.Validate() != nil {
+		return dates
+	}
+	for n := s.first(now); len(dates) < count; n++ {
+		d := s.Date(n)
+		if s.ended(d) {
+			break
+		}
+		dates = append(dates, d)
+	}
+	return dates
+}
+
+// Between lists the billing dates from the day of from up to and including until.
+func (s Schedule) Between(from, until time.Time) []time.Time {
+	var dates []time.Time
+	if s.Validate() != nil {
+		return dates
+	}
+	for n := s.first(from); ; n++ {
+		d := s.Date(n)
+		if d.After(until) || s.ended(d) {
+			return dates
+		}
+		dates = append(dates, d)
+	}
+}
+
+// first returns the index of the first billing date on or after the day of now.
+func (s Schedule) first(now time.Time) int {
+	now = now.In(s.Start.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	// start a little before the date so that an estimate that is off by one is still found
+	n := 0
+	if step := s.monthStep(); step > 0 {
+		elapsed := (today.Year()-s.Start.Year())*12 + int(today.Month()-s.Start.Month())
+		n = max(0, elapsed/step-1)
+	} else {
+		n = max(0, int(today.Sub(s.Start).Hours()/24/7)-1)
+	}
+	for s.Date(n).Before(today) {
+		n++
+	}
+	return n
+}
+
+func (s Schedule) ended(d time.Time) bool {
+	return !s.End.IsZero() && d.After(s.End)
+}
+
+// labels name each cadence.
+var labels = map[string]string{
+	Weekly:     "Weekly",
+	Monthly:    "Monthly",
+	Quarterly:  "Quarterly",
+	SemiAnnual: "Semi-annual",
+	Yearly:     "Yearly",
+	Custom:     "Custom",
+}
+
+// Label names a cadence, leaving a cadence it does not know as it is.
+func Label(cadence string) string {
+	if label, ok := labels[cadence]; ok {
+		return label
+	}
+	return cadence
+}
+
+// Describe describes the schedule in a sentence, such as "Every 2 months on the 31st or the last day of the month".
+func (s Schedule) Describe() string {
+	step := s.monthStep()
+	if step == 0 {
+		if s.Cadence == Weekly {
+			return "Every week on " + s.Start.Weekday().String()
+		}
+		return "Unknown billing cadence"
+	}
+	every := "Every month"
+	if step > 1 {
+		every = fmt.Sprintf("Every %d months", step)
+	}
+	day := s.AnchorDay
+	if day == 0 {
+		day = s.Start.Day()
+	}
+	if day > 28 {
+		return fmt.Sprintf("%s on the %s or the last day of the month", every, ordinal(day))
+	}
+	return fmt.Sprintf("%s on the %s", every, ordinal(day))
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
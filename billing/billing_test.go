@@ -0,0 +1,192 @@
+package billing
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/scottmckendry/beam/money"
+)
+
+func day(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDay(t *testing.T) {
+	auckland := time.FixedZone("NZDT", 13*60*60)
+	if got := Day(time.Date(2026, 11, 1, 8, 30, 0, 0, auckland)); !got.Equal(day(2026, 11, 1)) {
+		t.Errorf("expected the day to be read in the location of the time, got %v", got)
+	}
+	if got := Day(day(2026, 11, 1)); !got.Equal(day(2026, 11, 1)) {
+		t.Errorf("expected a stored day to be unchanged, got %v", got)
+	}
+}
+
+func TestDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule Schedule
+		want     []time.Time
+	}{
+		{
+			"month ends are clamped without drifting",
+			Schedule{Start: day(2026, 1, 31), Cadence: Monthly},
+			[]time.Time{day(2026, 1, 31), day(2026, 2, 28), day(2026, 3, 31), day(2026, 4, 30)},
+		},
+		{
+			"leap years",
+			Schedule{Start: day(2024, 2, 29), Cadence: Yearly},
+			[]time.Time{day(2024, 2, 29), day(2025, 2, 28), day(2026, 2, 28), day(2027, 2, 28), day(2028, 2, 29)},
+		},
+		{
+			"quarterly",
+			Schedule{Start: day(2026, 11, 30), Cadence: Quarterly},
+			[]time.Time{day(2026, 11, 30), day(2027, 2, 28), day(2027, 5, 30)},
+		},
+		{
+			"semi-annual",
+			Schedule{Start: day(2026, 3, 15), Cadence: SemiAnnual},
+			[]time.Time{day(2026, 3, 15), day(2026, 9, 15), day(2027, 3, 15)},
+		},
+		{
+			"custom interval",
+			Schedule{Start: day(2026, 1, 10), Cadence: Custom, Interval: 4},
+			[]time.Time{day(2026, 1, 10), day(2026, 5, 10), day(2026, 9, 10), day(2027, 1, 10)},
+		},
+		{
+			"anchor day after the start",
+			Schedule{Start: day(2026, 1, 10), Cadence: Monthly, AnchorDay: 31},
+			[]time.Time{day(2026, 1, 10), day(2026, 2, 28), day(2026, 3, 31), day(2026, 4, 30)},
+		},
+		{
+			"weekly",
+			Schedule{Start: day(2026, 2, 25), Cadence: Weekly},
+			[]time.Time{day(2026, 2, 25), day(2026, 3, 4), day(2026, 3, 11)},
+		},
+	}
+	for _, tt := range tests {
+		for n, want := range tt.want {
+			if got := tt.schedule.Date(n); !got.Equal(want) {
+				t.Errorf("%s: date %d = %s, want %s", tt.name, n, got.Format(time.DateOnly), want.Format(time.DateOnly))
+			}
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	s := Schedule{Start: day(2025, 8, 31), Cadence: Monthly}
+	if got := s.Next(time.Date(2026, 2, 28, 18, 30, 0, 0, time.UTC)); !got.Equal(day(2026, 2, 28)) {
+		t.Errorf("expected a date later on the same day to still be next, got %s", got)
+	}
+	if got := s.Next(day(2026, 3, 1)); !got.Equal(day(2026, 3, 31)) {
+		t.Errorf("expected the 31st of March, got %s", got)
+	}
+	if got := s.Next(day(2020, 1, 1)); !got.Equal(s.Start) {
+		t.Errorf("expected the start date before the subscription starts, got %s", got)
+	}
+
+	s.End = day(2026, 3, 30)
+	if got := s.Next(day(2026, 3, 1)); !got.IsZero() {
+		t.Errorf("expected no billing after the end date, got %s", got)
+	}
+	if got := (Schedule{Start: day(2026, 1, 1), Cadence: "fortnightly"}).Next(day(2026, 1, 1)); !got.IsZero() {
+		t.Errorf("expected nothing for an unknown cadence, got %s", got)
+	}
+}
+
+func TestBetween(t *testing.T) {
+	s := Schedule{Start: day(2025, 1, 15), Cadence: Quarterly, End: day(2026, 10, 15)}
+	got := s.Between(day(2026, 1, 1), day(2027, 1, 1))
+	want := []time.Time{day(2026, 1, 15), day(2026, 4, 15), day(2026, 7, 15), day(2026, 10, 15)}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("date %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	if got := (Schedule{Start: day(2026, 1, 1), Cadence: Weekly}).Upcoming(day(2026, 1, 2), PreviewCount); len(got) != PreviewCount || !got[0].Equal(day(2026, 1, 8)) {
+		t.Errorf("expected %d weekly dates from the 8th, got %v", PreviewCount, got)
+	}
+}
+
+func TestNewAndValidate(t *testing.T) {
+	interval := sql.NullInt64{Int64: 5, Valid: true}
+	anchor := sql.NullInt64{Int64: 20, Valid: true}
+
+	s := New(day(2026, 1, 1), Monthly, interval, anchor)
+	if s.Interval != 0 || s.AnchorDay != 20 {
+		t.Errorf("expected the interval of a monthly schedule to be ignored, got %+v", s)
+	}
+	if i, a := s.Columns(); i.Valid || a.Int64 != 20 {
+		t.Errorf("unexpected columns %v and %v", i, a)
+	}
+	if s := New(day(2026, 1, 1), Weekly, interval, anchor); s.AnchorDay != 0 {
+		t.Errorf("expected the anchor day of a weekly schedule to be ignored, got %+v", s)
+	}
+
+	for _, bad := range []Schedule{
+		{Cadence: Monthly},
+		{Start: day(2026, 1, 1), Cadence: "daily"},
+		{Start: day(2026, 1, 1), Cadence: Custom},
+		{Start: day(2026, 1, 1), Cadence: Custom, Interval: MaxInterval + 1},
+		{Start: day(2026, 1, 1), Cadence: Monthly, AnchorDay: 32},
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		schedule Schedule
+		want     string
+	}{
+		{Schedule{Start: day(2026, 1, 2), Cadence: Monthly}, "Every month on the 2nd"},
+		{Schedule{Start: day(2026, 1, 2), Cadence: Quarterly, AnchorDay: 11}, "Every 3 months on the 11th"},
+		{Schedule{Start: day(2026, 1, 31), Cadence: Custom, Interval: 2}, "Every 2 months on the 31st or the last day of the month"},
+		{Schedule{Start: day(2026, 1, 2), Cadence: Weekly}, "Every week on Friday"},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.Describe(); got != tt.want {
+			t.Errorf("Describe() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestMonthly(t *testing.T) {
+	tests := []struct {
+		schedule Schedule
+		want     money.Amount
+	}{
+		{Schedule{Cadence: Monthly}, 1200},
+		{Schedule{Cadence: Quarterly}, 400},
+		{Schedule{Cadence: Yearly}, 100},
+		{Schedule{Cadence: Custom, Interval: 5}, 240},
+		{Schedule{Cadence: Weekly}, 5200},
+		{Schedule{Cadence: "daily"}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.Monthly(1200); got != tt.want {
+			t.Errorf("%s: Monthly(12.00) = %s, want %s", tt.schedule.Cadence, got, tt.want)
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	tests := map[string]string{
+		Monthly:    "Monthly",
+		SemiAnnual: "Semi-annual",
+		"":         "",
+		"Annual":   "Annual",
+		"été":      "été",
+	}
+	for cadence, want := range tests {
+		if got := Label(cadence); got != want {
+			t.Errorf("Label(%q) = %q, want %q", cadence, got, want)
+		}
+	}
+}
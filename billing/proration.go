@@ -0,0 +1,57 @@
+package billing
+
+import (
+	"math"
+	"time"
+)
+
+// Proration is the share of billing periods that were billed at an old price before a new price took effect part way
+// through them. Periods are billed in advance, on the billing date that starts them, so a period is billed once its
+// billing date has come.
+type Proration struct {
+	// Fraction is how many billing periods the new price covers, such as 0.5 for the second half of one period, or
+	// 2.5 for a price backdated to the middle of the period before last.
+	Fraction float64
+	// From is the day the new price took effect, and To the billing date that ends the last period billed at the old
+	// price. To is the zero time when no period has been billed yet.
+	From, To time.Time
+}
+
+// Prorate works out the share of the periods billed by now that fall on or after the day of from, counting in whole
+// days.
+func (s Schedule) Prorate(from, now time.Time) Proration {
+	p := Proration{From: s.startOfDay(from)}
+	if s.Validate() != nil {
+		return p
+	}
+	today := s.startOfDay(now)
+
+	// begin with the period that from falls in, which started on the billing date before it
+	n := s.first(p.From)
+	if n > 0 && s.Date(n).After(p.From) {
+		n--
+	}
+	for ; ; n++ {
+		start, end := s.Date(n), s.Date(n+1)
+		if start.After(today) || s.ended(start) {
+			return p
+		}
+		covered := start
+		if p.From.After(start) {
+			covered = p.From
+		}
+		p.Fraction += days(covered, end) / days(start, end)
+		p.To = end
+	}
+}
+
+// startOfDay returns the start of the day of t in the time zone of the schedule.
+func (s Schedule) startOfDay(t time.Time) time.Time {
+	t = t.In(s.Start.Location())
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// days counts the days from one time to another, rounding away the odd hour of a daylight saving change.
+func days(from, to time.Time) float64 {
+	return math.Round(to.Sub(from).Hours() / 24)
+}
@@ -0,0 +1,34 @@
+package billing
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestProrate(t *testing.T) {
+	monthly := Schedule{Start: day(2026, 1, 1), Cadence: Monthly}
+	tests := []struct {
+		name     string
+		schedule Schedule
+		from     time.Time
+		now      time.Time
+		fraction float64
+		to       time.Time
+	}{
+		{"half way through April", monthly, day(2026, 4, 16), day(2026, 4, 16), 0.5, day(2026, 5, 1)},
+		{"on a billing date", monthly, day(2026, 4, 1), day(2026, 4, 1), 1, day(2026, 5, 1)},
+		{"backdated across a billing date", monthly, day(2026, 3, 17), day(2026, 4, 10), 15.0/31 + 1, day(2026, 5, 1)},
+		{"before the first billing date", Schedule{Start: day(2026, 12, 1), Cadence: Monthly}, day(2026, 10, 19), day(2026, 10, 19), 0, time.Time{}},
+		{"weekly", Schedule{Start: day(2026, 10, 5), Cadence: Weekly}, day(2026, 10, 17), day(2026, 10, 17), 2.0 / 7, day(2026, 10, 19)},
+		{"after billing ended", Schedule{Start: day(2026, 1, 1), Cadence: Monthly, End: day(2026, 3, 31)}, day(2026, 3, 16), day(2026, 6, 1), 16.0 / 31, day(2026, 4, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.schedule.Prorate(tt.from, tt.now)
+			if math.Abs(got.Fraction-tt.fraction) > 1e-9 || !got.To.Equal(tt.to) {
+				t.Errorf("Prorate() = %v to %s, want %v to %s", got.Fraction, got.To, tt.fraction, tt.to)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Package calendar builds iCalendar (RFC 5545) feeds of upcoming subscription billing dates, renewals and the ends of
+// free trials, which calendar apps subscribe to with a secret URL. Every event is all-day, with a UID that stays the same between
+// fetches so apps update events in place rather than duplicating them. Beam has no invoices yet, so there are no
+// invoice due dates to include.
+package calendar
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/discounts"
+	"github.com/scottmckendry/beam/money"
+)
+
+// ContentType is the media type of a feed.
+const ContentType = "text/calendar; charset=utf-8"
+
+// Horizon is how far ahead billing dates are listed. Renewals are listed however far ahead they are.
+const Horizon = 12 // months
+
+// refresh is how often calendar apps are asked to fetch the feed again.
+const refresh = "PT6H"
+
+// lineLength is the longest a content line can be, in octets, before it is folded.
+const lineLength = 75
+
+// Event is an all-day calendar event.
+type Event struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	Category    string
+	// Stamp is when the event's details last changed.
+	Stamp time.Time
+}
+
+// NewToken returns a new secret for feed URLs.
+func NewToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Events lists the billing dates of active subscriptions over the next Horizon months, with what each date is billed
+// after free trials and discounts, along with the renewal of each subscription with an end date still to come and the
+// end of each free trial still to come, in date order.
+func Events(subscriptions []db.ListCalendarSubscriptionsRow, allDiscounts []db.SubscriptionDiscount, now time.Time) []Event {
+	until := now.AddDate(0, Horizon, 0)
+	today := now.UTC().Truncate(24 * time.Hour)
+
+	var events []Event
+	for _, s := range subscriptions {
+		stamp := now
+		if s.UpdatedAt.Valid {
+			stamp = s.UpdatedAt.Time
+		}
+		schedule := billing.New(s.StartDate, s.BillingCadence, s.BillingInterval, s.BillingAnchorDay)
+		var end *time.Time
+		if s.EndDate.Valid {
+			end = &s.EndDate.Time
+			schedule.End = s.EndDate.Time
+		}
+
+		sub := subscription(s)
+		for _, d := range schedule.Between(now, until) {
+			description := fmt.Sprintf("%s is billed %s for %s, %s.", s.CustomerName, money.New(discounts.Charge(sub, allDiscounts, d), s.Currency), s.Description, strings.ToLower(schedule.Describe()))
+			if discounts.InTrial(sub, d) {
+				description = fmt.Sprintf("Nothing is billed to %s for %s during its free trial, which ends %s.", s.CustomerName, s.Description, s.TrialEndsOn.Time.Format("Jan 2, 2006"))
+			}
+			events = append(events, Event{
+				UID:         fmt.Sprintf("billing-%s-%s@beam", s.ID, d.UTC().Format("20060102")),
+				Date:        d,
+				Summary:     fmt.Sprintf("Bill %s: %s", s.CustomerName, s.Description),
+				Description: description,
+				Category:    "Billing",
+				Stamp:       stamp,
+			})
+		}
+		if s.TrialEndsOn.Valid && !s.TrialEndsOn.Time.UTC().Before(today) {
+			events = append(events, Event{
+				UID:         fmt.Sprintf("trial-%s@beam", s.ID),
+				Date:        s.TrialEndsOn.Time,
+				Summary:     fmt.Sprintf("Trial ends: %s %s", s.CustomerName, s.Description),
+				Description: fmt.Sprintf("The free trial of %s for %s ends today.", s.Description, s.CustomerName),
+				Category:    "Trial",
+				Stamp:       stamp,
+			})
+		}
+		if end != nil && !end.UTC().Before(today) {
+			events = append(events, Event{
+				UID:         fmt.Sprintf("renewal-%s@beam", s.ID),
+				Date:        *end,
+				Summary:     fmt.Sprintf("Renewal: %s %s", s.CustomerName, s.Description),
+				Description: fmt.Sprintf("The %s term of %s for %s ends today.", s.Term, s.Description, s.CustomerName),
+				Category:    "Renewal",
+				Stamp:       stamp,
+			})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Date.Equal(events[j].Date) {
+			return events[i].Date.Before(events[j].Date)
+		}
+		return events[i].UID < events[j].UID
+	})
+	return events
+}
+
+// subscription returns the subscription of a calendar row, to work out what it is billed.
+func subscription(s db.ListCalendarSubscriptionsRow) db.Subscription {
+	return db.Subscription{
+		ID:          s.ID,
+		Amount:      s.Amount,
+		Currency:    s.Currency,
+		TrialEndsOn: s.TrialEndsOn,
+	}
+}
+
+// Write writes a calendar of events named name.
+func Write(w io.Writer, name string, events []Event) error {
+	var b strings.Builder
+	line := func(s string) { writeLine(&b, s) }
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Beam//Billing calendar//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escape(name))
+	line("REFRESH-INTERVAL;VALUE=DURATION:" + refresh)
+	line("X-PUBLISHED-TTL:" + refresh)
+	for _, e := range events {
+		date := e.Date.UTC()
+		line("BEGIN:VEVENT")
+		line("UID:" + e.UID)
+		line("DTSTAMP:" + e.Stamp.UTC().Format("20060102T150405Z"))
+		line("DTSTART;VALUE=DATE:" + date.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + escape(e.Description))
+		}
+		if e.Category != "" {
+			line("CATEGORIES:" + escape(e.Category))
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeLine writes a content line, folding it onto continuation lines so that none is longer than lineLength octets.
+// Lines are only folded between characters, never within one.
+func writeLine(b *strings.Builder, s string) {
+	limit := lineLength
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isCharStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut])
+		b.WriteString("\r\n ")
+		s = s[cut:]
+		// the leading space of a continuation line counts towards its length
+		limit = lineLength - 1
+	}
+	b.WriteString(s)
+	b.WriteString("\r\n")
+}
+
+func isCharStart(c byte) bool {
+	return c&0xC0 != 0x80
+}
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
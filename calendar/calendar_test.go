@@ -0,0 +1,157 @@
+package calendar
+
+import (
+	"bytes"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+func TestEvents(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	monthly := db.ListCalendarSubscriptionsRow{
+		ID:             uuid.New(),
+		Description:    "Hosting",
+		Amount:         4950,
+		Term:           "yearly",
+		BillingCadence: "monthly",
+		StartDate:      now.AddDate(0, -3, 1),
+		EndDate:        sql.NullTime{Time: now.AddDate(0, 2, 0), Valid: true},
+		CustomerName:   "Acme",
+	}
+	yearly := db.ListCalendarSubscriptionsRow{
+		ID:             uuid.New(),
+		Description:    "Support",
+		Amount:         120000,
+		Term:           "yearly",
+		BillingCadence: "yearly",
+		StartDate:      now.AddDate(-2, 0, 7),
+		CustomerName:   "Globex",
+	}
+
+	events := Events([]db.ListCalendarSubscriptionsRow{monthly, yearly}, nil, now)
+	var billing, renewals, globex int
+	for i, e := range events {
+		if i > 0 && e.Date.Before(events[i-1].Date) {
+			t.Errorf("expected events in date order, got %v after %v", e.Date, events[i-1].Date)
+		}
+		switch {
+		case strings.HasPrefix(e.UID, "billing-"+monthly.ID.String()):
+			billing++
+			if e.Date.After(monthly.EndDate.Time) {
+				t.Errorf("expected no billing after the end date, got %v", e.Date)
+			}
+		case e.UID == "renewal-"+monthly.ID.String()+"@beam":
+			renewals++
+		case strings.HasPrefix(e.UID, "billing-"+yearly.ID.String()):
+			globex++
+		default:
+			t.Errorf("unexpected event %+v", e)
+		}
+	}
+	if billing != 2 || renewals != 1 || globex != 1 {
+		t.Errorf("expected two monthly billing dates, a renewal and a yearly billing date, got %d, %d and %d", billing, renewals, globex)
+	}
+
+	again := Events([]db.ListCalendarSubscriptionsRow{monthly, yearly}, nil, now)
+	for i := range events {
+		if events[i].UID != again[i].UID {
+			t.Fatalf("expected stable UIDs, got %q then %q", events[i].UID, again[i].UID)
+		}
+	}
+
+	monthly.EndDate = sql.NullTime{Time: now.AddDate(0, 0, -1), Valid: true}
+	if past := Events([]db.ListCalendarSubscriptionsRow{monthly}, nil, now); len(past) != 0 {
+		t.Errorf("expected nothing for a subscription that has ended, got %+v", past)
+	}
+}
+
+func TestEvents_TrialsAndDiscounts(t *testing.T) {
+	now := time.Date(2026, 5, 10, 12, 0, 0, 0, time.UTC)
+	sub := db.ListCalendarSubscriptionsRow{
+		ID:             uuid.New(),
+		Description:    "Hosting",
+		Amount:         5000,
+		Currency:       "NZD",
+		Term:           "yearly",
+		BillingCadence: "monthly",
+		StartDate:      time.Date(2026, 5, 15, 0, 0, 0, 0, time.UTC),
+		EndDate:        sql.NullTime{Time: time.Date(2026, 8, 15, 0, 0, 0, 0, time.UTC), Valid: true},
+		TrialEndsOn:    sql.NullTime{Time: time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC), Valid: true},
+		CustomerName:   "Acme",
+	}
+	discounts := []db.SubscriptionDiscount{{
+		SubscriptionID: sub.ID,
+		Kind:           "percent",
+		Percent:        10,
+		Currency:       "NZD",
+		StartsOn:       time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+		EndsOn:         sql.NullTime{Time: time.Date(2026, 7, 31, 0, 0, 0, 0, time.UTC), Valid: true},
+	}}
+
+	described := map[string]string{}
+	for _, e := range Events([]db.ListCalendarSubscriptionsRow{sub}, discounts, now) {
+		described[e.Date.Format("2006-01-02")+" "+e.Category] = e.Description
+	}
+	tests := map[string]string{
+		"2026-06-15 Billing": "Nothing is billed to Acme for Hosting during its free trial, which ends Jun 30, 2026.",
+		"2026-06-30 Trial":   "The free trial of Hosting for Acme ends today.",
+		"2026-07-15 Billing": "Acme is billed NZ$45.00 for Hosting, every month on the 15th.",
+		"2026-08-15 Billing": "Acme is billed NZ$50.00 for Hosting, every month on the 15th.",
+	}
+	for key, want := range tests {
+		if got := described[key]; got != want {
+			t.Errorf("%s: got %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, "Acme, Ltd billing", []Event{{
+		UID:         "billing-1@beam",
+		Date:        time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC),
+		Summary:     "Bill Acme; Ltd: Hosting",
+		Description: strings.Repeat("Ünïcödé line ", 10) + "\nsecond line",
+		Category:    "Billing",
+		Stamp:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}})
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+		t.Errorf("expected a calendar, got\n%s", out)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(line) > lineLength {
+			t.Errorf("line longer than %d octets: %q", lineLength, line)
+		}
+	}
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	for _, want := range []string{
+		"X-WR-CALNAME:Acme\\, Ltd billing\r\n",
+		"DTSTAMP:20260102T030405Z\r\n",
+		"DTSTART;VALUE=DATE:20260331\r\n",
+		"DTEND;VALUE=DATE:20260401\r\n",
+		"SUMMARY:Bill Acme\\; Ltd: Hosting\r\n",
+		"\\nsecond line\r\n",
+	} {
+		if !strings.Contains(unfolded, want) {
+			t.Errorf("expected %q in\n%s", want, out)
+		}
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, b := NewToken(), NewToken()
+	if len(a) != 43 || a == b || strings.ContainsAny(a, "+/=") {
+		t.Errorf("expected distinct URL-safe tokens, got %q and %q", a, b)
+	}
+}
@@ -0,0 +1,471 @@
+// Package carddav serves Beam contacts to phones and mail clients over CardDAV (RFC 6352), read-only. There is an
+// address book for every customer along with one holding every contact, and users sign in with an app password.
+//
+// Resources live under the prefix the handler is mounted on:
+//
+//	/                          the service root
+//	/principal/                the signed in user
+//	/books/                    the address book home
+//	/books/all/                every contact
+//	/books/{customerID}/       the contacts of one customer
+//	/books/{book}/{id}.vcf     a single contact
+package carddav
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/apppasswords"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/vcard"
+)
+
+// AllBook is the name of the address book holding every contact.
+const AllBook = "all"
+
+// methods are the methods a read-only server allows.
+const methods = "OPTIONS, GET, HEAD, PROPFIND, REPORT"
+
+// Methods lists the WebDAV methods the handler needs a router to pass through, beyond the standard HTTP methods.
+var Methods = []string{"PROPFIND", "REPORT"}
+
+// Handler serves the CardDAV address books.
+type Handler struct {
+	queries *db.Queries
+	prefix  string
+}
+
+// New returns a handler for the address books, mounted at prefix (e.g. /carddav).
+func New(queries *db.Queries, prefix string) *Handler {
+	return &Handler{queries: queries, prefix: strings.TrimSuffix(prefix, "/")}
+}
+
+// book is an address book along with the contacts in it.
+type book struct {
+	name        string
+	displayName string
+	contacts    []db.Contact
+	// customers maps each contact's customer to its name, for the organisation of the card.
+	customers map[uuid.UUID]string
+}
+
+// ServeHTTP authenticates the request with an app password and serves the resource at its path.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		unauthorised(w)
+		return
+	}
+	if _, err := apppasswords.Authenticate(r.Context(), h.queries, username, password); err != nil {
+		if !errors.Is(err, apppasswords.ErrInvalid) {
+			slog.Error("CardDAV authentication failed", "err", err)
+		}
+		unauthorised(w)
+		return
+	}
+
+	w.Header().Set("DAV", "1, 3, addressbook")
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("Allow", methods)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead, "PROPFIND", "REPORT":
+		h.serve(w, r)
+	default:
+		w.Header().Set("Allow", methods)
+		http.Error(w, "Beam address books are read-only", http.StatusMethodNotAllowed)
+	}
+}
+
+func unauthorised(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Basic realm="Beam", charset="UTF-8"`)
+	http.Error(w, "Sign in with your GitHub username and an app password", http.StatusUnauthorized)
+}
+
+// serve routes a request to the resource at its path.
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, h.prefix)
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if parts[0] == "" {
+		parts = nil
+	}
+
+	switch {
+	case len(parts) == 0:
+		h.collection(w, r, h.root())
+	case len(parts) == 1 && parts[0] == "principal":
+		h.collection(w, r, h.principal())
+	case len(parts) == 1 && parts[0] == "books":
+		h.home(w, r)
+	case len(parts) == 2 && parts[0] == "books":
+		b, ok := h.loadBook(w, r, parts[1])
+		if !ok {
+			return
+		}
+		if r.Method == "REPORT" {
+			h.report(w, r, b)
+			return
+		}
+		h.addressBook(w, r, b)
+	case len(parts) == 3 && parts[0] == "books" && strings.HasSuffix(parts[2], ".vcf"):
+		b, ok := h.loadBook(w, r, parts[1])
+		if !ok {
+			return
+		}
+		h.card(w, r, b, strings.TrimSuffix(parts[2], ".vcf"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// collection serves a collection without members of its own, which only has properties.
+func (h *Handler) collection(w http.ResponseWriter, r *http.Request, res resource) {
+	if r.Method != "PROPFIND" {
+		h.notAllowed(w, r)
+		return
+	}
+	req, ok := readPropfind(w, r)
+	if !ok {
+		return
+	}
+	writeMultistatus(w, []response{res.respond(req)})
+}
+
+// home serves the address book home, listing every book at depth 1.
+func (h *Handler) home(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PROPFIND" {
+		h.notAllowed(w, r)
+		return
+	}
+	req, ok := readPropfind(w, r)
+	if !ok {
+		return
+	}
+	home := h.principal()
+	home.href = h.href("books/")
+	home.props[propResourceType] = "<d:collection/>"
+	home.props[propDisplayName] = "Address books"
+	responses := []response{home.respond(req)}
+
+	if depth(r) > 0 {
+		books, err := h.books(r.Context())
+		if err != nil {
+			slog.Error("Failed to list CardDAV address books", "err", err)
+			http.Error(w, "Failed to list address books", http.StatusInternalServerError)
+			return
+		}
+		for _, b := range books {
+			responses = append(responses, h.bookResource(b).respond(req))
+		}
+	}
+	writeMultistatus(w, responses)
+}
+
+// addressBook serves an address book, listing every card at depth 1.
+func (h *Handler) addressBook(w http.ResponseWriter, r *http.Request, b book) {
+	if r.Method != "PROPFIND" {
+		h.notAllowed(w, r)
+		return
+	}
+	req, ok := readPropfind(w, r)
+	if !ok {
+		return
+	}
+	responses := []response{h.bookResource(b).respond(req)}
+	if depth(r) > 0 {
+		for _, c := range b.contacts {
+			res, err := h.cardResource(b, c, req.wants(propAddressData))
+			if err != nil {
+				slog.Error("Failed to write vCard", "contact_id", c.ID, "err", err)
+				http.Error(w, "Failed to write contacts", http.StatusInternalServerError)
+				return
+			}
+			responses = append(responses, res.respond(req))
+		}
+	}
+	writeMultistatus(w, responses)
+}
+
+// report answers an addressbook-multiget or addressbook-query report. Query filters are not applied, so a query
+// returns every card in the book, which clients syncing a whole book ask for anyway.
+func (h *Handler) report(w http.ResponseWriter, r *http.Request, b book) {
+	req, err := parseRequest(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var responses []response
+	switch req.root {
+	case reportMultiget:
+		byHref := make(map[string]db.Contact, len(b.contacts))
+		for _, c := range b.contacts {
+			byHref[h.cardHref(b, c.ID)] = c
+		}
+		for _, href := range req.hrefs {
+			// some clients send absolute or escaped hrefs
+			path := href
+			if u, err := url.Parse(href); err == nil {
+				path = u.Path
+			}
+			c, found := byHref[path]
+			if !found {
+				responses = append(responses, response{href: href, status: http.StatusNotFound})
+				continue
+			}
+			res, err := h.cardResource(b, c, req.wants(propAddressData))
+			if err != nil {
+				slog.Error("Failed to write vCard", "contact_id", c.ID, "err", err)
+				http.Error(w, "Failed to write contacts", http.StatusInternalServerError)
+				return
+			}
+			responses = append(responses, res.respond(req))
+		}
+	case reportQuery:
+		for _, c := range b.contacts {
+			res, err := h.cardResource(b, c, req.wants(propAddressData))
+			if err != nil {
+				slog.Error("Failed to write vCard", "contact_id", c.ID, "err", err)
+				http.Error(w, "Failed to write contacts", http.StatusInternalServerError)
+				return
+			}
+			responses = append(responses, res.respond(req))
+		}
+	default:
+		http.Error(w, "Unsupported report", http.StatusForbidden)
+		return
+	}
+	writeMultistatus(w, responses)
+}
+
+// card serves a single card, as a vCard for GET and HEAD or its properties for PROPFIND.
+func (h *Handler) card(w http.ResponseWriter, r *http.Request, b book, id string) {
+	var contact db.Contact
+	found := false
+	for _, c := range b.contacts {
+		if c.ID.String() == id {
+			contact, found = c, true
+			break
+		}
+	}
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case "PROPFIND":
+		req, ok := readPropfind(w, r)
+		if !ok {
+			return
+		}
+		res, err := h.cardResource(b, contact, req.wants(propAddressData))
+		if err != nil {
+			slog.Error("Failed to write vCard", "contact_id", contact.ID, "err", err)
+			http.Error(w, "Failed to write the contact", http.StatusInternalServerError)
+			return
+		}
+		writeMultistatus(w, []response{res.respond(req)})
+	case http.MethodGet, http.MethodHead:
+		etag := ETag(contact)
+		w.Header().Set("ETag", etag)
+		if contact.UpdatedAt.Valid {
+			w.Header().Set("Last-Modified", contact.UpdatedAt.Time.UTC().Format(http.TimeFormat))
+		}
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		var buf bytes.Buffer
+		if err := vcard.Write(&buf, contact, b.customers[contact.CustomerID]); err != nil {
+			slog.Error("Failed to write vCard", "contact_id", contact.ID, "err", err)
+			http.Error(w, "Failed to write the contact", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", vcard.ContentType)
+		w.Header().Set("Content-Length", fmt.Sprint(buf.Len()))
+		if r.Method == http.MethodGet {
+			w.Write(buf.Bytes())
+		}
+	default:
+		h.notAllowed(w, r)
+	}
+}
+
+func (h *Handler) notAllowed(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Allow", methods)
+	http.Error(w, fmt.Sprintf("%s is not supported here", r.Method), http.StatusMethodNotAllowed)
+}
+
+// ETag identifies the version of a contact's card. It changes whenever the contact is saved.
+func ETag(c db.Contact) string {
+	return fmt.Sprintf(`"%d-%d"`, c.UpdatedAt.Time.Unix(), c.Version)
+}
+
+// CTag identifies the version of an address book, changing whenever a card is added, changed or removed.
+func CTag(contacts []db.Contact) string {
+	tags := make([]string, len(contacts))
+	for i, c := range contacts {
+		tags[i] = c.ID.String() + ETag(c)
+	}
+	sort.Strings(tags)
+	sum := sha256.Sum256([]byte(strings.Join(tags, ",")))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// books loads every address book, the book of every contact first and then one per customer by name.
+func (h *Handler) books(ctx context.Context) ([]book, error) {
+	customers, err := h.queries.ListCustomers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading customers: %w", err)
+	}
+	contacts, err := h.queries.ListContacts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading contacts: %w", err)
+	}
+
+	all := book{name: AllBook, displayName: "Beam", customers: make(map[uuid.UUID]string, len(customers))}
+	byCustomer := make(map[uuid.UUID]*book, len(customers))
+	for _, c := range customers {
+		all.customers[c.ID] = c.Name
+		byCustomer[c.ID] = &book{name: c.ID.String(), displayName: c.Name, customers: map[uuid.UUID]string{c.ID: c.Name}}
+	}
+	for _, c := range contacts {
+		// contacts of deleted customers are left out
+		if b, ok := byCustomer[c.CustomerID]; ok {
+			all.contacts = append(all.contacts, c)
+			b.contacts = append(b.contacts, c)
+		}
+	}
+
+	sort.Slice(customers, func(i, j int) bool { return strings.ToLower(customers[i].Name) < strings.ToLower(customers[j].Name) })
+	books := []book{all}
+	for _, c := range customers {
+		books = append(books, *byCustomer[c.ID])
+	}
+	return books, nil
+}
+
+// loadBook loads the address book named in the path, writing a not found response when there is none.
+func (h *Handler) loadBook(w http.ResponseWriter, r *http.Request, name string) (book, bool) {
+	if name == AllBook {
+		books, err := h.books(r.Context())
+		if err != nil {
+			slog.Error("Failed to load CardDAV address book", "book", name, "err", err)
+			http.Error(w, "Failed to load the address book", http.StatusInternalServerError)
+			return book{}, false
+		}
+		return books[0], true
+	}
+
+	id, err := uuid.Parse(name)
+	if err != nil {
+		http.NotFound(w, r)
+		return book{}, false
+	}
+	customer, err := h.queries.GetCustomer(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return book{}, false
+	}
+	if err != nil {
+		slog.Error("Failed to load CardDAV address book", "book", name, "err", err)
+		http.Error(w, "Failed to load the address book", http.StatusInternalServerError)
+		return book{}, false
+	}
+	contacts, err := h.queries.ListContactsByCustomer(r.Context(), id)
+	if err != nil {
+		slog.Error("Failed to load CardDAV address book", "book", name, "err", err)
+		http.Error(w, "Failed to load the address book", http.StatusInternalServerError)
+		return book{}, false
+	}
+	return book{name: name, displayName: customer.Name, contacts: contacts, customers: map[uuid.UUID]string{id: customer.Name}}, true
+}
+
+func (h *Handler) href(path string) string {
+	return h.prefix + "/" + path
+}
+
+func (h *Handler) cardHref(b book, id uuid.UUID) string {
+	return h.href("books/" + b.name + "/" + id.String() + ".vcf")
+}
+
+// root is the service root, which points clients at the signed in user.
+func (h *Handler) root() resource {
+	return resource{href: h.href(""), props: map[propName]string{
+		propResourceType:         "<d:collection/>",
+		propCurrentUserPrincipal: "<d:href>" + h.href("principal/") + "</d:href>",
+	}}
+}
+
+// principal is the signed in user, which points clients at the address books.
+func (h *Handler) principal() resource {
+	return resource{href: h.href("principal/"), props: map[propName]string{
+		propResourceType:         "<d:principal/>",
+		propDisplayName:          "Beam",
+		propCurrentUserPrincipal: "<d:href>" + h.href("principal/") + "</d:href>",
+		propPrincipalURL:         "<d:href>" + h.href("principal/") + "</d:href>",
+		propAddressBookHomeSet:   "<d:href>" + h.href("books/") + "</d:href>",
+	}}
+}
+
+func (h *Handler) bookResource(b book) resource {
+	ctag := CTag(b.contacts)
+	description := "Every contact in Beam"
+	if b.name != AllBook {
+		description = "Contacts of " + b.displayName
+	}
+	return resource{href: h.href("books/" + b.name + "/"), props: map[propName]string{
+		propResourceType:          "<d:collection/><card:addressbook/>",
+		propDisplayName:           escapeText(b.displayName),
+		propAddressBookDesc:       escapeText(description),
+		propCurrentUserPrincipal:  "<d:href>" + h.href("principal/") + "</d:href>",
+		propCurrentUserPrivileges: "<d:privilege><d:read/></d:privilege>",
+		propSupportedReportSet:    "<d:supported-report><d:report><card:addressbook-multiget/></d:report></d:supported-report><d:supported-report><d:report><card:addressbook-query/></d:report></d:supported-report>",
+		propSupportedAddressData:  `<card:address-data-type content-type="text/vcard" version="4.0"/>`,
+		propGetCTag:               escapeText(ctag),
+		propGetETag:               escapeText(ctag),
+	}}
+}
+
+// cardResource describes a card, including the vCard itself when withData is set.
+func (h *Handler) cardResource(b book, c db.Contact, withData bool) (resource, error) {
+	res := resource{href: h.cardHref(b, c.ID), props: map[propName]string{
+		propResourceType:   "",
+		propGetETag:        escapeText(ETag(c)),
+		propGetContentType: vcard.ContentType,
+	}}
+	if c.UpdatedAt.Valid {
+		res.props[propLastModified] = c.UpdatedAt.Time.UTC().Format(time.RFC1123)
+	}
+	if withData {
+		var buf bytes.Buffer
+		if err := vcard.Write(&buf, c, b.customers[c.CustomerID]); err != nil {
+			return res, err
+		}
+		res.props[propAddressData] = escapeText(buf.String())
+	}
+	return res, nil
+}
+
+// depth returns the Depth header of a PROPFIND, which defaults to infinity. Infinity is treated as 1 as the
+// collections are at most one level deep.
+func depth(r *http.Request) int {
+	if r.Header.Get("Depth") == "0" {
+		return 0
+	}
+	return 1
+}
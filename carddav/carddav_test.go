@@ -0,0 +1,273 @@
+package carddav
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"html"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/apppasswords"
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+)
+
+// client is a minimal WebDAV client signed in with an app password.
+type client struct {
+	t        *testing.T
+	url      string
+	username string
+	password string
+}
+
+func (c client) do(method, path, depth, body string, header ...string) (*http.Response, string) {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.url+path, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatalf("NewRequest failed: %v", err)
+	}
+	req.SetBasicAuth(c.username, c.password)
+	if depth != "" {
+		req.Header.Set("Depth", depth)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(res.Body)
+	return res, string(data)
+}
+
+// props sends a PROPFIND or REPORT, returning the properties found for each href.
+func (c client) props(method, path, depth, body string) map[string]map[string]string {
+	c.t.Helper()
+	res, data := c.do(method, path, depth, body)
+	if res.StatusCode != http.StatusMultiStatus {
+		c.t.Fatalf("%s %s: expected 207, got %d: %s", method, path, res.StatusCode, data)
+	}
+	var ms struct {
+		Responses []struct {
+			Href      string `xml:"DAV: href"`
+			Status    string `xml:"DAV: status"`
+			Propstats []struct {
+				Status string `xml:"DAV: status"`
+				Prop   struct {
+					Values []struct {
+						XMLName xml.Name
+						Inner   string `xml:",innerxml"`
+					} `xml:",any"`
+				} `xml:"DAV: prop"`
+			} `xml:"DAV: propstat"`
+		} `xml:"DAV: response"`
+	}
+	if err := xml.Unmarshal([]byte(data), &ms); err != nil {
+		c.t.Fatalf("%s %s: invalid multistatus: %v\n%s", method, path, err, data)
+	}
+	found := make(map[string]map[string]string)
+	for _, r := range ms.Responses {
+		props := make(map[string]string)
+		if r.Status != "" {
+			props["status"] = r.Status
+		}
+		for _, ps := range r.Propstats {
+			for _, v := range ps.Prop.Values {
+				if strings.Contains(ps.Status, " 200 ") {
+					props[v.XMLName.Local] = html.UnescapeString(v.Inner)
+				} else {
+					props[v.XMLName.Local+" missing"] = ps.Status
+				}
+			}
+		}
+		found[r.Href] = props
+	}
+	return found
+}
+
+func TestCardDAV_Integration(t *testing.T) {
+	os.MkdirAll("data", 0755)
+	store, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	githubID := "dav-" + uuid.NewString()[:8]
+	if err := queries.InsertUser(ctx, sqlc.InsertUserParams{Name: "DAV User", Email: githubID + "@beam.test", GithubID: githubID}); err != nil {
+		t.Fatalf("InsertUser failed: %v", err)
+	}
+	if _, err := store.ExecContext(ctx, "UPDATE users SET is_admin = 1 WHERE github_id = ?", githubID); err != nil {
+		t.Fatalf("making the user an admin failed: %v", err)
+	}
+	user, _ := queries.GetUserByGithubID(ctx, githubID)
+	_, password, err := apppasswords.Create(ctx, queries, user.ID, "Phone")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "DAV & Co " + uuid.NewString()[:8], Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	contact, err := queries.CreateContact(ctx, sqlc.CreateContactParams{
+		CustomerID: customer.ID,
+		Name:       "Jane Smith",
+		Email:      sql.NullString{String: "jane@dav.test", Valid: true},
+		IsPrimary:  sql.NullBool{Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateContact failed: %v", err)
+	}
+	deleted, _ := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "Gone " + uuid.NewString()[:8], Status: "active"})
+	gone, _ := queries.CreateContact(ctx, sqlc.CreateContactParams{CustomerID: deleted.ID, Name: "Gone Contact", IsPrimary: sql.NullBool{Valid: true}})
+	if _, err := queries.DeleteCustomer(ctx, deleted.ID); err != nil {
+		t.Fatalf("DeleteCustomer failed: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/carddav/", New(queries, "/carddav"))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	dav := client{t: t, url: server.URL, username: githubID, password: password}
+	bookPath := "/carddav/books/" + customer.ID.String() + "/"
+	cardPath := bookPath + contact.ID.String() + ".vcf"
+
+	t.Run("authentication", func(t *testing.T) {
+		for name, c := range map[string]client{
+			"anonymous":      {t: t, url: server.URL},
+			"wrong password": {t: t, url: server.URL, username: githubID, password: apppasswords.Generate()},
+		} {
+			res, _ := c.do("PROPFIND", "/carddav/", "0", "")
+			if res.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(res.Header.Get("WWW-Authenticate"), "Basic") {
+				t.Errorf("%s: expected a Basic challenge, got %d %q", name, res.StatusCode, res.Header.Get("WWW-Authenticate"))
+			}
+		}
+		res, _ := dav.do(http.MethodOptions, "/carddav/", "", "")
+		if res.StatusCode != http.StatusOK || !strings.Contains(res.Header.Get("DAV"), "addressbook") {
+			t.Errorf("expected CardDAV to be advertised, got %d %q", res.StatusCode, res.Header.Get("DAV"))
+		}
+	})
+
+	t.Run("discovery", func(t *testing.T) {
+		root := dav.props("PROPFIND", "/carddav/", "0", `<?xml version="1.0"?><propfind xmlns="DAV:"><prop><current-user-principal/></prop></propfind>`)
+		if p := root["/carddav/"]["current-user-principal"]; !strings.Contains(p, "/carddav/principal/") {
+			t.Errorf("expected the principal, got %q", p)
+		}
+		principal := dav.props("PROPFIND", "/carddav/principal/", "0", `<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:carddav"><d:prop><c:addressbook-home-set/></d:prop></d:propfind>`)
+		if p := principal["/carddav/principal/"]["addressbook-home-set"]; !strings.Contains(p, "/carddav/books/") {
+			t.Errorf("expected the address book home, got %q", p)
+		}
+
+		books := dav.props("PROPFIND", "/carddav/books/", "1", `<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:displayname/></d:prop></d:propfind>`)
+		if b := books[bookPath]; !strings.Contains(b["resourcetype"], "addressbook") || b["displayname"] != customer.Name {
+			t.Errorf("expected the customer's address book, got %+v", b)
+		}
+		if _, ok := books["/carddav/books/all/"]; !ok {
+			t.Error("expected the address book of every contact")
+		}
+		if _, ok := books["/carddav/books/"+deleted.ID.String()+"/"]; ok {
+			t.Error("expected no address book for a deleted customer")
+		}
+	})
+
+	var etag, ctag string
+	t.Run("listing", func(t *testing.T) {
+		list := dav.props("PROPFIND", bookPath, "1", `<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/" xmlns:x="urn:example"><d:prop><d:getetag/><cs:getctag/><x:colour/></d:prop></d:propfind>`)
+		if len(list) != 2 {
+			t.Fatalf("expected the book and its card, got %+v", list)
+		}
+		ctag = list[bookPath]["getctag"]
+		etag = list[cardPath]["getetag"]
+		if ctag == "" || etag == "" {
+			t.Errorf("expected tags for the book and card, got %+v", list)
+		}
+		if list[cardPath]["colour missing"] == "" {
+			t.Errorf("expected unknown properties reported as not found, got %+v", list[cardPath])
+		}
+
+		all := dav.props("PROPFIND", "/carddav/books/all/", "1", "")
+		if _, ok := all["/carddav/books/all/"+contact.ID.String()+".vcf"]; !ok {
+			t.Error("expected the contact in the book of every contact")
+		}
+		if _, ok := all["/carddav/books/all/"+gone.ID.String()+".vcf"]; ok {
+			t.Error("expected no contacts of deleted customers")
+		}
+	})
+
+	t.Run("multiget", func(t *testing.T) {
+		missing := bookPath + uuid.NewString() + ".vcf"
+		cards := dav.props("REPORT", bookPath, "1", `<c:addressbook-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:carddav"><d:prop><d:getetag/><c:address-data/></d:prop><d:href>`+server.URL+cardPath+`</d:href><d:href>`+missing+`</d:href></c:addressbook-multiget>`)
+		card := cards[cardPath]
+		if card["getetag"] != etag || !strings.Contains(card["address-data"], "FN:Jane Smith") || !strings.Contains(card["address-data"], "jane@dav.test") {
+			t.Errorf("expected the vCard, got %+v", card)
+		}
+		if !strings.Contains(cards[missing]["status"], "404") {
+			t.Errorf("expected a missing card reported as not found, got %+v", cards[missing])
+		}
+
+		query := dav.props("REPORT", bookPath, "1", `<c:addressbook-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:carddav"><d:prop><d:getetag/></d:prop></c:addressbook-query>`)
+		if query[cardPath]["getetag"] != etag {
+			t.Errorf("expected a query to return the card, got %+v", query)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		res, body := dav.do(http.MethodGet, cardPath, "", "")
+		if res.StatusCode != http.StatusOK || res.Header.Get("ETag") != etag || !strings.HasPrefix(body, "BEGIN:VCARD") {
+			t.Fatalf("expected the vCard with its ETag, got %d %q\n%s", res.StatusCode, res.Header.Get("ETag"), body)
+		}
+		if res, _ := dav.do(http.MethodGet, cardPath, "", "", "If-None-Match", res.Header.Get("ETag")); res.StatusCode != http.StatusNotModified {
+			t.Errorf("expected an unchanged card not to be sent again, got %d", res.StatusCode)
+		}
+		if res, _ := dav.do(http.MethodGet, "/carddav/books/"+deleted.ID.String()+"/"+gone.ID.String()+".vcf", "", ""); res.StatusCode != http.StatusNotFound {
+			t.Errorf("expected the cards of deleted customers not to be found, got %d", res.StatusCode)
+		}
+		if res, _ := dav.do(http.MethodPut, cardPath, "", "BEGIN:VCARD\r\nEND:VCARD\r\n"); res.StatusCode != http.StatusMethodNotAllowed {
+			t.Errorf("expected the address book to be read-only, got %d", res.StatusCode)
+		}
+	})
+
+	t.Run("changes", func(t *testing.T) {
+		if _, err := queries.UpdateContact(ctx, sqlc.UpdateContactParams{
+			Name:      "Jane Doe",
+			Email:     contact.Email,
+			IsPrimary: contact.IsPrimary,
+			ID:        contact.ID,
+			Version:   contact.Version,
+		}); err != nil {
+			t.Fatalf("UpdateContact failed: %v", err)
+		}
+		list := dav.props("PROPFIND", bookPath, "1", `<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/"><d:prop><d:getetag/><cs:getctag/></d:prop></d:propfind>`)
+		if list[cardPath]["getetag"] == etag || list[bookPath]["getctag"] == ctag {
+			t.Errorf("expected the tags to change with the contact, got %+v", list)
+		}
+	})
+}
+
+func TestParseRequest(t *testing.T) {
+	req, err := parseRequest(strings.NewReader(""))
+	if err != nil || !req.allprop || req.wants(propAddressData) {
+		t.Errorf("expected an empty body to ask for every property but the vCard, got %+v (%v)", req, err)
+	}
+	req, err = parseRequest(strings.NewReader(`<propfind xmlns="DAV:"><prop><getetag/><address-data xmlns="urn:ietf:params:xml:ns:carddav"/></prop></propfind>`))
+	if err != nil || req.allprop || !req.wants(propGetETag) || !req.wants(propAddressData) || req.wants(propDisplayName) {
+		t.Errorf("expected only the named properties, got %+v (%v)", req, err)
+	}
+	if _, err := parseRequest(strings.NewReader("<propfind><prop>")); err == nil {
+		t.Error("expected an error for truncated XML")
+	}
+}
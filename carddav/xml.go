@@ -0,0 +1,260 @@
+package carddav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// The XML namespaces of WebDAV, CardDAV and the calendar server extensions clients use for change tags.
+const (
+	nsDAV     = "DAV:"
+	nsCardDAV = "urn:ietf:params:xml:ns:carddav"
+	nsCS      = "http://calendarserver.org/ns/"
+)
+
+// prefixes are the prefixes responses declare for each namespace.
+var prefixes = map[string]string{nsDAV: "d", nsCardDAV: "card", nsCS: "cs"}
+
+// propName is the qualified name of a WebDAV property.
+type propName struct {
+	space string
+	local string
+}
+
+// The properties the server knows about, in the order they are written for allprop requests.
+var (
+	propResourceType          = propName{nsDAV, "resourcetype"}
+	propDisplayName           = propName{nsDAV, "displayname"}
+	propGetETag               = propName{nsDAV, "getetag"}
+	propGetContentType        = propName{nsDAV, "getcontenttype"}
+	propLastModified          = propName{nsDAV, "getlastmodified"}
+	propCurrentUserPrincipal  = propName{nsDAV, "current-user-principal"}
+	propPrincipalURL          = propName{nsDAV, "principal-URL"}
+	propCurrentUserPrivileges = propName{nsDAV, "current-user-privilege-set"}
+	propSupportedReportSet    = propName{nsDAV, "supported-report-set"}
+	propAddressBookHomeSet    = propName{nsCardDAV, "addressbook-home-set"}
+	propAddressBookDesc       = propName{nsCardDAV, "addressbook-description"}
+	propSupportedAddressData  = propName{nsCardDAV, "supported-address-data"}
+	propAddressData           = propName{nsCardDAV, "address-data"}
+	propGetCTag               = propName{nsCS, "getctag"}
+
+	allProps = []propName{
+		propResourceType, propDisplayName, propGetETag, propGetContentType, propLastModified,
+		propCurrentUserPrincipal, propPrincipalURL, propCurrentUserPrivileges, propSupportedReportSet,
+		propAddressBookHomeSet, propAddressBookDesc, propSupportedAddressData, propGetCTag,
+	}
+)
+
+// The root elements of the requests the server understands.
+var (
+	rootPropfind   = propName{nsDAV, "propfind"}
+	reportMultiget = propName{nsCardDAV, "addressbook-multiget"}
+	reportQuery    = propName{nsCardDAV, "addressbook-query"}
+)
+
+// request is a parsed PROPFIND or REPORT body.
+type request struct {
+	root propName
+	// allprop is set for allprop requests and requests without a body, propname for those asking only for names.
+	allprop  bool
+	propname bool
+	props    []propName
+	hrefs    []string
+}
+
+// wants reports whether the request asks for a property. The vCard itself is only sent when asked for by name, as
+// RFC 6352 leaves it out of allprop.
+func (req request) wants(name propName) bool {
+	if req.allprop || req.propname {
+		return name != propAddressData
+	}
+	for _, p := range req.props {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRequest reads the properties and hrefs asked for by a request body. An empty body asks for every property.
+func parseRequest(body io.Reader) (request, error) {
+	req := request{root: rootPropfind}
+	decoder := xml.NewDecoder(io.LimitReader(body, 1<<20))
+	depth := 0
+	inProp, inHref := false, false
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return req, fmt.Errorf("invalid XML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			name := propName{t.Name.Space, t.Name.Local}
+			switch {
+			case depth == 1:
+				req.root = name
+			case depth == 2 && name == propName{nsDAV, "allprop"}:
+				req.allprop = true
+			case depth == 2 && name == propName{nsDAV, "propname"}:
+				req.propname = true
+			case depth == 2 && name == propName{nsDAV, "prop"}:
+				inProp = true
+			case depth == 2 && name == propName{nsDAV, "href"}:
+				inHref = true
+				req.hrefs = append(req.hrefs, "")
+			case depth == 3 && inProp:
+				req.props = append(req.props, name)
+			}
+		case xml.EndElement:
+			if depth == 2 {
+				inProp, inHref = false, false
+			}
+			depth--
+		case xml.CharData:
+			if inHref {
+				req.hrefs[len(req.hrefs)-1] += strings.TrimSpace(string(t))
+			}
+		}
+	}
+
+	if depth == 0 && req.root == rootPropfind && req.props == nil && !req.propname {
+		req.allprop = true
+	}
+	return req, nil
+}
+
+// readPropfind reads the body of a PROPFIND, writing a bad request response when it cannot be read.
+func readPropfind(w http.ResponseWriter, r *http.Request) (request, bool) {
+	req, err := parseRequest(r.Body)
+	if err == nil && req.root != rootPropfind {
+		err = fmt.Errorf("expected a propfind element")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// resource is a collection or card along with the values of its properties, already encoded as XML.
+type resource struct {
+	href  string
+	props map[propName]string
+}
+
+// respond answers a request for the resource's properties, splitting those it has from those it lacks.
+func (res resource) respond(req request) response {
+	out := response{href: res.href}
+	if req.allprop || req.propname {
+		for _, name := range allProps {
+			if value, ok := res.props[name]; ok {
+				if req.propname {
+					value = ""
+				}
+				out.found = append(out.found, prop{name, value})
+			}
+		}
+		if value, ok := res.props[propAddressData]; ok && req.wants(propAddressData) {
+			out.found = append(out.found, prop{propAddressData, value})
+		}
+		return out
+	}
+	for _, name := range req.props {
+		if value, ok := res.props[name]; ok {
+			out.found = append(out.found, prop{name, value})
+		} else {
+			out.missing = append(out.missing, name)
+		}
+	}
+	return out
+}
+
+type prop struct {
+	name  propName
+	value string
+}
+
+// response is a single response of a multistatus. Responses with a status have no properties, such as hrefs of a
+// multiget report that are not in the address book.
+type response struct {
+	href    string
+	status  int
+	found   []prop
+	missing []propName
+}
+
+// writeMultistatus writes a 207 Multi-Status response.
+func writeMultistatus(w http.ResponseWriter, responses []response) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<d:multistatus xmlns:d=%q xmlns:card=%q xmlns:cs=%q>`, nsDAV, nsCardDAV, nsCS)
+	for _, res := range responses {
+		b.WriteString("<d:response><d:href>")
+		b.WriteString(escapeText(res.href))
+		b.WriteString("</d:href>")
+		if res.status != 0 {
+			writeStatus(&b, res.status)
+		}
+		if len(res.found) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, p := range res.found {
+				writeProp(&b, p.name, p.value)
+			}
+			b.WriteString("</d:prop>")
+			writeStatus(&b, http.StatusOK)
+			b.WriteString("</d:propstat>")
+		}
+		if len(res.missing) > 0 {
+			b.WriteString("<d:propstat><d:prop>")
+			for _, name := range res.missing {
+				writeProp(&b, name, "")
+			}
+			b.WriteString("</d:prop>")
+			writeStatus(&b, http.StatusNotFound)
+			b.WriteString("</d:propstat>")
+		}
+		b.WriteString("</d:response>")
+	}
+	b.WriteString("</d:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write(b.Bytes())
+}
+
+func writeStatus(b *bytes.Buffer, status int) {
+	fmt.Fprintf(b, "<d:status>HTTP/1.1 %d %s</d:status>", status, http.StatusText(status))
+}
+
+// writeProp writes a property, declaring its namespace inline when it is not one the multistatus declares.
+func writeProp(b *bytes.Buffer, name propName, value string) {
+	tag, open := name.local, name.local
+	if prefix, ok := prefixes[name.space]; ok {
+		tag = prefix + ":" + name.local
+		open = tag
+	} else if name.space != "" {
+		open = fmt.Sprintf("%s xmlns=%q", name.local, name.space)
+	}
+	if value == "" {
+		fmt.Fprintf(b, "<%s/>", open)
+		return
+	}
+	fmt.Fprintf(b, "<%s>%s</%s>", open, value, tag)
+}
+
+// escapeText escapes a value for use as XML character data.
+func escapeText(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
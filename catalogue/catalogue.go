@@ -0,0 +1,128 @@
+// Package catalogue is the list of products that subscriptions are sold from. Each product has a SKU, a default unit
+// price for each billing cadence and currency it is sold in, and is archived once it is no longer sold. A customer can
+// be given their own price for a product, which is used instead of the default for the same cadence and currency.
+package catalogue
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/currency"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/money"
+)
+
+// Statuses of a product.
+const (
+	Active   = "active"
+	Archived = "archived"
+)
+
+// Statuses lists every status, in the order they are offered.
+var Statuses = []string{Active, Archived}
+
+// Where a resolved price came from.
+const (
+	SourceCustomer  = "customer"
+	SourceCatalogue = "catalogue"
+)
+
+var skuPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9._-]{0,63}$`)
+
+// ProductParams checks a product entered by hand, returning the parameters to save it with. SKUs are kept in upper
+// case so that they read the same wherever they are shown.
+func ProductParams(sku, name string) (db.CreateProductParams, error) {
+	params := db.CreateProductParams{Sku: strings.ToUpper(strings.TrimSpace(sku)), Name: strings.TrimSpace(name)}
+	if params.Sku == "" {
+		return params, fmt.Errorf("a product needs a SKU")
+	}
+	if !skuPattern.MatchString(params.Sku) {
+		return params, fmt.Errorf("invalid SKU %q, use up to 64 letters, digits, dots, dashes and underscores", params.Sku)
+	}
+	if params.Name == "" {
+		return params, fmt.Errorf("a product needs a name")
+	}
+	return params, nil
+}
+
+// CheckStatus returns an error for statuses a product cannot have.
+func CheckStatus(status string) error {
+	if !slices.Contains(Statuses, status) {
+		return fmt.Errorf("invalid status %q, expected one of %s", status, strings.Join(Statuses, ", "))
+	}
+	return nil
+}
+
+// Price is the unit price of a product for a billing cadence and currency.
+type Price struct {
+	Cadence   string
+	Currency  string
+	UnitPrice money.Amount
+}
+
+// ParsePrice checks a price entered by hand.
+func ParsePrice(cadence, currencyCode, unitPrice string) (Price, error) {
+	var err error
+	p := Price{Cadence: cadence}
+	if !slices.Contains(billing.Cadences, p.Cadence) {
+		return p, fmt.Errorf("invalid billing cadence %q", cadence)
+	}
+	if p.Currency, err = currency.Parse(currencyCode); err != nil {
+		return p, err
+	}
+	if p.UnitPrice, err = money.Parse(unitPrice); err != nil {
+		return p, err
+	}
+	if p.UnitPrice < 0 {
+		return p, fmt.Errorf("the unit price cannot be negative")
+	}
+	return p, nil
+}
+
+// Resolve finds the unit price of a product for a billing cadence and currency, preferring a price agreed with the
+// customer over the default price. It reports where the price came from, or false when there is neither.
+func Resolve(defaults []db.ProductPrice, overrides []db.CustomerProductPrice, productID uuid.UUID, cadence, currency string) (money.Amount, string, bool) {
+	for _, p := range overrides {
+		if p.ProductID == productID && p.BillingCadence == cadence && p.Currency == currency {
+			return p.UnitPrice, SourceCustomer, true
+		}
+	}
+	for _, p := range defaults {
+		if p.ProductID == productID && p.BillingCadence == cadence && p.Currency == currency {
+			return p.UnitPrice, SourceCatalogue, true
+		}
+	}
+	return 0, "", false
+}
+
+// Load resolves the unit price of a product for a customer from the database.
+func Load(ctx context.Context, queries *db.Queries, customerID, productID uuid.UUID, cadence, currency string) (money.Amount, string, bool, error) {
+	defaults, err := queries.ListProductPrices(ctx)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("error loading product prices: %w", err)
+	}
+	overrides, err := queries.ListCustomerProductPrices(ctx, customerID)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("error loading customer prices: %w", err)
+	}
+	price, source, ok := Resolve(defaults, overrides, productID, cadence, currency)
+	return price, source, ok, nil
+}
+
+// Sellable lists the products that can be chosen for a subscription, which are the active ones and the product the
+// subscription already has, even once it is archived.
+func Sellable(products []db.Product, current uuid.NullUUID) []db.Product {
+	var sellable []db.Product
+	for _, p := range products {
+		if p.Status == Active || (current.Valid && p.ID == current.UUID) {
+			sellable = append(sellable, p)
+		}
+	}
+	return sellable
+}
@@ -0,0 +1,162 @@
+package catalogue
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+)
+
+func TestProductParams(t *testing.T) {
+	params, err := ProductParams("  seat-std ", " Standard Seat ")
+	if err != nil || params.Sku != "SEAT-STD" || params.Name != "Standard Seat" {
+		t.Errorf("expected an upper case SKU and a trimmed name, got %+v (%v)", params, err)
+	}
+
+	tests := []struct{ name, sku, product string }{
+		{"blank SKU", " ", "Standard Seat"},
+		{"SKU with spaces", "SEAT STD", "Standard Seat"},
+		{"SKU starting with a dash", "-SEAT", "Standard Seat"},
+		{"blank name", "SEAT-STD", " "},
+	}
+	for _, tt := range tests {
+		if _, err := ProductParams(tt.sku, tt.product); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	p, err := ParsePrice("monthly", "aud", "12.50")
+	if err != nil || p.Currency != "AUD" || p.UnitPrice != 1250 {
+		t.Errorf("expected a monthly AUD price of 12.50, got %+v (%v)", p, err)
+	}
+
+	tests := []struct{ name, cadence, currency, price string }{
+		{"bad cadence", "fortnightly", "NZD", "10"},
+		{"bad currency", "monthly", "GBP", "10"},
+		{"bad amount", "monthly", "NZD", "ten"},
+		{"negative amount", "monthly", "NZD", "-1"},
+	}
+	for _, tt := range tests {
+		if _, err := ParsePrice(tt.cadence, tt.currency, tt.price); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	product, other := uuid.New(), uuid.New()
+	defaults := []sqlc.ProductPrice{
+		{ProductID: product, BillingCadence: "monthly", Currency: "NZD", UnitPrice: 1000},
+		{ProductID: product, BillingCadence: "yearly", Currency: "NZD", UnitPrice: 11000},
+		{ProductID: other, BillingCadence: "monthly", Currency: "AUD", UnitPrice: 900},
+	}
+	overrides := []sqlc.CustomerProductPrice{
+		{ProductID: product, BillingCadence: "monthly", Currency: "NZD", UnitPrice: 800},
+	}
+
+	tests := []struct {
+		name              string
+		product           uuid.UUID
+		cadence, currency string
+		want              int64
+		source            string
+		ok                bool
+	}{
+		{"customer price", product, "monthly", "NZD", 800, SourceCustomer, true},
+		{"default price", product, "yearly", "NZD", 11000, SourceCatalogue, true},
+		{"no price in currency", product, "monthly", "AUD", 0, "", false},
+		{"other product", other, "monthly", "AUD", 900, SourceCatalogue, true},
+	}
+	for _, tt := range tests {
+		price, source, ok := Resolve(defaults, overrides, tt.product, tt.cadence, tt.currency)
+		if int64(price) != tt.want || source != tt.source || ok != tt.ok {
+			t.Errorf("%s: expected %d from %q (%v), got %d from %q (%v)", tt.name, tt.want, tt.source, tt.ok, price, source, ok)
+		}
+	}
+}
+
+func TestSellable(t *testing.T) {
+	active := sqlc.Product{ID: uuid.New(), Status: Active}
+	archived := sqlc.Product{ID: uuid.New(), Status: Archived}
+	products := []sqlc.Product{active, archived}
+
+	if got := Sellable(products, uuid.NullUUID{}); len(got) != 1 || got[0].ID != active.ID {
+		t.Errorf("expected only the active product, got %+v", got)
+	}
+	if got := Sellable(products, uuid.NullUUID{UUID: archived.ID, Valid: true}); len(got) != 2 {
+		t.Errorf("expected the archived product a subscription already has to be kept, got %+v", got)
+	}
+}
+
+func TestLoad_CustomerPriceOverridesDefault_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// a unique SKU per run keeps products from earlier runs against the same database out of the way
+	sku := "T-" + strings.ToUpper(uuid.NewString()[:8])
+	product, err := queries.CreateProduct(ctx, sqlc.CreateProductParams{Sku: sku, Name: "Catalogue Test"})
+	if err != nil {
+		t.Fatalf("CreateProduct failed: %v", err)
+	}
+	if product.Status != Active {
+		t.Errorf("expected new products to be active, got %q", product.Status)
+	}
+	if _, err := queries.CreateProduct(ctx, sqlc.CreateProductParams{Sku: strings.ToLower(sku), Name: "Duplicate"}); err == nil {
+		t.Errorf("expected SKUs to be unique regardless of case")
+	}
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "Catalogue", Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+
+	set := sqlc.SetProductPriceParams{ProductID: product.ID, BillingCadence: "monthly", Currency: "NZD", UnitPrice: 1000}
+	if _, err := queries.SetProductPrice(ctx, set); err != nil {
+		t.Fatalf("SetProductPrice failed: %v", err)
+	}
+	set.UnitPrice = 1200
+	if _, err := queries.SetProductPrice(ctx, set); err != nil {
+		t.Fatalf("expected setting the price again to replace it, got %v", err)
+	}
+	if price, source, ok, err := Load(ctx, queries, customer.ID, product.ID, "monthly", "NZD"); err != nil || !ok || price != 1200 || source != SourceCatalogue {
+		t.Errorf("expected the replaced default price, got %d from %q (%v, %v)", price, source, ok, err)
+	}
+
+	override, err := queries.SetCustomerProductPrice(ctx, sqlc.SetCustomerProductPriceParams{
+		CustomerID: customer.ID, ProductID: product.ID, BillingCadence: "monthly", Currency: "NZD", UnitPrice: 900,
+	})
+	if err != nil {
+		t.Fatalf("SetCustomerProductPrice failed: %v", err)
+	}
+	if price, source, _, _ := Load(ctx, queries, customer.ID, product.ID, "monthly", "NZD"); price != 900 || source != SourceCustomer {
+		t.Errorf("expected the customer price, got %d from %q", price, source)
+	}
+
+	if _, err := queries.DeleteCustomerProductPrice(ctx, sqlc.DeleteCustomerProductPriceParams{ID: override.ID, CustomerID: uuid.New()}); err != sql.ErrNoRows {
+		t.Errorf("expected another customer's price not to be deleted, got %v", err)
+	}
+	if _, err := queries.DeleteCustomerProductPrice(ctx, sqlc.DeleteCustomerProductPriceParams{ID: override.ID, CustomerID: customer.ID}); err != nil {
+		t.Fatalf("DeleteCustomerProductPrice failed: %v", err)
+	}
+	if price, _, _, _ := Load(ctx, queries, customer.ID, product.ID, "monthly", "NZD"); price != 1200 {
+		t.Errorf("expected the default price once the customer price is deleted, got %d", price)
+	}
+}
+
+func setupTestDB(t *testing.T) (*sqlc.Queries, func()) {
+	os.MkdirAll("data", 0755)
+	dbConn, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	cleanup := func() { dbConn.Close() }
+	return queries, cleanup
+}
@@ -0,0 +1,67 @@
+// Command beamctl provides administrative tasks for a beam database. Run it from the
+// directory containing data/beam.db.
+//
+// Usage:
+//
+//	beamctl audit verify   walk the activity hash chain and report the first broken link
+//	beamctl audit seal     add any unsealed activity entries to the end of the chain
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/db"
+)
+
+const usage = `usage: beamctl <command>
+
+commands:
+  audit verify   walk the activity hash chain and report the first broken link
+  audit seal     add any unsealed activity entries to the end of the chain
+`
+
+func main() {
+	if len(os.Args) < 3 || os.Args[1] != "audit" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	store, queries, err := db.InitialiseDB()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	switch os.Args[2] {
+	case "verify":
+		report, err := activitylog.Verify(ctx, queries)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error verifying activity log: %v\n", err)
+			os.Exit(1)
+		}
+		if report.Unsealed > 0 {
+			fmt.Printf("%d entries are not sealed yet, run 'beamctl audit seal' to add them to the chain\n", report.Unsealed)
+		}
+		if !report.OK() {
+			fmt.Printf("Chain broken at entry %d (%s): %s\n", report.Broken.Seq, report.Broken.ID, report.Broken.Reason)
+			fmt.Printf("%d entries verified before the break\n", report.Checked)
+			os.Exit(1)
+		}
+		fmt.Printf("Activity log intact, %d entries verified\n", report.Checked)
+	case "seal":
+		n, err := activitylog.Seal(ctx, queries)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error sealing activity log: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Sealed %d entries\n", n)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
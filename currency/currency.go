@@ -0,0 +1,169 @@
+// Package currency handles the currencies subscriptions are billed in and converts their amounts into the reporting
+// currency using exchange rates maintained by hand. Each rate applies from its effective date until a later rate for
+// the same pair takes over, and a pair's rate is used in reverse when only the opposite pair has been entered.
+package currency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/money"
+)
+
+// Default is the currency of customers and subscriptions that have not been given one, and the reporting currency
+// when none is configured.
+const Default = "NZD"
+
+// Supported lists the currencies customers can be billed in.
+var Supported = []string{"NZD", "AUD", "USD"}
+
+// ErrNoRate is returned when there is no exchange rate between two currencies on a date.
+var ErrNoRate = errors.New("no exchange rate")
+
+// Parse reads a currency code, ignoring case and surrounding space, and checks it is supported. Empty values are
+// the default currency.
+func Parse(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return Default, nil
+	}
+	if !slices.Contains(Supported, code) {
+		return "", fmt.Errorf("unsupported currency %q, expected one of %s", code, strings.Join(Supported, ", "))
+	}
+	return code, nil
+}
+
+// RateParams checks an exchange rate entered by hand, returning the parameters to save it with.
+func RateParams(from, to string, rate float64, effectiveFrom string) (db.CreateExchangeRateParams, error) {
+	var err error
+	params := db.CreateExchangeRateParams{Rate: rate}
+	if params.FromCurrency, err = Parse(from); err != nil {
+		return params, err
+	}
+	if params.ToCurrency, err = Parse(to); err != nil {
+		return params, err
+	}
+	if params.FromCurrency == params.ToCurrency {
+		return params, fmt.Errorf("an exchange rate needs two different currencies")
+	}
+	if rate <= 0 {
+		return params, fmt.Errorf("the rate must be greater than zero")
+	}
+	if params.EffectiveFrom, err = time.Parse("2006-01-02", effectiveFrom); err != nil {
+		return params, fmt.Errorf("invalid effective date %q, expected YYYY-MM-DD", effectiveFrom)
+	}
+	return params, nil
+}
+
+type pair struct{ from, to string }
+
+// Converter converts amounts into a single currency.
+type Converter struct {
+	to string
+	// rates holds the rates of each pair, latest first.
+	rates map[pair][]db.ExchangeRate
+}
+
+// NewConverter returns a converter into the given currency using rates.
+func NewConverter(rates []db.ExchangeRate, to string) *Converter {
+	c := &Converter{to: to, rates: make(map[pair][]db.ExchangeRate)}
+	for _, r := range rates {
+		p := pair{r.FromCurrency, r.ToCurrency}
+		c.rates[p] = append(c.rates[p], r)
+	}
+	for _, list := range c.rates {
+		sort.Slice(list, func(i, j int) bool { return list[i].EffectiveFrom.After(list[j].EffectiveFrom) })
+	}
+	return c
+}
+
+// Load returns a converter into the given currency using every saved exchange rate.
+func Load(ctx context.Context, queries *db.Queries, to string) (*Converter, error) {
+	rates, err := queries.ListExchangeRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading exchange rates: %w", err)
+	}
+	return NewConverter(rates, to), nil
+}
+
+// Currency returns the currency amounts are converted into.
+func (c *Converter) Currency() string {
+	return c.to
+}
+
+// Convert converts an amount into the converter's currency at the rate in effect on the given date, rounding it to
+// the nearest minor unit.
+func (c *Converter) Convert(amount money.Amount, from string, on time.Time) (money.Amount, error) {
+	if from == c.to {
+		return amount, nil
+	}
+	if rate, ok := c.rate(pair{from, c.to}, on); ok {
+		return amount.Mul(rate), nil
+	}
+	if rate, ok := c.rate(pair{c.to, from}, on); ok {
+		return amount.Mul(1 / rate), nil
+	}
+	return 0, fmt.Errorf("%w from %s to %s on %s", ErrNoRate, from, c.to, on.Format("2006-01-02"))
+}
+
+func (c *Converter) rate(p pair, on time.Time) (float64, bool) {
+	for _, r := range c.rates[p] {
+		if !r.EffectiveFrom.After(on) {
+			return r.Rate, true
+		}
+	}
+	return 0, false
+}
+
+// Total is a sum of amounts converted into a single currency. Amounts in currencies without a rate are left out and
+// their currencies listed in Missing, so that a partial total is not mistaken for a complete one.
+type Total struct {
+	Currency string
+	Amount   money.Amount
+	Missing  []string
+}
+
+// NewTotal starts a total in the converter's currency.
+func (c *Converter) NewTotal() Total {
+	return Total{Currency: c.to}
+}
+
+// Add converts an amount at the rate in effect on the given date and adds it to the total.
+func (c *Converter) Add(t *Total, amount money.Amount, from string, on time.Time) {
+	converted, err := c.Convert(amount, from, on)
+	if err != nil {
+		if !slices.Contains(t.Missing, from) {
+			t.Missing = append(t.Missing, from)
+		}
+		return
+	}
+	t.Amount += converted
+}
+
+// String formats the total, noting any currencies left out of it.
+func (t Total) String() string {
+	s := t.Money().String()
+	if len(t.Missing) > 0 {
+		s += " (" + t.Excluded() + ")"
+	}
+	return s
+}
+
+// Money returns the total as an amount in its currency.
+func (t Total) Money() money.Money {
+	return money.New(t.Amount, t.Currency)
+}
+
+// Excluded notes the currencies left out of the total, or is empty when none were.
+func (t Total) Excluded() string {
+	if len(t.Missing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("excluding %s without an exchange rate", strings.Join(t.Missing, ", "))
+}
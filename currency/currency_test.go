@@ -0,0 +1,109 @@
+package currency
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/money"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		code    string
+		want    string
+		wantErr bool
+	}{
+		{"", Default, false},
+		{" usd ", "USD", false},
+		{"AUD", "AUD", false},
+		{"GBP", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.code)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q) = %q, %v, want %q (error %v)", tt.code, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRateParams(t *testing.T) {
+	params, err := RateParams("usd", "", 1.6, "2026-01-31")
+	if err != nil || params.FromCurrency != "USD" || params.ToCurrency != Default || !params.EffectiveFrom.Equal(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected params %+v (%v)", params, err)
+	}
+	for _, bad := range []struct {
+		from, to, date string
+		rate           float64
+	}{
+		{"USD", "USD", "2026-01-31", 1},
+		{"USD", "NZD", "2026-01-31", 0},
+		{"USD", "NZD", "31/01/2026", 1},
+		{"GBP", "NZD", "2026-01-31", 1},
+	} {
+		if _, err := RateParams(bad.from, bad.to, bad.rate, bad.date); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	jan := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	conv := NewConverter([]db.ExchangeRate{
+		{FromCurrency: "USD", ToCurrency: "NZD", Rate: 1.5, EffectiveFrom: jan},
+		{FromCurrency: "USD", ToCurrency: "NZD", Rate: 2, EffectiveFrom: feb},
+		{FromCurrency: "NZD", ToCurrency: "AUD", Rate: 0.8, EffectiveFrom: jan},
+	}, "NZD")
+
+	tests := []struct {
+		name   string
+		amount money.Amount
+		from   string
+		on     time.Time
+		want   money.Amount
+	}{
+		{"same currency", 1000, "NZD", jan, 1000},
+		{"first rate", 1000, "USD", jan.AddDate(0, 0, 10), 1500},
+		{"later rate", 1000, "USD", feb, 2000},
+		{"inverse rate", 800, "AUD", feb, 1000},
+		{"rounded to the cent", 333, "USD", jan, 500},
+		{"halves rounded away from zero", 1, "USD", jan, 2},
+	}
+	for _, tt := range tests {
+		got, err := conv.Convert(tt.amount, tt.from, tt.on)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %v (%v), want %v", tt.name, got, err, tt.want)
+		}
+	}
+
+	if _, err := conv.Convert(10, "USD", jan.AddDate(0, 0, -1)); !errors.Is(err, ErrNoRate) {
+		t.Errorf("expected no rate before the first effective date, got %v", err)
+	}
+}
+
+func TestTotal(t *testing.T) {
+	conv := NewConverter([]db.ExchangeRate{
+		{FromCurrency: "USD", ToCurrency: "NZD", Rate: 1.5, EffectiveFrom: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}, "NZD")
+	on := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	total := conv.NewTotal()
+	conv.Add(&total, 1000, "NZD", on)
+	conv.Add(&total, 1000, "USD", on)
+	conv.Add(&total, 1000, "AUD", on)
+	conv.Add(&total, 500, "AUD", on)
+
+	if total.Amount != 2500 || len(total.Missing) != 1 || total.Missing[0] != "AUD" {
+		t.Fatalf("expected 25 leaving out AUD, got %+v", total)
+	}
+	if got, want := total.String(), "NZ$25.00 (excluding AUD without an exchange rate)"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	before := conv.NewTotal()
+	conv.Add(&before, 10, "USD", time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC))
+	if before.Amount != 0 || len(before.Missing) != 1 {
+		t.Errorf("expected no USD rate before it took effect, got %+v", before)
+	}
+}
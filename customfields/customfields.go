@@ -0,0 +1,235 @@
+// Package customfields lets admins define typed fields for customers and contacts, such as an ABN or an account
+// manager, and validates, stores and loads their values.
+package customfields
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// Kinds of record a custom field can belong to.
+const (
+	EntityCustomer = "customer"
+	EntityContact  = "contact"
+)
+
+// Entities lists the kinds of record that can have custom fields.
+var Entities = []string{EntityCustomer, EntityContact}
+
+// Field types.
+const (
+	TypeText   = "text"
+	TypeNumber = "number"
+	TypeDate   = "date"
+	TypeSelect = "select"
+	TypeURL    = "url"
+)
+
+// Types lists every field type, in the order they are offered.
+var Types = []string{TypeText, TypeNumber, TypeDate, TypeSelect, TypeURL}
+
+// dateFormat is the format date values are stored in, matching date inputs.
+const dateFormat = "2006-01-02"
+
+// inputPrefix prefixes the form input name of each custom field.
+const inputPrefix = "custom-"
+
+// FieldParams validates a new field definition. options lists the choices of a select field, one per line, and is
+// ignored for other types.
+func FieldParams(entity, name, fieldType, options string) (db.CreateCustomFieldParams, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return db.CreateCustomFieldParams{}, fmt.Errorf("a custom field needs a name")
+	}
+	if !slices.Contains(Entities, entity) {
+		return db.CreateCustomFieldParams{}, fmt.Errorf("invalid record type %q", entity)
+	}
+	if !slices.Contains(Types, fieldType) {
+		return db.CreateCustomFieldParams{}, fmt.Errorf("invalid field type %q", fieldType)
+	}
+
+	var choices []string
+	if fieldType == TypeSelect {
+		for _, line := range strings.Split(options, "\n") {
+			if choice := strings.TrimSpace(line); choice != "" && !slices.Contains(choices, choice) {
+				choices = append(choices, choice)
+			}
+		}
+		if len(choices) == 0 {
+			return db.CreateCustomFieldParams{}, fmt.Errorf("a select field needs at least one option")
+		}
+	}
+	return db.CreateCustomFieldParams{
+		Entity:    entity,
+		Name:      name,
+		FieldType: fieldType,
+		Options:   strings.Join(choices, "\n"),
+	}, nil
+}
+
+// Options returns the choices of a select field.
+func Options(f db.CustomField) []string {
+	if f.Options == "" {
+		return nil
+	}
+	return strings.Split(f.Options, "\n")
+}
+
+// InputName returns the name of the form input for a field.
+func InputName(f db.CustomField) string {
+	return inputPrefix + f.ID.String()
+}
+
+// Normalise validates a submitted value for a field, returning it in the form it is stored in. Empty values are
+// always valid and mean the field is unset.
+func Normalise(f db.CustomField, raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return "", nil
+	}
+	switch f.FieldType {
+	case TypeNumber:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a number", f.Name)
+		}
+		return strconv.FormatFloat(n, 'f', -1, 64), nil
+	case TypeDate:
+		d, err := time.Parse(dateFormat, value)
+		if err != nil {
+			return "", fmt.Errorf("%s must be a date", f.Name)
+		}
+		return d.Format(dateFormat), nil
+	case TypeSelect:
+		if !slices.Contains(Options(f), value) {
+			return "", fmt.Errorf("%s must be one of %s", f.Name, strings.Join(Options(f), ", "))
+		}
+	case TypeURL:
+		u, err := url.ParseRequestURI(value)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "", fmt.Errorf("%s must be a web address starting with http:// or https://", f.Name)
+		}
+	}
+	return value, nil
+}
+
+// Value is a field along with its value on a record, which is empty when unset.
+type Value struct {
+	Field db.CustomField
+	Value string
+}
+
+// Display formats the value for display.
+func (v Value) Display() string {
+	if v.Field.FieldType == TypeDate {
+		if d, err := time.Parse(dateFormat, v.Value); err == nil {
+			return d.Format("2 Jan 2006")
+		}
+	}
+	return v.Value
+}
+
+// Set holds stored values by record and then by field.
+type Set map[uuid.UUID]map[uuid.UUID]string
+
+// Index groups stored values by record.
+func Index(values []db.CustomFieldValue) Set {
+	set := make(Set)
+	for _, v := range values {
+		if set[v.RecordID] == nil {
+			set[v.RecordID] = make(map[uuid.UUID]string)
+		}
+		set[v.RecordID][v.FieldID] = v.Value
+	}
+	return set
+}
+
+// For returns the value of every field on a record, in field order.
+func (s Set) For(fields []db.CustomField, recordID uuid.UUID) []Value {
+	values := make([]Value, len(fields))
+	for i, f := range fields {
+		values[i] = Value{Field: f, Value: s[recordID][f.ID]}
+	}
+	return values
+}
+
+// Load returns the value of every field defined for entity on a record.
+func Load(ctx context.Context, queries *db.Queries, entity string, recordID uuid.UUID) ([]Value, error) {
+	fields, err := queries.ListCustomFields(ctx, entity)
+	if err != nil {
+		return nil, fmt.Errorf("error loading custom fields: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	stored, err := queries.ListCustomFieldValues(ctx, recordID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading custom field values: %w", err)
+	}
+	return Index(stored).For(fields, recordID), nil
+}
+
+// Parse validates the submitted value of every field that was present in a form, returning the normalised values by
+// field. Fields missing from the form are left out so that their stored values are kept.
+func Parse(fields []db.CustomField, form url.Values) (map[uuid.UUID]string, error) {
+	values := make(map[uuid.UUID]string)
+	var errs []error
+	for _, f := range fields {
+		if !form.Has(InputName(f)) {
+			continue
+		}
+		value, err := Normalise(f, form.Get(InputName(f)))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		values[f.ID] = value
+	}
+	return values, errors.Join(errs...)
+}
+
+// Save stores parsed values on a record, deleting those that were cleared, and returns the fields that changed.
+func Save(ctx context.Context, queries *db.Queries, fields []db.CustomField, recordID uuid.UUID, values map[uuid.UUID]string) ([]al.FieldChange, error) {
+	stored, err := queries.ListCustomFieldValues(ctx, recordID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading custom field values: %w", err)
+	}
+	current := Index(stored)[recordID]
+
+	var changes []al.FieldChange
+	for _, f := range fields {
+		value, ok := values[f.ID]
+		if !ok || value == current[f.ID] {
+			continue
+		}
+		if value == "" {
+			err = queries.DeleteCustomFieldValue(ctx, db.DeleteCustomFieldValueParams{FieldID: f.ID, RecordID: recordID})
+		} else {
+			err = queries.UpsertCustomFieldValue(ctx, db.UpsertCustomFieldValueParams{FieldID: f.ID, RecordID: recordID, Value: value})
+		}
+		if err != nil {
+			return changes, fmt.Errorf("error saving %s: %w", f.Name, err)
+		}
+		changes = append(changes, al.FieldChange{Field: f.Name, From: nullable(current[f.ID]), To: nullable(value)})
+	}
+	return changes, nil
+}
+
+// nullable reports unset values as nil, matching how the activity log records empty nullable columns.
+func nullable(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,169 @@
+package customfields
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/search"
+)
+
+func TestFieldParams(t *testing.T) {
+	params, err := FieldParams(EntityCustomer, "  Tier ", TypeSelect, "Gold\n\n Silver \nGold")
+	if err != nil || params.Name != "Tier" || params.Options != "Gold\nSilver" {
+		t.Errorf("expected a trimmed name and de-duplicated options, got %+v (%v)", params, err)
+	}
+	if params, _ := FieldParams(EntityContact, "Birthday", TypeDate, "ignored"); params.Options != "" {
+		t.Errorf("expected options to be ignored for a date field, got %q", params.Options)
+	}
+
+	tests := []struct {
+		name                         string
+		entity, field, kind, options string
+	}{
+		{"blank name", EntityCustomer, " ", TypeText, ""},
+		{"bad entity", "subscription", "Tier", TypeText, ""},
+		{"bad type", EntityCustomer, "Tier", "colour", ""},
+		{"select without options", EntityCustomer, "Tier", TypeSelect, "\n "},
+	}
+	for _, tt := range tests {
+		if _, err := FieldParams(tt.entity, tt.field, tt.kind, tt.options); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestNormalise(t *testing.T) {
+	tier := sqlc.CustomField{Name: "Tier", FieldType: TypeSelect, Options: "Gold\nSilver"}
+	tests := []struct {
+		field   sqlc.CustomField
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{sqlc.CustomField{FieldType: TypeText}, "  ABN 123 ", "ABN 123", false},
+		{sqlc.CustomField{FieldType: TypeNumber}, "", "", false},
+		{sqlc.CustomField{FieldType: TypeNumber}, "012.50", "12.5", false},
+		{sqlc.CustomField{FieldType: TypeNumber}, "ten", "", true},
+		{sqlc.CustomField{FieldType: TypeDate}, "2024-02-29", "2024-02-29", false},
+		{sqlc.CustomField{FieldType: TypeDate}, "29/02/2024", "", true},
+		{tier, "Gold", "Gold", false},
+		{tier, "Bronze", "", true},
+		{sqlc.CustomField{FieldType: TypeURL}, "https://example.com/a", "https://example.com/a", false},
+		{sqlc.CustomField{FieldType: TypeURL}, "example.com", "", true},
+		{sqlc.CustomField{FieldType: TypeURL}, "javascript:alert(1)", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Normalise(tt.field, tt.raw)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Normalise(%s, %q) = %q, %v; want %q, error %v", tt.field.FieldType, tt.raw, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	number := sqlc.CustomField{ID: uuid.New(), Name: "Seats", FieldType: TypeNumber}
+	text := sqlc.CustomField{ID: uuid.New(), Name: "ABN", FieldType: TypeText}
+	date := sqlc.CustomField{ID: uuid.New(), Name: "Renewal", FieldType: TypeDate}
+	fields := []sqlc.CustomField{number, text, date}
+
+	values, err := Parse(fields, url.Values{InputName(number): {"5"}, InputName(text): {""}})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(values) != 2 || values[number.ID] != "5" || values[text.ID] != "" {
+		t.Errorf("expected values for the submitted fields only, got %v", values)
+	}
+
+	_, err = Parse(fields, url.Values{InputName(number): {"five"}, InputName(date): {"soon"}})
+	if err == nil || !strings.Contains(err.Error(), "Seats") || !strings.Contains(err.Error(), "Renewal") {
+		t.Errorf("expected an error naming both invalid fields, got %v", err)
+	}
+}
+
+func TestSave_ValuesAreSearchable_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// a unique word per run keeps rows from earlier runs against the same database out of the way
+	word := "zq" + strings.ReplaceAll(uuid.NewString(), "-", "")[:10]
+
+	params, err := FieldParams(EntityCustomer, "Field "+word, TypeText, "")
+	if err != nil {
+		t.Fatalf("FieldParams failed: %v", err)
+	}
+	field, err := queries.CreateCustomField(ctx, params)
+	if err != nil {
+		t.Fatalf("CreateCustomField failed: %v", err)
+	}
+	defer queries.DeleteCustomField(ctx, field.ID)
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "Custom Fields", Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+
+	fields := []sqlc.CustomField{field}
+	changes, err := Save(ctx, queries, fields, customer.ID, map[uuid.UUID]string{field.ID: "Managed by " + word})
+	if err != nil || len(changes) != 1 || changes[0].From != nil {
+		t.Fatalf("expected one change from unset, got %+v (%v)", changes, err)
+	}
+	values, err := Load(ctx, queries, EntityCustomer, customer.ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if v := find(values, field.ID); v != "Managed by "+word {
+		t.Errorf("expected the saved value to load, got %q", v)
+	}
+	if ids := searchIDs(t, queries, word); len(ids) != 1 || ids[0] != customer.ID {
+		t.Errorf("expected the customer to be found by its custom field, got %v", ids)
+	}
+
+	if changes, _ := Save(ctx, queries, fields, customer.ID, map[uuid.UUID]string{field.ID: "Managed by " + word}); len(changes) != 0 {
+		t.Errorf("expected saving the same value to change nothing, got %+v", changes)
+	}
+	if changes, _ := Save(ctx, queries, fields, customer.ID, map[uuid.UUID]string{field.ID: ""}); len(changes) != 1 || changes[0].To != nil {
+		t.Errorf("expected clearing the value to be recorded as unset, got %+v", changes)
+	}
+	if ids := searchIDs(t, queries, word); len(ids) != 0 {
+		t.Errorf("expected a cleared value to leave the index, got %v", ids)
+	}
+}
+
+func find(values []Value, fieldID uuid.UUID) string {
+	for _, v := range values {
+		if v.Field.ID == fieldID {
+			return v.Value
+		}
+	}
+	return ""
+}
+
+func searchIDs(t *testing.T, queries *sqlc.Queries, input string) []uuid.UUID {
+	t.Helper()
+	results, err := search.Run(context.Background(), queries, search.Parse(input), 20)
+	if err != nil {
+		t.Fatalf("search.Run failed: %v", err)
+	}
+	var ids []uuid.UUID
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func setupTestDB(t *testing.T) (*sqlc.Queries, func()) {
+	os.MkdirAll("data", 0755)
+	dbConn, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	cleanup := func() { dbConn.Close() }
+	return queries, cleanup
+}
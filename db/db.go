@@ -132,7 +132,8 @@ func applySingleMigration(
 	return nil
 }
 
-// splitSQLStatements splits SQL migration files into individual statements by semicolon.
+// splitSQLStatements splits SQL migration files into individual statements by semicolon. Trigger bodies contain
+// semicolons of their own, so a CREATE TRIGGER statement only ends at the semicolon following its END.
 func splitSQLStatements(sql string) []string {
 	stmts := []string{}
 	curr := ""
@@ -141,7 +142,7 @@ func splitSQLStatements(sql string) []string {
 		if r == '\'' {
 			inString = !inString
 		}
-		if r == ';' && !inString {
+		if r == ';' && !inString && !inTriggerBody(curr) {
 			stmts = append(stmts, curr)
 			curr = ""
 		} else {
@@ -153,3 +154,18 @@ func splitSQLStatements(sql string) []string {
 	}
 	return stmts
 }
+
+// inTriggerBody reports whether a partial statement is a CREATE TRIGGER whose body has not yet been closed by END.
+// Comment lines are skipped so a statement can be preceded by a comment.
+func inTriggerBody(stmt string) bool {
+	var fields []string
+	for _, line := range strings.Split(strings.ToUpper(stmt), "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			fields = append(fields, strings.Fields(line)...)
+		}
+	}
+	if len(fields) < 2 || fields[0] != "CREATE" || fields[1] != "TRIGGER" {
+		return false
+	}
+	return fields[len(fields)-1] != "END"
+}
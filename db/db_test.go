@@ -2,8 +2,13 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"os"
+	"strings"
 	"testing"
+
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
 )
 
 func TestInitialiseDB(t *testing.T) {
@@ -38,3 +43,85 @@ func TestSplitSQLStatements(t *testing.T) {
 		t.Fatalf("expected 2 statements, got %d", len(stmts))
 	}
 }
+
+func TestUpdateCustomer_RejectsStaleVersion(t *testing.T) {
+	os.MkdirAll("data", 0755)
+	db, queries, err := InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "Versioned", Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	loaded := customer.Version
+
+	updated, err := queries.UpdateCustomer(ctx, sqlc.UpdateCustomerParams{ID: customer.ID, Version: loaded, Name: "First Save", Status: "active"})
+	if err != nil {
+		t.Fatalf("UpdateCustomer failed: %v", err)
+	}
+	if updated.Version != loaded+1 {
+		t.Errorf("expected version %d after update, got %d", loaded+1, updated.Version)
+	}
+
+	// a second edit made from the same loaded version is stale
+	_, err = queries.UpdateCustomer(ctx, sqlc.UpdateCustomerParams{ID: customer.ID, Version: loaded, Name: "Second Save", Status: "active"})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("expected stale update to be rejected, got %v", err)
+	}
+	current, err := queries.GetCustomer(ctx, customer.ID)
+	if err != nil {
+		t.Fatalf("GetCustomer failed: %v", err)
+	}
+	if current.Name != "First Save" {
+		t.Errorf("expected the first save to be kept, got %q", current.Name)
+	}
+}
+
+func TestUnsetOtherPrimaryContacts_OnlyBumpsPrimaries(t *testing.T) {
+	os.MkdirAll("data", 0755)
+	db, queries, err := InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "Primary Contacts", Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	primary, _ := queries.CreateContact(ctx, sqlc.CreateContactParams{CustomerID: customer.ID, Name: "Primary", IsPrimary: sql.NullBool{Bool: true, Valid: true}})
+	other, _ := queries.CreateContact(ctx, sqlc.CreateContactParams{CustomerID: customer.ID, Name: "Other"})
+	replacement, _ := queries.CreateContact(ctx, sqlc.CreateContactParams{CustomerID: customer.ID, Name: "Replacement", IsPrimary: sql.NullBool{Bool: true, Valid: true}})
+
+	if err := queries.UnsetOtherPrimaryContacts(ctx, sqlc.UnsetOtherPrimaryContactsParams{CustomerID: customer.ID, ID: replacement.ID}); err != nil {
+		t.Fatalf("UnsetOtherPrimaryContacts failed: %v", err)
+	}
+	if got, _ := queries.GetContact(ctx, primary.ID); got.IsPrimary.Bool || got.Version != primary.Version+1 {
+		t.Errorf("expected the old primary to be unset with a new version, got %+v", got)
+	}
+	if got, _ := queries.GetContact(ctx, other.ID); got.Version != other.Version {
+		t.Errorf("expected a non-primary contact to keep its version, got %d", got.Version)
+	}
+}
+
+func TestSplitSQLStatements_Triggers(t *testing.T) {
+	sql := `-- keep names indexed
+CREATE TRIGGER t AFTER INSERT ON a
+BEGIN
+    INSERT INTO b VALUES (new.id);
+    DELETE FROM c WHERE id = new.id;
+END;
+INSERT INTO a VALUES ('x;y');`
+	stmts := splitSQLStatements(sql)
+	if len(stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %d: %q", len(stmts), stmts)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(stmts[0]), "END") {
+		t.Errorf("expected the trigger to end with its END, got %q", stmts[0])
+	}
+}
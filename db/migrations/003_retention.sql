@@ -0,0 +1,27 @@
+-- Rebuild activity_log without ON DELETE CASCADE so that purging a customer
+-- keeps its history. anonymised_at records when retention scrubbed an entry.
+CREATE TABLE IF NOT EXISTS activity_log_new (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    customer_id UUID NOT NULL,
+    activity_type TEXT NOT NULL,
+    action TEXT NOT NULL,
+    description TEXT NOT NULL,
+    created_at DATETIME DEFAULT (datetime('now')),
+    anonymised_at DATETIME DEFAULT NULL
+);
+
+INSERT INTO activity_log_new (id, customer_id, activity_type, action, description, created_at)
+SELECT id, customer_id, activity_type, action, description, created_at FROM activity_log;
+
+DROP TABLE activity_log;
+
+ALTER TABLE activity_log_new RENAME TO activity_log;
+
+CREATE INDEX IF NOT EXISTS idx_activity_log_created_at ON activity_log (created_at);
+
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NOT NULL,
+    report TEXT NOT NULL -- JSON encoded retention.Report
+);
@@ -0,0 +1,7 @@
+-- actor is the GitHub login of the user who made the change (NULL for system changes)
+ALTER TABLE activity_log ADD COLUMN actor TEXT DEFAULT NULL;
+
+-- changes is a JSON array of {field, from, to} objects describing an update
+ALTER TABLE activity_log ADD COLUMN changes TEXT DEFAULT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_activity_log_customer_id ON activity_log (customer_id, created_at);
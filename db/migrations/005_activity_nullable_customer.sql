@@ -0,0 +1,24 @@
+-- Rebuild activity_log with a nullable customer_id so that events which are not
+-- tied to a customer (such as user sign-ins) can be recorded.
+CREATE TABLE IF NOT EXISTS activity_log_new (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    customer_id UUID DEFAULT NULL,
+    activity_type TEXT NOT NULL,
+    action TEXT NOT NULL,
+    description TEXT NOT NULL,
+    created_at DATETIME DEFAULT (datetime('now')),
+    anonymised_at DATETIME DEFAULT NULL,
+    actor TEXT DEFAULT NULL,
+    changes TEXT DEFAULT NULL
+);
+
+INSERT INTO activity_log_new (id, customer_id, activity_type, action, description, created_at, anonymised_at, actor, changes)
+SELECT id, customer_id, activity_type, action, description, created_at, anonymised_at, actor, changes FROM activity_log;
+
+DROP TABLE activity_log;
+
+ALTER TABLE activity_log_new RENAME TO activity_log;
+
+CREATE INDEX IF NOT EXISTS idx_activity_log_created_at ON activity_log (created_at);
+
+CREATE INDEX IF NOT EXISTS idx_activity_log_customer_id ON activity_log (customer_id, created_at);
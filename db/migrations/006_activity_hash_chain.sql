@@ -0,0 +1,13 @@
+-- Hash chain for tamper evidence. seq orders the chain, prev_hash links each entry to the one
+-- before it and hash covers the entry metadata plus content_hash. content_hash is a digest of the
+-- description and changes, kept separately so that retention can anonymise the content without
+-- breaking the chain. Entries are sealed by the application straight after they are inserted.
+ALTER TABLE activity_log ADD COLUMN seq INTEGER DEFAULT NULL;
+
+ALTER TABLE activity_log ADD COLUMN prev_hash TEXT DEFAULT NULL;
+
+ALTER TABLE activity_log ADD COLUMN content_hash TEXT DEFAULT NULL;
+
+ALTER TABLE activity_log ADD COLUMN hash TEXT DEFAULT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_activity_log_seq ON activity_log (seq);
@@ -0,0 +1,6 @@
+-- version is incremented on every form update so that stale edits can be detected and rejected
+ALTER TABLE customers ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE contacts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE subscriptions ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,90 @@
+-- Full-text index over customers, contacts and subscriptions used by the global search. Each row
+-- points back at the record it was built from. title holds the record name (or description) and
+-- body the other searchable fields. status is kept for qualifiers and is NULL for contacts. The
+-- triggers below keep the index in sync, dropping rows for records that are soft deleted.
+CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+    record_type UNINDEXED,
+    record_id UNINDEXED,
+    customer_id UNINDEXED,
+    status UNINDEXED,
+    title,
+    body,
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+-- one demo contact was seeded with an ID that is not a valid UUID, which cannot be scanned and
+-- would break any search that matched it
+UPDATE contacts SET id = 'e5f6a7b8-eeee-4eee-8eee-eeeeeeeeeee0' WHERE id = 'e5f6a7b8-eeee-4eee-8eee-eeeeeeeeeeeg';
+
+CREATE TRIGGER IF NOT EXISTS customers_search_insert AFTER INSERT ON customers
+WHEN new.deleted_at IS NULL
+BEGIN
+    INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+    VALUES ('customer', new.id, new.id, new.status, new.name, coalesce(new.email, '') || ' ' || coalesce(new.notes, ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS customers_search_update AFTER UPDATE ON customers
+BEGIN
+    DELETE FROM search_index WHERE record_type = 'customer' AND record_id = old.id;
+    INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+    SELECT 'customer', new.id, new.id, new.status, new.name, coalesce(new.email, '') || ' ' || coalesce(new.notes, '')
+    WHERE new.deleted_at IS NULL;
+END;
+
+CREATE TRIGGER IF NOT EXISTS customers_search_delete AFTER DELETE ON customers
+BEGIN
+    DELETE FROM search_index WHERE record_type = 'customer' AND record_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS contacts_search_insert AFTER INSERT ON contacts
+WHEN new.deleted_at IS NULL
+BEGIN
+    INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+    VALUES ('contact', new.id, new.customer_id, NULL, new.name, coalesce(new.role, '') || ' ' || coalesce(new.email, '') || ' ' || coalesce(new.phone, ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS contacts_search_update AFTER UPDATE ON contacts
+BEGIN
+    DELETE FROM search_index WHERE record_type = 'contact' AND record_id = old.id;
+    INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+    SELECT 'contact', new.id, new.customer_id, NULL, new.name, coalesce(new.role, '') || ' ' || coalesce(new.email, '') || ' ' || coalesce(new.phone, '')
+    WHERE new.deleted_at IS NULL;
+END;
+
+CREATE TRIGGER IF NOT EXISTS contacts_search_delete AFTER DELETE ON contacts
+BEGIN
+    DELETE FROM search_index WHERE record_type = 'contact' AND record_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS subscriptions_search_insert AFTER INSERT ON subscriptions
+WHEN new.deleted_at IS NULL
+BEGIN
+    INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+    VALUES ('subscription', new.id, new.customer_id, new.status, new.description, '');
+END;
+
+CREATE TRIGGER IF NOT EXISTS subscriptions_search_update AFTER UPDATE ON subscriptions
+BEGIN
+    DELETE FROM search_index WHERE record_type = 'subscription' AND record_id = old.id;
+    INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+    SELECT 'subscription', new.id, new.customer_id, new.status, new.description, ''
+    WHERE new.deleted_at IS NULL;
+END;
+
+CREATE TRIGGER IF NOT EXISTS subscriptions_search_delete AFTER DELETE ON subscriptions
+BEGIN
+    DELETE FROM search_index WHERE record_type = 'subscription' AND record_id = old.id;
+END;
+
+-- index the records that already exist
+INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+SELECT 'customer', id, id, status, name, coalesce(email, '') || ' ' || coalesce(notes, '')
+FROM customers WHERE deleted_at IS NULL;
+
+INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+SELECT 'contact', id, customer_id, NULL, name, coalesce(role, '') || ' ' || coalesce(email, '') || ' ' || coalesce(phone, '')
+FROM contacts WHERE deleted_at IS NULL;
+
+INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+SELECT 'subscription', id, customer_id, status, description, ''
+FROM subscriptions WHERE deleted_at IS NULL;
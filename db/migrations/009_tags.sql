@@ -0,0 +1,38 @@
+-- Tags classify customers beyond their status. colour is one of the named colours offered in the
+-- settings page, rendered by the views.
+CREATE TABLE IF NOT EXISTS tags (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    colour TEXT NOT NULL DEFAULT 'gray',
+    created_at DATETIME DEFAULT (datetime('now'))
+);
+
+CREATE TABLE IF NOT EXISTS customer_tags (
+    customer_id UUID NOT NULL,
+    tag_id UUID NOT NULL,
+    PRIMARY KEY (customer_id, tag_id),
+    FOREIGN KEY (customer_id) REFERENCES customers(id),
+    FOREIGN KEY (tag_id) REFERENCES tags(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_tags_tag_id ON customer_tags (tag_id);
+
+-- customers are only hard deleted by retention, which should not leave tag assignments behind
+CREATE TRIGGER IF NOT EXISTS customers_tags_delete AFTER DELETE ON customers
+BEGIN
+    DELETE FROM customer_tags WHERE customer_id = old.id;
+END;
+
+-- Saved filters are named combinations of the customer list filters. Empty conditions are NULL
+-- and match every customer. created_from and created_to are inclusive dates.
+CREATE TABLE IF NOT EXISTS saved_filters (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    tag_id UUID DEFAULT NULL,
+    status TEXT DEFAULT NULL,
+    created_from TEXT DEFAULT NULL,
+    created_to TEXT DEFAULT NULL,
+    sort TEXT NOT NULL DEFAULT 'newest',
+    created_at DATETIME DEFAULT (datetime('now')),
+    FOREIGN KEY (tag_id) REFERENCES tags(id)
+);
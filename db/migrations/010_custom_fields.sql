@@ -0,0 +1,130 @@
+-- Custom fields are admin defined, typed fields for customers or contacts. entity is the kind of
+-- record the field belongs to and field_type is one of text, number, date, select or url. options
+-- holds the choices for select fields, one per line.
+CREATE TABLE IF NOT EXISTS custom_fields (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    entity TEXT NOT NULL,
+    name TEXT NOT NULL,
+    field_type TEXT NOT NULL DEFAULT 'text',
+    options TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT (datetime('now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_fields_entity_name ON custom_fields (entity, name COLLATE NOCASE);
+
+-- Values are stored as normalised text, one row per field per record. Empty values are not stored.
+CREATE TABLE IF NOT EXISTS custom_field_values (
+    field_id UUID NOT NULL,
+    record_id UUID NOT NULL,
+    value TEXT NOT NULL,
+    updated_at DATETIME DEFAULT (datetime('now')),
+    PRIMARY KEY (field_id, record_id),
+    FOREIGN KEY (field_id) REFERENCES custom_fields(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_custom_field_values_record_id ON custom_field_values (record_id);
+
+CREATE TRIGGER IF NOT EXISTS custom_fields_values_delete AFTER DELETE ON custom_fields
+BEGIN
+    DELETE FROM custom_field_values WHERE field_id = old.id;
+END;
+
+-- records are only hard deleted by retention, which should not leave values behind
+CREATE TRIGGER IF NOT EXISTS customers_custom_fields_delete AFTER DELETE ON customers
+BEGIN
+    DELETE FROM custom_field_values WHERE record_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS contacts_custom_fields_delete AFTER DELETE ON contacts
+BEGIN
+    DELETE FROM custom_field_values WHERE record_id = old.id;
+END;
+
+-- Custom values are searchable, so the customer and contact search rows are rebuilt with every
+-- value for the record appended to the body.
+DROP TRIGGER IF EXISTS customers_search_insert;
+DROP TRIGGER IF EXISTS customers_search_update;
+DROP TRIGGER IF EXISTS contacts_search_insert;
+DROP TRIGGER IF EXISTS contacts_search_update;
+
+CREATE TRIGGER IF NOT EXISTS customers_search_insert AFTER INSERT ON customers
+WHEN new.deleted_at IS NULL
+BEGIN
+    INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+    VALUES ('customer', new.id, new.id, new.status, new.name,
+        coalesce(new.email, '') || ' ' || coalesce(new.notes, '') || ' ' ||
+        coalesce((SELECT group_concat(v.value, ' ') FROM custom_field_values v WHERE v.record_id = new.id), ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS customers_search_update AFTER UPDATE ON customers
+BEGIN
+    DELETE FROM search_index WHERE record_type = 'customer' AND record_id = old.id;
+    INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+    SELECT 'customer', new.id, new.id, new.status, new.name,
+        coalesce(new.email, '') || ' ' || coalesce(new.notes, '') || ' ' ||
+        coalesce((SELECT group_concat(v.value, ' ') FROM custom_field_values v WHERE v.record_id = new.id), '')
+    WHERE new.deleted_at IS NULL;
+END;
+
+CREATE TRIGGER IF NOT EXISTS contacts_search_insert AFTER INSERT ON contacts
+WHEN new.deleted_at IS NULL
+BEGIN
+    INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+    VALUES ('contact', new.id, new.customer_id, NULL, new.name,
+        coalesce(new.role, '') || ' ' || coalesce(new.email, '') || ' ' || coalesce(new.phone, '') || ' ' ||
+        coalesce((SELECT group_concat(v.value, ' ') FROM custom_field_values v WHERE v.record_id = new.id), ''));
+END;
+
+CREATE TRIGGER IF NOT EXISTS contacts_search_update AFTER UPDATE ON contacts
+BEGIN
+    DELETE FROM search_index WHERE record_type = 'contact' AND record_id = old.id;
+    INSERT INTO search_index (record_type, record_id, customer_id, status, title, body)
+    SELECT 'contact', new.id, new.customer_id, NULL, new.name,
+        coalesce(new.role, '') || ' ' || coalesce(new.email, '') || ' ' || coalesce(new.phone, '') || ' ' ||
+        coalesce((SELECT group_concat(v.value, ' ') FROM custom_field_values v WHERE v.record_id = new.id), '')
+    WHERE new.deleted_at IS NULL;
+END;
+
+-- Changing a value rebuilds the search row of the customer or contact it belongs to. Only one of
+-- the two updates matches, since a record ID is either a customer or a contact.
+CREATE TRIGGER IF NOT EXISTS custom_field_values_search_insert AFTER INSERT ON custom_field_values
+BEGIN
+    UPDATE search_index SET body = (
+        SELECT coalesce(c.email, '') || ' ' || coalesce(c.notes, '') || ' ' ||
+            coalesce((SELECT group_concat(v.value, ' ') FROM custom_field_values v WHERE v.record_id = c.id), '')
+        FROM customers c WHERE c.id = new.record_id
+    ) WHERE record_type = 'customer' AND record_id = new.record_id;
+    UPDATE search_index SET body = (
+        SELECT coalesce(c.role, '') || ' ' || coalesce(c.email, '') || ' ' || coalesce(c.phone, '') || ' ' ||
+            coalesce((SELECT group_concat(v.value, ' ') FROM custom_field_values v WHERE v.record_id = c.id), '')
+        FROM contacts c WHERE c.id = new.record_id
+    ) WHERE record_type = 'contact' AND record_id = new.record_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS custom_field_values_search_update AFTER UPDATE ON custom_field_values
+BEGIN
+    UPDATE search_index SET body = (
+        SELECT coalesce(c.email, '') || ' ' || coalesce(c.notes, '') || ' ' ||
+            coalesce((SELECT group_concat(v.value, ' ') FROM custom_field_values v WHERE v.record_id = c.id), '')
+        FROM customers c WHERE c.id = new.record_id
+    ) WHERE record_type = 'customer' AND record_id = new.record_id;
+    UPDATE search_index SET body = (
+        SELECT coalesce(c.role, '') || ' ' || coalesce(c.email, '') || ' ' || coalesce(c.phone, '') || ' ' ||
+            coalesce((SELECT group_concat(v.value, ' ') FROM custom_field_values v WHERE v.record_id = c.id), '')
+        FROM contacts c WHERE c.id = new.record_id
+    ) WHERE record_type = 'contact' AND record_id = new.record_id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS custom_field_values_search_delete AFTER DELETE ON custom_field_values
+BEGIN
+    UPDATE search_index SET body = (
+        SELECT coalesce(c.email, '') || ' ' || coalesce(c.notes, '') || ' ' ||
+            coalesce((SELECT group_concat(v.value, ' ') FROM custom_field_values v WHERE v.record_id = c.id), '')
+        FROM customers c WHERE c.id = old.record_id
+    ) WHERE record_type = 'customer' AND record_id = old.record_id;
+    UPDATE search_index SET body = (
+        SELECT coalesce(c.role, '') || ' ' || coalesce(c.email, '') || ' ' || coalesce(c.phone, '') || ' ' ||
+            coalesce((SELECT group_concat(v.value, ' ') FROM custom_field_values v WHERE v.record_id = c.id), '')
+        FROM contacts c WHERE c.id = old.record_id
+    ) WHERE record_type = 'contact' AND record_id = old.record_id;
+END;
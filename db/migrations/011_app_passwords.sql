@@ -0,0 +1,13 @@
+-- App passwords let a user sign in to CardDAV from phones and mail clients, which cannot use the
+-- GitHub sign-in. Only a SHA-256 hash of each password is kept, as they are shown once when created.
+CREATE TABLE IF NOT EXISTS app_passwords (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    user_id UUID NOT NULL,
+    name TEXT NOT NULL,
+    password_hash TEXT NOT NULL UNIQUE,
+    created_at DATETIME DEFAULT (datetime('now')),
+    last_used_at DATETIME DEFAULT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_app_passwords_user_id ON app_passwords (user_id);
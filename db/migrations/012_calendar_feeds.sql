@@ -0,0 +1,9 @@
+-- Calendar feeds let calendar apps, which cannot sign in, subscribe to billing dates. Each user has
+-- one secret token, used in the feed URLs. Replacing or removing it stops old URLs from working.
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_id UUID PRIMARY KEY,
+    token TEXT NOT NULL UNIQUE,
+    created_at DATETIME DEFAULT (datetime('now')),
+    last_used_at DATETIME DEFAULT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
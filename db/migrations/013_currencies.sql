@@ -0,0 +1,16 @@
+-- Subscriptions are billed in a currency, defaulting to that of their customer. Amounts already recorded
+-- were all in New Zealand dollars.
+ALTER TABLE customers ADD COLUMN currency TEXT NOT NULL DEFAULT 'NZD';
+ALTER TABLE subscriptions ADD COLUMN currency TEXT NOT NULL DEFAULT 'NZD';
+
+-- Exchange rates are maintained by hand. A rate converts one unit of from_currency into to_currency
+-- and applies from its effective date until a later rate for the same pair takes over.
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    from_currency TEXT NOT NULL,
+    to_currency TEXT NOT NULL,
+    rate REAL NOT NULL CHECK (rate > 0),
+    effective_from DATETIME NOT NULL,
+    created_at DATETIME DEFAULT (datetime('now')),
+    UNIQUE (from_currency, to_currency, effective_from)
+);
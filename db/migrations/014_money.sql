@@ -0,0 +1,3 @@
+-- Subscription amounts were stored in dollars and read back as floating point numbers, which do not add up exactly.
+-- They are now stored as a whole number of cents.
+UPDATE subscriptions SET amount = CAST(ROUND(amount * 100) AS INTEGER);
@@ -0,0 +1,17 @@
+-- Subscriptions billed every few months on a custom cadence record how many months apart their billing dates are.
+ALTER TABLE subscriptions ADD COLUMN billing_interval INTEGER DEFAULT NULL CHECK (billing_interval BETWEEN 1 AND 120);
+
+-- Month based cadences bill on the anchor day of the month, or on the day of the start date when there is none. Months
+-- shorter than the anchor day are billed on their last day, so an anchor day of 31 bills at the end of every month.
+ALTER TABLE subscriptions ADD COLUMN billing_anchor_day INTEGER DEFAULT NULL CHECK (billing_anchor_day BETWEEN 1 AND 31);
+
+-- Billing cadences used to be free text, and anything other than yearly was billed monthly. Known spellings are mapped
+-- onto the cadences the schedule engine understands, and whatever is left keeps being billed monthly.
+UPDATE subscriptions SET billing_cadence = lower(trim(billing_cadence));
+UPDATE subscriptions SET billing_cadence = 'weekly' WHERE billing_cadence IN ('week', 'every week');
+UPDATE subscriptions SET billing_cadence = 'monthly' WHERE billing_cadence IN ('month', 'every month');
+UPDATE subscriptions SET billing_cadence = 'quarterly' WHERE billing_cadence IN ('quarter', 'every quarter', '3 monthly', 'three monthly');
+UPDATE subscriptions SET billing_cadence = 'semiannual'
+WHERE billing_cadence IN ('semi-annual', 'semi annual', 'semiannually', 'semi-annually', 'biannual', 'biannually', 'half-yearly', 'half yearly', '6 monthly', 'six monthly');
+UPDATE subscriptions SET billing_cadence = 'yearly' WHERE billing_cadence IN ('year', 'annual', 'annually', 'every year');
+UPDATE subscriptions SET billing_cadence = 'monthly' WHERE billing_cadence NOT IN ('weekly', 'monthly', 'quarterly', 'semiannual', 'yearly');
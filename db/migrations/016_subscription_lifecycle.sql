@@ -0,0 +1,36 @@
+-- Subscription status changes, both made and scheduled. A change takes effect on its effective date,
+-- straight away when that has already come or otherwise when the lifecycle job applies it. Applied
+-- changes are the status history of a subscription. Changes that could no longer be made when they
+-- came due are kept with the reason they were skipped.
+CREATE TABLE IF NOT EXISTS subscription_status_changes (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    subscription_id UUID NOT NULL,
+    from_status TEXT DEFAULT NULL,
+    to_status TEXT NOT NULL CHECK (to_status IN ('active', 'paused', 'cancelled')),
+    effective_date DATETIME NOT NULL,
+    note TEXT DEFAULT NULL,
+    created_by TEXT DEFAULT NULL,
+    created_at DATETIME DEFAULT (datetime('now')),
+    applied_at DATETIME DEFAULT NULL,
+    skipped_reason TEXT DEFAULT NULL,
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_subscription_status_changes_subscription ON subscription_status_changes(subscription_id);
+CREATE INDEX IF NOT EXISTS idx_subscription_status_changes_pending ON subscription_status_changes(effective_date) WHERE applied_at IS NULL;
+
+-- Status was free text. Known spellings are mapped onto the three lifecycle statuses.
+UPDATE subscriptions SET status = lower(trim(status));
+UPDATE subscriptions SET status = 'active' WHERE status IN ('enabled', 'live', 'current');
+UPDATE subscriptions SET status = 'paused' WHERE status IN ('on hold', 'on-hold', 'suspended', 'inactive');
+UPDATE subscriptions SET status = 'cancelled' WHERE status IN ('canceled', 'terminated', 'ended', 'expired');
+
+-- The history of existing subscriptions starts with the status they have now.
+INSERT INTO subscription_status_changes (subscription_id, to_status, effective_date, applied_at)
+SELECT id, status, start_date, datetime('now') FROM subscriptions WHERE status IN ('active', 'paused', 'cancelled');
+
+-- Any other status is paused rather than guessed at, so that it is not billed until someone looks at it. Its history
+-- starts with the status it had and a note saying why it was paused.
+INSERT INTO subscription_status_changes (subscription_id, from_status, to_status, effective_date, note, applied_at)
+SELECT id, status, 'paused', start_date, 'Paused on upgrade because the status "' || status || '" was not recognised', datetime('now')
+FROM subscriptions WHERE status NOT IN ('active', 'paused', 'cancelled');
+UPDATE subscriptions SET status = 'paused' WHERE status NOT IN ('active', 'paused', 'cancelled');
@@ -0,0 +1,39 @@
+-- Price periods of subscriptions. An amendment to the price starts a new period on the day it takes
+-- effect, which lasts until the next one starts, and the amount on the subscription is the price of
+-- its current period.
+CREATE TABLE IF NOT EXISTS subscription_prices (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    subscription_id UUID NOT NULL,
+    amount INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    effective_from DATETIME NOT NULL,
+    note TEXT DEFAULT NULL,
+    created_by TEXT DEFAULT NULL,
+    created_at DATETIME DEFAULT (datetime('now')),
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_subscription_prices_subscription ON subscription_prices(subscription_id);
+
+-- Prorated charges, which are positive, and credits, which are negative, for amendments that took
+-- effect part way through a billing period that was already billed at the old price. They are owed
+-- on the next invoice, and invoiced_at is set once an invoice includes them.
+CREATE TABLE IF NOT EXISTS subscription_adjustments (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    subscription_id UUID NOT NULL,
+    price_id UUID NOT NULL,
+    amount INTEGER NOT NULL,
+    currency TEXT NOT NULL,
+    description TEXT NOT NULL,
+    period_start DATETIME NOT NULL,
+    period_end DATETIME NOT NULL,
+    invoiced_at DATETIME DEFAULT NULL,
+    created_at DATETIME DEFAULT (datetime('now')),
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE,
+    FOREIGN KEY (price_id) REFERENCES subscription_prices(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_subscription_adjustments_subscription ON subscription_adjustments(subscription_id);
+CREATE INDEX IF NOT EXISTS idx_subscription_adjustments_uninvoiced ON subscription_adjustments(subscription_id) WHERE invoiced_at IS NULL;
+
+-- The price history of existing subscriptions starts with the price they have now.
+INSERT INTO subscription_prices (subscription_id, amount, currency, effective_from)
+SELECT id, amount, currency, start_date FROM subscriptions;
@@ -0,0 +1,10 @@
+-- Subscriptions are priced per seat. The amount is the quantity times the unit price, and is kept
+-- on the subscription so that totals and reports read it as before.
+ALTER TABLE subscriptions ADD COLUMN quantity INTEGER NOT NULL DEFAULT 1 CHECK (quantity >= 1);
+ALTER TABLE subscriptions ADD COLUMN unit_price INTEGER NOT NULL DEFAULT 0;
+UPDATE subscriptions SET unit_price = amount;
+
+-- Price periods record the seats and unit price too, so that seat changes have dated history.
+ALTER TABLE subscription_prices ADD COLUMN quantity INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE subscription_prices ADD COLUMN unit_price INTEGER NOT NULL DEFAULT 0;
+UPDATE subscription_prices SET unit_price = amount;
@@ -0,0 +1,40 @@
+-- The product catalogue. Products are archived rather than deleted once they are no longer sold, so
+-- that the subscriptions sold from them keep their product.
+CREATE TABLE IF NOT EXISTS products (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    sku TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    name TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'archived')),
+    created_at DATETIME DEFAULT (datetime('now')),
+    updated_at DATETIME DEFAULT (datetime('now'))
+);
+
+-- Default unit prices of a product, one for each billing cadence and currency it is sold in.
+CREATE TABLE IF NOT EXISTS product_prices (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    product_id UUID NOT NULL,
+    billing_cadence TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    unit_price INTEGER NOT NULL CHECK (unit_price >= 0),
+    created_at DATETIME DEFAULT (datetime('now')),
+    UNIQUE (product_id, billing_cadence, currency),
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Prices agreed with a customer, which override the default price of the same cadence and currency.
+CREATE TABLE IF NOT EXISTS customer_product_prices (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    customer_id UUID NOT NULL,
+    product_id UUID NOT NULL,
+    billing_cadence TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    unit_price INTEGER NOT NULL CHECK (unit_price >= 0),
+    created_at DATETIME DEFAULT (datetime('now')),
+    UNIQUE (customer_id, product_id, billing_cadence, currency),
+    FOREIGN KEY (customer_id) REFERENCES customers(id) ON DELETE CASCADE,
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+-- Subscriptions sold from the catalogue. Those entered before it existed have no product.
+ALTER TABLE subscriptions ADD COLUMN product_id UUID DEFAULT NULL;
+CREATE INDEX IF NOT EXISTS idx_subscriptions_product ON subscriptions(product_id);
@@ -0,0 +1,39 @@
+-- Coupons are discounts offered under a code, which are copied onto a subscription when it is redeemed.
+-- A coupon with no duration discounts every billing period for as long as the subscription lasts.
+CREATE TABLE IF NOT EXISTS coupons (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    code TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    description TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    percent INTEGER NOT NULL DEFAULT 0 CHECK (percent >= 0 AND percent <= 100),
+    amount INTEGER NOT NULL DEFAULT 0 CHECK (amount >= 0),
+    currency TEXT DEFAULT NULL,
+    duration_periods INTEGER DEFAULT NULL CHECK (duration_periods IS NULL OR duration_periods >= 1),
+    status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'archived')),
+    created_at DATETIME DEFAULT (datetime('now'))
+);
+
+-- Discounts on a subscription, either percentage or fixed, from a start day to an end day or for as
+-- long as the subscription lasts. The terms of a coupon are copied so that later changes to it do not
+-- change discounts already given. Removed discounts are kept for their history.
+CREATE TABLE IF NOT EXISTS subscription_discounts (
+    id UUID PRIMARY KEY DEFAULT (uuid()),
+    subscription_id UUID NOT NULL,
+    coupon_id UUID DEFAULT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('percent', 'fixed')),
+    percent INTEGER NOT NULL DEFAULT 0 CHECK (percent >= 0 AND percent <= 100),
+    amount INTEGER NOT NULL DEFAULT 0 CHECK (amount >= 0),
+    currency TEXT NOT NULL,
+    starts_on DATETIME NOT NULL,
+    ends_on DATETIME DEFAULT NULL,
+    note TEXT DEFAULT NULL,
+    created_by TEXT DEFAULT NULL,
+    created_at DATETIME DEFAULT (datetime('now')),
+    removed_at DATETIME DEFAULT NULL,
+    FOREIGN KEY (subscription_id) REFERENCES subscriptions(id) ON DELETE CASCADE,
+    FOREIGN KEY (coupon_id) REFERENCES coupons(id) ON DELETE SET NULL
+);
+CREATE INDEX IF NOT EXISTS idx_subscription_discounts_subscription ON subscription_discounts(subscription_id);
+
+-- Free trials. Nothing is billed for a subscription before its trial ends.
+ALTER TABLE subscriptions ADD COLUMN trial_ends_on DATETIME DEFAULT NULL;
@@ -0,0 +1,6 @@
+-- What each subscription costs from its vendor, in the currency of the subscription and charged on a
+-- cadence of its own. Subscriptions without a cost cadence have no cost recorded.
+ALTER TABLE subscriptions ADD COLUMN cost_amount INTEGER NOT NULL DEFAULT 0 CHECK (cost_amount >= 0);
+ALTER TABLE subscriptions ADD COLUMN cost_cadence TEXT DEFAULT NULL;
+-- The day the cost was last changed, to tell a vendor price rise apart from a cost recorded at sale.
+ALTER TABLE subscriptions ADD COLUMN cost_changed_on DATETIME DEFAULT NULL;
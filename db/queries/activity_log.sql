@@ -1,7 +1,59 @@
 -- name: LogActivity :one
-INSERT INTO activity_log (customer_id, activity_type, action, description)
-VALUES (?, ?, ?, ?)
+INSERT INTO activity_log (customer_id, activity_type, action, description, actor, changes)
+VALUES (?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: ListRecentActivity :many
 SELECT * FROM activity_log ORDER BY created_at DESC LIMIT 50;
+
+-- name: ListActivityByCustomer :many
+SELECT al.*, u.name AS actor_name
+FROM activity_log al
+LEFT JOIN users u ON u.github_id = al.actor
+WHERE al.customer_id = ?
+ORDER BY al.created_at DESC
+LIMIT 50;
+
+-- name: ListActivity :many
+SELECT al.*, c.name AS customer_name, u.name AS actor_name
+FROM activity_log al
+LEFT JOIN customers c ON c.id = al.customer_id
+LEFT JOIN users u ON u.github_id = al.actor
+WHERE (sqlc.narg(customer_id) IS NULL OR al.customer_id = sqlc.narg(customer_id))
+  AND (sqlc.narg(activity_type) IS NULL OR al.activity_type = sqlc.narg(activity_type))
+  AND (sqlc.narg(action) IS NULL OR al.action = sqlc.narg(action))
+  AND (sqlc.narg(actor) IS NULL OR al.actor = sqlc.narg(actor))
+  AND (sqlc.narg(from_date) IS NULL OR al.created_at >= datetime(sqlc.narg(from_date)))
+  AND (sqlc.narg(to_date) IS NULL OR al.created_at < datetime(sqlc.narg(to_date), '+1 day'))
+  AND (
+    sqlc.narg(cursor_created_at) IS NULL
+    OR al.created_at < datetime(sqlc.narg(cursor_created_at))
+    OR (al.created_at = datetime(sqlc.narg(cursor_created_at)) AND al.id < sqlc.narg(cursor_id))
+  )
+ORDER BY al.created_at DESC, al.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: ListActivityActions :many
+SELECT DISTINCT action FROM activity_log ORDER BY action;
+
+-- name: GetActivityChainHead :one
+SELECT seq, hash FROM activity_log
+WHERE seq IS NOT NULL
+ORDER BY seq DESC
+LIMIT 1;
+
+-- name: ListUnsealedActivity :many
+SELECT * FROM activity_log
+WHERE seq IS NULL
+ORDER BY created_at, rowid;
+
+-- name: SealActivity :execrows
+UPDATE activity_log
+SET seq = ?, prev_hash = ?, content_hash = ?, hash = ?
+WHERE id = ? AND seq IS NULL;
+
+-- name: ListActivityChain :many
+SELECT * FROM activity_log
+WHERE seq > ?
+ORDER BY seq
+LIMIT ?;
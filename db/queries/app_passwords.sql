@@ -0,0 +1,22 @@
+-- name: CreateAppPassword :one
+INSERT INTO app_passwords (user_id, name, password_hash)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: ListAppPasswordsByUser :many
+SELECT * FROM app_passwords WHERE user_id = ? ORDER BY created_at DESC, name;
+
+-- name: DeleteAppPassword :one
+DELETE FROM app_passwords
+WHERE id = ? AND user_id = ?
+RETURNING *;
+
+-- name: GetAppPasswordUser :one
+SELECT ap.id AS app_password_id, u.github_id, u.is_admin
+FROM app_passwords ap
+JOIN users u ON u.id = ap.user_id
+WHERE ap.password_hash = ?
+LIMIT 1;
+
+-- name: TouchAppPassword :exec
+UPDATE app_passwords SET last_used_at = datetime('now') WHERE id = ?;
@@ -0,0 +1,28 @@
+-- name: GetCalendarToken :one
+SELECT * FROM calendar_tokens WHERE user_id = ?;
+
+-- name: SetCalendarToken :one
+INSERT INTO calendar_tokens (user_id, token)
+VALUES (?, ?)
+ON CONFLICT (user_id) DO UPDATE SET token = excluded.token, created_at = datetime('now'), last_used_at = NULL
+RETURNING *;
+
+-- name: DeleteCalendarToken :exec
+DELETE FROM calendar_tokens WHERE user_id = ?;
+
+-- name: GetCalendarTokenUser :one
+SELECT ct.user_id, u.github_id, u.is_admin
+FROM calendar_tokens ct
+JOIN users u ON u.id = ct.user_id
+WHERE ct.token = ?;
+
+-- name: TouchCalendarToken :exec
+UPDATE calendar_tokens SET last_used_at = datetime('now') WHERE user_id = ?;
+
+-- name: ListCalendarSubscriptions :many
+SELECT s.*, c.name AS customer_name
+FROM subscriptions s
+JOIN customers c ON c.id = s.customer_id
+WHERE s.deleted_at IS NULL AND c.deleted_at IS NULL AND s.status = 'active'
+  AND (sqlc.narg('customer_id') IS NULL OR s.customer_id = sqlc.narg('customer_id'))
+ORDER BY c.name, s.description;
@@ -12,21 +12,25 @@ RETURNING *;
 -- name: DeleteContactsByCustomer :exec
 UPDATE contacts SET deleted_at = datetime('now') WHERE customer_id = ?;
 
+-- name: ListContacts :many
+SELECT * FROM contacts WHERE deleted_at IS NULL ORDER BY name;
+
 -- name: ListContactsByCustomer :many
 SELECT * FROM contacts WHERE customer_id = ? AND deleted_at IS NULL ORDER BY is_primary DESC, created_at DESC;
 
 -- name: GetContact :one
 SELECT * FROM contacts WHERE id = ? AND deleted_at IS NULL;
 
--- name: UpdateContact :exec
+-- name: UpdateContact :one
 UPDATE contacts
-SET name = ?, role = ?, email = ?, phone = ?, is_primary = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
-WHERE id = ?;
+SET name = ?, role = ?, email = ?, phone = ?, is_primary = ?, notes = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+WHERE id = ? AND version = ? AND deleted_at IS NULL
+RETURNING *;
 
 -- name: UnsetOtherPrimaryContacts :exec
 UPDATE contacts
-SET is_primary = 0
-WHERE customer_id = ? AND id != ? AND deleted_at IS NULL;
+SET is_primary = 0, version = version + 1
+WHERE customer_id = ? AND id != ? AND is_primary = 1 AND deleted_at IS NULL;
 
 -- name: UpdateContactAvatar :exec
 UPDATE contacts
@@ -0,0 +1,36 @@
+-- name: ListCustomFields :many
+SELECT * FROM custom_fields WHERE entity = ? ORDER BY created_at, name;
+
+-- name: ListAllCustomFields :many
+SELECT * FROM custom_fields ORDER BY entity, created_at, name;
+
+-- name: CreateCustomField :one
+INSERT INTO custom_fields (entity, name, field_type, options)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: DeleteCustomField :one
+DELETE FROM custom_fields
+WHERE id = ?
+RETURNING *;
+
+-- name: ListCustomFieldValues :many
+SELECT * FROM custom_field_values WHERE record_id = ?;
+
+-- name: ListCustomFieldValuesByEntity :many
+SELECT v.* FROM custom_field_values v
+JOIN custom_fields f ON f.id = v.field_id
+WHERE f.entity = ?;
+
+-- name: ListContactCustomFieldValuesByCustomer :many
+SELECT v.* FROM custom_field_values v
+JOIN contacts c ON c.id = v.record_id
+WHERE c.customer_id = ?;
+
+-- name: UpsertCustomFieldValue :exec
+INSERT INTO custom_field_values (field_id, record_id, value)
+VALUES (?, ?, ?)
+ON CONFLICT (field_id, record_id) DO UPDATE SET value = excluded.value, updated_at = datetime('now');
+
+-- name: DeleteCustomFieldValue :exec
+DELETE FROM custom_field_values WHERE field_id = ? AND record_id = ?;
@@ -1,12 +1,12 @@
 -- name: CreateCustomer :one
-INSERT INTO customers (name, logo, status, email, phone, address, website, notes)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+INSERT INTO customers (name, logo, status, email, phone, address, website, notes, currency)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
 -- name: UpdateCustomer :one
 UPDATE customers
-SET name = ?, logo = ?, status = ?, email = ?, phone = ?, address = ?, website = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
-WHERE id = ?
+SET name = ?, logo = ?, status = ?, email = ?, phone = ?, address = ?, website = ?, notes = ?, currency = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+WHERE id = ? AND version = ? AND deleted_at IS NULL
 RETURNING *;
 
 -- name: DeleteCustomer :one
@@ -21,7 +21,6 @@ SELECT
     (SELECT COUNT(*) FROM contacts WHERE customer_id = c.id AND deleted_at IS NULL) AS contact_count,
     (SELECT COUNT(*) FROM subscriptions WHERE customer_id = c.id AND deleted_at IS NULL) AS subscription_count,
     8 AS project_count, -- TODO:
-    (SELECT SUM(amount) FROM subscriptions WHERE customer_id = c.id AND deleted_at IS NULL) AS subscription_revenue,
     267 AS monthly_revenue, -- TODO:
     15 AS revenue_change -- TODO:
 FROM customers c
@@ -34,3 +33,23 @@ SELECT * FROM customers WHERE deleted_at IS NULL ORDER BY created_at DESC;
 UPDATE customers
 SET logo = ?, updated_at = CURRENT_TIMESTAMP
 WHERE id = ?;
+
+-- name: ListCustomersFiltered :many
+SELECT c.* FROM customers c
+WHERE c.deleted_at IS NULL
+  AND (sqlc.narg(tag_id) IS NULL OR EXISTS (
+    SELECT 1 FROM customer_tags ct WHERE ct.customer_id = c.id AND ct.tag_id = sqlc.narg(tag_id)
+  ))
+  AND (sqlc.narg(status) IS NULL OR c.status = sqlc.narg(status))
+  AND (sqlc.narg(created_from) IS NULL OR c.created_at >= datetime(sqlc.narg(created_from)))
+  AND (sqlc.narg(created_to) IS NULL OR c.created_at < datetime(sqlc.narg(created_to), '+1 day'))
+ORDER BY
+  CASE WHEN sqlc.arg(sort) = 'name' THEN c.name END COLLATE NOCASE ASC,
+  CASE WHEN sqlc.arg(sort) = 'oldest' THEN c.created_at END ASC,
+  c.created_at DESC;
+
+-- name: UpdateCustomerStatus :one
+UPDATE customers
+SET status = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+WHERE id = ? AND deleted_at IS NULL
+RETURNING *;
@@ -22,10 +22,11 @@ SELECT
     al.action,
     al.description,
     al.created_at,
+    al.actor,
     c.name AS customer_name
 FROM
     activity_log al
-JOIN
+LEFT JOIN
     customers c ON al.customer_id = c.id
 WHERE c.deleted_at IS NULL
 ORDER BY
@@ -0,0 +1,43 @@
+-- name: CreateCoupon :one
+INSERT INTO coupons (code, description, kind, percent, amount, currency, duration_periods)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListCoupons :many
+SELECT * FROM coupons ORDER BY status, code;
+
+-- name: GetCouponByCode :one
+SELECT * FROM coupons WHERE code = ?;
+
+-- name: SetCouponStatus :one
+UPDATE coupons SET status = ? WHERE id = ? RETURNING *;
+
+-- name: CreateSubscriptionDiscount :one
+INSERT INTO subscription_discounts (subscription_id, coupon_id, kind, percent, amount, currency, starts_on, ends_on, note, created_by)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListSubscriptionDiscounts :many
+SELECT * FROM subscription_discounts WHERE subscription_id = ? AND removed_at IS NULL ORDER BY starts_on, created_at;
+
+-- name: ListActiveSubscriptionDiscounts :many
+SELECT d.* FROM subscription_discounts d
+JOIN subscriptions s ON s.id = d.subscription_id
+WHERE d.removed_at IS NULL AND s.deleted_at IS NULL AND s.status = 'active'
+ORDER BY d.subscription_id, d.starts_on, d.created_at;
+
+-- name: RemoveSubscriptionDiscount :one
+UPDATE subscription_discounts SET removed_at = datetime('now')
+WHERE id = ? AND subscription_id = ? AND removed_at IS NULL
+RETURNING *;
+
+-- name: ListTrialSubscriptions :many
+SELECT s.id, s.customer_id, c.name AS customer_name, s.description, s.status, s.amount, s.currency, s.trial_ends_on,
+    CAST(EXISTS (
+        SELECT 1 FROM subscription_status_changes sc
+        WHERE sc.subscription_id = s.id AND sc.applied_at IS NULL AND sc.to_status = 'cancelled'
+    ) AS BOOLEAN) AS cancelling
+FROM subscriptions s
+JOIN customers c ON c.id = s.customer_id
+WHERE s.deleted_at IS NULL AND c.deleted_at IS NULL AND s.status != 'cancelled' AND s.trial_ends_on IS NOT NULL
+ORDER BY s.trial_ends_on, c.name;
@@ -0,0 +1,10 @@
+-- name: ListExchangeRates :many
+SELECT * FROM exchange_rates ORDER BY from_currency, to_currency, effective_from DESC;
+
+-- name: CreateExchangeRate :one
+INSERT INTO exchange_rates (from_currency, to_currency, rate, effective_from)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: DeleteExchangeRate :one
+DELETE FROM exchange_rates WHERE id = ? RETURNING *;
@@ -0,0 +1,50 @@
+-- Exports read one page at a time in ID order, continuing after the last ID of the previous page, so that large
+-- exports are streamed rather than loaded into memory. Customer conditions match ListCustomersFiltered.
+
+-- name: ExportCustomers :many
+SELECT c.* FROM customers c
+WHERE (sqlc.narg(tag_id) IS NULL OR EXISTS (
+    SELECT 1 FROM customer_tags ct WHERE ct.customer_id = c.id AND ct.tag_id = sqlc.narg(tag_id)
+  ))
+  AND (sqlc.narg(status) IS NULL OR c.status = sqlc.narg(status))
+  AND (sqlc.narg(created_from) IS NULL OR c.created_at >= datetime(sqlc.narg(created_from)))
+  AND (sqlc.narg(created_to) IS NULL OR c.created_at < datetime(sqlc.narg(created_to), '+1 day'))
+  AND (sqlc.arg(include_deleted) OR c.deleted_at IS NULL)
+  AND c.id > sqlc.arg(after)
+ORDER BY c.id
+LIMIT sqlc.arg(page_size);
+
+-- name: ExportContacts :many
+SELECT ct.*, c.name AS customer_name FROM contacts ct
+JOIN customers c ON c.id = ct.customer_id
+WHERE (sqlc.narg(tag_id) IS NULL OR EXISTS (
+    SELECT 1 FROM customer_tags t WHERE t.customer_id = c.id AND t.tag_id = sqlc.narg(tag_id)
+  ))
+  AND (sqlc.narg(status) IS NULL OR c.status = sqlc.narg(status))
+  AND (sqlc.narg(created_from) IS NULL OR c.created_at >= datetime(sqlc.narg(created_from)))
+  AND (sqlc.narg(created_to) IS NULL OR c.created_at < datetime(sqlc.narg(created_to), '+1 day'))
+  AND (sqlc.arg(include_deleted) OR (ct.deleted_at IS NULL AND c.deleted_at IS NULL))
+  AND ct.id > sqlc.arg(after)
+ORDER BY ct.id
+LIMIT sqlc.arg(page_size);
+
+-- name: ExportSubscriptions :many
+SELECT s.*, c.name AS customer_name FROM subscriptions s
+JOIN customers c ON c.id = s.customer_id
+WHERE (sqlc.narg(tag_id) IS NULL OR EXISTS (
+    SELECT 1 FROM customer_tags t WHERE t.customer_id = c.id AND t.tag_id = sqlc.narg(tag_id)
+  ))
+  AND (sqlc.narg(status) IS NULL OR c.status = sqlc.narg(status))
+  AND (sqlc.narg(created_from) IS NULL OR c.created_at >= datetime(sqlc.narg(created_from)))
+  AND (sqlc.narg(created_to) IS NULL OR c.created_at < datetime(sqlc.narg(created_to), '+1 day'))
+  AND (sqlc.arg(include_deleted) OR (s.deleted_at IS NULL AND c.deleted_at IS NULL))
+  AND s.id > sqlc.arg(after)
+ORDER BY s.id
+LIMIT sqlc.arg(page_size);
+
+-- name: ExportCustomFieldValues :many
+SELECT v.* FROM custom_field_values v
+JOIN custom_fields f ON f.id = v.field_id
+WHERE f.entity = sqlc.arg(entity)
+  AND v.record_id >= sqlc.arg(first)
+  AND v.record_id <= sqlc.arg(last);
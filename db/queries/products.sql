@@ -0,0 +1,35 @@
+-- name: ListProducts :many
+SELECT * FROM products ORDER BY status, name;
+
+-- name: GetProduct :one
+SELECT * FROM products WHERE id = ?;
+
+-- name: CreateProduct :one
+INSERT INTO products (sku, name) VALUES (?, ?) RETURNING *;
+
+-- name: SetProductStatus :one
+UPDATE products SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? RETURNING *;
+
+-- name: ListProductPrices :many
+SELECT * FROM product_prices ORDER BY product_id, billing_cadence, currency;
+
+-- name: SetProductPrice :one
+INSERT INTO product_prices (product_id, billing_cadence, currency, unit_price)
+VALUES (?, ?, ?, ?)
+ON CONFLICT (product_id, billing_cadence, currency) DO UPDATE SET unit_price = excluded.unit_price
+RETURNING *;
+
+-- name: DeleteProductPrice :one
+DELETE FROM product_prices WHERE id = ? RETURNING *;
+
+-- name: ListCustomerProductPrices :many
+SELECT * FROM customer_product_prices WHERE customer_id = ? ORDER BY product_id, billing_cadence, currency;
+
+-- name: SetCustomerProductPrice :one
+INSERT INTO customer_product_prices (customer_id, product_id, billing_cadence, currency, unit_price)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT (customer_id, product_id, billing_cadence, currency) DO UPDATE SET unit_price = excluded.unit_price
+RETURNING *;
+
+-- name: DeleteCustomerProductPrice :one
+DELETE FROM customer_product_prices WHERE id = ? AND customer_id = ? RETURNING *;
@@ -0,0 +1,101 @@
+-- name: PurgeDeletedContacts :many
+DELETE FROM contacts
+WHERE deleted_at IS NOT NULL AND deleted_at < datetime(sqlc.arg(cutoff))
+RETURNING id, avatar;
+
+-- name: PurgeStatusChangesOfDeletedSubscriptions :execrows
+DELETE FROM subscription_status_changes
+WHERE subscription_id IN (
+    SELECT id FROM subscriptions WHERE deleted_at IS NOT NULL AND deleted_at < datetime(sqlc.arg(cutoff))
+);
+
+-- name: PurgePricesOfDeletedSubscriptions :execrows
+DELETE FROM subscription_prices
+WHERE subscription_id IN (
+    SELECT id FROM subscriptions WHERE deleted_at IS NOT NULL AND deleted_at < datetime(sqlc.arg(cutoff))
+);
+
+-- name: PurgeAdjustmentsOfDeletedSubscriptions :execrows
+DELETE FROM subscription_adjustments
+WHERE subscription_id IN (
+    SELECT id FROM subscriptions WHERE deleted_at IS NOT NULL AND deleted_at < datetime(sqlc.arg(cutoff))
+);
+
+-- name: PurgeDiscountsOfDeletedSubscriptions :execrows
+DELETE FROM subscription_discounts
+WHERE subscription_id IN (
+    SELECT id FROM subscriptions WHERE deleted_at IS NOT NULL AND deleted_at < datetime(sqlc.arg(cutoff))
+);
+
+-- name: PurgeDeletedSubscriptions :execrows
+DELETE FROM subscriptions
+WHERE deleted_at IS NOT NULL AND deleted_at < datetime(sqlc.arg(cutoff));
+
+-- name: PurgeContactsOfDeletedCustomers :many
+DELETE FROM contacts
+WHERE customer_id IN (
+    SELECT id FROM customers WHERE deleted_at IS NOT NULL AND deleted_at < datetime(sqlc.arg(cutoff))
+)
+RETURNING id, avatar;
+
+-- name: PurgeStatusChangesOfDeletedCustomers :execrows
+DELETE FROM subscription_status_changes
+WHERE subscription_id IN (
+    SELECT s.id FROM subscriptions s
+    JOIN customers c ON c.id = s.customer_id
+    WHERE c.deleted_at IS NOT NULL AND c.deleted_at < datetime(sqlc.arg(cutoff))
+);
+
+-- name: PurgePricesOfDeletedCustomers :execrows
+DELETE FROM subscription_prices
+WHERE subscription_id IN (
+    SELECT s.id FROM subscriptions s
+    JOIN customers c ON c.id = s.customer_id
+    WHERE c.deleted_at IS NOT NULL AND c.deleted_at < datetime(sqlc.arg(cutoff))
+);
+
+-- name: PurgeAdjustmentsOfDeletedCustomers :execrows
+DELETE FROM subscription_adjustments
+WHERE subscription_id IN (
+    SELECT s.id FROM subscriptions s
+    JOIN customers c ON c.id = s.customer_id
+    WHERE c.deleted_at IS NOT NULL AND c.deleted_at < datetime(sqlc.arg(cutoff))
+);
+
+-- name: PurgeDiscountsOfDeletedCustomers :execrows
+DELETE FROM subscription_discounts
+WHERE subscription_id IN (
+    SELECT s.id FROM subscriptions s
+    JOIN customers c ON c.id = s.customer_id
+    WHERE c.deleted_at IS NOT NULL AND c.deleted_at < datetime(sqlc.arg(cutoff))
+);
+
+-- name: PurgeProductPricesOfDeletedCustomers :execrows
+DELETE FROM customer_product_prices
+WHERE customer_id IN (
+    SELECT id FROM customers WHERE deleted_at IS NOT NULL AND deleted_at < datetime(sqlc.arg(cutoff))
+);
+
+-- name: PurgeSubscriptionsOfDeletedCustomers :execrows
+DELETE FROM subscriptions
+WHERE customer_id IN (
+    SELECT id FROM customers WHERE deleted_at IS NOT NULL AND deleted_at < datetime(sqlc.arg(cutoff))
+);
+
+-- name: PurgeDeletedCustomers :many
+DELETE FROM customers
+WHERE deleted_at IS NOT NULL AND deleted_at < datetime(sqlc.arg(cutoff))
+RETURNING id, logo;
+
+-- name: AnonymiseActivity :execrows
+UPDATE activity_log
+SET description = sqlc.arg(description), changes = NULL, anonymised_at = datetime('now')
+WHERE anonymised_at IS NULL AND action <> sqlc.arg(kept_action) AND created_at < datetime(sqlc.arg(cutoff));
+
+-- name: CreateRetentionRun :one
+INSERT INTO retention_runs (started_at, finished_at, report)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: ListRetentionRuns :many
+SELECT * FROM retention_runs ORDER BY started_at DESC LIMIT ?;
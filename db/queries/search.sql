@@ -0,0 +1,34 @@
+-- name: SearchRecords :many
+SELECT
+    search_index.record_type,
+    search_index.record_id,
+    search_index.customer_id,
+    c.name AS customer_name,
+    search_index.status,
+    CAST(highlight(search_index, 4, char(2), char(3)) AS TEXT) AS title,
+    CAST(snippet(search_index, 5, char(2), char(3), '…', 12) AS TEXT) AS snippet
+FROM search_index
+JOIN customers c ON c.id = search_index.customer_id
+WHERE search_index MATCH sqlc.arg(match)
+  AND c.deleted_at IS NULL
+  AND (sqlc.narg(record_type) IS NULL OR search_index.record_type = sqlc.narg(record_type))
+  AND (sqlc.narg(status) IS NULL OR search_index.status = sqlc.narg(status))
+ORDER BY bm25(search_index, 0, 0, 0, 0, 10.0, 1.0)
+LIMIT sqlc.arg(result_limit);
+
+-- name: BrowseSearchIndex :many
+SELECT
+    search_index.record_type,
+    search_index.record_id,
+    search_index.customer_id,
+    c.name AS customer_name,
+    search_index.status,
+    CAST(search_index.title AS TEXT) AS title,
+    CAST(search_index.body AS TEXT) AS snippet
+FROM search_index
+JOIN customers c ON c.id = search_index.customer_id
+WHERE c.deleted_at IS NULL
+  AND (sqlc.narg(record_type) IS NULL OR search_index.record_type = sqlc.narg(record_type))
+  AND (sqlc.narg(status) IS NULL OR search_index.status = sqlc.narg(status))
+ORDER BY search_index.title
+LIMIT sqlc.arg(result_limit);
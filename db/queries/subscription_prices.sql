@@ -0,0 +1,15 @@
+-- name: CreateSubscriptionAdjustment :one
+INSERT INTO subscription_adjustments (subscription_id, price_id, amount, currency, description, period_start, period_end)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: CreateSubscriptionPrice :one
+INSERT INTO subscription_prices (subscription_id, amount, currency, effective_from, note, created_by, quantity, unit_price)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListSubscriptionAdjustments :many
+SELECT * FROM subscription_adjustments WHERE subscription_id = ? ORDER BY created_at DESC, rowid DESC;
+
+-- name: ListSubscriptionPrices :many
+SELECT * FROM subscription_prices WHERE subscription_id = ? ORDER BY effective_from DESC, created_at DESC, rowid DESC;
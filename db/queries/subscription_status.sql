@@ -0,0 +1,16 @@
+-- name: CreateStatusChange :one
+INSERT INTO subscription_status_changes (subscription_id, from_status, to_status, effective_date, note, created_by, applied_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: ListStatusChanges :many
+SELECT * FROM subscription_status_changes WHERE subscription_id = ? ORDER BY effective_date DESC, created_at DESC, rowid DESC;
+
+-- name: ListPendingStatusChanges :many
+SELECT * FROM subscription_status_changes WHERE applied_at IS NULL ORDER BY effective_date, created_at, rowid;
+
+-- name: CompleteStatusChange :exec
+UPDATE subscription_status_changes SET from_status = ?, applied_at = ?, skipped_reason = ? WHERE id = ?;
+
+-- name: WithdrawStatusChange :one
+DELETE FROM subscription_status_changes WHERE id = ? AND subscription_id = ? AND applied_at IS NULL RETURNING *;
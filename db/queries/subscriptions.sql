@@ -1,17 +1,34 @@
 -- name: ListSubscriptionsByCustomer :many
-SELECT s.*, s.start_date as next_billing_date FROM subscriptions s WHERE customer_id = ? AND deleted_at IS NULL ORDER BY created_at DESC;
+SELECT * FROM subscriptions WHERE customer_id = ? AND deleted_at IS NULL ORDER BY created_at DESC;
 
 -- name: CreateSubscription :one
-INSERT INTO subscriptions ( customer_id, description, amount, term, billing_cadence, status, start_date, notes)
-VALUES ( ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
+INSERT INTO subscriptions ( customer_id, description, amount, term, billing_cadence, status, start_date, notes, currency, billing_interval, billing_anchor_day, quantity, unit_price, product_id, trial_ends_on, cost_amount, cost_cadence, cost_changed_on)
+VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
 
 -- name: GetSubscription :one
 SELECT * FROM subscriptions WHERE id = ? AND deleted_at IS NULL;
 
 -- name: UpdateSubscription :one
-UPDATE subscriptions SET description = ?, amount = ?, term = ?, billing_cadence = ?, status = ?, start_date = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
-WHERE id = ? AND deleted_at IS NULL
+UPDATE subscriptions SET description = ?, amount = ?, term = ?, billing_cadence = ?, start_date = ?, notes = ?, currency = ?, billing_interval = ?, billing_anchor_day = ?, quantity = ?, unit_price = ?, product_id = ?, trial_ends_on = ?, cost_amount = ?, cost_cadence = ?, cost_changed_on = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+WHERE id = ? AND version = ? AND deleted_at IS NULL
 RETURNING *;
 
+-- name: SetSubscriptionStatus :one
+UPDATE subscriptions SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL RETURNING *;
+
 -- name: DeleteSubscription :one
 UPDATE subscriptions SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL RETURNING *;
+
+-- name: ListActiveSubscriptions :many
+SELECT * FROM subscriptions WHERE deleted_at IS NULL AND status = 'active' ORDER BY customer_id, created_at;
+
+-- name: ListSeatTotalsByCustomer :many
+SELECT
+    CAST(COALESCE(p.name, s.description) AS TEXT) AS description,
+    CAST(COALESCE(SUM(s.quantity), 0) AS INTEGER) AS seats,
+    COUNT(*) AS subscription_count
+FROM subscriptions s
+LEFT JOIN products p ON p.id = s.product_id
+WHERE s.customer_id = ? AND s.deleted_at IS NULL AND s.status = 'active'
+GROUP BY COALESCE(p.name, s.description)
+ORDER BY description;
@@ -0,0 +1,58 @@
+-- name: ListTags :many
+SELECT * FROM tags ORDER BY name;
+
+-- name: CreateTag :one
+INSERT INTO tags (name, colour)
+VALUES (?, ?)
+RETURNING *;
+
+-- name: DeleteTag :one
+DELETE FROM tags
+WHERE id = ?
+RETURNING *;
+
+-- name: DeleteCustomerTagsByTag :exec
+DELETE FROM customer_tags WHERE tag_id = ?;
+
+-- name: ListCustomerTags :many
+SELECT t.*
+FROM tags t
+JOIN customer_tags ct ON ct.tag_id = t.id
+WHERE ct.customer_id = ?
+ORDER BY t.name;
+
+-- name: AddCustomerTag :exec
+INSERT INTO customer_tags (customer_id, tag_id)
+VALUES (?, ?)
+ON CONFLICT (customer_id, tag_id) DO NOTHING;
+
+-- name: RemoveCustomerTag :exec
+DELETE FROM customer_tags WHERE customer_id = ? AND tag_id = ?;
+
+-- name: ClearCustomerTags :exec
+DELETE FROM customer_tags WHERE customer_id = ?;
+
+-- name: ListSavedFilters :many
+SELECT * FROM saved_filters ORDER BY name;
+
+-- name: GetSavedFilter :one
+SELECT * FROM saved_filters WHERE id = ?;
+
+-- name: CreateSavedFilter :one
+INSERT INTO saved_filters (name, tag_id, status, created_from, created_to, sort)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: DeleteSavedFilter :one
+DELETE FROM saved_filters
+WHERE id = ?
+RETURNING *;
+
+-- name: CountSavedFiltersByTag :one
+SELECT COUNT(*) FROM saved_filters WHERE tag_id = ?;
+
+-- name: ListCustomerTagAssignments :many
+SELECT ct.customer_id, t.*
+FROM customer_tags ct
+JOIN tags t ON t.id = ct.tag_id
+ORDER BY t.name;
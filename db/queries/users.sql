@@ -7,3 +7,6 @@ SELECT * FROM users WHERE github_id = ? LIMIT 1;
 
 -- name: IsUserAdmin :one
 SELECT is_admin FROM users WHERE github_id = ? LIMIT 1;
+
+-- name: ListUsers :many
+SELECT * FROM users ORDER BY name;
@@ -7,12 +7,271 @@ package db
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const getActivityChainHead = `-- name: GetActivityChainHead :one
+SELECT seq, hash FROM activity_log
+WHERE seq IS NOT NULL
+ORDER BY seq DESC
+LIMIT 1
+`
+
+type GetActivityChainHeadRow struct {
+	Seq  sql.NullInt64
+	Hash sql.NullString
+}
+
+func (q *Queries) GetActivityChainHead(ctx context.Context) (GetActivityChainHeadRow, error) {
+	row := q.db.QueryRowContext(ctx, getActivityChainHead)
+	var i GetActivityChainHeadRow
+	err := row.Scan(&i.Seq, &i.Hash)
+	return i, err
+}
+
+const listActivity = `-- name: ListActivity :many
+SELECT al.id, al.customer_id, al.activity_type, al."action", al.description, al.created_at, al.anonymised_at, al.actor, al.changes, al.seq, al.prev_hash, al.content_hash, al.hash, c.name AS customer_name, u.name AS actor_name
+FROM activity_log al
+LEFT JOIN customers c ON c.id = al.customer_id
+LEFT JOIN users u ON u.github_id = al.actor
+WHERE (?1 IS NULL OR al.customer_id = ?1)
+  AND (?2 IS NULL OR al.activity_type = ?2)
+  AND (?3 IS NULL OR al.action = ?3)
+  AND (?4 IS NULL OR al.actor = ?4)
+  AND (?5 IS NULL OR al.created_at >= datetime(?5))
+  AND (?6 IS NULL OR al.created_at < datetime(?6, '+1 day'))
+  AND (
+    ?7 IS NULL
+    OR al.created_at < datetime(?7)
+    OR (al.created_at = datetime(?7) AND al.id < ?8)
+  )
+ORDER BY al.created_at DESC, al.id DESC
+LIMIT ?9
+`
+
+type ListActivityParams struct {
+	CustomerID      uuid.NullUUID
+	ActivityType    sql.NullString
+	Action          sql.NullString
+	Actor           sql.NullString
+	FromDate        interface{}
+	ToDate          interface{}
+	CursorCreatedAt interface{}
+	CursorID        uuid.NullUUID
+	PageSize        int64
+}
+
+type ListActivityRow struct {
+	ID           uuid.UUID
+	CustomerID   uuid.NullUUID
+	ActivityType string
+	Action       string
+	Description  string
+	CreatedAt    sql.NullTime
+	AnonymisedAt sql.NullTime
+	Actor        sql.NullString
+	Changes      sql.NullString
+	Seq          sql.NullInt64
+	PrevHash     sql.NullString
+	ContentHash  sql.NullString
+	Hash         sql.NullString
+	CustomerName sql.NullString
+	ActorName    sql.NullString
+}
+
+func (q *Queries) ListActivity(ctx context.Context, arg ListActivityParams) ([]ListActivityRow, error) {
+	rows, err := q.db.QueryContext(ctx, listActivity,
+		arg.CustomerID,
+		arg.ActivityType,
+		arg.Action,
+		arg.Actor,
+		arg.FromDate,
+		arg.ToDate,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListActivityRow
+	for rows.Next() {
+		var i ListActivityRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.ActivityType,
+			&i.Action,
+			&i.Description,
+			&i.CreatedAt,
+			&i.AnonymisedAt,
+			&i.Actor,
+			&i.Changes,
+			&i.Seq,
+			&i.PrevHash,
+			&i.ContentHash,
+			&i.Hash,
+			&i.CustomerName,
+			&i.ActorName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listActivityActions = `-- name: ListActivityActions :many
+SELECT DISTINCT action FROM activity_log ORDER BY action
+`
+
+func (q *Queries) ListActivityActions(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, listActivityActions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var action string
+		if err := rows.Scan(&action); err != nil {
+			return nil, err
+		}
+		items = append(items, action)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listActivityByCustomer = `-- name: ListActivityByCustomer :many
+SELECT al.id, al.customer_id, al.activity_type, al."action", al.description, al.created_at, al.anonymised_at, al.actor, al.changes, al.seq, al.prev_hash, al.content_hash, al.hash, u.name AS actor_name
+FROM activity_log al
+LEFT JOIN users u ON u.github_id = al.actor
+WHERE al.customer_id = ?
+ORDER BY al.created_at DESC
+LIMIT 50
+`
+
+type ListActivityByCustomerRow struct {
+	ID           uuid.UUID
+	CustomerID   uuid.NullUUID
+	ActivityType string
+	Action       string
+	Description  string
+	CreatedAt    sql.NullTime
+	AnonymisedAt sql.NullTime
+	Actor        sql.NullString
+	Changes      sql.NullString
+	Seq          sql.NullInt64
+	PrevHash     sql.NullString
+	ContentHash  sql.NullString
+	Hash         sql.NullString
+	ActorName    sql.NullString
+}
+
+func (q *Queries) ListActivityByCustomer(ctx context.Context, customerID uuid.NullUUID) ([]ListActivityByCustomerRow, error) {
+	rows, err := q.db.QueryContext(ctx, listActivityByCustomer, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListActivityByCustomerRow
+	for rows.Next() {
+		var i ListActivityByCustomerRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.ActivityType,
+			&i.Action,
+			&i.Description,
+			&i.CreatedAt,
+			&i.AnonymisedAt,
+			&i.Actor,
+			&i.Changes,
+			&i.Seq,
+			&i.PrevHash,
+			&i.ContentHash,
+			&i.Hash,
+			&i.ActorName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listActivityChain = `-- name: ListActivityChain :many
+SELECT id, customer_id, activity_type, "action", description, created_at, anonymised_at, actor, changes, seq, prev_hash, content_hash, hash FROM activity_log
+WHERE seq > ?
+ORDER BY seq
+LIMIT ?
+`
+
+type ListActivityChainParams struct {
+	Seq   sql.NullInt64
+	Limit int64
+}
+
+func (q *Queries) ListActivityChain(ctx context.Context, arg ListActivityChainParams) ([]ActivityLog, error) {
+	rows, err := q.db.QueryContext(ctx, listActivityChain, arg.Seq, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ActivityLog
+	for rows.Next() {
+		var i ActivityLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.ActivityType,
+			&i.Action,
+			&i.Description,
+			&i.CreatedAt,
+			&i.AnonymisedAt,
+			&i.Actor,
+			&i.Changes,
+			&i.Seq,
+			&i.PrevHash,
+			&i.ContentHash,
+			&i.Hash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listRecentActivity = `-- name: ListRecentActivity :many
-SELECT id, customer_id, activity_type, "action", description, created_at FROM activity_log ORDER BY created_at DESC LIMIT 50
+SELECT id, customer_id, activity_type, "action", description, created_at, anonymised_at, actor, changes, seq, prev_hash, content_hash, hash FROM activity_log ORDER BY created_at DESC LIMIT 50
 `
 
 func (q *Queries) ListRecentActivity(ctx context.Context) ([]ActivityLog, error) {
@@ -31,6 +290,56 @@ func (q *Queries) ListRecentActivity(ctx context.Context) ([]ActivityLog, error)
 			&i.Action,
 			&i.Description,
 			&i.CreatedAt,
+			&i.AnonymisedAt,
+			&i.Actor,
+			&i.Changes,
+			&i.Seq,
+			&i.PrevHash,
+			&i.ContentHash,
+			&i.Hash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnsealedActivity = `-- name: ListUnsealedActivity :many
+SELECT id, customer_id, activity_type, "action", description, created_at, anonymised_at, actor, changes, seq, prev_hash, content_hash, hash FROM activity_log
+WHERE seq IS NULL
+ORDER BY created_at, rowid
+`
+
+func (q *Queries) ListUnsealedActivity(ctx context.Context) ([]ActivityLog, error) {
+	rows, err := q.db.QueryContext(ctx, listUnsealedActivity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ActivityLog
+	for rows.Next() {
+		var i ActivityLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.ActivityType,
+			&i.Action,
+			&i.Description,
+			&i.CreatedAt,
+			&i.AnonymisedAt,
+			&i.Actor,
+			&i.Changes,
+			&i.Seq,
+			&i.PrevHash,
+			&i.ContentHash,
+			&i.Hash,
 		); err != nil {
 			return nil, err
 		}
@@ -46,16 +355,18 @@ func (q *Queries) ListRecentActivity(ctx context.Context) ([]ActivityLog, error)
 }
 
 const logActivity = `-- name: LogActivity :one
-INSERT INTO activity_log (customer_id, activity_type, action, description)
-VALUES (?, ?, ?, ?)
-RETURNING id, customer_id, activity_type, "action", description, created_at
+INSERT INTO activity_log (customer_id, activity_type, action, description, actor, changes)
+VALUES (?, ?, ?, ?, ?, ?)
+RETURNING id, customer_id, activity_type, "action", description, created_at, anonymised_at, actor, changes, seq, prev_hash, content_hash, hash
 `
 
 type LogActivityParams struct {
-	CustomerID   uuid.UUID
+	CustomerID   uuid.NullUUID
 	ActivityType string
 	Action       string
 	Description  string
+	Actor        sql.NullString
+	Changes      sql.NullString
 }
 
 func (q *Queries) LogActivity(ctx context.Context, arg LogActivityParams) (ActivityLog, error) {
@@ -64,6 +375,8 @@ func (q *Queries) LogActivity(ctx context.Context, arg LogActivityParams) (Activ
 		arg.ActivityType,
 		arg.Action,
 		arg.Description,
+		arg.Actor,
+		arg.Changes,
 	)
 	var i ActivityLog
 	err := row.Scan(
@@ -73,6 +386,41 @@ func (q *Queries) LogActivity(ctx context.Context, arg LogActivityParams) (Activ
 		&i.Action,
 		&i.Description,
 		&i.CreatedAt,
+		&i.AnonymisedAt,
+		&i.Actor,
+		&i.Changes,
+		&i.Seq,
+		&i.PrevHash,
+		&i.ContentHash,
+		&i.Hash,
 	)
 	return i, err
 }
+
+const sealActivity = `-- name: SealActivity :execrows
+UPDATE activity_log
+SET seq = ?, prev_hash = ?, content_hash = ?, hash = ?
+WHERE id = ? AND seq IS NULL
+`
+
+type SealActivityParams struct {
+	Seq         sql.NullInt64
+	PrevHash    sql.NullString
+	ContentHash sql.NullString
+	Hash        sql.NullString
+	ID          uuid.UUID
+}
+
+func (q *Queries) SealActivity(ctx context.Context, arg SealActivityParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, sealActivity,
+		arg.Seq,
+		arg.PrevHash,
+		arg.ContentHash,
+		arg.Hash,
+		arg.ID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -15,7 +15,7 @@ import (
 const createContact = `-- name: CreateContact :one
 INSERT INTO contacts (customer_id, name, role, email, phone, avatar, is_primary, notes)
 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-RETURNING id, customer_id, name, role, email, phone, avatar, is_primary, notes, created_at, updated_at, deleted_at
+RETURNING id, customer_id, name, role, email, phone, avatar, is_primary, notes, created_at, updated_at, deleted_at, version
 `
 
 type CreateContactParams struct {
@@ -54,6 +54,7 @@ func (q *Queries) CreateContact(ctx context.Context, arg CreateContactParams) (C
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
 	)
 	return i, err
 }
@@ -62,7 +63,7 @@ const deleteContact = `-- name: DeleteContact :one
 UPDATE contacts
 SET deleted_at = datetime('now')
 WHERE id = ?
-RETURNING id, customer_id, name, role, email, phone, avatar, is_primary, notes, created_at, updated_at, deleted_at
+RETURNING id, customer_id, name, role, email, phone, avatar, is_primary, notes, created_at, updated_at, deleted_at, version
 `
 
 func (q *Queries) DeleteContact(ctx context.Context, id uuid.UUID) (Contact, error) {
@@ -81,6 +82,7 @@ func (q *Queries) DeleteContact(ctx context.Context, id uuid.UUID) (Contact, err
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
 	)
 	return i, err
 }
@@ -106,7 +108,7 @@ func (q *Queries) DeleteContactsByCustomer(ctx context.Context, customerID uuid.
 }
 
 const getContact = `-- name: GetContact :one
-SELECT id, customer_id, name, role, email, phone, avatar, is_primary, notes, created_at, updated_at, deleted_at FROM contacts WHERE id = ? AND deleted_at IS NULL
+SELECT id, customer_id, name, role, email, phone, avatar, is_primary, notes, created_at, updated_at, deleted_at, version FROM contacts WHERE id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) GetContact(ctx context.Context, id uuid.UUID) (Contact, error) {
@@ -125,12 +127,54 @@ func (q *Queries) GetContact(ctx context.Context, id uuid.UUID) (Contact, error)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
 	)
 	return i, err
 }
 
+const listContacts = `-- name: ListContacts :many
+SELECT id, customer_id, name, role, email, phone, avatar, is_primary, notes, created_at, updated_at, deleted_at, version FROM contacts WHERE deleted_at IS NULL ORDER BY name
+`
+
+func (q *Queries) ListContacts(ctx context.Context) ([]Contact, error) {
+	rows, err := q.db.QueryContext(ctx, listContacts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Contact
+	for rows.Next() {
+		var i Contact
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.Name,
+			&i.Role,
+			&i.Email,
+			&i.Phone,
+			&i.Avatar,
+			&i.IsPrimary,
+			&i.Notes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listContactsByCustomer = `-- name: ListContactsByCustomer :many
-SELECT id, customer_id, name, role, email, phone, avatar, is_primary, notes, created_at, updated_at, deleted_at FROM contacts WHERE customer_id = ? AND deleted_at IS NULL ORDER BY is_primary DESC, created_at DESC
+SELECT id, customer_id, name, role, email, phone, avatar, is_primary, notes, created_at, updated_at, deleted_at, version FROM contacts WHERE customer_id = ? AND deleted_at IS NULL ORDER BY is_primary DESC, created_at DESC
 `
 
 func (q *Queries) ListContactsByCustomer(ctx context.Context, customerID uuid.UUID) ([]Contact, error) {
@@ -155,6 +199,7 @@ func (q *Queries) ListContactsByCustomer(ctx context.Context, customerID uuid.UU
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -171,8 +216,8 @@ func (q *Queries) ListContactsByCustomer(ctx context.Context, customerID uuid.UU
 
 const unsetOtherPrimaryContacts = `-- name: UnsetOtherPrimaryContacts :exec
 UPDATE contacts
-SET is_primary = 0
-WHERE customer_id = ? AND id != ? AND deleted_at IS NULL
+SET is_primary = 0, version = version + 1
+WHERE customer_id = ? AND id != ? AND is_primary = 1 AND deleted_at IS NULL
 `
 
 type UnsetOtherPrimaryContactsParams struct {
@@ -185,10 +230,11 @@ func (q *Queries) UnsetOtherPrimaryContacts(ctx context.Context, arg UnsetOtherP
 	return err
 }
 
-const updateContact = `-- name: UpdateContact :exec
+const updateContact = `-- name: UpdateContact :one
 UPDATE contacts
-SET name = ?, role = ?, email = ?, phone = ?, is_primary = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
-WHERE id = ?
+SET name = ?, role = ?, email = ?, phone = ?, is_primary = ?, notes = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+WHERE id = ? AND version = ? AND deleted_at IS NULL
+RETURNING id, customer_id, name, role, email, phone, avatar, is_primary, notes, created_at, updated_at, deleted_at, version
 `
 
 type UpdateContactParams struct {
@@ -199,10 +245,11 @@ type UpdateContactParams struct {
 	IsPrimary sql.NullBool
 	Notes     sql.NullString
 	ID        uuid.UUID
+	Version   int64
 }
 
-func (q *Queries) UpdateContact(ctx context.Context, arg UpdateContactParams) error {
-	_, err := q.db.ExecContext(ctx, updateContact,
+func (q *Queries) UpdateContact(ctx context.Context, arg UpdateContactParams) (Contact, error) {
+	row := q.db.QueryRowContext(ctx, updateContact,
 		arg.Name,
 		arg.Role,
 		arg.Email,
@@ -210,8 +257,25 @@ func (q *Queries) UpdateContact(ctx context.Context, arg UpdateContactParams) er
 		arg.IsPrimary,
 		arg.Notes,
 		arg.ID,
+		arg.Version,
 	)
-	return err
+	var i Contact
+	err := row.Scan(
+		&i.ID,
+		&i.CustomerID,
+		&i.Name,
+		&i.Role,
+		&i.Email,
+		&i.Phone,
+		&i.Avatar,
+		&i.IsPrimary,
+		&i.Notes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Version,
+	)
+	return i, err
 }
 
 const updateContactAvatar = `-- name: UpdateContactAvatar :exec
@@ -13,20 +13,21 @@ import (
 )
 
 const createCustomer = `-- name: CreateCustomer :one
-INSERT INTO customers (name, logo, status, email, phone, address, website, notes)
-VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-RETURNING id, name, logo, status, email, phone, address, website, notes, created_at, updated_at, deleted_at
+INSERT INTO customers (name, logo, status, email, phone, address, website, notes, currency)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+RETURNING id, name, logo, status, email, phone, address, website, notes, created_at, updated_at, deleted_at, version, currency
 `
 
 type CreateCustomerParams struct {
-	Name    string
-	Logo    sql.NullString
-	Status  string
-	Email   sql.NullString
-	Phone   sql.NullString
-	Address sql.NullString
-	Website sql.NullString
-	Notes   sql.NullString
+	Name     string
+	Logo     sql.NullString
+	Status   string
+	Email    sql.NullString
+	Phone    sql.NullString
+	Address  sql.NullString
+	Website  sql.NullString
+	Notes    sql.NullString
+	Currency string
 }
 
 func (q *Queries) CreateCustomer(ctx context.Context, arg CreateCustomerParams) (Customer, error) {
@@ -39,6 +40,7 @@ func (q *Queries) CreateCustomer(ctx context.Context, arg CreateCustomerParams)
 		arg.Address,
 		arg.Website,
 		arg.Notes,
+		arg.Currency,
 	)
 	var i Customer
 	err := row.Scan(
@@ -54,6 +56,8 @@ func (q *Queries) CreateCustomer(ctx context.Context, arg CreateCustomerParams)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
+		&i.Currency,
 	)
 	return i, err
 }
@@ -62,7 +66,7 @@ const deleteCustomer = `-- name: DeleteCustomer :one
 UPDATE customers
 SET deleted_at = datetime('now')
 WHERE id = ?
-RETURNING id, name, logo, status, email, phone, address, website, notes, created_at, updated_at, deleted_at
+RETURNING id, name, logo, status, email, phone, address, website, notes, created_at, updated_at, deleted_at, version, currency
 `
 
 func (q *Queries) DeleteCustomer(ctx context.Context, id uuid.UUID) (Customer, error) {
@@ -81,17 +85,18 @@ func (q *Queries) DeleteCustomer(ctx context.Context, id uuid.UUID) (Customer, e
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
+		&i.Currency,
 	)
 	return i, err
 }
 
 const getCustomer = `-- name: GetCustomer :one
 SELECT
-    c.id, c.name, c.logo, c.status, c.email, c.phone, c.address, c.website, c.notes, c.created_at, c.updated_at, c.deleted_at,
+    c.id, c.name, c.logo, c.status, c.email, c.phone, c.address, c.website, c.notes, c.created_at, c.updated_at, c.deleted_at, c.version, c.currency,
     (SELECT COUNT(*) FROM contacts WHERE customer_id = c.id AND deleted_at IS NULL) AS contact_count,
     (SELECT COUNT(*) FROM subscriptions WHERE customer_id = c.id AND deleted_at IS NULL) AS subscription_count,
     8 AS project_count, -- TODO:
-    (SELECT SUM(amount) FROM subscriptions WHERE customer_id = c.id AND deleted_at IS NULL) AS subscription_revenue,
     267 AS monthly_revenue, -- TODO:
     15 AS revenue_change -- TODO:
 FROM customers c
@@ -99,24 +104,25 @@ WHERE c.id = ? AND c.deleted_at IS NULL
 `
 
 type GetCustomerRow struct {
-	ID                  uuid.UUID
-	Name                string
-	Logo                sql.NullString
-	Status              string
-	Email               sql.NullString
-	Phone               sql.NullString
-	Address             sql.NullString
-	Website             sql.NullString
-	Notes               sql.NullString
-	CreatedAt           sql.NullTime
-	UpdatedAt           sql.NullTime
-	DeletedAt           sql.NullTime
-	ContactCount        int64
-	SubscriptionCount   int64
-	ProjectCount        int64
-	SubscriptionRevenue sql.NullFloat64
-	MonthlyRevenue      int64
-	RevenueChange       int64
+	ID                uuid.UUID
+	Name              string
+	Logo              sql.NullString
+	Status            string
+	Email             sql.NullString
+	Phone             sql.NullString
+	Address           sql.NullString
+	Website           sql.NullString
+	Notes             sql.NullString
+	CreatedAt         sql.NullTime
+	UpdatedAt         sql.NullTime
+	DeletedAt         sql.NullTime
+	Version           int64
+	Currency          string
+	ContactCount      int64
+	SubscriptionCount int64
+	ProjectCount      int64
+	MonthlyRevenue    int64
+	RevenueChange     int64
 }
 
 func (q *Queries) GetCustomer(ctx context.Context, id uuid.UUID) (GetCustomerRow, error) {
@@ -135,10 +141,11 @@ func (q *Queries) GetCustomer(ctx context.Context, id uuid.UUID) (GetCustomerRow
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
+		&i.Currency,
 		&i.ContactCount,
 		&i.SubscriptionCount,
 		&i.ProjectCount,
-		&i.SubscriptionRevenue,
 		&i.MonthlyRevenue,
 		&i.RevenueChange,
 	)
@@ -146,7 +153,7 @@ func (q *Queries) GetCustomer(ctx context.Context, id uuid.UUID) (GetCustomerRow
 }
 
 const listCustomers = `-- name: ListCustomers :many
-SELECT id, name, logo, status, email, phone, address, website, notes, created_at, updated_at, deleted_at FROM customers WHERE deleted_at IS NULL ORDER BY created_at DESC
+SELECT id, name, logo, status, email, phone, address, website, notes, created_at, updated_at, deleted_at, version, currency FROM customers WHERE deleted_at IS NULL ORDER BY created_at DESC
 `
 
 func (q *Queries) ListCustomers(ctx context.Context) ([]Customer, error) {
@@ -171,6 +178,75 @@ func (q *Queries) ListCustomers(ctx context.Context) ([]Customer, error) {
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
+			&i.Version,
+			&i.Currency,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCustomersFiltered = `-- name: ListCustomersFiltered :many
+SELECT c.id, c.name, c.logo, c.status, c.email, c.phone, c.address, c.website, c.notes, c.created_at, c.updated_at, c.deleted_at, c.version, c.currency FROM customers c
+WHERE c.deleted_at IS NULL
+  AND (?1 IS NULL OR EXISTS (
+    SELECT 1 FROM customer_tags ct WHERE ct.customer_id = c.id AND ct.tag_id = ?1
+  ))
+  AND (?2 IS NULL OR c.status = ?2)
+  AND (?3 IS NULL OR c.created_at >= datetime(?3))
+  AND (?4 IS NULL OR c.created_at < datetime(?4, '+1 day'))
+ORDER BY
+  CASE WHEN ?5 = 'name' THEN c.name END COLLATE NOCASE ASC,
+  CASE WHEN ?5 = 'oldest' THEN c.created_at END ASC,
+  c.created_at DESC
+`
+
+type ListCustomersFilteredParams struct {
+	TagID       uuid.NullUUID
+	Status      sql.NullString
+	CreatedFrom interface{}
+	CreatedTo   interface{}
+	Sort        interface{}
+}
+
+func (q *Queries) ListCustomersFiltered(ctx context.Context, arg ListCustomersFilteredParams) ([]Customer, error) {
+	rows, err := q.db.QueryContext(ctx, listCustomersFiltered,
+		arg.TagID,
+		arg.Status,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.Sort,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Customer
+	for rows.Next() {
+		var i Customer
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Logo,
+			&i.Status,
+			&i.Email,
+			&i.Phone,
+			&i.Address,
+			&i.Website,
+			&i.Notes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.Version,
+			&i.Currency,
 		); err != nil {
 			return nil, err
 		}
@@ -187,21 +263,23 @@ func (q *Queries) ListCustomers(ctx context.Context) ([]Customer, error) {
 
 const updateCustomer = `-- name: UpdateCustomer :one
 UPDATE customers
-SET name = ?, logo = ?, status = ?, email = ?, phone = ?, address = ?, website = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
-WHERE id = ?
-RETURNING id, name, logo, status, email, phone, address, website, notes, created_at, updated_at, deleted_at
+SET name = ?, logo = ?, status = ?, email = ?, phone = ?, address = ?, website = ?, notes = ?, currency = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+WHERE id = ? AND version = ? AND deleted_at IS NULL
+RETURNING id, name, logo, status, email, phone, address, website, notes, created_at, updated_at, deleted_at, version, currency
 `
 
 type UpdateCustomerParams struct {
-	Name    string
-	Logo    sql.NullString
-	Status  string
-	Email   sql.NullString
-	Phone   sql.NullString
-	Address sql.NullString
-	Website sql.NullString
-	Notes   sql.NullString
-	ID      uuid.UUID
+	Name     string
+	Logo     sql.NullString
+	Status   string
+	Email    sql.NullString
+	Phone    sql.NullString
+	Address  sql.NullString
+	Website  sql.NullString
+	Notes    sql.NullString
+	Currency string
+	ID       uuid.UUID
+	Version  int64
 }
 
 func (q *Queries) UpdateCustomer(ctx context.Context, arg UpdateCustomerParams) (Customer, error) {
@@ -214,7 +292,9 @@ func (q *Queries) UpdateCustomer(ctx context.Context, arg UpdateCustomerParams)
 		arg.Address,
 		arg.Website,
 		arg.Notes,
+		arg.Currency,
 		arg.ID,
+		arg.Version,
 	)
 	var i Customer
 	err := row.Scan(
@@ -230,6 +310,8 @@ func (q *Queries) UpdateCustomer(ctx context.Context, arg UpdateCustomerParams)
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
+		&i.Currency,
 	)
 	return i, err
 }
@@ -249,3 +331,37 @@ func (q *Queries) UpdateCustomerLogo(ctx context.Context, arg UpdateCustomerLogo
 	_, err := q.db.ExecContext(ctx, updateCustomerLogo, arg.Logo, arg.ID)
 	return err
 }
+
+const updateCustomerStatus = `-- name: UpdateCustomerStatus :one
+UPDATE customers
+SET status = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+WHERE id = ? AND deleted_at IS NULL
+RETURNING id, name, logo, status, email, phone, address, website, notes, created_at, updated_at, deleted_at, version, currency
+`
+
+type UpdateCustomerStatusParams struct {
+	Status string
+	ID     uuid.UUID
+}
+
+func (q *Queries) UpdateCustomerStatus(ctx context.Context, arg UpdateCustomerStatusParams) (Customer, error) {
+	row := q.db.QueryRowContext(ctx, updateCustomerStatus, arg.Status, arg.ID)
+	var i Customer
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Logo,
+		&i.Status,
+		&i.Email,
+		&i.Phone,
+		&i.Address,
+		&i.Website,
+		&i.Notes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Version,
+		&i.Currency,
+	)
+	return i, err
+}
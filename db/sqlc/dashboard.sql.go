@@ -73,10 +73,11 @@ SELECT
     al.action,
     al.description,
     al.created_at,
+    al.actor,
     c.name AS customer_name
 FROM
     activity_log al
-JOIN
+LEFT JOIN
     customers c ON al.customer_id = c.id
 WHERE c.deleted_at IS NULL
 ORDER BY
@@ -86,12 +87,13 @@ LIMIT 10
 
 type GetRecentActivityRow struct {
 	ID           uuid.UUID
-	CustomerID   uuid.UUID
+	CustomerID   uuid.NullUUID
 	ActivityType string
 	Action       string
 	Description  string
 	CreatedAt    sql.NullTime
-	CustomerName string
+	Actor        sql.NullString
+	CustomerName sql.NullString
 }
 
 // TODO:
@@ -111,6 +113,7 @@ func (q *Queries) GetRecentActivity(ctx context.Context) ([]GetRecentActivityRow
 			&i.Action,
 			&i.Description,
 			&i.CreatedAt,
+			&i.Actor,
 			&i.CustomerName,
 		); err != nil {
 			return nil, err
@@ -9,15 +9,39 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/scottmckendry/beam/money"
 )
 
 type ActivityLog struct {
 	ID           uuid.UUID
-	CustomerID   uuid.UUID
+	CustomerID   uuid.NullUUID
 	ActivityType string
 	Action       string
 	Description  string
 	CreatedAt    sql.NullTime
+	AnonymisedAt sql.NullTime
+	Actor        sql.NullString
+	Changes      sql.NullString
+	Seq          sql.NullInt64
+	PrevHash     sql.NullString
+	ContentHash  sql.NullString
+	Hash         sql.NullString
+}
+
+type AppPassword struct {
+	ID           uuid.UUID
+	UserID       uuid.UUID
+	Name         string
+	PasswordHash string
+	CreatedAt    sql.NullTime
+	LastUsedAt   sql.NullTime
+}
+
+type CalendarToken struct {
+	UserID     uuid.UUID
+	Token      string
+	CreatedAt  sql.NullTime
+	LastUsedAt sql.NullTime
 }
 
 type Contact struct {
@@ -33,6 +57,36 @@ type Contact struct {
 	CreatedAt  sql.NullTime
 	UpdatedAt  sql.NullTime
 	DeletedAt  sql.NullTime
+	Version    int64
+}
+
+type Coupon struct {
+	ID              uuid.UUID
+	Code            string
+	Description     string
+	Kind            string
+	Percent         int64
+	Amount          money.Amount
+	Currency        sql.NullString
+	DurationPeriods sql.NullInt64
+	Status          string
+	CreatedAt       sql.NullTime
+}
+
+type CustomField struct {
+	ID        uuid.UUID
+	Entity    string
+	Name      string
+	FieldType string
+	Options   string
+	CreatedAt sql.NullTime
+}
+
+type CustomFieldValue struct {
+	FieldID   uuid.UUID
+	RecordID  uuid.UUID
+	Value     string
+	UpdatedAt sql.NullTime
 }
 
 type Customer struct {
@@ -48,6 +102,32 @@ type Customer struct {
 	CreatedAt sql.NullTime
 	UpdatedAt sql.NullTime
 	DeletedAt sql.NullTime
+	Version   int64
+	Currency  string
+}
+
+type CustomerProductPrice struct {
+	ID             uuid.UUID
+	CustomerID     uuid.UUID
+	ProductID      uuid.UUID
+	BillingCadence string
+	Currency       string
+	UnitPrice      money.Amount
+	CreatedAt      sql.NullTime
+}
+
+type CustomerTag struct {
+	CustomerID uuid.UUID
+	TagID      uuid.UUID
+}
+
+type ExchangeRate struct {
+	ID            uuid.UUID
+	FromCurrency  string
+	ToCurrency    string
+	Rate          float64
+	EffectiveFrom time.Time
+	CreatedAt     sql.NullTime
 }
 
 type Migration struct {
@@ -56,20 +136,129 @@ type Migration struct {
 	Applied time.Time
 }
 
+type Product struct {
+	ID        uuid.UUID
+	Sku       string
+	Name      string
+	Status    string
+	CreatedAt sql.NullTime
+	UpdatedAt sql.NullTime
+}
+
+type ProductPrice struct {
+	ID             uuid.UUID
+	ProductID      uuid.UUID
+	BillingCadence string
+	Currency       string
+	UnitPrice      money.Amount
+	CreatedAt      sql.NullTime
+}
+
+type RetentionRun struct {
+	ID         uuid.UUID
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Report     string
+}
+
+type SavedFilter struct {
+	ID          uuid.UUID
+	Name        string
+	TagID       uuid.NullUUID
+	Status      sql.NullString
+	CreatedFrom sql.NullString
+	CreatedTo   sql.NullString
+	Sort        string
+	CreatedAt   sql.NullTime
+}
+
 type Subscription struct {
+	ID               uuid.UUID
+	CustomerID       uuid.UUID
+	Description      string
+	Amount           money.Amount
+	Term             string
+	BillingCadence   string
+	StartDate        time.Time
+	EndDate          sql.NullTime
+	Status           string
+	Notes            sql.NullString
+	CreatedAt        sql.NullTime
+	UpdatedAt        sql.NullTime
+	DeletedAt        sql.NullTime
+	Version          int64
+	Currency         string
+	BillingInterval  sql.NullInt64
+	BillingAnchorDay sql.NullInt64
+	Quantity         int64
+	UnitPrice        money.Amount
+	ProductID        uuid.NullUUID
+	TrialEndsOn      sql.NullTime
+	CostAmount       money.Amount
+	CostCadence      sql.NullString
+	CostChangedOn    sql.NullTime
+}
+
+type SubscriptionAdjustment struct {
 	ID             uuid.UUID
-	CustomerID     uuid.UUID
+	SubscriptionID uuid.UUID
+	PriceID        uuid.UUID
+	Amount         money.Amount
+	Currency       string
 	Description    string
-	Amount         float64
-	Term           string
-	BillingCadence string
-	StartDate      time.Time
-	EndDate        sql.NullTime
-	Status         string
-	Notes          sql.NullString
+	PeriodStart    time.Time
+	PeriodEnd      time.Time
+	InvoicedAt     sql.NullTime
 	CreatedAt      sql.NullTime
-	UpdatedAt      sql.NullTime
-	DeletedAt      sql.NullTime
+}
+
+type SubscriptionDiscount struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	CouponID       uuid.NullUUID
+	Kind           string
+	Percent        int64
+	Amount         money.Amount
+	Currency       string
+	StartsOn       time.Time
+	EndsOn         sql.NullTime
+	Note           sql.NullString
+	CreatedBy      sql.NullString
+	CreatedAt      sql.NullTime
+	RemovedAt      sql.NullTime
+}
+
+type SubscriptionPrice struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	Amount         money.Amount
+	Currency       string
+	EffectiveFrom  time.Time
+	Note           sql.NullString
+	CreatedBy      sql.NullString
+	CreatedAt      sql.NullTime
+	Quantity       int64
+	UnitPrice      money.Amount
+}
+
+type SubscriptionStatusChange struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	FromStatus     sql.NullString
+	ToStatus       string
+	EffectiveDate  time.Time
+	Note           sql.NullString
+	CreatedBy      sql.NullString
+	CreatedAt      sql.NullTime
+	AppliedAt      sql.NullTime
+	SkippedReason  sql.NullString
+}
+
+type Tag struct {
+	ID        uuid.UUID
+	Name      string
+	Colour    string
+	CreatedAt sql.NullTime
 }
 
 type User struct {
@@ -11,22 +11,33 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/scottmckendry/beam/money"
 )
 
 const createSubscription = `-- name: CreateSubscription :one
-INSERT INTO subscriptions ( customer_id, description, amount, term, billing_cadence, status, start_date, notes)
-VALUES ( ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at
+INSERT INTO subscriptions ( customer_id, description, amount, term, billing_cadence, status, start_date, notes, currency, billing_interval, billing_anchor_day, quantity, unit_price, product_id, trial_ends_on, cost_amount, cost_cadence, cost_changed_on)
+VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at, version, currency, billing_interval, billing_anchor_day, quantity, unit_price, product_id, trial_ends_on, cost_amount, cost_cadence, cost_changed_on
 `
 
 type CreateSubscriptionParams struct {
-	CustomerID     uuid.UUID
-	Description    string
-	Amount         float64
-	Term           string
-	BillingCadence string
-	Status         string
-	StartDate      time.Time
-	Notes          sql.NullString
+	CustomerID       uuid.UUID
+	Description      string
+	Amount           money.Amount
+	Term             string
+	BillingCadence   string
+	Status           string
+	StartDate        time.Time
+	Notes            sql.NullString
+	Currency         string
+	BillingInterval  sql.NullInt64
+	BillingAnchorDay sql.NullInt64
+	Quantity         int64
+	UnitPrice        money.Amount
+	ProductID        uuid.NullUUID
+	TrialEndsOn      sql.NullTime
+	CostAmount       money.Amount
+	CostCadence      sql.NullString
+	CostChangedOn    sql.NullTime
 }
 
 func (q *Queries) CreateSubscription(ctx context.Context, arg CreateSubscriptionParams) (Subscription, error) {
@@ -39,6 +50,16 @@ func (q *Queries) CreateSubscription(ctx context.Context, arg CreateSubscription
 		arg.Status,
 		arg.StartDate,
 		arg.Notes,
+		arg.Currency,
+		arg.BillingInterval,
+		arg.BillingAnchorDay,
+		arg.Quantity,
+		arg.UnitPrice,
+		arg.ProductID,
+		arg.TrialEndsOn,
+		arg.CostAmount,
+		arg.CostCadence,
+		arg.CostChangedOn,
 	)
 	var i Subscription
 	err := row.Scan(
@@ -55,12 +76,23 @@ func (q *Queries) CreateSubscription(ctx context.Context, arg CreateSubscription
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
+		&i.Currency,
+		&i.BillingInterval,
+		&i.BillingAnchorDay,
+		&i.Quantity,
+		&i.UnitPrice,
+		&i.ProductID,
+		&i.TrialEndsOn,
+		&i.CostAmount,
+		&i.CostCadence,
+		&i.CostChangedOn,
 	)
 	return i, err
 }
 
 const deleteSubscription = `-- name: DeleteSubscription :one
-UPDATE subscriptions SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL RETURNING id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at
+UPDATE subscriptions SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL RETURNING id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at, version, currency, billing_interval, billing_anchor_day, quantity, unit_price, product_id, trial_ends_on, cost_amount, cost_cadence, cost_changed_on
 `
 
 func (q *Queries) DeleteSubscription(ctx context.Context, id uuid.UUID) (Subscription, error) {
@@ -80,12 +112,23 @@ func (q *Queries) DeleteSubscription(ctx context.Context, id uuid.UUID) (Subscri
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
+		&i.Currency,
+		&i.BillingInterval,
+		&i.BillingAnchorDay,
+		&i.Quantity,
+		&i.UnitPrice,
+		&i.ProductID,
+		&i.TrialEndsOn,
+		&i.CostAmount,
+		&i.CostCadence,
+		&i.CostChangedOn,
 	)
 	return i, err
 }
 
 const getSubscription = `-- name: GetSubscription :one
-SELECT id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at FROM subscriptions WHERE id = ? AND deleted_at IS NULL
+SELECT id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at, version, currency, billing_interval, billing_anchor_day, quantity, unit_price, product_id, trial_ends_on, cost_amount, cost_cadence, cost_changed_on FROM subscriptions WHERE id = ? AND deleted_at IS NULL
 `
 
 func (q *Queries) GetSubscription(ctx context.Context, id uuid.UUID) (Subscription, error) {
@@ -105,40 +148,127 @@ func (q *Queries) GetSubscription(ctx context.Context, id uuid.UUID) (Subscripti
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
+		&i.Currency,
+		&i.BillingInterval,
+		&i.BillingAnchorDay,
+		&i.Quantity,
+		&i.UnitPrice,
+		&i.ProductID,
+		&i.TrialEndsOn,
+		&i.CostAmount,
+		&i.CostCadence,
+		&i.CostChangedOn,
 	)
 	return i, err
 }
 
-const listSubscriptionsByCustomer = `-- name: ListSubscriptionsByCustomer :many
-SELECT s.id, s.customer_id, s.description, s.amount, s.term, s.billing_cadence, s.start_date, s.end_date, s.status, s.notes, s.created_at, s.updated_at, s.deleted_at, s.start_date as next_billing_date FROM subscriptions s WHERE customer_id = ? AND deleted_at IS NULL ORDER BY created_at DESC
+const listActiveSubscriptions = `-- name: ListActiveSubscriptions :many
+SELECT id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at, version, currency, billing_interval, billing_anchor_day, quantity, unit_price, product_id, trial_ends_on, cost_amount, cost_cadence, cost_changed_on FROM subscriptions WHERE deleted_at IS NULL AND status = 'active' ORDER BY customer_id, created_at
 `
 
-type ListSubscriptionsByCustomerRow struct {
-	ID              uuid.UUID
-	CustomerID      uuid.UUID
-	Description     string
-	Amount          float64
-	Term            string
-	BillingCadence  string
-	StartDate       time.Time
-	EndDate         sql.NullTime
-	Status          string
-	Notes           sql.NullString
-	CreatedAt       sql.NullTime
-	UpdatedAt       sql.NullTime
-	DeletedAt       sql.NullTime
-	NextBillingDate time.Time
+func (q *Queries) ListActiveSubscriptions(ctx context.Context) ([]Subscription, error) {
+	rows, err := q.db.QueryContext(ctx, listActiveSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Subscription
+	for rows.Next() {
+		var i Subscription
+		if err := rows.Scan(
+			&i.ID,
+			&i.CustomerID,
+			&i.Description,
+			&i.Amount,
+			&i.Term,
+			&i.BillingCadence,
+			&i.StartDate,
+			&i.EndDate,
+			&i.Status,
+			&i.Notes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.Version,
+			&i.Currency,
+			&i.BillingInterval,
+			&i.BillingAnchorDay,
+			&i.Quantity,
+			&i.UnitPrice,
+			&i.ProductID,
+			&i.TrialEndsOn,
+			&i.CostAmount,
+			&i.CostCadence,
+			&i.CostChangedOn,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSeatTotalsByCustomer = `-- name: ListSeatTotalsByCustomer :many
+SELECT
+    CAST(COALESCE(p.name, s.description) AS TEXT) AS description,
+    CAST(COALESCE(SUM(s.quantity), 0) AS INTEGER) AS seats,
+    COUNT(*) AS subscription_count
+FROM subscriptions s
+LEFT JOIN products p ON p.id = s.product_id
+WHERE s.customer_id = ? AND s.deleted_at IS NULL AND s.status = 'active'
+GROUP BY COALESCE(p.name, s.description)
+ORDER BY description
+`
+
+type ListSeatTotalsByCustomerRow struct {
+	Description       string
+	Seats             int64
+	SubscriptionCount int64
 }
 
-func (q *Queries) ListSubscriptionsByCustomer(ctx context.Context, customerID uuid.UUID) ([]ListSubscriptionsByCustomerRow, error) {
+func (q *Queries) ListSeatTotalsByCustomer(ctx context.Context, customerID uuid.UUID) ([]ListSeatTotalsByCustomerRow, error) {
+	rows, err := q.db.QueryContext(ctx, listSeatTotalsByCustomer, customerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListSeatTotalsByCustomerRow
+	for rows.Next() {
+		var i ListSeatTotalsByCustomerRow
+		if err := rows.Scan(&i.Description, &i.Seats, &i.SubscriptionCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSubscriptionsByCustomer = `-- name: ListSubscriptionsByCustomer :many
+SELECT id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at, version, currency, billing_interval, billing_anchor_day, quantity, unit_price, product_id, trial_ends_on, cost_amount, cost_cadence, cost_changed_on FROM subscriptions WHERE customer_id = ? AND deleted_at IS NULL ORDER BY created_at DESC
+`
+
+func (q *Queries) ListSubscriptionsByCustomer(ctx context.Context, customerID uuid.UUID) ([]Subscription, error) {
 	rows, err := q.db.QueryContext(ctx, listSubscriptionsByCustomer, customerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListSubscriptionsByCustomerRow
+	var items []Subscription
 	for rows.Next() {
-		var i ListSubscriptionsByCustomerRow
+		var i Subscription
 		if err := rows.Scan(
 			&i.ID,
 			&i.CustomerID,
@@ -153,7 +283,17 @@ func (q *Queries) ListSubscriptionsByCustomer(ctx context.Context, customerID uu
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.DeletedAt,
-			&i.NextBillingDate,
+			&i.Version,
+			&i.Currency,
+			&i.BillingInterval,
+			&i.BillingAnchorDay,
+			&i.Quantity,
+			&i.UnitPrice,
+			&i.ProductID,
+			&i.TrialEndsOn,
+			&i.CostAmount,
+			&i.CostCadence,
+			&i.CostChangedOn,
 		); err != nil {
 			return nil, err
 		}
@@ -168,21 +308,72 @@ func (q *Queries) ListSubscriptionsByCustomer(ctx context.Context, customerID uu
 	return items, nil
 }
 
+const setSubscriptionStatus = `-- name: SetSubscriptionStatus :one
+UPDATE subscriptions SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND deleted_at IS NULL RETURNING id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at, version, currency, billing_interval, billing_anchor_day, quantity, unit_price, product_id, trial_ends_on, cost_amount, cost_cadence, cost_changed_on
+`
+
+type SetSubscriptionStatusParams struct {
+	Status string
+	ID     uuid.UUID
+}
+
+func (q *Queries) SetSubscriptionStatus(ctx context.Context, arg SetSubscriptionStatusParams) (Subscription, error) {
+	row := q.db.QueryRowContext(ctx, setSubscriptionStatus, arg.Status, arg.ID)
+	var i Subscription
+	err := row.Scan(
+		&i.ID,
+		&i.CustomerID,
+		&i.Description,
+		&i.Amount,
+		&i.Term,
+		&i.BillingCadence,
+		&i.StartDate,
+		&i.EndDate,
+		&i.Status,
+		&i.Notes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Version,
+		&i.Currency,
+		&i.BillingInterval,
+		&i.BillingAnchorDay,
+		&i.Quantity,
+		&i.UnitPrice,
+		&i.ProductID,
+		&i.TrialEndsOn,
+		&i.CostAmount,
+		&i.CostCadence,
+		&i.CostChangedOn,
+	)
+	return i, err
+}
+
 const updateSubscription = `-- name: UpdateSubscription :one
-UPDATE subscriptions SET description = ?, amount = ?, term = ?, billing_cadence = ?, status = ?, start_date = ?, notes = ?, updated_at = CURRENT_TIMESTAMP
-WHERE id = ? AND deleted_at IS NULL
-RETURNING id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at
+UPDATE subscriptions SET description = ?, amount = ?, term = ?, billing_cadence = ?, start_date = ?, notes = ?, currency = ?, billing_interval = ?, billing_anchor_day = ?, quantity = ?, unit_price = ?, product_id = ?, trial_ends_on = ?, cost_amount = ?, cost_cadence = ?, cost_changed_on = ?, updated_at = CURRENT_TIMESTAMP, version = version + 1
+WHERE id = ? AND version = ? AND deleted_at IS NULL
+RETURNING id, customer_id, description, amount, term, billing_cadence, start_date, end_date, status, notes, created_at, updated_at, deleted_at, version, currency, billing_interval, billing_anchor_day, quantity, unit_price, product_id, trial_ends_on, cost_amount, cost_cadence, cost_changed_on
 `
 
 type UpdateSubscriptionParams struct {
-	Description    string
-	Amount         float64
-	Term           string
-	BillingCadence string
-	Status         string
-	StartDate      time.Time
-	Notes          sql.NullString
-	ID             uuid.UUID
+	Description      string
+	Amount           money.Amount
+	Term             string
+	BillingCadence   string
+	StartDate        time.Time
+	Notes            sql.NullString
+	Currency         string
+	BillingInterval  sql.NullInt64
+	BillingAnchorDay sql.NullInt64
+	Quantity         int64
+	UnitPrice        money.Amount
+	ProductID        uuid.NullUUID
+	TrialEndsOn      sql.NullTime
+	CostAmount       money.Amount
+	CostCadence      sql.NullString
+	CostChangedOn    sql.NullTime
+	ID               uuid.UUID
+	Version          int64
 }
 
 func (q *Queries) UpdateSubscription(ctx context.Context, arg UpdateSubscriptionParams) (Subscription, error) {
@@ -191,10 +382,20 @@ func (q *Queries) UpdateSubscription(ctx context.Context, arg UpdateSubscription
 		arg.Amount,
 		arg.Term,
 		arg.BillingCadence,
-		arg.Status,
 		arg.StartDate,
 		arg.Notes,
+		arg.Currency,
+		arg.BillingInterval,
+		arg.BillingAnchorDay,
+		arg.Quantity,
+		arg.UnitPrice,
+		arg.ProductID,
+		arg.TrialEndsOn,
+		arg.CostAmount,
+		arg.CostCadence,
+		arg.CostChangedOn,
 		arg.ID,
+		arg.Version,
 	)
 	var i Subscription
 	err := row.Scan(
@@ -211,6 +412,17 @@ func (q *Queries) UpdateSubscription(ctx context.Context, arg UpdateSubscription
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.DeletedAt,
+		&i.Version,
+		&i.Currency,
+		&i.BillingInterval,
+		&i.BillingAnchorDay,
+		&i.Quantity,
+		&i.UnitPrice,
+		&i.ProductID,
+		&i.TrialEndsOn,
+		&i.CostAmount,
+		&i.CostCadence,
+		&i.CostChangedOn,
 	)
 	return i, err
 }
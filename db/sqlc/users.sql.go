@@ -52,3 +52,36 @@ func (q *Queries) IsUserAdmin(ctx context.Context, githubID string) (bool, error
 	err := row.Scan(&is_admin)
 	return is_admin, err
 }
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, name, email, github_id, is_admin FROM users ORDER BY name
+`
+
+func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.GithubID,
+			&i.IsAdmin,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,299 @@
+// Package discounts takes money off what subscriptions are billed, and gives them free trials. A discount is a
+// percentage or a fixed amount off every billing date from the day it starts until the day it ends, or for as long as
+// the subscription lasts. A duration in billing periods is turned into an end date when the discount is given, so that
+// a later change to the billing schedule does not lengthen or shorten a discount already agreed. Coupons are discounts
+// offered under a code, and their terms are copied onto each subscription they are redeemed on. Nothing is billed for
+// a subscription until its free trial has ended.
+package discounts
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/currency"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/lifecycle"
+	"github.com/scottmckendry/beam/money"
+)
+
+// Kinds of discount.
+const (
+	Percent = "percent"
+	Fixed   = "fixed"
+)
+
+// Kinds lists every kind of discount, in the order they are offered.
+var Kinds = []string{Percent, Fixed}
+
+// Statuses of a coupon. Archived coupons can no longer be redeemed, but discounts already given from them are kept.
+const (
+	Active   = "active"
+	Archived = "archived"
+)
+
+// Statuses lists every coupon status, in the order they are offered.
+var Statuses = []string{Active, Archived}
+
+// TrialWindow is how many days ahead trials that are about to end are shown.
+const TrialWindow = 14
+
+// ErrCurrency is returned for fixed discounts in a different currency from the subscription they are given on.
+var ErrCurrency = errors.New("a fixed discount must be in the currency of the subscription")
+
+var codePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{0,31}$`)
+
+// Terms are what a discount takes off and for how long.
+type Terms struct {
+	Kind    string
+	Percent int64
+	Amount  money.Amount
+	// Currency is the currency of a fixed amount. Percentages are taken off in any currency.
+	Currency string
+	// Periods is the number of billing dates the discount lasts for, or zero for as long as the subscription lasts.
+	Periods int64
+}
+
+// ParseTerms checks the terms of a discount entered by hand. The value is a whole percentage for percentage discounts
+// and an amount of money for fixed ones, and a blank number of periods lasts for as long as the subscription.
+func ParseTerms(kind, value, currencyCode, periods string) (Terms, error) {
+	var err error
+	t := Terms{Kind: kind}
+	value = strings.TrimSpace(value)
+	switch kind {
+	case Percent:
+		if t.Percent, err = strconv.ParseInt(value, 10, 64); err != nil || t.Percent < 1 || t.Percent > 100 {
+			return t, fmt.Errorf("a percentage discount must be a whole number from 1 to 100")
+		}
+	case Fixed:
+		if t.Amount, err = money.Parse(value); err != nil {
+			return t, err
+		}
+		if t.Amount <= 0 {
+			return t, fmt.Errorf("a fixed discount must take off more than nothing")
+		}
+		if t.Currency, err = currency.Parse(currencyCode); err != nil {
+			return t, err
+		}
+	default:
+		return t, fmt.Errorf("invalid discount kind %q, expected one of %s", kind, strings.Join(Kinds, ", "))
+	}
+	if periods = strings.TrimSpace(periods); periods != "" {
+		if t.Periods, err = strconv.ParseInt(periods, 10, 64); err != nil || t.Periods < 1 {
+			return t, fmt.Errorf("a discount must last for at least one billing period")
+		}
+	}
+	return t, nil
+}
+
+// Label describes terms, such as "10% off for 12 billing periods".
+func (t Terms) Label() string {
+	off := fmt.Sprintf("%d%% off", t.Percent)
+	if t.Kind == Fixed {
+		off = fmt.Sprintf("%s off", money.New(t.Amount, t.Currency))
+	}
+	switch t.Periods {
+	case 0:
+		return off + " every billing period"
+	case 1:
+		return off + " for 1 billing period"
+	}
+	return fmt.Sprintf("%s for %d billing periods", off, t.Periods)
+}
+
+// CouponParams checks a coupon entered by hand, returning the parameters to save it with. Codes are kept in upper
+// case, and coupons without a description are described by their terms.
+func CouponParams(code, description string, t Terms) (db.CreateCouponParams, error) {
+	params := db.CreateCouponParams{
+		Code:            strings.ToUpper(strings.TrimSpace(code)),
+		Description:     strings.TrimSpace(description),
+		Kind:            t.Kind,
+		Percent:         t.Percent,
+		Amount:          t.Amount,
+		Currency:        sql.NullString{String: t.Currency, Valid: t.Currency != ""},
+		DurationPeriods: sql.NullInt64{Int64: t.Periods, Valid: t.Periods > 0},
+	}
+	if !codePattern.MatchString(params.Code) {
+		return params, fmt.Errorf("invalid coupon code %q, use up to 32 letters, digits, dashes and underscores", params.Code)
+	}
+	if params.Description == "" {
+		params.Description = t.Label()
+	}
+	return params, nil
+}
+
+// CheckStatus returns an error for statuses a coupon cannot have.
+func CheckStatus(status string) error {
+	if !slices.Contains(Statuses, status) {
+		return fmt.Errorf("invalid status %q, expected one of %s", status, strings.Join(Statuses, ", "))
+	}
+	return nil
+}
+
+// FromCoupon returns the terms of a coupon.
+func FromCoupon(c db.Coupon) Terms {
+	return Terms{Kind: c.Kind, Percent: c.Percent, Amount: c.Amount, Currency: c.Currency.String, Periods: c.DurationPeriods.Int64}
+}
+
+// Give returns the parameters to give a discount on a subscription from the day it starts. It ends on the day given,
+// or when that is the zero time, after the number of billing periods of its terms or never.
+func Give(sub db.Subscription, t Terms, startsOn, endsOn time.Time) (db.CreateSubscriptionDiscountParams, error) {
+	params := db.CreateSubscriptionDiscountParams{
+		SubscriptionID: sub.ID,
+		Kind:           t.Kind,
+		Percent:        t.Percent,
+		Amount:         t.Amount,
+		Currency:       sub.Currency,
+		StartsOn:       billing.Day(startsOn),
+	}
+	if t.Kind == Fixed && t.Currency != sub.Currency {
+		return params, ErrCurrency
+	}
+	if startsOn.IsZero() {
+		return params, fmt.Errorf("a discount needs a day to start from")
+	}
+	switch {
+	case !endsOn.IsZero():
+		if billing.Day(endsOn).Before(params.StartsOn) {
+			return params, fmt.Errorf("a discount cannot end before it starts")
+		}
+		params.EndsOn = sql.NullTime{Time: billing.Day(endsOn), Valid: true}
+	case t.Periods > 0:
+		end, ok := EndAfter(billing.FromSubscription(sub), params.StartsOn, int(t.Periods))
+		if !ok {
+			return params, fmt.Errorf("the subscription has no billing dates left to discount")
+		}
+		params.EndsOn = sql.NullTime{Time: end, Valid: true}
+	}
+	return params, nil
+}
+
+// EndAfter returns the last day of a discount that starts on a day and lasts for a number of billing dates, which is
+// the day before the first billing date it does not cover. A schedule that ends sooner ends the discount on its last
+// billing date. It is false when there are no billing dates left to discount.
+func EndAfter(s billing.Schedule, start time.Time, periods int) (time.Time, bool) {
+	dates := s.Upcoming(start, periods+1)
+	switch {
+	case len(dates) == 0:
+		return time.Time{}, false
+	case len(dates) <= periods:
+		return billing.Day(dates[len(dates)-1]), true
+	}
+	return billing.Day(dates[periods]).AddDate(0, 0, -1), true
+}
+
+// Covers reports whether a discount is taken off what is billed on a day.
+func Covers(d db.SubscriptionDiscount, on time.Time) bool {
+	on = billing.Day(on)
+	if d.RemovedAt.Valid || on.Before(billing.Day(d.StartsOn)) {
+		return false
+	}
+	return !d.EndsOn.Valid || !on.After(billing.Day(d.EndsOn.Time))
+}
+
+// Apply takes the discounts that cover a day off an amount. Percentages are added together and taken off first, then
+// fixed amounts, and what is left is never less than nothing. Fixed discounts in another currency, left over from
+// before the subscription changed currency, are not taken off.
+func Apply(amount money.Amount, code string, discounts []db.SubscriptionDiscount, on time.Time) money.Amount {
+	var percent int64
+	var fixed money.Amount
+	for _, d := range discounts {
+		if !Covers(d, on) {
+			continue
+		}
+		switch {
+		case d.Kind == Percent:
+			percent += d.Percent
+		case d.Kind == Fixed && d.Currency == code:
+			fixed += d.Amount
+		}
+	}
+	charged := amount.Mul(float64(100-min(percent, 100))/100) - fixed
+	return max(charged, 0)
+}
+
+// CheckTrial returns an error for a free trial that ends before the subscription starts.
+func CheckTrial(startDate time.Time, trialEndsOn sql.NullTime) error {
+	if trialEndsOn.Valid && billing.Day(trialEndsOn.Time).Before(billing.Day(startDate)) {
+		return fmt.Errorf("a free trial cannot end before the subscription starts")
+	}
+	return nil
+}
+
+// InTrial reports whether a subscription is still in its free trial on a day. The trial includes the day it ends.
+func InTrial(sub db.Subscription, on time.Time) bool {
+	return sub.TrialEndsOn.Valid && !billing.Day(on).After(billing.Day(sub.TrialEndsOn.Time))
+}
+
+// Charge returns what a subscription is billed on a day, which is nothing during its free trial and otherwise its
+// amount less its discounts. Discounts of other subscriptions are ignored, so the discounts of many subscriptions can
+// be passed together.
+func Charge(sub db.Subscription, discounts []db.SubscriptionDiscount, on time.Time) money.Amount {
+	if InTrial(sub, on) {
+		return 0
+	}
+	return Apply(sub.Amount, sub.Currency, Of(discounts, sub.ID), on)
+}
+
+// Monthly returns the monthly recurring revenue of a subscription on a day, which is what it would be charged that day
+// spread over the months between its billing dates.
+func Monthly(sub db.Subscription, discounts []db.SubscriptionDiscount, on time.Time) money.Amount {
+	return billing.FromSubscription(sub).Monthly(Charge(sub, discounts, on))
+}
+
+// Of lists the discounts of a subscription.
+func Of(discounts []db.SubscriptionDiscount, subscriptionID uuid.UUID) []db.SubscriptionDiscount {
+	var of []db.SubscriptionDiscount
+	for _, d := range discounts {
+		if d.SubscriptionID == subscriptionID {
+			of = append(of, d)
+		}
+	}
+	return of
+}
+
+// Describe describes a discount given on a subscription, such as "10% off from Jan 1, 2026 until Dec 31, 2026".
+func Describe(d db.SubscriptionDiscount) string {
+	off := fmt.Sprintf("%d%% off", d.Percent)
+	if d.Kind == Fixed {
+		off = fmt.Sprintf("%s off", money.New(d.Amount, d.Currency))
+	}
+	if !d.EndsOn.Valid {
+		return fmt.Sprintf("%s from %s", off, d.StartsOn.Format("Jan 2, 2006"))
+	}
+	return fmt.Sprintf("%s from %s until %s", off, d.StartsOn.Format("Jan 2, 2006"), d.EndsOn.Time.Format("Jan 2, 2006"))
+}
+
+// Trial is a free trial that is about to end. Trials convert into paid subscriptions when they end, unless the
+// subscription is paused or due to be cancelled, in which case they expire instead.
+type Trial struct {
+	db.ListTrialSubscriptionsRow
+	Converts bool
+}
+
+// Ending lists the trials that end from the day of now until TrialWindow days later, soonest first.
+func Ending(trials []db.ListTrialSubscriptionsRow, now time.Time) []Trial {
+	today := billing.Day(now)
+	until := today.AddDate(0, 0, TrialWindow)
+	var ending []Trial
+	for _, t := range trials {
+		if !t.TrialEndsOn.Valid {
+			continue
+		}
+		end := billing.Day(t.TrialEndsOn.Time)
+		if end.Before(today) || end.After(until) {
+			continue
+		}
+		ending = append(ending, Trial{ListTrialSubscriptionsRow: t, Converts: t.Status == lifecycle.Active && !t.Cancelling})
+	}
+	slices.SortStableFunc(ending, func(a, b Trial) int { return a.TrialEndsOn.Time.Compare(b.TrialEndsOn.Time) })
+	return ending
+}
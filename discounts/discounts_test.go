@@ -0,0 +1,262 @@
+package discounts
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/money"
+)
+
+func date(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func until(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: true}
+}
+
+func TestParseTerms(t *testing.T) {
+	terms, err := ParseTerms(Percent, " 10 ", "", "12")
+	if err != nil || terms.Percent != 10 || terms.Periods != 12 {
+		t.Errorf("expected 10%% off for 12 periods, got %+v (%v)", terms, err)
+	}
+	if terms.Label() != "10% off for 12 billing periods" {
+		t.Errorf("unexpected label %q", terms.Label())
+	}
+	terms, err = ParseTerms(Fixed, "5.50", "aud", "")
+	if err != nil || terms.Amount != 550 || terms.Currency != "AUD" || terms.Periods != 0 {
+		t.Errorf("expected A$5.50 off for good, got %+v (%v)", terms, err)
+	}
+
+	tests := []struct{ name, kind, value, currency, periods string }{
+		{"unknown kind", "free", "10", "", ""},
+		{"percentage over 100", Percent, "101", "", ""},
+		{"fractional percentage", Percent, "10.5", "", ""},
+		{"no percentage", Percent, "0", "", ""},
+		{"no fixed amount", Fixed, "0", "NZD", ""},
+		{"fixed amount in an unsupported currency", Fixed, "5", "GBP", ""},
+		{"no periods", Percent, "10", "", "0"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseTerms(tt.kind, tt.value, tt.currency, tt.periods); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestCouponParams(t *testing.T) {
+	params, err := CouponParams(" welcome10 ", "", Terms{Kind: Percent, Percent: 10, Periods: 3})
+	if err != nil || params.Code != "WELCOME10" || params.Description != "10% off for 3 billing periods" || params.DurationPeriods.Int64 != 3 {
+		t.Errorf("expected an upper case code described by its terms, got %+v (%v)", params, err)
+	}
+	if _, err := CouponParams("WELCOME 10", "", Terms{Kind: Percent, Percent: 10}); err == nil {
+		t.Errorf("expected codes with spaces to be rejected")
+	}
+}
+
+func TestGive(t *testing.T) {
+	sub := sqlc.Subscription{ID: uuid.New(), Currency: "NZD", StartDate: date(2026, 1, 15), BillingCadence: billing.Monthly}
+
+	params, err := Give(sub, Terms{Kind: Percent, Percent: 10, Periods: 3}, date(2026, 2, 1), time.Time{})
+	if err != nil {
+		t.Fatalf("Give failed: %v", err)
+	}
+	// Feb 15, Mar 15 and Apr 15 are discounted, so the discount ends the day before May 15
+	if !params.EndsOn.Valid || !params.EndsOn.Time.Equal(date(2026, 5, 14)) {
+		t.Errorf("expected the discount to end on May 14, got %v", params.EndsOn)
+	}
+
+	params, err = Give(sub, Terms{Kind: Percent, Percent: 10, Periods: 3}, date(2026, 2, 1), date(2026, 12, 31))
+	if err != nil || !params.EndsOn.Time.Equal(date(2026, 12, 31)) {
+		t.Errorf("expected an end date to take precedence over the periods, got %v (%v)", params.EndsOn, err)
+	}
+	if params, _ := Give(sub, Terms{Kind: Percent, Percent: 10}, date(2026, 2, 1), time.Time{}); params.EndsOn.Valid {
+		t.Errorf("expected a discount without periods to last for good, got %v", params.EndsOn)
+	}
+	if _, err := Give(sub, Terms{Kind: Fixed, Amount: 500, Currency: "AUD"}, date(2026, 2, 1), time.Time{}); err != ErrCurrency {
+		t.Errorf("expected ErrCurrency, got %v", err)
+	}
+	if _, err := Give(sub, Terms{Kind: Percent, Percent: 10}, date(2026, 2, 1), date(2026, 1, 1)); err == nil {
+		t.Errorf("expected a discount ending before it starts to be rejected")
+	}
+
+	ended := sub
+	ended.EndDate = until(date(2026, 3, 31))
+	if params, err := Give(ended, Terms{Kind: Percent, Percent: 10, Periods: 6}, date(2026, 2, 1), time.Time{}); err != nil || !params.EndsOn.Time.Equal(date(2026, 3, 15)) {
+		t.Errorf("expected the discount to end on the last billing date, got %v (%v)", params.EndsOn, err)
+	}
+}
+
+func TestCharge(t *testing.T) {
+	sub := sqlc.Subscription{ID: uuid.New(), Amount: 10000, Currency: "NZD", StartDate: date(2026, 1, 1), BillingCadence: billing.Quarterly}
+	discounts := []sqlc.SubscriptionDiscount{
+		{SubscriptionID: sub.ID, Kind: Percent, Percent: 10, Currency: "NZD", StartsOn: date(2026, 1, 1), EndsOn: until(date(2026, 12, 31))},
+		{SubscriptionID: sub.ID, Kind: Percent, Percent: 5, Currency: "NZD", StartsOn: date(2026, 4, 1)},
+		{SubscriptionID: sub.ID, Kind: Fixed, Amount: 1000, Currency: "NZD", StartsOn: date(2026, 7, 1), EndsOn: until(date(2026, 7, 1))},
+		{SubscriptionID: sub.ID, Kind: Fixed, Amount: 1000, Currency: "AUD", StartsOn: date(2026, 1, 1)},
+		{SubscriptionID: sub.ID, Kind: Percent, Percent: 50, Currency: "NZD", StartsOn: date(2026, 1, 1), RemovedAt: until(date(2026, 1, 2))},
+		{SubscriptionID: uuid.New(), Kind: Percent, Percent: 50, Currency: "NZD", StartsOn: date(2026, 1, 1)},
+	}
+
+	tests := []struct {
+		name string
+		on   time.Time
+		want money.Amount
+	}{
+		{"one percentage", date(2026, 1, 1), 9000},
+		{"percentages added together", date(2026, 4, 1), 8500},
+		{"fixed amount after percentages", date(2026, 7, 1), 7500},
+		{"first discount ended", date(2027, 1, 1), 9500},
+	}
+	for _, tt := range tests {
+		if got := Charge(sub, discounts, tt.on); got != tt.want {
+			t.Errorf("%s: Charge() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	if got := Apply(500, "NZD", discounts[2:3], date(2026, 7, 1)); got != 0 {
+		t.Errorf("expected a charge to never fall below nothing, got %s", got)
+	}
+
+	trial := sub
+	trial.TrialEndsOn = until(date(2026, 3, 31))
+	if got := Charge(trial, discounts, date(2026, 3, 31)); got != 0 {
+		t.Errorf("expected nothing to be charged on the last day of the trial, got %s", got)
+	}
+	if got := Charge(trial, discounts, date(2026, 4, 1)); got != 8500 {
+		t.Errorf("expected the discounted amount once the trial has ended, got %s", got)
+	}
+	if got := Monthly(sub, discounts, date(2026, 4, 1)); got != 2833 {
+		t.Errorf("expected a quarterly charge of 85.00 to come to 28.33 a month, got %s", got)
+	}
+}
+
+func TestEnding(t *testing.T) {
+	now := date(2026, 3, 1)
+	trials := []sqlc.ListTrialSubscriptionsRow{
+		{Description: "Converting", Status: "active", TrialEndsOn: until(date(2026, 3, 10))},
+		{Description: "Cancelling", Status: "active", TrialEndsOn: until(date(2026, 3, 5)), Cancelling: true},
+		{Description: "Paused", Status: "paused", TrialEndsOn: until(date(2026, 3, 1))},
+		{Description: "Ended", Status: "active", TrialEndsOn: until(date(2026, 2, 28))},
+		{Description: "Later", Status: "active", TrialEndsOn: until(date(2026, 3, 16))},
+	}
+
+	ending := Ending(trials, now)
+	want := []struct {
+		description string
+		converts    bool
+	}{{"Paused", false}, {"Cancelling", false}, {"Converting", true}}
+	if len(ending) != len(want) {
+		t.Fatalf("expected %d trials, got %+v", len(want), ending)
+	}
+	for i, w := range want {
+		if ending[i].Description != w.description || ending[i].Converts != w.converts {
+			t.Errorf("trial %d: expected %s (converts %v), got %s (converts %v)", i, w.description, w.converts, ending[i].Description, ending[i].Converts)
+		}
+	}
+}
+
+func TestDiscounts_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// a unique code per run keeps coupons from earlier runs against the same database out of the way
+	code := "T" + strings.ToUpper(uuid.NewString()[:8])
+	params, err := CouponParams(code, "", Terms{Kind: Percent, Percent: 20, Periods: 2})
+	if err != nil {
+		t.Fatalf("CouponParams failed: %v", err)
+	}
+	if _, err := queries.CreateCoupon(ctx, params); err != nil {
+		t.Fatalf("CreateCoupon failed: %v", err)
+	}
+	coupon, err := queries.GetCouponByCode(ctx, strings.ToLower(code))
+	if err != nil {
+		t.Fatalf("expected coupon codes to be found regardless of case, got %v", err)
+	}
+	if coupon.Status != Active {
+		t.Errorf("expected new coupons to be active, got %q", coupon.Status)
+	}
+
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "Discounts", Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	sub, err := queries.CreateSubscription(ctx, sqlc.CreateSubscriptionParams{
+		CustomerID: customer.ID, Description: "Trial", Amount: 10000, Term: "yearly", BillingCadence: billing.Monthly,
+		Status: "active", StartDate: date(2026, 1, 1), Currency: "NZD", Quantity: 1, UnitPrice: 10000,
+		TrialEndsOn: until(date(2026, 3, 31)),
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	if !sub.TrialEndsOn.Valid || !sub.TrialEndsOn.Time.Equal(date(2026, 3, 31)) {
+		t.Errorf("expected the trial end date to be saved, got %v", sub.TrialEndsOn)
+	}
+
+	give, err := Give(sub, FromCoupon(coupon), date(2026, 4, 1), time.Time{})
+	if err != nil {
+		t.Fatalf("Give failed: %v", err)
+	}
+	give.CouponID = uuid.NullUUID{UUID: coupon.ID, Valid: true}
+	discount, err := queries.CreateSubscriptionDiscount(ctx, give)
+	if err != nil {
+		t.Fatalf("CreateSubscriptionDiscount failed: %v", err)
+	}
+	discounts, err := queries.ListSubscriptionDiscounts(ctx, sub.ID)
+	if err != nil || len(discounts) != 1 {
+		t.Fatalf("expected the discount to be listed, got %+v (%v)", discounts, err)
+	}
+	if got := Charge(sub, discounts, date(2026, 4, 1)); got != 8000 {
+		t.Errorf("expected 20%% off after the trial, got %s", got)
+	}
+	if got := Charge(sub, discounts, date(2026, 6, 1)); got != 10000 {
+		t.Errorf("expected the full amount after two discounted periods, got %s", got)
+	}
+
+	trials, err := queries.ListTrialSubscriptions(ctx)
+	if err != nil {
+		t.Fatalf("ListTrialSubscriptions failed: %v", err)
+	}
+	if ending := Ending(trials, date(2026, 3, 25)); !containsTrial(ending, sub.ID) {
+		t.Errorf("expected the trial to be ending, got %+v", ending)
+	}
+
+	if _, err := queries.RemoveSubscriptionDiscount(ctx, sqlc.RemoveSubscriptionDiscountParams{ID: discount.ID, SubscriptionID: uuid.New()}); err != sql.ErrNoRows {
+		t.Errorf("expected a discount of another subscription not to be removed, got %v", err)
+	}
+	if _, err := queries.RemoveSubscriptionDiscount(ctx, sqlc.RemoveSubscriptionDiscountParams{ID: discount.ID, SubscriptionID: sub.ID}); err != nil {
+		t.Fatalf("RemoveSubscriptionDiscount failed: %v", err)
+	}
+	if discounts, _ := queries.ListSubscriptionDiscounts(ctx, sub.ID); len(discounts) != 0 {
+		t.Errorf("expected removed discounts not to be listed, got %+v", discounts)
+	}
+}
+
+func containsTrial(trials []Trial, id uuid.UUID) bool {
+	for _, t := range trials {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func setupTestDB(t *testing.T) (*sqlc.Queries, func()) {
+	os.MkdirAll("data", 0755)
+	dbConn, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	cleanup := func() { dbConn.Close() }
+	return queries, cleanup
+}
@@ -0,0 +1,420 @@
+// Package export writes customers, contacts and subscriptions out as CSV or JSON Lines for use in spreadsheets and
+// other tools, including the value of every custom field. Records are read a page at a time and written as they are
+// read, so large exports are streamed rather than held in memory.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/customfields"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/filters"
+)
+
+// Formats an export can be written in.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// Formats lists every export format, in the order they are offered.
+var Formats = []string{FormatCSV, FormatJSONL}
+
+// ContentTypes maps each format to the content type it is served as.
+var ContentTypes = map[string]string{
+	FormatCSV:   "text/csv; charset=utf-8",
+	FormatJSONL: "application/x-ndjson; charset=utf-8",
+}
+
+// pageSize is the number of records read per query while exporting.
+const pageSize = 500
+
+// timeFormat is the format timestamps are exported in.
+const timeFormat = time.RFC3339
+
+// dateFormat is the format dates without a time are exported in.
+const dateFormat = "2006-01-02"
+
+// Options chooses the format of an export and the records it includes.
+type Options struct {
+	Format string
+	// Filter narrows the export to the matching customers, and to the contacts and subscriptions of those customers.
+	// Its sort order is ignored as exports are always in ID order.
+	Filter filters.Filter
+	// IncludeDeleted includes soft-deleted records, along with a column for when they were deleted.
+	IncludeDeleted bool
+}
+
+// Validate checks the format and filter.
+func (o Options) Validate() error {
+	if !slices.Contains(Formats, o.Format) {
+		return fmt.Errorf("invalid format %q", o.Format)
+	}
+	return o.Filter.Validate()
+}
+
+// page holds the customer conditions shared by every export query, along with the ID the page continues after.
+type page struct {
+	params db.ListCustomersFilteredParams
+	after  uuid.UUID
+}
+
+func newPage(opts Options) (page, error) {
+	if err := opts.Validate(); err != nil {
+		return page{}, err
+	}
+	params, err := opts.Filter.Params()
+	return page{params: params}, err
+}
+
+// Customers writes every customer matching opts, with a column for each customer custom field.
+func Customers(ctx context.Context, queries *db.Queries, opts Options, w io.Writer) error {
+	p, err := newPage(opts)
+	if err != nil {
+		return err
+	}
+	fields, err := queries.ListCustomFields(ctx, customfields.EntityCustomer)
+	if err != nil {
+		return fmt.Errorf("error loading custom fields: %w", err)
+	}
+
+	enc := newEncoder(opts, w, customerColumns(db.Customer{}, opts), fields)
+	for {
+		customers, err := queries.ExportCustomers(ctx, db.ExportCustomersParams{
+			TagID:          p.params.TagID,
+			Status:         p.params.Status,
+			CreatedFrom:    p.params.CreatedFrom,
+			CreatedTo:      p.params.CreatedTo,
+			IncludeDeleted: opts.IncludeDeleted,
+			After:          p.after,
+			PageSize:       pageSize,
+		})
+		if err != nil {
+			return fmt.Errorf("error loading customers: %w", err)
+		}
+		if len(customers) == 0 {
+			return enc.flush()
+		}
+		values, err := pageValues(ctx, queries, customfields.EntityCustomer, customers[0].ID, customers[len(customers)-1].ID)
+		if err != nil {
+			return err
+		}
+		for _, c := range customers {
+			if err := enc.write(customerColumns(c, opts), values.For(fields, c.ID)); err != nil {
+				return err
+			}
+		}
+		if err := enc.flush(); err != nil || len(customers) < pageSize {
+			return err
+		}
+		p.after = customers[len(customers)-1].ID
+	}
+}
+
+// Contacts writes every contact of the customers matching opts along with the name of their customer, with a column
+// for each contact custom field. Contacts of deleted customers are only included along with deleted records.
+func Contacts(ctx context.Context, queries *db.Queries, opts Options, w io.Writer) error {
+	p, err := newPage(opts)
+	if err != nil {
+		return err
+	}
+	fields, err := queries.ListCustomFields(ctx, customfields.EntityContact)
+	if err != nil {
+		return fmt.Errorf("error loading custom fields: %w", err)
+	}
+
+	enc := newEncoder(opts, w, contactColumns(db.ExportContactsRow{}, opts), fields)
+	for {
+		contacts, err := queries.ExportContacts(ctx, db.ExportContactsParams{
+			TagID:          p.params.TagID,
+			Status:         p.params.Status,
+			CreatedFrom:    p.params.CreatedFrom,
+			CreatedTo:      p.params.CreatedTo,
+			IncludeDeleted: opts.IncludeDeleted,
+			After:          p.after,
+			PageSize:       pageSize,
+		})
+		if err != nil {
+			return fmt.Errorf("error loading contacts: %w", err)
+		}
+		if len(contacts) == 0 {
+			return enc.flush()
+		}
+		values, err := pageValues(ctx, queries, customfields.EntityContact, contacts[0].ID, contacts[len(contacts)-1].ID)
+		if err != nil {
+			return err
+		}
+		for _, c := range contacts {
+			if err := enc.write(contactColumns(c, opts), values.For(fields, c.ID)); err != nil {
+				return err
+			}
+		}
+		if err := enc.flush(); err != nil || len(contacts) < pageSize {
+			return err
+		}
+		p.after = contacts[len(contacts)-1].ID
+	}
+}
+
+// Subscriptions writes every subscription of the customers matching opts along with the name of their customer.
+// Subscriptions of deleted customers are only included along with deleted records.
+func Subscriptions(ctx context.Context, queries *db.Queries, opts Options, w io.Writer) error {
+	p, err := newPage(opts)
+	if err != nil {
+		return err
+	}
+
+	enc := newEncoder(opts, w, subscriptionColumns(db.ExportSubscriptionsRow{}, opts), nil)
+	for {
+		subscriptions, err := queries.ExportSubscriptions(ctx, db.ExportSubscriptionsParams{
+			TagID:          p.params.TagID,
+			Status:         p.params.Status,
+			CreatedFrom:    p.params.CreatedFrom,
+			CreatedTo:      p.params.CreatedTo,
+			IncludeDeleted: opts.IncludeDeleted,
+			After:          p.after,
+			PageSize:       pageSize,
+		})
+		if err != nil {
+			return fmt.Errorf("error loading subscriptions: %w", err)
+		}
+		for _, s := range subscriptions {
+			if err := enc.write(subscriptionColumns(s, opts), nil); err != nil {
+				return err
+			}
+		}
+		if err := enc.flush(); err != nil || len(subscriptions) < pageSize {
+			return err
+		}
+		p.after = subscriptions[len(subscriptions)-1].ID
+	}
+}
+
+// column is a single exported value. Values are nil, strings, bools, int64s or float64s.
+type column struct {
+	heading string
+	key     string
+	value   any
+}
+
+func customerColumns(c db.Customer, opts Options) []column {
+	return withDeleted([]column{
+		{"ID", "id", c.ID.String()},
+		{"Name", "name", c.Name},
+		{"Status", "status", c.Status},
+		{"Email", "email", text(c.Email)},
+		{"Phone", "phone", text(c.Phone)},
+		{"Address", "address", text(c.Address)},
+		{"Website", "website", text(c.Website)},
+		{"Currency", "currency", c.Currency},
+		{"Notes", "notes", text(c.Notes)},
+		{"Created", "created_at", timestamp(c.CreatedAt)},
+		{"Updated", "updated_at", timestamp(c.UpdatedAt)},
+	}, c.DeletedAt, opts)
+}
+
+func contactColumns(c db.ExportContactsRow, opts Options) []column {
+	return withDeleted([]column{
+		{"ID", "id", c.ID.String()},
+		{"Customer ID", "customer_id", c.CustomerID.String()},
+		{"Customer", "customer", c.CustomerName},
+		{"Name", "name", c.Name},
+		{"Role", "role", text(c.Role)},
+		{"Email", "email", text(c.Email)},
+		{"Phone", "phone", text(c.Phone)},
+		{"Primary", "primary", c.IsPrimary.Bool},
+		{"Notes", "notes", text(c.Notes)},
+		{"Created", "created_at", timestamp(c.CreatedAt)},
+		{"Updated", "updated_at", timestamp(c.UpdatedAt)},
+	}, c.DeletedAt, opts)
+}
+
+func subscriptionColumns(s db.ExportSubscriptionsRow, opts Options) []column {
+	var end, trialEnd, cost, costChanged any
+	if s.EndDate.Valid {
+		end = s.EndDate.Time.Format(dateFormat)
+	}
+	if s.TrialEndsOn.Valid {
+		trialEnd = s.TrialEndsOn.Time.Format(dateFormat)
+	}
+	// a cost is only recorded along with the cadence the vendor charges it on
+	if s.CostCadence.Valid {
+		cost = s.CostAmount
+	}
+	if s.CostChangedOn.Valid {
+		costChanged = s.CostChangedOn.Time.Format(dateFormat)
+	}
+	return withDeleted([]column{
+		{"ID", "id", s.ID.String()},
+		{"Customer ID", "customer_id", s.CustomerID.String()},
+		{"Customer", "customer", s.CustomerName},
+		{"Product ID", "product_id", id(s.ProductID)},
+		{"Description", "description", s.Description},
+		{"Quantity", "quantity", s.Quantity},
+		{"Unit Price", "unit_price", s.UnitPrice},
+		{"Amount", "amount", s.Amount},
+		{"Currency", "currency", s.Currency},
+		{"Cost", "cost_amount", cost},
+		{"Cost Cadence", "cost_cadence", text(s.CostCadence)},
+		{"Cost Changed", "cost_changed_on", costChanged},
+		{"Term", "term", s.Term},
+		{"Billing Cadence", "billing_cadence", s.BillingCadence},
+		{"Billing Interval", "billing_interval", number(s.BillingInterval)},
+		{"Billing Anchor Day", "billing_anchor_day", number(s.BillingAnchorDay)},
+		{"Start Date", "start_date", s.StartDate.Format(dateFormat)},
+		{"End Date", "end_date", end},
+		{"Trial Ends", "trial_ends_on", trialEnd},
+		{"Status", "status", s.Status},
+		{"Notes", "notes", text(s.Notes)},
+		{"Created", "created_at", timestamp(s.CreatedAt)},
+		{"Updated", "updated_at", timestamp(s.UpdatedAt)},
+	}, s.DeletedAt, opts)
+}
+
+// withDeleted adds when a record was deleted to its columns, if deleted records were asked for.
+func withDeleted(columns []column, deletedAt sql.NullTime, opts Options) []column {
+	if !opts.IncludeDeleted {
+		return columns
+	}
+	return append(columns, column{"Deleted", "deleted_at", timestamp(deletedAt)})
+}
+
+// pageValues loads the custom field values of the records in a page, which run in ID order from first to last.
+func pageValues(ctx context.Context, queries *db.Queries, entity string, first, last uuid.UUID) (customfields.Set, error) {
+	stored, err := queries.ExportCustomFieldValues(ctx, db.ExportCustomFieldValuesParams{Entity: entity, First: first, Last: last})
+	if err != nil {
+		return nil, fmt.Errorf("error loading custom field values: %w", err)
+	}
+	return customfields.Index(stored), nil
+}
+
+// encoder writes records in the format of an export. CSV exports start with a header and give every custom field a
+// column of its own, while JSON Lines exports write an object per record with the custom fields nested by name.
+type encoder struct {
+	format string
+	csv    *csv.Writer
+	w      io.Writer
+	header []string
+	err    error
+}
+
+func newEncoder(opts Options, w io.Writer, columns []column, fields []db.CustomField) *encoder {
+	enc := &encoder{format: opts.Format, w: w}
+	if opts.Format == FormatCSV {
+		enc.csv = csv.NewWriter(w)
+		for _, c := range columns {
+			enc.header = append(enc.header, c.heading)
+		}
+		for _, f := range fields {
+			enc.header = append(enc.header, f.Name)
+		}
+		enc.err = enc.csv.Write(enc.header)
+	}
+	return enc
+}
+
+func (e *encoder) write(columns []column, values []customfields.Value) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.format == FormatCSV {
+		row := make([]string, 0, len(e.header))
+		for _, c := range columns {
+			row = append(row, csvValue(c.value))
+		}
+		for _, v := range values {
+			row = append(row, v.Value)
+		}
+		e.err = e.csv.Write(row)
+		return e.err
+	}
+
+	// objects are built by hand so that keys keep the column order
+	line := []byte{'{'}
+	for i, c := range columns {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = appendJSON(line, c.key)
+		line = append(line, ':')
+		line = appendJSON(line, c.value)
+	}
+	if values != nil {
+		custom := make(map[string]string, len(values))
+		for _, v := range values {
+			custom[v.Field.Name] = v.Value
+		}
+		line = append(line, `,"custom_fields":`...)
+		line = appendJSON(line, custom)
+	}
+	line = append(line, '}', '\n')
+	_, e.err = e.w.Write(line)
+	return e.err
+}
+
+// flush writes out any buffered records.
+func (e *encoder) flush() error {
+	if e.csv != nil && e.err == nil {
+		e.csv.Flush()
+		e.err = e.csv.Error()
+	}
+	return e.err
+}
+
+func appendJSON(dst []byte, v any) []byte {
+	encoded, _ := json.Marshal(v)
+	return append(dst, encoded...)
+}
+
+func csvValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func text(s sql.NullString) any {
+	if !s.Valid {
+		return nil
+	}
+	return s.String
+}
+
+func id(u uuid.NullUUID) any {
+	if !u.Valid {
+		return nil
+	}
+	return u.UUID.String()
+}
+
+func number(n sql.NullInt64) any {
+	if !n.Valid {
+		return nil
+	}
+	return n.Int64
+}
+
+func timestamp(t sql.NullTime) any {
+	if !t.Valid {
+		return nil
+	}
+	return t.Time.UTC().Format(timeFormat)
+}
@@ -0,0 +1,172 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/customfields"
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/filters"
+)
+
+func TestCustomersAndContacts_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	word := "zq" + strings.ReplaceAll(uuid.NewString(), "-", "")[:10]
+
+	field, err := queries.CreateCustomField(ctx, sqlc.CreateCustomFieldParams{Entity: customfields.EntityContact, Name: "Field " + word, FieldType: customfields.TypeText})
+	if err != nil {
+		t.Fatalf("CreateCustomField failed: %v", err)
+	}
+	defer queries.DeleteCustomField(ctx, field.ID)
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{
+		Name:   "Export " + word,
+		Status: "active",
+		Notes:  sql.NullString{String: "Line one\nline, two", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	contact, err := queries.CreateContact(ctx, sqlc.CreateContactParams{CustomerID: customer.ID, Name: "Jane " + word})
+	if err != nil {
+		t.Fatalf("CreateContact failed: %v", err)
+	}
+	if err := queries.UpsertCustomFieldValue(ctx, sqlc.UpsertCustomFieldValueParams{FieldID: field.ID, RecordID: contact.ID, Value: "Finance"}); err != nil {
+		t.Fatalf("UpsertCustomFieldValue failed: %v", err)
+	}
+
+	csvOpts := Options{Format: FormatCSV}
+	header, row := exportRow(t, queries, Customers, csvOpts, customer.ID)
+	if row == nil || row[slices.Index(header, "Notes")] != customer.Notes.String {
+		t.Errorf("expected the customer with their notes intact, got %v", row)
+	}
+
+	header, row = exportRow(t, queries, Contacts, csvOpts, contact.ID)
+	if row == nil {
+		t.Fatal("expected the contact to be exported")
+	}
+	if row[slices.Index(header, "Customer")] != customer.Name || row[slices.Index(header, field.Name)] != "Finance" {
+		t.Errorf("expected the customer name and custom field value, got %v", row)
+	}
+}
+
+func TestFiltersDeletedAndJSONL_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	word := "zq" + strings.ReplaceAll(uuid.NewString(), "-", "")[:10]
+
+	tag, err := queries.CreateTag(ctx, sqlc.CreateTagParams{Name: "Tag " + word, Colour: "blue"})
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	defer queries.DeleteTag(ctx, tag.ID)
+	tagged, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "Tagged " + word, Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	if err := queries.AddCustomerTag(ctx, sqlc.AddCustomerTagParams{CustomerID: tagged.ID, TagID: tag.ID}); err != nil {
+		t.Fatalf("AddCustomerTag failed: %v", err)
+	}
+	untagged, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "Untagged " + word, Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	subscription, err := queries.CreateSubscription(ctx, sqlc.CreateSubscriptionParams{
+		CustomerID:     tagged.ID,
+		Description:    "Support " + word,
+		Amount:         9950,
+		Quantity:       1,
+		UnitPrice:      9950,
+		Term:           "monthly",
+		BillingCadence: "monthly",
+		Status:         "active",
+		StartDate:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	if _, err := queries.DeleteSubscription(ctx, subscription.ID); err != nil {
+		t.Fatalf("DeleteSubscription failed: %v", err)
+	}
+
+	filtered := Options{Format: FormatCSV, Filter: filters.Filter{Tag: tag.ID.String()}}
+	if _, row := exportRow(t, queries, Customers, filtered, tagged.ID); row == nil {
+		t.Error("expected the tagged customer to match the filter")
+	}
+	if _, row := exportRow(t, queries, Customers, filtered, untagged.ID); row != nil {
+		t.Error("expected the untagged customer to be left out")
+	}
+	if _, row := exportRow(t, queries, Subscriptions, filtered, subscription.ID); row != nil {
+		t.Error("expected the deleted subscription to be left out by default")
+	}
+	filtered.IncludeDeleted = true
+	header, row := exportRow(t, queries, Subscriptions, filtered, subscription.ID)
+	if row == nil || row[slices.Index(header, "Deleted")] == "" || row[slices.Index(header, "Amount")] != "99.50" {
+		t.Errorf("expected the deleted subscription with when it was deleted, got %v %v", header, row)
+	}
+
+	var buf bytes.Buffer
+	if err := Subscriptions(ctx, queries, Options{Format: FormatJSONL, Filter: filtered.Filter, IncludeDeleted: true}, &buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], `{"id":"`+subscription.ID.String()+`"`) {
+		t.Fatalf("expected one line for the subscription with its ID first, got %q", buf.String())
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil {
+		t.Fatalf("line is not valid JSON: %v", err)
+	}
+	if decoded["amount"] != 99.5 || decoded["end_date"] != nil || decoded["start_date"] != "2024-03-01" {
+		t.Errorf("expected typed values, got %v", decoded)
+	}
+
+	if err := Customers(ctx, queries, Options{Format: "xml"}, &buf); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
+
+// exportRow runs an export and returns its header along with the row for a record.
+func exportRow(t *testing.T, queries *sqlc.Queries, write func(context.Context, *sqlc.Queries, Options, io.Writer) error, opts Options, id uuid.UUID) ([]string, []string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := write(context.Background(), queries, opts, &buf); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("export is not valid CSV: %v", err)
+	}
+	for _, record := range records[1:] {
+		if record[0] == id.String() {
+			return records[0], record
+		}
+	}
+	return records[0], nil
+}
+
+func setupTestDB(t *testing.T) (*sqlc.Queries, func()) {
+	os.MkdirAll("data", 0755)
+	dbConn, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	cleanup := func() { dbConn.Close() }
+	return queries, cleanup
+}
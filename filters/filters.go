@@ -0,0 +1,218 @@
+// Package filters narrows and sorts the customer list by tag, status and creation date. Named combinations of those
+// conditions are saved so that navigation, reports and bulk actions can reuse them.
+package filters
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// Sort orders for the customer list.
+const (
+	SortNewest = "newest"
+	SortOldest = "oldest"
+	SortName   = "name"
+)
+
+// Sorts lists every sort order, in the order they are offered.
+var Sorts = []string{SortNewest, SortOldest, SortName}
+
+// Statuses lists the customer statuses that can be filtered on.
+var Statuses = []string{"active", "inactive", "prospect"}
+
+// TagColours lists the colours a tag can be given.
+var TagColours = []string{"gray", "red", "orange", "amber", "green", "teal", "blue", "indigo", "purple", "pink"}
+
+// dateFormat is the format used by date inputs for the created from and to conditions.
+const dateFormat = "2006-01-02"
+
+// Filter narrows and sorts the customer list. Empty conditions are ignored.
+type Filter struct {
+	// Saved is the ID of the saved filter the conditions were loaded from, if any.
+	Saved  string `json:"saved"`
+	Tag    string `json:"tag"`
+	Status string `json:"status"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Sort   string `json:"sort"`
+}
+
+// FromSaved converts a saved filter into a filter.
+func FromSaved(s db.SavedFilter) Filter {
+	f := Filter{
+		Saved:  s.ID.String(),
+		Status: s.Status.String,
+		From:   s.CreatedFrom.String,
+		To:     s.CreatedTo.String,
+		Sort:   s.Sort,
+	}
+	if s.TagID.Valid {
+		f.Tag = s.TagID.UUID.String()
+	}
+	return f
+}
+
+// IsZero reports whether the filter matches every customer in the default order.
+func (f Filter) IsZero() bool {
+	return f.Tag == "" && f.Status == "" && f.From == "" && f.To == "" && (f.Sort == "" || f.Sort == SortNewest)
+}
+
+// Validate checks that every condition is well formed.
+func (f Filter) Validate() error {
+	if f.Tag != "" {
+		if _, err := uuid.Parse(f.Tag); err != nil {
+			return fmt.Errorf("invalid tag: %w", err)
+		}
+	}
+	if f.Status != "" && !slices.Contains(Statuses, f.Status) {
+		return fmt.Errorf("invalid status %q", f.Status)
+	}
+	var from, to time.Time
+	var err error
+	if f.From != "" {
+		if from, err = time.Parse(dateFormat, f.From); err != nil {
+			return fmt.Errorf("invalid from date: %w", err)
+		}
+	}
+	if f.To != "" {
+		if to, err = time.Parse(dateFormat, f.To); err != nil {
+			return fmt.Errorf("invalid to date: %w", err)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return fmt.Errorf("the to date is before the from date")
+	}
+	if f.Sort != "" && !slices.Contains(Sorts, f.Sort) {
+		return fmt.Errorf("invalid sort %q", f.Sort)
+	}
+	return nil
+}
+
+// Params converts the filter into query parameters.
+func (f Filter) Params() (db.ListCustomersFilteredParams, error) {
+	if err := f.Validate(); err != nil {
+		return db.ListCustomersFilteredParams{}, err
+	}
+	params := db.ListCustomersFilteredParams{
+		Status: nullString(f.Status),
+		Sort:   f.sortOrder(),
+	}
+	if f.Tag != "" {
+		params.TagID = uuid.NullUUID{UUID: uuid.MustParse(f.Tag), Valid: true}
+	}
+	if f.From != "" {
+		params.CreatedFrom = f.From
+	}
+	if f.To != "" {
+		params.CreatedTo = f.To
+	}
+	return params, nil
+}
+
+// SaveParams converts the filter into the parameters for saving it under name.
+func (f Filter) SaveParams(name string) (db.CreateSavedFilterParams, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return db.CreateSavedFilterParams{}, fmt.Errorf("a saved filter needs a name")
+	}
+	params, err := f.Params()
+	if err != nil {
+		return db.CreateSavedFilterParams{}, err
+	}
+	return db.CreateSavedFilterParams{
+		Name:        name,
+		TagID:       params.TagID,
+		Status:      params.Status,
+		CreatedFrom: nullString(f.From),
+		CreatedTo:   nullString(f.To),
+		Sort:        f.sortOrder(),
+	}, nil
+}
+
+// Describe summarises the filter conditions for display, e.g. "Tagged Enterprise, inactive, created from 1 Mar 2024".
+func (f Filter) Describe(tags []db.Tag) string {
+	var parts []string
+	if f.Tag != "" {
+		name := "an unknown tag"
+		for _, t := range tags {
+			if t.ID.String() == f.Tag {
+				name = t.Name
+				break
+			}
+		}
+		parts = append(parts, "tagged "+name)
+	}
+	if f.Status != "" {
+		parts = append(parts, f.Status)
+	}
+	switch {
+	case f.From != "" && f.To != "":
+		parts = append(parts, fmt.Sprintf("created %s to %s", displayDate(f.From), displayDate(f.To)))
+	case f.From != "":
+		parts = append(parts, "created from "+displayDate(f.From))
+	case f.To != "":
+		parts = append(parts, "created up to "+displayDate(f.To))
+	}
+	if len(parts) == 0 {
+		return "All customers"
+	}
+	summary := strings.Join(parts, ", ")
+	return strings.ToUpper(summary[:1]) + summary[1:]
+}
+
+// sortOrder returns the sort order, defaulting to newest first.
+func (f Filter) sortOrder() string {
+	if f.Sort == "" {
+		return SortNewest
+	}
+	return f.Sort
+}
+
+// Customers lists the customers matching the filter, in the filter's sort order.
+func Customers(ctx context.Context, queries *db.Queries, f Filter) ([]db.Customer, error) {
+	params, err := f.Params()
+	if err != nil {
+		return nil, err
+	}
+	return queries.ListCustomersFiltered(ctx, params)
+}
+
+// TagsByCustomer groups every tag assignment by customer.
+func TagsByCustomer(ctx context.Context, queries *db.Queries) (map[uuid.UUID][]db.Tag, error) {
+	rows, err := queries.ListCustomerTagAssignments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tags := make(map[uuid.UUID][]db.Tag)
+	for _, row := range rows {
+		tags[row.CustomerID] = append(tags[row.CustomerID], db.Tag{
+			ID:        row.ID,
+			Name:      row.Name,
+			Colour:    row.Colour,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	return tags, nil
+}
+
+// displayDate formats a date condition for display, falling back to the raw value if it cannot be parsed.
+func displayDate(value string) string {
+	t, err := time.Parse(dateFormat, value)
+	if err != nil {
+		return value
+	}
+	return t.Format("2 Jan 2006")
+}
+
+// nullString converts an empty string to a NULL query parameter.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,218 @@
+package filters
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/currency"
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		wantErr bool
+	}{
+		{"empty", Filter{}, false},
+		{"all conditions", Filter{Tag: uuid.NewString(), Status: "active", From: "2024-01-01", To: "2024-12-31", Sort: SortName}, false},
+		{"same day", Filter{From: "2024-01-01", To: "2024-01-01"}, false},
+		{"bad tag", Filter{Tag: "enterprise"}, true},
+		{"bad status", Filter{Status: "archived"}, true},
+		{"bad date", Filter{From: "01/02/2024"}, true},
+		{"reversed dates", Filter{From: "2024-02-01", To: "2024-01-01"}, true},
+		{"bad sort", Filter{Sort: "revenue"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.filter.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tag := sqlc.Tag{ID: uuid.New(), Name: "Enterprise"}
+	tests := []struct {
+		filter Filter
+		want   string
+	}{
+		{Filter{}, "All customers"},
+		{Filter{Sort: SortName}, "All customers"},
+		{Filter{Tag: tag.ID.String(), Status: "inactive"}, "Tagged Enterprise, inactive"},
+		{Filter{Tag: uuid.NewString()}, "Tagged an unknown tag"},
+		{Filter{Status: "prospect", From: "2024-03-01"}, "Prospect, created from 1 Mar 2024"},
+		{Filter{To: "2024-03-01"}, "Created up to 1 Mar 2024"},
+		{Filter{From: "2024-03-01", To: "2024-04-15"}, "Created 1 Mar 2024 to 15 Apr 2024"},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Describe([]sqlc.Tag{tag}); got != tt.want {
+			t.Errorf("Describe(%+v) = %q, want %q", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestTagParams(t *testing.T) {
+	params, err := TagParams("  VIP ", "")
+	if err != nil || params.Name != "VIP" || params.Colour != TagColours[0] {
+		t.Errorf("expected a trimmed name and the default colour, got %+v (%v)", params, err)
+	}
+	if _, err := TagParams(" ", "red"); err == nil {
+		t.Error("expected an error for a blank name")
+	}
+	if _, err := TagParams("VIP", "chartreuse"); err == nil {
+		t.Error("expected an error for an unknown colour")
+	}
+}
+
+func TestCustomers_FiltersAndSorts_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// a unique word per run keeps rows from earlier runs against the same database out of the way
+	word := "zq" + strings.ReplaceAll(uuid.NewString(), "-", "")[:10]
+
+	tag, err := queries.CreateTag(ctx, sqlc.CreateTagParams{Name: "Tag " + word, Colour: "blue"})
+	if err != nil {
+		t.Fatalf("CreateTag failed: %v", err)
+	}
+	beta, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: word + " Beta", Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	alpha, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: word + " Alpha", Status: "inactive"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	product, err := queries.CreateProduct(ctx, sqlc.CreateProductParams{Sku: "HOST-" + word, Name: "Hosting " + word})
+	if err != nil {
+		t.Fatalf("CreateProduct failed: %v", err)
+	}
+	if _, err := queries.CreateSubscription(ctx, sqlc.CreateSubscriptionParams{
+		CustomerID:     beta.ID,
+		ProductID:      uuid.NullUUID{UUID: product.ID, Valid: true},
+		Description:    "Hosting",
+		Amount:         30000,
+		Quantity:       1,
+		UnitPrice:      30000,
+		Term:           "yearly",
+		BillingCadence: "yearly",
+		Status:         "active",
+		StartDate:      time.Now(),
+		Currency:       "NZD",
+	}); err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	if _, err := queries.CreateSubscription(ctx, sqlc.CreateSubscriptionParams{
+		CustomerID:     beta.ID,
+		Description:    "Support",
+		Amount:         1000,
+		Quantity:       1,
+		UnitPrice:      1000,
+		Term:           "monthly",
+		BillingCadence: "monthly",
+		Status:         "active",
+		StartDate:      time.Now(),
+		Currency:       "USD",
+	}); err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	for _, c := range []sqlc.Customer{alpha, beta} {
+		added, removed, err := SetCustomerTags(ctx, queries, c.ID, []string{tag.ID.String(), "not-a-tag"})
+		if err != nil || len(added) != 1 || len(removed) != 0 {
+			t.Fatalf("expected the tag to be added to %s, got added %v removed %v (%v)", c.Name, added, removed, err)
+		}
+	}
+	if added, _, _ := SetCustomerTags(ctx, queries, beta.ID, []string{tag.ID.String()}); len(added) != 0 {
+		t.Errorf("expected setting the same tags again to change nothing, got %v", added)
+	}
+
+	today := time.Now().UTC()
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []uuid.UUID
+	}{
+		{"tag by name", Filter{Tag: tag.ID.String(), Sort: SortName}, []uuid.UUID{alpha.ID, beta.ID}},
+		{"tag and status", Filter{Tag: tag.ID.String(), Status: "inactive"}, []uuid.UUID{alpha.ID}},
+		{"created today", Filter{Tag: tag.ID.String(), From: today.Format(dateFormat), To: today.Format(dateFormat), Sort: SortName}, []uuid.UUID{alpha.ID, beta.ID}},
+		{"created before today", Filter{Tag: tag.ID.String(), To: today.AddDate(0, 0, -1).Format(dateFormat)}, nil},
+	}
+	for _, tt := range tests {
+		customers, err := Customers(ctx, queries, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: Customers failed: %v", tt.name, err)
+		}
+		var got []uuid.UUID
+		for _, c := range customers {
+			got = append(got, c.ID)
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && (got[0] != tt.want[0] || got[len(got)-1] != tt.want[len(tt.want)-1])) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	saved, err := queries.CreateSavedFilter(ctx, mustSaveParams(t, Filter{Tag: tag.ID.String(), Sort: SortName}, "Saved "+word))
+	if err != nil {
+		t.Fatalf("CreateSavedFilter failed: %v", err)
+	}
+	defer queries.DeleteSavedFilter(ctx, saved.ID)
+	if n, err := queries.CountSavedFiltersByTag(ctx, uuid.NullUUID{UUID: tag.ID, Valid: true}); err != nil || n != 1 {
+		t.Errorf("expected the saved filter to use the tag, got %d (%v)", n, err)
+	}
+
+	rates := []sqlc.ExchangeRate{{FromCurrency: "USD", ToCurrency: "NZD", Rate: 1.5, EffectiveFrom: today.AddDate(0, 0, -1)}}
+	report, err := BuildReport(ctx, queries, FromSaved(saved), currency.NewConverter(rates, "NZD"), time.Now())
+	if err != nil {
+		t.Fatalf("BuildReport failed: %v", err)
+	}
+	if len(report.Rows) != 2 || report.Rows[0].Customer.ID != alpha.ID {
+		t.Fatalf("expected both customers sorted by name, got %+v", report.Rows)
+	}
+	if report.StatusCounts["active"] != 1 || report.StatusCounts["inactive"] != 1 {
+		t.Errorf("unexpected status counts %v", report.StatusCounts)
+	}
+	if report.ActiveSubscriptions != 2 || report.Revenue.Amount != 4000 || report.Rows[1].Revenue.Amount != 4000 {
+		t.Errorf("expected two active subscriptions worth 40 a month between them, got %d worth %v", report.ActiveSubscriptions, report.Revenue)
+	}
+	if len(report.Products) != 2 || report.Products[0].ProductID.UUID != product.ID || report.Products[0].Revenue.Amount != 2500 || report.Products[1].ProductID.Valid || report.Products[1].Revenue.Amount != 1500 {
+		t.Errorf("expected the monthly revenue of the yearly product ahead of subscriptions without one, got %+v", report.Products)
+	}
+	if len(report.Rows[0].Tags) != 1 || report.Rows[0].Tags[0].ID != tag.ID {
+		t.Errorf("expected the report to list each customer's tags, got %v", report.Rows[0].Tags)
+	}
+
+	_, removed, err := SetCustomerTags(ctx, queries, alpha.ID, nil)
+	if err != nil || len(removed) != 1 {
+		t.Fatalf("expected the tag to be removed, got %v (%v)", removed, err)
+	}
+	if customers, _ := Customers(ctx, queries, Filter{Tag: tag.ID.String()}); len(customers) != 1 || customers[0].ID != beta.ID {
+		t.Errorf("expected only the customer still tagged, got %v", customers)
+	}
+}
+
+func mustSaveParams(t *testing.T, f Filter, name string) sqlc.CreateSavedFilterParams {
+	t.Helper()
+	params, err := f.SaveParams(name)
+	if err != nil {
+		t.Fatalf("SaveParams failed: %v", err)
+	}
+	return params
+}
+
+func setupTestDB(t *testing.T) (*sqlc.Queries, func()) {
+	os.MkdirAll("data", 0755)
+	dbConn, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	cleanup := func() { dbConn.Close() }
+	return queries, cleanup
+}
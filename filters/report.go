@@ -0,0 +1,130 @@
+package filters
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/currency"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/discounts"
+)
+
+// Report summarises the customers matching a filter.
+type Report struct {
+	Rows []ReportRow
+	// StatusCounts is the number of matching customers with each status.
+	StatusCounts map[string]int
+	// ActiveSubscriptions is the number of active subscriptions held by matching customers.
+	ActiveSubscriptions int64
+	// Revenue is the monthly recurring revenue of those active subscriptions in the reporting currency.
+	Revenue currency.Total
+	// Products breaks that monthly recurring revenue down by product, highest first.
+	Products []ProductRow
+}
+
+// ProductRow is the monthly recurring revenue of a product across the customers in a report. Subscriptions that were not sold from the
+// catalogue share a row without a product.
+type ProductRow struct {
+	ProductID           uuid.NullUUID
+	SKU                 string
+	Name                string
+	ActiveSubscriptions int64
+	Seats               int64
+	Revenue             currency.Total
+}
+
+// ReportRow is a single customer in a report.
+type ReportRow struct {
+	Customer            db.Customer
+	Tags                []db.Tag
+	ActiveSubscriptions int64
+	Revenue             currency.Total
+}
+
+// BuildReport lists the customers matching the filter along with their tags and active subscription totals on the day
+// of now. Revenue is monthly recurring revenue after trials and discounts, as on the dashboard, converted into the
+// converter's currency.
+func BuildReport(ctx context.Context, queries *db.Queries, f Filter, conv *currency.Converter, now time.Time) (Report, error) {
+	customers, err := Customers(ctx, queries, f)
+	if err != nil {
+		return Report{}, err
+	}
+	tags, err := TagsByCustomer(ctx, queries)
+	if err != nil {
+		return Report{}, err
+	}
+	subscriptions, err := queries.ListActiveSubscriptions(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	given, err := queries.ListActiveSubscriptionDiscounts(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	byCustomer := make(map[uuid.UUID][]db.Subscription)
+	for _, s := range subscriptions {
+		byCustomer[s.CustomerID] = append(byCustomer[s.CustomerID], s)
+	}
+
+	report := Report{StatusCounts: make(map[string]int), Revenue: conv.NewTotal()}
+	for _, c := range customers {
+		row := ReportRow{Customer: c, Tags: tags[c.ID], Revenue: conv.NewTotal()}
+		for _, s := range byCustomer[c.ID] {
+			monthly := discounts.Monthly(s, given, now)
+			row.ActiveSubscriptions++
+			conv.Add(&row.Revenue, monthly, s.Currency, now)
+			conv.Add(&report.Revenue, monthly, s.Currency, now)
+		}
+		report.Rows = append(report.Rows, row)
+		report.StatusCounts[c.Status]++
+		report.ActiveSubscriptions += row.ActiveSubscriptions
+	}
+
+	products, err := queries.ListProducts(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	report.Products = productRevenue(customers, subscriptions, given, products, conv, now)
+	return report, nil
+}
+
+// productRevenue totals the monthly recurring revenue of the customers' active subscriptions by product on the day
+// of now, after trials and discounts.
+func productRevenue(customers []db.Customer, subscriptions []db.Subscription, given []db.SubscriptionDiscount, products []db.Product, conv *currency.Converter, now time.Time) []ProductRow {
+	matched := make(map[uuid.UUID]bool, len(customers))
+	for _, c := range customers {
+		matched[c.ID] = true
+	}
+	byID := make(map[uuid.UUID]db.Product, len(products))
+	for _, p := range products {
+		byID[p.ID] = p
+	}
+
+	var rows []ProductRow
+	index := make(map[uuid.NullUUID]int)
+	for _, s := range subscriptions {
+		if !matched[s.CustomerID] {
+			continue
+		}
+		i, ok := index[s.ProductID]
+		if !ok {
+			i = len(rows)
+			index[s.ProductID] = i
+			product := byID[s.ProductID.UUID]
+			rows = append(rows, ProductRow{ProductID: s.ProductID, SKU: product.Sku, Name: product.Name, Revenue: conv.NewTotal()})
+		}
+		rows[i].ActiveSubscriptions++
+		rows[i].Seats += s.Quantity
+		conv.Add(&rows[i].Revenue, discounts.Monthly(s, given, now), s.Currency, now)
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Revenue.Amount != rows[j].Revenue.Amount {
+			return rows[i].Revenue.Amount > rows[j].Revenue.Amount
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows
+}
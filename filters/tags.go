@@ -0,0 +1,67 @@
+package filters
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// TagParams validates a new tag, trimming its name and defaulting its colour.
+func TagParams(name, colour string) (db.CreateTagParams, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return db.CreateTagParams{}, fmt.Errorf("a tag needs a name")
+	}
+	if colour == "" {
+		colour = TagColours[0]
+	}
+	if !slices.Contains(TagColours, colour) {
+		return db.CreateTagParams{}, fmt.Errorf("invalid colour %q", colour)
+	}
+	return db.CreateTagParams{Name: name, Colour: colour}, nil
+}
+
+// SetCustomerTags makes the given tag IDs the complete set of tags on a customer, returning the tags that were added
+// and removed. IDs that do not belong to a tag are ignored.
+func SetCustomerTags(ctx context.Context, queries *db.Queries, customerID uuid.UUID, tagIDs []string) (added, removed []db.Tag, err error) {
+	all, err := queries.ListTags(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading tags: %w", err)
+	}
+	current, err := queries.ListCustomerTags(ctx, customerID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading customer tags: %w", err)
+	}
+
+	want := make(map[uuid.UUID]bool, len(tagIDs))
+	for _, id := range tagIDs {
+		if parsed, err := uuid.Parse(id); err == nil {
+			want[parsed] = true
+		}
+	}
+	have := make(map[uuid.UUID]bool, len(current))
+	for _, t := range current {
+		have[t.ID] = true
+	}
+
+	for _, t := range all {
+		switch {
+		case want[t.ID] && !have[t.ID]:
+			if err := queries.AddCustomerTag(ctx, db.AddCustomerTagParams{CustomerID: customerID, TagID: t.ID}); err != nil {
+				return added, removed, fmt.Errorf("error adding tag %s: %w", t.Name, err)
+			}
+			added = append(added, t)
+		case !want[t.ID] && have[t.ID]:
+			if err := queries.RemoveCustomerTag(ctx, db.RemoveCustomerTagParams{CustomerID: customerID, TagID: t.ID}); err != nil {
+				return added, removed, fmt.Errorf("error removing tag %s: %w", t.Name, err)
+			}
+			removed = append(removed, t)
+		}
+	}
+	return added, removed, nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/starfederation/datastar-go/datastar"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// activitySignals are the explorer signals sent with each results request.
+type activitySignals struct {
+	Activity al.Filter `json:"activity"`
+	Cursor   string    `json:"activityCursor"`
+}
+
+// RegisterActivityRoutes registers the activity explorer routes on the given router.
+func (h *Handlers) RegisterActivityRoutes(r chi.Router) {
+	r.Get("/sse/activity", h.ActivitySSE)
+	r.Get("/sse/activity/results", h.ActivityResultsSSE)
+	r.Get("/sse/activity/more", h.ActivityMoreSSE)
+}
+
+// ActivitySSE renders the activity explorer page via SSE
+func (h *Handlers) ActivitySSE(w http.ResponseWriter, r *http.Request) {
+	h.trackView(r, hub.View{Page: hub.PageActivity})
+	customers, err := h.Queries.ListCustomers(r.Context())
+	if err != nil {
+		slog.Error("Failed to load customers for activity filters", "err", err)
+	}
+	users, err := h.Queries.ListUsers(r.Context())
+	if err != nil {
+		slog.Error("Failed to load users for activity filters", "err", err)
+	}
+	actions, err := h.Queries.ListActivityActions(r.Context())
+	if err != nil {
+		slog.Error("Failed to load actions for activity filters", "err", err)
+	}
+
+	pageSignals := utils.PageSignals{
+		HeaderTitle:       "Activity",
+		HeaderDescription: "Browse and export the full activity log",
+		CurrentPage:       "activity",
+	}
+	encodedSignals, _ := json.Marshal(pageSignals)
+
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Signals: encodedSignals,
+		Views: []templ.Component{
+			views.ActivityExplorer(customers, users, actions),
+			views.HeaderIcon("activity"),
+		},
+	})
+}
+
+// ActivityResultsSSE renders the first page of activity matching the current filters, replacing any previous results
+func (h *Handlers) ActivityResultsSSE(w http.ResponseWriter, r *http.Request) {
+	h.renderActivityPage(w, r, false)
+}
+
+// ActivityMoreSSE appends the next page of activity after the current cursor, used for infinite scrolling
+func (h *Handlers) ActivityMoreSSE(w http.ResponseWriter, r *http.Request) {
+	h.renderActivityPage(w, r, true)
+}
+
+// renderActivityPage loads a page of activity for the filters in the request signals. When more is set the rows
+// after the signalled cursor are appended to the existing results, otherwise the results are replaced.
+func (h *Handlers) renderActivityPage(w http.ResponseWriter, r *http.Request, more bool) {
+	var signals activitySignals
+	if err := datastar.ReadSignals(r, &signals); err != nil {
+		slog.Error("Error reading activity signals", "err", err)
+		h.Notify(NotifyError, "Filter Error", "An error occurred while reading the activity filters.", w, r)
+		return
+	}
+
+	cursor := al.Cursor{}
+	if more {
+		parsed, err := al.ParseCursor(signals.Cursor)
+		if err != nil || signals.Cursor == "" {
+			slog.Error("Invalid activity cursor", "cursor", signals.Cursor, "err", err)
+			return
+		}
+		cursor = parsed
+	}
+
+	rows, next, err := al.Page(r.Context(), h.Queries, signals.Activity, cursor, al.PageSize)
+	if err != nil {
+		slog.Error("Failed to load activity", "err", err)
+		h.Notify(NotifyError, "Activity Error", "Failed to load activity for these filters.", w, r)
+		return
+	}
+
+	encodedSignals, _ := json.Marshal(map[string]string{"activityCursor": next.String()})
+	if more {
+		utils.RenderSSE(w, r, utils.SSEOpts{
+			Signals:  encodedSignals,
+			Views:    []templ.Component{views.ActivityRows(rows)},
+			Selector: "activity-rows",
+			Mode:     datastar.ElementPatchModeAppend,
+		})
+		return
+	}
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Signals: encodedSignals,
+		Views:   []templ.Component{views.ActivityResults(rows)},
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/a-h/templ"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/calendar"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// RegisterCalendarRoutes registers the calendar feed routes on the given router. Calendar apps cannot sign in, so
+// feeds are authorised by the secret token in their URL and the router must not require a session.
+func (h *Handlers) RegisterCalendarRoutes(r chi.Router) {
+	r.Get("/calendar/{token}.ics", h.CalendarFeed)
+	r.Get("/calendar/{token}/{customerID}.ics", h.CalendarFeed)
+}
+
+// CalendarFeed serves the billing dates and renewals of every customer, or of the customer in the URL, as an
+// iCalendar feed
+func (h *Handlers) CalendarFeed(w http.ResponseWriter, r *http.Request) {
+	owner, err := h.Queries.GetCalendarTokenUser(r.Context(), chi.URLParam(r, "token"))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !owner.IsAdmin) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("GetCalendarTokenUser failed", "err", err)
+		http.Error(w, "Failed to load the calendar", http.StatusInternalServerError)
+		return
+	}
+	if err := h.Queries.TouchCalendarToken(r.Context(), owner.UserID); err != nil {
+		slog.Error("TouchCalendarToken failed", "user", owner.GithubID, "err", err)
+	}
+
+	name := "Beam billing"
+	var customerID uuid.NullUUID
+	if param := chi.URLParam(r, "customerID"); param != "" {
+		id, err := uuid.Parse(param)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		customer, err := h.Queries.GetCustomer(r.Context(), id)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		name = customer.Name + " billing"
+		customerID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	subscriptions, err := h.Queries.ListCalendarSubscriptions(r.Context(), customerID)
+	if err != nil {
+		slog.Error("ListCalendarSubscriptions failed", "err", err)
+		http.Error(w, "Failed to load the calendar", http.StatusInternalServerError)
+		return
+	}
+	discounts, err := h.Queries.ListActiveSubscriptionDiscounts(r.Context())
+	if err != nil {
+		slog.Error("ListActiveSubscriptionDiscounts failed", "err", err)
+		http.Error(w, "Failed to load the calendar", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", calendar.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="beam.ics"`)
+	if err := calendar.Write(w, name, calendar.Events(subscriptions, discounts, h.Clock())); err != nil {
+		slog.Error("Failed to write calendar", "err", err)
+	}
+}
+
+// CreateCalendarTokenSSE creates the current user's calendar feed token, replacing any existing one so that the old
+// feed URLs stop working
+func (h *Handlers) CreateCalendarTokenSSE(w http.ResponseWriter, r *http.Request) {
+	user, err := h.currentUser(r.Context())
+	if err != nil {
+		slog.Error("Failed to load the current user", "err", err)
+		h.Notify(NotifyError, "Calendar Error", "An error occurred while creating the calendar feed.", w, r)
+		return
+	}
+	_, err = h.Queries.GetCalendarToken(r.Context(), user.ID)
+	replaced := err == nil
+
+	if _, err := h.Queries.SetCalendarToken(r.Context(), db.SetCalendarTokenParams{UserID: user.ID, Token: calendar.NewToken()}); err != nil {
+		slog.Error("SetCalendarToken failed", "user", user.GithubID, "err", err)
+		h.Notify(NotifyError, "Calendar Error", "An error occurred while creating the calendar feed.", w, r)
+		return
+	}
+	if replaced {
+		al.LogCalendarFeedReset(r.Context(), h.Queries)
+		h.Notify(NotifySuccess, "Calendar Feed Reset", "Calendars subscribed to the old feed URLs will no longer update.", w, r)
+	} else {
+		al.LogCalendarFeedCreated(r.Context(), h.Queries)
+		h.Notify(NotifySuccess, "Calendar Feed Created", "Subscribe to the feed URL from your calendar app.", w, r)
+	}
+	h.renderCalendarSettings(w, r, user)
+}
+
+// DeleteCalendarTokenSSE turns off the current user's calendar feeds
+func (h *Handlers) DeleteCalendarTokenSSE(w http.ResponseWriter, r *http.Request) {
+	user, err := h.currentUser(r.Context())
+	if err != nil {
+		slog.Error("Failed to load the current user", "err", err)
+		h.Notify(NotifyError, "Calendar Error", "An error occurred while turning off the calendar feed.", w, r)
+		return
+	}
+	if err := h.Queries.DeleteCalendarToken(r.Context(), user.ID); err != nil {
+		slog.Error("DeleteCalendarToken failed", "user", user.GithubID, "err", err)
+		h.Notify(NotifyError, "Calendar Error", "An error occurred while turning off the calendar feed.", w, r)
+		return
+	}
+	al.LogCalendarFeedDeleted(r.Context(), h.Queries)
+	h.Notify(NotifySuccess, "Calendar Feed Turned Off", "Calendars subscribed to your feed URLs will no longer update.", w, r)
+	h.renderCalendarSettings(w, r, user)
+}
+
+// renderCalendarSettings renders the user's calendar feed URLs.
+func (h *Handlers) renderCalendarSettings(w http.ResponseWriter, r *http.Request, user db.User) {
+	token, customers := h.calendarSettings(w, r, user)
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.CalendarSettings(token, customers)},
+	})
+}
+
+// calendarSettings loads the user's calendar feed token, which is empty when they have none, and the customers they
+// can subscribe to individually.
+func (h *Handlers) calendarSettings(w http.ResponseWriter, r *http.Request, user db.User) (string, []db.Customer) {
+	token, err := h.Queries.GetCalendarToken(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		slog.Error("Failed to load calendar token", "user", user.GithubID, "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the calendar feed.", w, r)
+	}
+	customers, err := h.Queries.ListCustomers(r.Context())
+	if err != nil {
+		slog.Error("Failed to load customers", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the customers.", w, r)
+	}
+	sort.Slice(customers, func(i, j int) bool { return strings.ToLower(customers[i].Name) < strings.ToLower(customers[j].Name) })
+	return token.Token, customers
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/apppasswords"
+	"github.com/scottmckendry/beam/carddav"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// RegisterCardDAVRoutes registers the CardDAV server on the given router. CardDAV clients sign in with app passwords
+// rather than a session, so the router must not require one.
+func (h *Handlers) RegisterCardDAVRoutes(r chi.Router) {
+	for _, method := range carddav.Methods {
+		chi.RegisterMethod(method)
+	}
+	r.Mount("/carddav", carddav.New(h.Queries, "/carddav"))
+	// clients given only the server's address discover the service here (RFC 6764)
+	r.HandleFunc("/.well-known/carddav", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/carddav/", http.StatusMovedPermanently)
+	})
+}
+
+// AddAppPasswordSSE creates an app password for the current user, showing it once
+func (h *Handlers) AddAppPasswordSSE(w http.ResponseWriter, r *http.Request) {
+	user, err := h.currentUser(r.Context())
+	if err != nil {
+		slog.Error("Failed to load the current user", "err", err)
+		h.Notify(NotifyError, "Create Failed", "An error occurred while creating the app password.", w, r)
+		return
+	}
+
+	created, password, err := apppasswords.Create(r.Context(), h.Queries, user.ID, r.FormValue("name"))
+	if err != nil {
+		slog.Error("Error creating app password", "user", user.GithubID, "err", err)
+		h.Notify(NotifyError, "Create Failed", fmt.Sprintf("The app password could not be created: %v.", err), w, r)
+		return
+	}
+	al.LogAppPasswordCreated(r.Context(), h.Queries, created.Name)
+
+	h.Notify(NotifySuccess, "App Password Created", fmt.Sprintf("Use it to sign in to CardDAV on %s.", created.Name), w, r)
+	h.renderAppPasswords(w, r, user, password)
+}
+
+// DeleteAppPasswordSSE revokes one of the current user's app passwords
+func (h *Handlers) DeleteAppPasswordSSE(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		slog.Error("Invalid app password ID", "err", err)
+		h.Notify(NotifyError, "Invalid App Password ID", "The app password ID provided is not valid.", w, r)
+		return
+	}
+	user, err := h.currentUser(r.Context())
+	if err != nil {
+		slog.Error("Failed to load the current user", "err", err)
+		h.Notify(NotifyError, "Revoke Failed", "An error occurred while revoking the app password.", w, r)
+		return
+	}
+
+	revoked, err := h.Queries.DeleteAppPassword(r.Context(), db.DeleteAppPasswordParams{ID: id, UserID: user.ID})
+	if err != nil {
+		slog.Error("Error revoking app password", "app_password_id", id, "err", err)
+		h.Notify(NotifyError, "Revoke Failed", "An error occurred while revoking the app password.", w, r)
+		return
+	}
+	al.LogAppPasswordRevoked(r.Context(), h.Queries, revoked.Name)
+
+	h.Notify(NotifySuccess, "App Password Revoked", fmt.Sprintf("%s can no longer sign in to CardDAV.", revoked.Name), w, r)
+	h.renderAppPasswords(w, r, user, "")
+}
+
+// renderAppPasswords renders the current user's app passwords, along with a password that has just been created.
+func (h *Handlers) renderAppPasswords(w http.ResponseWriter, r *http.Request, user db.User, created string) {
+	passwords, err := h.Queries.ListAppPasswordsByUser(r.Context(), user.ID)
+	if err != nil {
+		slog.Error("Failed to load app passwords", "user", user.GithubID, "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the app passwords.", w, r)
+	}
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.AppPasswordSettings(user, passwords, created)},
+	})
+}
+
+// currentUser loads the signed in user.
+func (h *Handlers) currentUser(ctx context.Context) (db.User, error) {
+	return h.Queries.GetUserByGithubID(ctx, al.Actor(ctx))
+}
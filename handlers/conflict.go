@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/a-h/templ"
+
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// renderConflict shows that an update was rejected because the record was saved by someone else after the form was
+// loaded. The submitted values are carried into the conflict view so the user can choose to overwrite.
+func (h *Handlers) renderConflict(w http.ResponseWriter, r *http.Request, props views.ConflictProps) {
+	props.Values = r.Form
+	h.Notify(NotifyError, "Update Conflict", fmt.Sprintf("This %s was changed by someone else while you were editing.", props.Record), w, r)
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.EditConflict(props)},
+	})
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -14,8 +15,10 @@ import (
 	"github.com/google/uuid"
 
 	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/customfields"
 	"github.com/scottmckendry/beam/db/sqlc"
 	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
 	"github.com/scottmckendry/beam/ui/views"
 )
 
@@ -37,6 +40,7 @@ func (h *Handlers) GetCustomerContactsSSE(w http.ResponseWriter, r *http.Request
 	if !ok {
 		return
 	}
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: c.ID, Tab: hub.TabContacts})
 
 	contacts, err := h.Queries.ListContactsByCustomer(r.Context(), c.ID)
 	if err != nil {
@@ -46,7 +50,7 @@ func (h *Handlers) GetCustomerContactsSSE(w http.ResponseWriter, r *http.Request
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.CustomerContacts(c, contacts),
+			views.CustomerContacts(c, contacts, h.contactCustomFields(r.Context(), c.ID, contacts)),
 			views.HeaderIcon("customer"),
 		},
 	})
@@ -61,10 +65,13 @@ func (h *Handlers) AddContactFormSSE(w http.ResponseWriter, r *http.Request) {
 		h.Notify(NotifyError, "Missing Customer ID", "No customer ID provided.", w, r)
 		return
 	}
+	if cid, err := uuid.Parse(customerID); err == nil {
+		h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: cid, Tab: hub.TabForm})
+	}
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.AddContact(customerID),
+			views.AddContact(customerID, h.customFieldValues(r.Context(), customfields.EntityContact, uuid.Nil)),
 		},
 	})
 }
@@ -87,6 +94,10 @@ func (h *Handlers) AddContactSubmitSSE(w http.ResponseWriter, r *http.Request) {
 		h.Notify(NotifyError, "Form Error", "An error occurred while processing the form.", w, r)
 		return
 	}
+	fields, custom, ok := h.parseCustomFields(w, r, customfields.EntityContact)
+	if !ok {
+		return
+	}
 
 	// Ensure the customer ID is set
 	params.CustomerID = cid
@@ -109,8 +120,12 @@ func (h *Handlers) AddContactSubmitSSE(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	h.saveCustomFields(w, r, fields, custom, cid, newContact.ID, al.ActivityTypeContact, newContact.Name, false)
+
 	h.Notify(NotifySuccess, "Contact added", "The contact has been successfully added.", w, r)
 	al.LogContactAdded(r.Context(), h.Queries, newContact.CustomerID, newContact.Name)
+	h.publish(r, hub.Event{CustomerID: cid})
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: cid, Tab: hub.TabContacts})
 
 	// Refresh the contact list for the customer
 	contacts, err := h.Queries.ListContactsByCustomer(r.Context(), cid)
@@ -131,7 +146,7 @@ func (h *Handlers) AddContactSubmitSSE(w http.ResponseWriter, r *http.Request) {
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Signals: buildCustomerPageSignals(customer), // needed to update contact totals
 		Views: []templ.Component{
-			views.CustomerContacts(customer, contacts),
+			views.CustomerContacts(customer, contacts, h.contactCustomFields(r.Context(), customer.ID, contacts)),
 		},
 	})
 }
@@ -163,6 +178,7 @@ func (h *Handlers) DeleteContactSSE(w http.ResponseWriter, r *http.Request) {
 	}
 	h.Notify(NotifySuccess, "Contact deleted", "The contact has been successfully deleted.", w, r)
 	al.LogContactDeleted(r.Context(), h.Queries, contact.CustomerID, contact.Name)
+	h.publish(r, hub.Event{CustomerID: contact.CustomerID})
 
 	// Refresh the contact list for the customer
 	contacts, err := h.Queries.ListContactsByCustomer(r.Context(), contact.CustomerID)
@@ -183,7 +199,7 @@ func (h *Handlers) DeleteContactSSE(w http.ResponseWriter, r *http.Request) {
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Signals: buildCustomerPageSignals(customer), // needed to update contact totals
 		Views: []templ.Component{
-			views.CustomerContacts(customer, contacts),
+			views.CustomerContacts(customer, contacts, h.contactCustomFields(r.Context(), customer.ID, contacts)),
 		},
 	})
 }
@@ -204,7 +220,12 @@ func (h *Handlers) EditContactFormSSE(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Contact not found", http.StatusNotFound)
 		return
 	}
-	views.EditContact(contact).Render(r.Context(), w)
+	view := hub.View{Page: hub.PageCustomer, CustomerID: contact.CustomerID, Tab: hub.TabForm}
+	openURL := fmt.Sprintf("/sse/customer/%s/edit-contact/%s", contact.CustomerID, contact.ID)
+	if !h.openEditForm(w, r, hub.ContactRecord(contact.ID), "contact", openURL, view) {
+		return
+	}
+	views.EditContact(contact, h.customFieldValues(r.Context(), customfields.EntityContact, contact.ID)).Render(r.Context(), w)
 }
 
 // EditContactSubmitSSE handles the submission of the edit contact form, updates the contact, and refreshes the contact list via SSE.
@@ -218,7 +239,7 @@ func (h *Handlers) EditContactSubmitSSE(w http.ResponseWriter, r *http.Request)
 		h.Notify(NotifyError, "Invalid contact ID", "The provided contact ID is invalid.", w, r)
 		return
 	}
-	parsedCustID, err := uuid.Parse(customerID)
+	parsedCustID, _ := uuid.Parse(customerID)
 
 	var params db.UpdateContactParams
 	if err := utils.MapFormToStruct(r, &params); err != nil {
@@ -227,24 +248,37 @@ func (h *Handlers) EditContactSubmitSSE(w http.ResponseWriter, r *http.Request)
 		h.Notify(NotifyError, "Form Error", "An error occurred while processing the form.", w, r)
 		return
 	}
+	fields, custom, ok := h.parseCustomFields(w, r, customfields.EntityContact)
+	if !ok {
+		return
+	}
 
 	// ensure the contact and customer IDs are set
 	params.ID = cid
 
-	// If this contact is set as primary, unset all other primary contacts for the customer
-	if params.IsPrimary.Bool {
-		if err == nil {
-			err = h.Queries.UnsetOtherPrimaryContacts(r.Context(), db.UnsetOtherPrimaryContactsParams{
-				CustomerID: parsedCustID,
-				ID:         cid,
-			})
-			if err != nil {
-				slog.Error("Failed to unset other primary contacts", "err", err)
-			}
-		}
+	// keep the previous version for the activity diff
+	before, err := h.Queries.GetContact(r.Context(), cid)
+	if err != nil {
+		slog.Error("Failed to get contact", "err", err)
+		w.WriteHeader(http.StatusNotFound)
+		h.Notify(NotifyError, "Contact not found", "The contact you are trying to update does not exist.", w, r)
+		return
 	}
 
-	err = h.Queries.UpdateContact(r.Context(), params)
+	updated, err := h.Queries.UpdateContact(r.Context(), params)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the contact was saved by someone else after this form was loaded
+		h.renderConflict(w, r, views.ConflictProps{
+			TargetID:  "customer-tab-content",
+			Record:    "contact",
+			Changes:   al.Diff(params, before),
+			SavedAt:   before.UpdatedAt.Time,
+			Version:   before.Version,
+			SubmitURL: fmt.Sprintf("/sse/customer/%s/edit-contact-submit/%s", before.CustomerID, before.ID),
+			ReloadURL: fmt.Sprintf("/sse/customer/%s/edit-contact/%s", before.CustomerID, before.ID),
+		})
+		return
+	}
 	if err != nil {
 		slog.Error("Failed to update contact", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -252,8 +286,23 @@ func (h *Handlers) EditContactSubmitSSE(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// If this contact is set as primary, unset all other primary contacts for the customer
+	if params.IsPrimary.Bool {
+		err = h.Queries.UnsetOtherPrimaryContacts(r.Context(), db.UnsetOtherPrimaryContactsParams{
+			CustomerID: updated.CustomerID,
+			ID:         cid,
+		})
+		if err != nil {
+			slog.Error("Failed to unset other primary contacts", "err", err)
+		}
+	}
+
+	h.saveCustomFields(w, r, fields, custom, updated.CustomerID, updated.ID, al.ActivityTypeContact, updated.Name, true)
+
 	h.Notify(NotifySuccess, "Contact updated", "The contact has been successfully updated.", w, r)
-	al.LogContactUpdated(r.Context(), h.Queries, parsedCustID, params.Name)
+	al.LogContactUpdated(r.Context(), h.Queries, before, updated)
+	h.publish(r, hub.Event{CustomerID: updated.CustomerID})
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: updated.CustomerID, Tab: hub.TabContacts})
 
 	// Fetch the updated contacts for the customer
 	contacts, err := h.Queries.ListContactsByCustomer(r.Context(), parsedCustID)
@@ -274,7 +323,7 @@ func (h *Handlers) EditContactSubmitSSE(w http.ResponseWriter, r *http.Request)
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.CustomerContacts(customer, contacts),
+			views.CustomerContacts(customer, contacts, h.contactCustomFields(r.Context(), customer.ID, contacts)),
 		},
 	})
 }
@@ -294,6 +343,7 @@ func (h *Handlers) UploadContactAvatarSSE(w http.ResponseWriter, r *http.Request
 		Avatar      []string `json:"avatar"`
 		AvatarMimes []string `json:"avatarMimes"`
 		AvatarNames []string `json:"avatarNames"`
+		StreamID    string   `json:"streamId"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		slog.Error("Error decoding JSON", "err", err)
@@ -341,6 +391,10 @@ func (h *Handlers) UploadContactAvatarSSE(w http.ResponseWriter, r *http.Request
 	}
 
 	h.Notify(NotifySuccess, "Avatar Uploaded", "Contact avatar has been successfully uploaded.", w, r)
+	if contact, err := h.Queries.GetContact(r.Context(), contactID); err == nil {
+		al.LogAvatarUploaded(r.Context(), h.Queries, contact.CustomerID, contact.Name)
+		h.publish(r, hub.Event{CustomerID: contact.CustomerID, Origin: payload.StreamID})
+	}
 
 	// Refresh the contacts list for the customer
 	parsedCustID, _ := uuid.Parse(chi.URLParam(r, "customerID"))
@@ -349,7 +403,7 @@ func (h *Handlers) UploadContactAvatarSSE(w http.ResponseWriter, r *http.Request
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Signals: []byte(`{"avatar": ""}`),
 		Views: []templ.Component{
-			views.CustomerContacts(customer, contacts),
+			views.CustomerContacts(customer, contacts, h.contactCustomFields(r.Context(), customer.ID, contacts)),
 		},
 	})
 }
@@ -373,6 +427,10 @@ func (h *Handlers) DeleteContactAvatarSSE(w http.ResponseWriter, r *http.Request
 	}
 
 	h.Notify(NotifySuccess, "Avatar Deleted", "Contact avatar has been successfully deleted.", w, r)
+	if contact, err := h.Queries.GetContact(r.Context(), contactID); err == nil {
+		al.LogAvatarDeleted(r.Context(), h.Queries, contact.CustomerID, contact.Name)
+		h.publish(r, hub.Event{CustomerID: contact.CustomerID})
+	}
 
 	// Refresh the contacts list for the customer
 	parsedCustID, _ := uuid.Parse(chi.URLParam(r, "customerID"))
@@ -380,7 +438,7 @@ func (h *Handlers) DeleteContactAvatarSSE(w http.ResponseWriter, r *http.Request
 	customer, _ := h.Queries.GetCustomer(r.Context(), parsedCustID)
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.CustomerContacts(customer, contacts),
+			views.CustomerContacts(customer, contacts, h.contactCustomFields(r.Context(), customer.ID, contacts)),
 		},
 	})
 
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/currency"
+	"github.com/scottmckendry/beam/discounts"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// AddExchangeRateSSE saves an exchange rate entered on the settings page
+func (h *Handlers) AddExchangeRateSSE(w http.ResponseWriter, r *http.Request) {
+	rate, err := strconv.ParseFloat(r.FormValue("rate"), 64)
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Exchange Rate", "The rate must be a number.", w, r)
+		return
+	}
+	params, err := currency.RateParams(r.FormValue("from"), r.FormValue("to"), rate, r.FormValue("effective"))
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Exchange Rate", err.Error(), w, r)
+		return
+	}
+
+	if _, err := h.Queries.CreateExchangeRate(r.Context(), params); err != nil {
+		slog.Error("Error adding exchange rate", "from", params.FromCurrency, "to", params.ToCurrency, "err", err)
+		h.Notify(NotifyError, "Add Failed", fmt.Sprintf("The rate could not be added. Each pair can only have one rate from %s.", params.EffectiveFrom.Format("Jan 2, 2006")), w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Exchange Rate Added", fmt.Sprintf("%s to %s is now %g from %s.", params.FromCurrency, params.ToCurrency, params.Rate, params.EffectiveFrom.Format("Jan 2, 2006")), w, r)
+	h.renderExchangeRates(w, r)
+}
+
+// DeleteExchangeRateSSE deletes an exchange rate
+func (h *Handlers) DeleteExchangeRateSSE(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		slog.Error("Invalid exchange rate ID", "err", err)
+		h.Notify(NotifyError, "Invalid Exchange Rate ID", "The exchange rate ID provided is not valid.", w, r)
+		return
+	}
+
+	deleted, err := h.Queries.DeleteExchangeRate(r.Context(), id)
+	if err != nil {
+		slog.Error("Error deleting exchange rate", "exchange_rate_id", id, "err", err)
+		h.Notify(NotifyError, "Delete Failed", "An error occurred while deleting the exchange rate.", w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Exchange Rate Deleted", fmt.Sprintf("The %s to %s rate from %s has been deleted.", deleted.FromCurrency, deleted.ToCurrency, deleted.EffectiveFrom.Format("Jan 2, 2006")), w, r)
+	h.renderExchangeRates(w, r)
+}
+
+// renderExchangeRates renders the saved exchange rates.
+func (h *Handlers) renderExchangeRates(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.Queries.ListExchangeRates(r.Context())
+	if err != nil {
+		slog.Error("Failed to load exchange rates", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the exchange rates.", w, r)
+	}
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.ExchangeRateSettings(h.ReportingCurrency, rates)},
+	})
+}
+
+// recurringRevenue totals the monthly recurring revenue of the active subscriptions of a customer, or of every
+// customer when customerID is uuid.Nil, in the reporting currency. Subscriptions in a free trial bring in nothing, and
+// the rest what they are charged today after discounts, spread over the months between their billing dates.
+func (h *Handlers) recurringRevenue(ctx context.Context, customerID uuid.UUID) currency.Total {
+	revenue := currency.Total{Currency: h.ReportingCurrency}
+	conv, err := currency.Load(ctx, h.Queries, h.ReportingCurrency)
+	if err != nil {
+		slog.Error("Failed to load exchange rates", "err", err)
+		return revenue
+	}
+	subscriptions, err := h.Queries.ListActiveSubscriptions(ctx)
+	if err != nil {
+		slog.Error("Failed to load active subscriptions", "err", err)
+		return revenue
+	}
+	active, err := h.Queries.ListActiveSubscriptionDiscounts(ctx)
+	if err != nil {
+		slog.Error("Failed to load subscription discounts", "err", err)
+		return revenue
+	}
+	now := h.Clock()
+	for _, s := range subscriptions {
+		if customerID == uuid.Nil || s.CustomerID == customerID {
+			conv.Add(&revenue, discounts.Monthly(s, active, now), s.Currency, now)
+		}
+	}
+	return revenue
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/customfields"
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// customFieldValues returns the value of every custom field for entity on a record, or blank values for a record
+// that does not exist yet. Errors are logged and treated as having no custom fields.
+func (h *Handlers) customFieldValues(ctx context.Context, entity string, recordID uuid.UUID) []customfields.Value {
+	values, err := customfields.Load(ctx, h.Queries, entity, recordID)
+	if err != nil {
+		slog.Error("Failed to load custom fields", "entity", entity, "record_id", recordID, "err", err)
+	}
+	return values
+}
+
+// contactCustomFields returns the custom field values of a customer's contacts, by contact.
+func (h *Handlers) contactCustomFields(ctx context.Context, customerID uuid.UUID, contacts []db.Contact) map[uuid.UUID][]customfields.Value {
+	fields, err := h.Queries.ListCustomFields(ctx, customfields.EntityContact)
+	if err != nil {
+		slog.Error("Failed to load contact custom fields", "err", err)
+		return nil
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	stored, err := h.Queries.ListContactCustomFieldValuesByCustomer(ctx, customerID)
+	if err != nil {
+		slog.Error("Failed to load contact custom field values", "customer_id", customerID, "err", err)
+	}
+	set := customfields.Index(stored)
+
+	values := make(map[uuid.UUID][]customfields.Value, len(contacts))
+	for _, c := range contacts {
+		values[c.ID] = set.For(fields, c.ID)
+	}
+	return values
+}
+
+// parseCustomFields validates the custom field inputs on a submitted form. If any are invalid the errors are shown
+// and false is returned, before anything has been saved.
+func (h *Handlers) parseCustomFields(w http.ResponseWriter, r *http.Request, entity string) ([]db.CustomField, map[uuid.UUID]string, bool) {
+	fields, err := h.Queries.ListCustomFields(r.Context(), entity)
+	if err != nil {
+		slog.Error("Failed to load custom fields", "entity", entity, "err", err)
+		h.Notify(NotifyError, "Form Error", "An error occurred while loading the custom fields.", w, r)
+		return nil, nil, false
+	}
+	if err := r.ParseForm(); err != nil {
+		slog.Error("Error parsing form", "err", err)
+		h.Notify(NotifyError, "Form Error", "An error occurred while processing the form.", w, r)
+		return nil, nil, false
+	}
+	values, err := customfields.Parse(fields, r.Form)
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Custom Field", err.Error(), w, r)
+		return nil, nil, false
+	}
+	return fields, values, true
+}
+
+// saveCustomFields stores parsed custom field values on a record. Changes are logged against the customer when
+// logChanges is set, which is skipped for new records since their creation is already logged.
+func (h *Handlers) saveCustomFields(w http.ResponseWriter, r *http.Request, fields []db.CustomField, values map[uuid.UUID]string, customerID, recordID uuid.UUID, activityType al.ActivityType, recordName string, logChanges bool) {
+	changes, err := customfields.Save(r.Context(), h.Queries, fields, recordID, values)
+	if logChanges {
+		al.LogCustomFieldsUpdated(r.Context(), h.Queries, customerID, activityType, recordName, changes)
+	}
+	if err != nil {
+		slog.Error("Error saving custom fields", "record_id", recordID, "err", err)
+		h.Notify(NotifyError, "Custom Fields Not Saved", fmt.Sprintf("An error occurred while saving the custom fields of %s.", recordName), w, r)
+	}
+}
+
+// AddCustomFieldSSE handles the submission of the add custom field form
+func (h *Handlers) AddCustomFieldSSE(w http.ResponseWriter, r *http.Request) {
+	params, err := customfields.FieldParams(r.FormValue("entity"), r.FormValue("name"), r.FormValue("type"), r.FormValue("options"))
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Custom Field", err.Error(), w, r)
+		return
+	}
+
+	if _, err := h.Queries.CreateCustomField(r.Context(), params); err != nil {
+		slog.Error("Error adding custom field", "name", params.Name, "err", err)
+		h.Notify(NotifyError, "Add Failed", fmt.Sprintf("A %s field named %s could not be added. Field names must be unique.", params.Entity, params.Name), w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Custom Field Added", fmt.Sprintf("%s can now be recorded against each %s.", params.Name, params.Entity), w, r)
+	h.renderSettings(w, r, nil)
+}
+
+// DeleteCustomFieldSSE deletes a custom field along with its value on every record.
+func (h *Handlers) DeleteCustomFieldSSE(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		slog.Error("Invalid custom field ID", "err", err)
+		h.Notify(NotifyError, "Invalid Custom Field ID", "The custom field ID provided is not valid.", w, r)
+		return
+	}
+
+	f, err := h.Queries.DeleteCustomField(r.Context(), id)
+	if err != nil {
+		slog.Error("Error deleting custom field", "custom_field_id", id, "err", err)
+		h.Notify(NotifyError, "Delete Failed", "An error occurred while deleting the custom field.", w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Custom Field Deleted", fmt.Sprintf("%s has been removed from every %s.", f.Name, f.Entity), w, r)
+	h.renderSettings(w, r, nil)
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -14,8 +16,11 @@ import (
 	"github.com/google/uuid"
 
 	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/currency"
+	"github.com/scottmckendry/beam/customfields"
 	"github.com/scottmckendry/beam/db/sqlc"
 	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
 	"github.com/scottmckendry/beam/ui/views"
 )
 
@@ -23,6 +28,7 @@ import (
 func (h *Handlers) RegisterCustomerRoutes(r chi.Router) {
 	r.Get("/sse/customer/{id}", h.GetCustomerSSE)
 	r.Get("/sse/customer/{customerID}/overview", h.GetCustomerOverviewSSE)
+	r.Get("/sse/customer/{customerID}/activity", h.GetCustomerActivitySSE)
 	r.Get("/sse/customer/add", h.AddCustomerSSE)
 	r.Get("/sse/customer/add-submit", h.SubmitAddCustomerSSE)
 	r.Get("/sse/customer/delete/{id}", h.DeleteCustomerSSE)
@@ -40,11 +46,17 @@ func (h *Handlers) AddCustomerSSE(w http.ResponseWriter, r *http.Request) {
 		CurrentPage:       "none",
 	}
 	encodedSignals, _ := json.Marshal(pageSignals)
+	h.trackView(r, hub.View{Page: hub.PageCustomer, Tab: hub.TabForm})
+
+	tags, err := h.Queries.ListTags(r.Context())
+	if err != nil {
+		slog.Error("Failed to load tags for customer form", "err", err)
+	}
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Signals: encodedSignals,
 		Views: []templ.Component{
-			views.AddCustomer(),
+			views.AddCustomer(tags, h.customFieldValues(r.Context(), customfields.EntityCustomer, uuid.Nil)),
 			views.HeaderIcon("customer"),
 		},
 	})
@@ -56,13 +68,16 @@ func (h *Handlers) GetCustomerSSE(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: c.ID, Tab: hub.TabOverview})
 	pageSignals := buildCustomerPageSignals(c)
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Signals: pageSignals,
 		Views: []templ.Component{
-			views.Customer(c),
+			views.Customer(c, h.customerSummary(r.Context(), c.ID)),
 			views.HeaderIcon("customer"),
+			h.customerPresence(r, c.ID),
+			h.customerTags(r, c.ID),
 		},
 	})
 }
@@ -70,11 +85,20 @@ func (h *Handlers) GetCustomerSSE(w http.ResponseWriter, r *http.Request) {
 // SubmitAddCustomerSSE handles the submission of the add customer form, upon success it will render the customer overview page and refresh the customer navigation
 func (h *Handlers) SubmitAddCustomerSSE(w http.ResponseWriter, r *http.Request) {
 	var params db.CreateCustomerParams
-	if err := utils.MapFormToStruct(r, &params); err != nil {
+	err := utils.MapFormToStruct(r, &params)
+	if err != nil {
 		slog.Error("Error mapping form to struct", "err", err)
 		h.Notify(NotifyError, "Form Error", "An error occurred while processing the form.", w, r)
 		return
 	}
+	if params.Currency, err = currency.Parse(params.Currency); err != nil {
+		h.Notify(NotifyError, "Invalid Currency", err.Error(), w, r)
+		return
+	}
+	fields, custom, ok := h.parseCustomFields(w, r, customfields.EntityCustomer)
+	if !ok {
+		return
+	}
 
 	customer, err := h.Queries.CreateCustomer(r.Context(), params)
 	if err != nil {
@@ -86,6 +110,10 @@ func (h *Handlers) SubmitAddCustomerSSE(w http.ResponseWriter, r *http.Request)
 
 	h.Notify(NotifySuccess, "Customer Added", "Customer has been successfully added.", w, r)
 	al.LogCustomerCreated(r.Context(), h.Queries, customer)
+	h.saveCustomerTags(w, r, customer.ID, customer.Name)
+	h.saveCustomFields(w, r, fields, custom, customer.ID, customer.ID, al.ActivityTypeCustomer, customer.Name, false)
+	h.publish(r, hub.Event{CustomerID: customer.ID, Navigation: true})
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: customer.ID, Tab: hub.TabOverview})
 
 	c, err := h.Queries.GetCustomer(r.Context(), customer.ID)
 	if err != nil {
@@ -96,7 +124,7 @@ func (h *Handlers) SubmitAddCustomerSSE(w http.ResponseWriter, r *http.Request)
 	}
 
 	// get the updated customer list for navigation
-	customers, err := h.Queries.ListCustomers(r.Context())
+	customers, err := h.navigationCustomers(r.Context(), streamID(r))
 	if err != nil {
 		slog.Error("Failed to load customers for navigation", "err", err)
 		h.Notify(NotifyError, "Navigation Error", "An error occurred while loading the customer navigation.", w, r)
@@ -106,9 +134,11 @@ func (h *Handlers) SubmitAddCustomerSSE(w http.ResponseWriter, r *http.Request)
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Signals: buildCustomerPageSignals(c),
 		Views: []templ.Component{
-			views.Customer(c),
+			views.Customer(c, h.customerSummary(r.Context(), c.ID)),
 			views.HeaderIcon("customer"),
 			views.CustomerNavigation(customers),
+			h.customerPresence(r, c.ID),
+			h.customerTags(r, c.ID),
 		},
 	})
 }
@@ -127,11 +157,26 @@ func (h *Handlers) EditCustomerFormSSE(w http.ResponseWriter, r *http.Request) {
 	}
 	encodedSignals, _ := json.Marshal(pageSignals)
 
+	view := hub.View{Page: hub.PageCustomer, CustomerID: c.ID, Tab: hub.TabForm}
+	if !h.openEditForm(w, r, hub.CustomerRecord(c.ID), "customer", fmt.Sprintf("/sse/customer/edit/%s", c.ID), view) {
+		return
+	}
+
+	tags, err := h.Queries.ListTags(r.Context())
+	if err != nil {
+		slog.Error("Failed to load tags for customer form", "err", err)
+	}
+	selected, err := h.Queries.ListCustomerTags(r.Context(), c.ID)
+	if err != nil {
+		slog.Error("ListCustomerTags failed", "customer_id", c.ID, "err", err)
+	}
+
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Signals: encodedSignals,
 		Views: []templ.Component{
-			views.EditCustomer(c),
+			views.EditCustomer(c, tags, selected, h.customFieldValues(r.Context(), customfields.EntityCustomer, c.ID)),
 			views.HeaderIcon("customer"),
+			h.customerPresence(r, c.ID),
 		},
 	})
 }
@@ -146,13 +191,43 @@ func (h *Handlers) EditCustomerSubmitSSE(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// prevent non-form fields from being overwritten
 	var params db.UpdateCustomerParams
-	c, _ := h.getCustomerByID(w, r, "id")
-	params.ID = c.ID
-	params.Logo = c.Logo
+	if err := utils.MapFormToStruct(r, &params); err != nil {
+		slog.Error("Error mapping form to struct", "err", err)
+		h.Notify(NotifyError, "Form Error", "An error occurred while processing the form.", w, r)
+		return
+	}
+	if params.Currency, err = currency.Parse(params.Currency); err != nil {
+		h.Notify(NotifyError, "Invalid Currency", err.Error(), w, r)
+		return
+	}
+	fields, custom, ok := h.parseCustomFields(w, r, customfields.EntityCustomer)
+	if !ok {
+		return
+	}
 
-	_, err = h.Queries.UpdateCustomer(r.Context(), params)
+	// keep the previous version for the activity diff and prevent non-form fields from being overwritten
+	before, ok := h.getCustomerByID(w, r, "id")
+	if !ok {
+		return
+	}
+	params.ID = before.ID
+	params.Logo = before.Logo
+
+	updated, err := h.Queries.UpdateCustomer(r.Context(), params)
+	if errors.Is(err, sql.ErrNoRows) {
+		// the customer was saved by someone else after this form was loaded
+		h.renderConflict(w, r, views.ConflictProps{
+			TargetID:  "inner-content",
+			Record:    "customer",
+			Changes:   al.Diff(params, before),
+			SavedAt:   before.UpdatedAt.Time,
+			Version:   before.Version,
+			SubmitURL: fmt.Sprintf("/sse/customer/edit-submit/%s", before.ID),
+			ReloadURL: fmt.Sprintf("/sse/customer/edit/%s", before.ID),
+		})
+		return
+	}
 	if err != nil {
 		slog.Error("Error updating customer", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -161,10 +236,14 @@ func (h *Handlers) EditCustomerSubmitSSE(w http.ResponseWriter, r *http.Request)
 	}
 
 	h.Notify(NotifySuccess, "Customer Updated", fmt.Sprintf("%s has been successfully updated.", params.Name), w, r)
-	c, _ = h.Queries.GetCustomer(r.Context(), parsedID)
-	al.LogCustomerUpdated(r.Context(), h.Queries, c)
-
-	customers, err := h.Queries.ListCustomers(r.Context())
+	al.LogCustomerUpdated(r.Context(), h.Queries, before, updated)
+	h.saveCustomerTags(w, r, updated.ID, updated.Name)
+	h.saveCustomFields(w, r, fields, custom, updated.ID, updated.ID, al.ActivityTypeCustomer, updated.Name, true)
+	h.publish(r, hub.Event{CustomerID: updated.ID, Navigation: true})
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: updated.ID, Tab: hub.TabOverview})
+	c, _ := h.Queries.GetCustomer(r.Context(), parsedID)
+
+	customers, err := h.navigationCustomers(r.Context(), streamID(r))
 	if err != nil {
 		slog.Error("Failed to load customers for navigation", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -174,9 +253,11 @@ func (h *Handlers) EditCustomerSubmitSSE(w http.ResponseWriter, r *http.Request)
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Signals: buildCustomerPageSignals(c),
 		Views: []templ.Component{
-			views.Customer(c),
+			views.Customer(c, h.customerSummary(r.Context(), c.ID)),
 			views.HeaderIcon("customer"),
 			views.CustomerNavigation(customers),
+			h.customerPresence(r, c.ID),
+			h.customerTags(r, c.ID),
 		},
 	})
 }
@@ -208,11 +289,12 @@ func (h *Handlers) DeleteCustomerSSE(w http.ResponseWriter, r *http.Request) {
 
 	h.Notify(NotifySuccess, "Customer Deleted", fmt.Sprintf("Customer %s has been deleted and removed from active lists.", c.Name), w, r)
 	al.LogCustomerDeleted(r.Context(), h.Queries, c)
+	h.publish(r, hub.Event{CustomerID: c.ID, Navigation: true})
 
 	// render dashboard, refresh customer navigation
 	h.DashboardSSE(w, r)
 
-	customers, err := h.Queries.ListCustomers(r.Context())
+	customers, err := h.navigationCustomers(r.Context(), streamID(r))
 	if err != nil {
 		slog.Error("Failed to load customers for navigation", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -231,14 +313,56 @@ func (h *Handlers) GetCustomerOverviewSSE(w http.ResponseWriter, r *http.Request
 	if !ok {
 		return
 	}
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: c.ID, Tab: hub.TabOverview})
+
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{
+			views.CustomerOverview(c, h.customerSummary(r.Context(), c.ID)),
+		},
+	})
+}
+
+// GetCustomerActivitySSE renders a customer's activity timeline, including who made each change and what changed, via SSE
+func (h *Handlers) GetCustomerActivitySSE(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.getCustomerByID(w, r, "customerID")
+	if !ok {
+		return
+	}
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: c.ID, Tab: hub.TabActivity})
+
+	activities, err := h.Queries.ListActivityByCustomer(r.Context(), uuid.NullUUID{UUID: c.ID, Valid: true})
+	if err != nil {
+		slog.Error("ListActivityByCustomer failed", "customer_id", c.ID, "err", err)
+		h.Notify(NotifyError, "Activity Not Found", "An error occurred while loading the activity for this customer.", w, r)
+	}
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.CustomerOverview(c),
+			views.CustomerActivity(c, activities),
+			views.HeaderIcon("customer"),
 		},
 	})
 }
 
+// customerSummary loads what the overview of a customer shows besides the customer itself. Errors are logged and
+// treated as having nothing to show.
+func (h *Handlers) customerSummary(ctx context.Context, customerID uuid.UUID) views.CustomerSummary {
+	seats, err := h.Queries.ListSeatTotalsByCustomer(ctx, customerID)
+	if err != nil {
+		slog.Error("Failed to load seat totals", "customerID", customerID, "err", err)
+	}
+	report, err := h.marginReport(ctx, customerID)
+	if err != nil {
+		slog.Error("Failed to build margin report", "customerID", customerID, "err", err)
+	}
+	return views.CustomerSummary{
+		Custom:  h.customFieldValues(ctx, customfields.EntityCustomer, customerID),
+		Revenue: h.recurringRevenue(ctx, customerID),
+		Seats:   seats,
+		Margins: report,
+	}
+}
+
 // getCustomerByID fetches a customer by ID from the URL param and handles errors consistently
 func (h *Handlers) getCustomerByID(w http.ResponseWriter, r *http.Request, idParam string) (db.GetCustomerRow, bool) {
 	id := chi.URLParam(r, idParam)
@@ -287,11 +411,16 @@ func (h *Handlers) DeleteCustomerLogoSSE(w http.ResponseWriter, r *http.Request)
 
 	// Refresh the customer overview page
 	updated, _ := h.Queries.GetCustomer(r.Context(), customerID)
-	customers, _ := h.Queries.ListCustomers(r.Context())
+	al.LogLogoDeleted(r.Context(), h.Queries, customerID, updated.Name)
+	h.publish(r, hub.Event{CustomerID: customerID, Navigation: true})
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: customerID, Tab: hub.TabOverview})
+	customers, _ := h.navigationCustomers(r.Context(), streamID(r))
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.Customer(updated),
+			views.Customer(updated, h.customerSummary(r.Context(), customerID)),
 			views.CustomerNavigation(customers),
+			h.customerPresence(r, customerID),
+			h.customerTags(r, customerID),
 		},
 	})
 
@@ -329,6 +458,7 @@ func (h *Handlers) UploadCustomerLogoSSE(w http.ResponseWriter, r *http.Request)
 		Logo      []string `json:"logo"`
 		LogoMimes []string `json:"logoMimes"`
 		LogoNames []string `json:"logoNames"`
+		StreamID  string   `json:"streamId"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
@@ -381,12 +511,16 @@ func (h *Handlers) UploadCustomerLogoSSE(w http.ResponseWriter, r *http.Request)
 
 	// Refresh the customer overview page
 	updated, _ := h.Queries.GetCustomer(r.Context(), customerID)
-	customers, _ := h.Queries.ListCustomers(r.Context())
+	al.LogLogoUploaded(r.Context(), h.Queries, customerID, updated.Name)
+	h.publish(r, hub.Event{CustomerID: customerID, Navigation: true, Origin: payload.StreamID})
+	customers, _ := h.navigationCustomers(r.Context(), payload.StreamID)
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Signals: []byte(`{"logo": ""}`),
 		Views: []templ.Component{
-			views.Customer(updated),
+			views.Customer(updated, h.customerSummary(r.Context(), customerID)),
 			views.CustomerNavigation(customers),
+			h.customerPresence(r, customerID),
+			h.customerTags(r, customerID),
 		},
 	})
 }
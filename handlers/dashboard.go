@@ -8,7 +8,10 @@ import (
 
 	"github.com/a-h/templ"
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 
+	"github.com/scottmckendry/beam/discounts"
+	"github.com/scottmckendry/beam/hub"
 	"github.com/scottmckendry/beam/ui/views"
 )
 
@@ -17,6 +20,7 @@ func (h *Handlers) RegisterDashboardRoutes(r chi.Router) {
 	r.Get("/sse/dashboard", h.DashboardSSE)
 	r.Get("/sse/dashboard/stats", h.DashboardStatsSSE)
 	r.Get("/sse/dashboard/activity", h.DashboardActivitySSE)
+	r.Get("/sse/dashboard/trials", h.DashboardTrialsSSE)
 }
 
 func (h *Handlers) DashboardStatsSSE(w http.ResponseWriter, r *http.Request) {
@@ -28,7 +32,7 @@ func (h *Handlers) DashboardStatsSSE(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	utils.RenderSSE(w, r, utils.SSEOpts{Views: []templ.Component{views.DashboardStats(stats)}})
+	utils.RenderSSE(w, r, utils.SSEOpts{Views: []templ.Component{views.DashboardStats(stats, h.recurringRevenue(r.Context(), uuid.Nil))}})
 }
 
 func (h *Handlers) DashboardActivitySSE(w http.ResponseWriter, r *http.Request) {
@@ -43,19 +47,37 @@ func (h *Handlers) DashboardActivitySSE(w http.ResponseWriter, r *http.Request)
 	utils.RenderSSE(w, r, utils.SSEOpts{Views: []templ.Component{views.DashboardActivity(activities)}})
 }
 
-func (h *Handlers) DashboardSSE(w http.ResponseWriter, r *http.Request) {
-	pageSignals := utils.PageSignals{
-		HeaderTitle:       "Dashboard",
-		HeaderDescription: "Overview of your business metrics",
-		CurrentPage:       "dashboard",
+// DashboardTrialsSSE renders the free trials that are about to end.
+func (h *Handlers) DashboardTrialsSSE(w http.ResponseWriter, r *http.Request) {
+	trials, err := h.Queries.ListTrialSubscriptions(r.Context())
+	if err != nil {
+		slog.Error("Failed to load trials", "err", err)
+		h.Notify(NotifyError, "Trials Error", "Failed to load the trials ending soon.", w, r)
+		http.Error(w, "Failed to load trials", http.StatusInternalServerError)
+		return
 	}
-	encodedSignals, _ := json.Marshal(pageSignals)
 
+	utils.RenderSSE(w, r, utils.SSEOpts{Views: []templ.Component{views.DashboardTrials(discounts.Ending(trials, h.Clock()))}})
+}
+
+func (h *Handlers) DashboardSSE(w http.ResponseWriter, r *http.Request) {
+	h.trackView(r, hub.View{Page: hub.PageDashboard})
 	utils.RenderSSE(w, r, utils.SSEOpts{
-		Signals: encodedSignals,
+		Signals: dashboardPageSignals(),
 		Views: []templ.Component{
 			views.Dashboard(),
 			views.HeaderIcon("dashboard"),
 		},
 	})
 }
+
+// dashboardPageSignals constructs the page signals for the dashboard
+func dashboardPageSignals() []byte {
+	pageSignals := utils.PageSignals{
+		HeaderTitle:       "Dashboard",
+		HeaderDescription: "Overview of your business metrics",
+		CurrentPage:       "dashboard",
+	}
+	encodedSignals, _ := json.Marshal(pageSignals)
+	return encodedSignals
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	db "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/discounts"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// AddSubscriptionDiscountSSE gives a discount on a subscription, either by redeeming a coupon or on terms entered by
+// hand.
+func (h *Handlers) AddSubscriptionDiscountSSE(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.getSubscriptionByID(w, r, "subscriptionID")
+	if !ok {
+		return
+	}
+
+	var terms discounts.Terms
+	var couponID uuid.NullUUID
+	if code := strings.TrimSpace(r.FormValue("coupon")); code != "" {
+		coupon, err := h.Queries.GetCouponByCode(r.Context(), code)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && coupon.Status == discounts.Archived) {
+			w.WriteHeader(http.StatusBadRequest)
+			h.Notify(NotifyError, "Invalid Coupon", fmt.Sprintf("There is no coupon %s that can be redeemed.", code), w, r)
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to get coupon", "code", code, "err", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			h.Notify(NotifyError, "Discount Failed", "An error occurred while redeeming the coupon. Please try again.", w, r)
+			return
+		}
+		terms = discounts.FromCoupon(coupon)
+		couponID = uuid.NullUUID{UUID: coupon.ID, Valid: true}
+	} else {
+		var err error
+		if terms, err = discounts.ParseTerms(r.FormValue("kind"), r.FormValue("value"), r.FormValue("currency"), r.FormValue("periods")); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			h.Notify(NotifyError, "Invalid Discount", err.Error(), w, r)
+			return
+		}
+	}
+
+	startsOn, err := time.Parse("2006-01-02", r.FormValue("startson"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Date", "Choose the day the discount starts.", w, r)
+		return
+	}
+	var endsOn time.Time
+	if until := r.FormValue("endson"); until != "" {
+		if endsOn, err = time.Parse("2006-01-02", until); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			h.Notify(NotifyError, "Invalid Date", "Choose the last day of the discount.", w, r)
+			return
+		}
+	}
+
+	params, err := discounts.Give(sub, terms, startsOn, endsOn)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Discount", err.Error(), w, r)
+		return
+	}
+	params.CouponID = couponID
+	note := strings.TrimSpace(r.FormValue("note"))
+	params.Note = sql.NullString{String: note, Valid: note != ""}
+	actor := al.Actor(r.Context())
+	params.CreatedBy = sql.NullString{String: actor, Valid: actor != ""}
+
+	discount, err := h.Queries.CreateSubscriptionDiscount(r.Context(), params)
+	if err != nil {
+		slog.Error("Error giving discount", "subscriptionID", sub.ID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		h.Notify(NotifyError, "Discount Failed", "An error occurred while giving the discount. Please try again.", w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Discount Given", fmt.Sprintf("%s.", discounts.Describe(discount)), w, r)
+	al.LogSubscriptionDiscounted(r.Context(), h.Queries, sub, discounts.Describe(discount))
+	h.publish(r, hub.Event{CustomerID: sub.CustomerID})
+	h.renderSubscriptionDiscounts(w, r, sub)
+}
+
+// RemoveSubscriptionDiscountSSE takes a discount off a subscription. Billing dates it already covered keep it.
+func (h *Handlers) RemoveSubscriptionDiscountSSE(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.getSubscriptionByID(w, r, "subscriptionID")
+	if !ok {
+		return
+	}
+	discountID, err := uuid.Parse(chi.URLParam(r, "discountID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Discount", "The discount ID is invalid.", w, r)
+		return
+	}
+
+	discount, err := h.Queries.RemoveSubscriptionDiscount(r.Context(), db.RemoveSubscriptionDiscountParams{ID: discountID, SubscriptionID: sub.ID})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		w.WriteHeader(http.StatusNotFound)
+		h.Notify(NotifyError, "Cannot Remove", "The discount has already been removed.", w, r)
+	case err != nil:
+		slog.Error("Error removing discount", "subscriptionID", sub.ID, "discountID", discountID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		h.Notify(NotifyError, "Remove Failed", "An error occurred while removing the discount. Please try again.", w, r)
+		return
+	default:
+		h.Notify(NotifySuccess, "Discount Removed", "The discount is no longer taken off what the subscription is billed.", w, r)
+		al.LogSubscriptionDiscountRemoved(r.Context(), h.Queries, sub, discounts.Describe(discount))
+		h.publish(r, hub.Event{CustomerID: sub.CustomerID})
+	}
+	h.renderSubscriptionDiscounts(w, r, sub)
+}
+
+// renderSubscriptionDiscounts renders the discounts given on a subscription along with the coupons it can redeem.
+func (h *Handlers) renderSubscriptionDiscounts(w http.ResponseWriter, r *http.Request, sub db.Subscription) {
+	given, err := h.Queries.ListSubscriptionDiscounts(r.Context(), sub.ID)
+	if err != nil {
+		slog.Error("Failed to list subscription discounts", "subscriptionID", sub.ID, "err", err)
+	}
+	coupons, err := h.Queries.ListCoupons(r.Context())
+	if err != nil {
+		slog.Error("Failed to load coupons", "err", err)
+	}
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.SubscriptionDiscounts(sub.CustomerID.String(), sub, given, coupons, h.Clock())},
+	})
+}
+
+// activeDiscounts loads the discounts of every subscription that have not been removed, logging any failure so that
+// subscriptions are shown without their discounts rather than not at all.
+func (h *Handlers) activeDiscounts(ctx context.Context) []db.SubscriptionDiscount {
+	given, err := h.Queries.ListActiveSubscriptionDiscounts(ctx)
+	if err != nil {
+		slog.Error("Failed to load subscription discounts", "err", err)
+	}
+	return given
+}
+
+// AddCouponSSE adds a coupon from the settings page
+func (h *Handlers) AddCouponSSE(w http.ResponseWriter, r *http.Request) {
+	terms, err := discounts.ParseTerms(r.FormValue("kind"), r.FormValue("value"), r.FormValue("currency"), r.FormValue("periods"))
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Coupon", err.Error(), w, r)
+		return
+	}
+	params, err := discounts.CouponParams(r.FormValue("code"), r.FormValue("description"), terms)
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Coupon", err.Error(), w, r)
+		return
+	}
+
+	coupon, err := h.Queries.CreateCoupon(r.Context(), params)
+	if err != nil {
+		slog.Error("Error adding coupon", "code", params.Code, "err", err)
+		h.Notify(NotifyError, "Add Failed", fmt.Sprintf("The coupon could not be added. Is %s already in use?", params.Code), w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Coupon Added", fmt.Sprintf("%s can now be redeemed for %s.", coupon.Code, discounts.FromCoupon(coupon).Label()), w, r)
+	h.renderCoupons(w, r)
+}
+
+// SetCouponStatusSSE archives a coupon that can no longer be redeemed, or restores an archived one
+func (h *Handlers) SetCouponStatusSSE(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		slog.Error("Invalid coupon ID", "err", err)
+		h.Notify(NotifyError, "Invalid Coupon ID", "The coupon ID provided is not valid.", w, r)
+		return
+	}
+	status := chi.URLParam(r, "status")
+	if err := discounts.CheckStatus(status); err != nil {
+		h.Notify(NotifyError, "Invalid Status", err.Error(), w, r)
+		return
+	}
+
+	coupon, err := h.Queries.SetCouponStatus(r.Context(), db.SetCouponStatusParams{Status: status, ID: id})
+	if err != nil {
+		slog.Error("Error changing coupon status", "coupon_id", id, "err", err)
+		h.Notify(NotifyError, "Update Failed", "An error occurred while changing the coupon status.", w, r)
+		return
+	}
+
+	if status == discounts.Archived {
+		h.Notify(NotifySuccess, "Coupon Archived", fmt.Sprintf("%s can no longer be redeemed. Discounts already given are kept.", coupon.Code), w, r)
+	} else {
+		h.Notify(NotifySuccess, "Coupon Restored", fmt.Sprintf("%s can be redeemed again.", coupon.Code), w, r)
+	}
+	h.renderCoupons(w, r)
+}
+
+// renderCoupons renders the coupons on the settings page.
+func (h *Handlers) renderCoupons(w http.ResponseWriter, r *http.Request) {
+	coupons, err := h.Queries.ListCoupons(r.Context())
+	if err != nil {
+		slog.Error("Failed to load coupons", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the coupons.", w, r)
+	}
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.CouponSettings(coupons)},
+	})
+}
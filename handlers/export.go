@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/export"
+	"github.com/scottmckendry/beam/filters"
+)
+
+// RegisterExportRoutes registers the CSV and JSON Lines download routes on the given router.
+func (h *Handlers) RegisterExportRoutes(r chi.Router) {
+	r.Get("/export/customers.{format}", h.exportRecords("customers", export.Customers))
+	r.Get("/export/contacts.{format}", h.exportRecords("contacts", export.Contacts))
+	r.Get("/export/subscriptions.{format}", h.exportRecords("subscriptions", export.Subscriptions))
+	r.Get("/export/activity.{format}", h.ExportActivity)
+}
+
+// exportRecords returns a handler that streams the output of write as a dated download. The customer filter is read
+// from the tag, status, from and to query parameters, and deleted=1 includes soft-deleted records.
+func (h *Handlers) exportRecords(name string, write func(context.Context, *db.Queries, export.Options, io.Writer) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		opts := export.Options{
+			Format: chi.URLParam(r, "format"),
+			Filter: filters.Filter{
+				Tag:    query.Get("tag"),
+				Status: query.Get("status"),
+				From:   query.Get("from"),
+				To:     query.Get("to"),
+			},
+			IncludeDeleted: query.Get("deleted") == "1",
+		}
+		if err := opts.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.download(w, name, opts.Format)
+		if err := write(r.Context(), h.Queries, opts, w); err != nil {
+			// rows are streamed as they are read, so the download is cut short rather than replaced with an error
+			slog.Error("Export failed", "export", name, "err", err)
+		}
+	}
+}
+
+// ExportActivity streams every activity entry matching the filters in the query string as a download
+func (h *Handlers) ExportActivity(w http.ResponseWriter, r *http.Request) {
+	format := chi.URLParam(r, "format")
+	if !slices.Contains(export.Formats, format) {
+		http.Error(w, fmt.Sprintf("invalid format %q", format), http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	filter := al.Filter{
+		Customer: query.Get("customer"),
+		Type:     query.Get("type"),
+		Action:   query.Get("action"),
+		User:     query.Get("user"),
+		From:     query.Get("from"),
+		To:       query.Get("to"),
+	}
+	if _, err := filter.Params(al.Cursor{}, al.PageSize); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	write := al.ExportCSV
+	if format == export.FormatJSONL {
+		write = al.ExportJSONL
+	}
+	h.download(w, "activity", format)
+	if err := write(r.Context(), h.Queries, filter, w); err != nil {
+		slog.Error("Failed to export activity", "err", err)
+	}
+}
+
+// download sets the headers for a dated export file.
+func (h *Handlers) download(w http.ResponseWriter, name, format string) {
+	filename := fmt.Sprintf("beam-%s-%s.%s", name, time.Now().Format("2006-01-02"), format)
+	w.Header().Set("Content-Type", export.ContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/starfederation/datastar-go/datastar"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/currency"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/filters"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// bulkSignals holds the bulk action chosen on a saved filter report.
+type bulkSignals struct {
+	Bulk struct {
+		Action string `json:"action"`
+		Tag    string `json:"tag"`
+		Status string `json:"status"`
+	} `json:"bulk"`
+}
+
+// RegisterFilterRoutes registers the saved filter report and bulk action routes on the given router.
+func (h *Handlers) RegisterFilterRoutes(r chi.Router) {
+	r.Get("/sse/filters/{id}", h.FilterReportSSE)
+	r.Get("/sse/filters/{id}/bulk", h.FilterBulkActionSSE)
+}
+
+// FilterReportSSE renders a report of the customers matching a saved filter via SSE
+func (h *Handlers) FilterReportSSE(w http.ResponseWriter, r *http.Request) {
+	saved, ok := h.getSavedFilterByID(w, r)
+	if !ok {
+		return
+	}
+	h.trackView(r, hub.View{Page: hub.PageReport})
+	h.renderFilterReport(w, r, saved)
+}
+
+// FilterBulkActionSSE adds a tag to, removes a tag from, or sets the status of every customer matching a saved filter.
+// Each customer that changes gets its own activity entry naming the filter used.
+func (h *Handlers) FilterBulkActionSSE(w http.ResponseWriter, r *http.Request) {
+	saved, ok := h.getSavedFilterByID(w, r)
+	if !ok {
+		return
+	}
+
+	var signals bulkSignals
+	if err := datastar.ReadSignals(r, &signals); err != nil {
+		slog.Error("Error reading bulk action signals", "err", err)
+		h.Notify(NotifyError, "Bulk Action Error", "An error occurred while reading the bulk action.", w, r)
+		return
+	}
+
+	customers, err := filters.Customers(r.Context(), h.Queries, filters.FromSaved(saved))
+	if err != nil {
+		slog.Error("Failed to load customers for bulk action", "saved_filter_id", saved.ID, "err", err)
+		h.Notify(NotifyError, "Bulk Action Error", "An error occurred while loading the customers in this filter.", w, r)
+		return
+	}
+
+	var changed []uuid.UUID
+	switch signals.Bulk.Action {
+	case views.BulkAddTag, views.BulkRemoveTag:
+		changed, err = h.bulkTag(r, saved, customers, signals.Bulk.Tag, signals.Bulk.Action == views.BulkAddTag)
+	case views.BulkSetStatus:
+		changed, err = h.bulkSetStatus(r, saved, customers, signals.Bulk.Status)
+	default:
+		err = fmt.Errorf("unknown action %q", signals.Bulk.Action)
+	}
+	if err != nil {
+		slog.Error("Bulk action failed", "saved_filter_id", saved.ID, "action", signals.Bulk.Action, "err", err)
+		h.Notify(NotifyError, "Bulk Action Failed", fmt.Sprintf("%d customers were changed before an error occurred: %v", len(changed), err), w, r)
+	} else {
+		h.Notify(NotifySuccess, "Bulk Action Applied", fmt.Sprintf("%d %s changed.", len(changed), utils.Pluralise(int64(len(changed)), "customer", "customers")), w, r)
+	}
+
+	// one event per customer refreshes anyone viewing it, and the last also refreshes navigation
+	for i, id := range changed {
+		h.publish(r, hub.Event{CustomerID: id, Navigation: i == len(changed)-1})
+	}
+
+	h.renderFilterReport(w, r, saved)
+	h.renderCustomerNavigation(w, r)
+}
+
+// bulkTag adds the tag to, or removes it from, each customer that does not already have or lack it.
+func (h *Handlers) bulkTag(r *http.Request, saved db.SavedFilter, customers []db.Customer, tagID string, add bool) ([]uuid.UUID, error) {
+	id, err := uuid.Parse(tagID)
+	if err != nil {
+		return nil, fmt.Errorf("choose a tag")
+	}
+	tags, err := h.Queries.ListTags(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(tags, func(t db.Tag) bool { return t.ID == id })
+	if i < 0 {
+		return nil, fmt.Errorf("the tag no longer exists")
+	}
+	tag := tags[i]
+	current, err := filters.TagsByCustomer(r.Context(), h.Queries)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []uuid.UUID
+	for _, c := range customers {
+		has := slices.ContainsFunc(current[c.ID], func(t db.Tag) bool { return t.ID == id })
+		if has == add {
+			continue
+		}
+		if add {
+			err = h.Queries.AddCustomerTag(r.Context(), db.AddCustomerTagParams{CustomerID: c.ID, TagID: id})
+		} else {
+			err = h.Queries.RemoveCustomerTag(r.Context(), db.RemoveCustomerTagParams{CustomerID: c.ID, TagID: id})
+		}
+		if err != nil {
+			return changed, err
+		}
+		if add {
+			al.LogCustomerTagged(r.Context(), h.Queries, c.ID, c.Name, tag.Name, saved.Name)
+		} else {
+			al.LogCustomerUntagged(r.Context(), h.Queries, c.ID, c.Name, tag.Name, saved.Name)
+		}
+		changed = append(changed, c.ID)
+	}
+	return changed, nil
+}
+
+// bulkSetStatus sets the status of each customer that does not already have it.
+func (h *Handlers) bulkSetStatus(r *http.Request, saved db.SavedFilter, customers []db.Customer, status string) ([]uuid.UUID, error) {
+	if !slices.Contains(filters.Statuses, status) {
+		return nil, fmt.Errorf("invalid status %q", status)
+	}
+
+	var changed []uuid.UUID
+	for _, c := range customers {
+		if c.Status == status {
+			continue
+		}
+		updated, err := h.Queries.UpdateCustomerStatus(r.Context(), db.UpdateCustomerStatusParams{Status: status, ID: c.ID})
+		if err != nil {
+			return changed, err
+		}
+		al.LogCustomerStatusChanged(r.Context(), h.Queries, c, updated, saved.Name)
+		changed = append(changed, c.ID)
+	}
+	return changed, nil
+}
+
+// renderFilterReport renders the report for a saved filter along with its page signals.
+func (h *Handlers) renderFilterReport(w http.ResponseWriter, r *http.Request, saved db.SavedFilter) {
+	tags, err := h.Queries.ListTags(r.Context())
+	if err != nil {
+		slog.Error("Failed to load tags", "err", err)
+	}
+	f := filters.FromSaved(saved)
+	conv, err := currency.Load(r.Context(), h.Queries, h.ReportingCurrency)
+	if err != nil {
+		slog.Error("Failed to load exchange rates", "err", err)
+		h.Notify(NotifyError, "Report Error", "An error occurred while loading the exchange rates.", w, r)
+		return
+	}
+	report, err := filters.BuildReport(r.Context(), h.Queries, f, conv, h.Clock())
+	if err != nil {
+		slog.Error("Failed to build filter report", "saved_filter_id", saved.ID, "err", err)
+		h.Notify(NotifyError, "Report Error", "An error occurred while building the report.", w, r)
+		return
+	}
+
+	pageSignals := utils.PageSignals{
+		HeaderTitle:       saved.Name,
+		HeaderDescription: f.Describe(tags),
+		CurrentPage:       "report",
+	}
+	encodedSignals, _ := json.Marshal(pageSignals)
+
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Signals: encodedSignals,
+		Views: []templ.Component{
+			views.FilterReport(saved, tags, report),
+			views.HeaderIcon("report"),
+		},
+	})
+}
+
+// getSavedFilterByID fetches the saved filter named by the id URL param and handles errors consistently
+func (h *Handlers) getSavedFilterByID(w http.ResponseWriter, r *http.Request) (db.SavedFilter, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		slog.Error("Invalid saved filter ID", "err", err)
+		h.Notify(NotifyError, "Invalid Filter ID", "The saved filter ID provided is not valid.", w, r)
+		return db.SavedFilter{}, false
+	}
+	saved, err := h.Queries.GetSavedFilter(r.Context(), id)
+	if err != nil {
+		slog.Error("GetSavedFilter failed", "saved_filter_id", id, "err", err)
+		h.Notify(NotifyError, "Filter Not Found", "No saved filter found for the provided ID.", w, r)
+		return db.SavedFilter{}, false
+	}
+	return saved, true
+}
@@ -2,16 +2,34 @@
 package handlers
 
 import (
+	"database/sql"
+	"time"
+
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/currency"
 	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/hub"
 	"github.com/scottmckendry/beam/oauth"
 )
 
 type Handlers struct {
+	Store   *sql.DB
 	Queries *db.Queries
 	OAuth   *oauth.OAuth
+	Hub     *hub.Hub
+	// ReportingCurrency is the currency totals across customers and subscriptions are converted into
+	ReportingCurrency string
+	// Clock tells the time that billing dates are worked out from
+	Clock billing.Clock
+
+	// navFilters holds the customer navigation filter chosen by each live update stream
+	navFilters *navFilterStore
+	// imports holds uploaded import files between previewing and committing them
+	imports *importFileStore
 }
 
-// New creates a new Handlers instance with the provided database queries and OAuth environment.
-func New(queries *db.Queries, env *oauth.OAuth) *Handlers {
-	return &Handlers{Queries: queries, OAuth: env}
+// New creates a new Handlers instance with the provided database, queries and OAuth environment. The store is needed
+// for changes that must be made in a single transaction.
+func New(store *sql.DB, queries *db.Queries, env *oauth.OAuth) *Handlers {
+	return &Handlers{Store: store, Queries: queries, OAuth: env, Hub: hub.New(), ReportingCurrency: currency.Default, Clock: time.Now, navFilters: newNavFilterStore(), imports: newImportFileStore()}
 }
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/starfederation/datastar-go/datastar"
+
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	"github.com/scottmckendry/beam/importer"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// importFileTTL is how long an uploaded file is kept for previewing and committing.
+const importFileTTL = time.Hour
+
+// importSignals holds the file, record type and column mapping chosen on the import page. The file is only sent when
+// first chosen, after which it is referred to by token.
+type importSignals struct {
+	ImportEntity    string            `json:"importEntity"`
+	ImportFile      []string          `json:"importFile"`
+	ImportFileNames []string          `json:"importFileNames"`
+	ImportToken     string            `json:"importToken"`
+	ImportMapping   map[string]string `json:"importMapping"`
+}
+
+// importFileStore holds uploaded import files between previews and the commit, so large files are not sent with
+// every change to the column mapping.
+type importFileStore struct {
+	mu    sync.Mutex
+	files map[string]storedImportFile
+}
+
+type storedImportFile struct {
+	file    importer.File
+	expires time.Time
+}
+
+func newImportFileStore() *importFileStore {
+	return &importFileStore{files: make(map[string]storedImportFile)}
+}
+
+// add stores a file and returns its token, dropping any files that have expired.
+func (s *importFileStore) add(f importer.File) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for token, stored := range s.files {
+		if now.After(stored.expires) {
+			delete(s.files, token)
+		}
+	}
+	token := uuid.NewString()
+	s.files[token] = storedImportFile{file: f, expires: now.Add(importFileTTL)}
+	return token
+}
+
+func (s *importFileStore) get(token string) (importer.File, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.files[token]
+	if !ok || time.Now().After(stored.expires) {
+		return importer.File{}, false
+	}
+	return stored.file, true
+}
+
+func (s *importFileStore) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, token)
+}
+
+// RegisterImportRoutes registers the CSV import routes on the given router.
+func (h *Handlers) RegisterImportRoutes(r chi.Router) {
+	r.Get("/sse/import", h.ImportSSE)
+	r.Post("/sse/import/preview", h.ImportPreviewSSE)
+	r.Post("/sse/import/commit", h.ImportCommitSSE)
+}
+
+// ImportSSE renders the import page via SSE
+func (h *Handlers) ImportSSE(w http.ResponseWriter, r *http.Request) {
+	h.trackView(r, hub.View{Page: hub.PageImport})
+	pageSignals := utils.PageSignals{
+		HeaderTitle:       "Import",
+		HeaderDescription: "Add customers and contacts from a CSV file",
+		CurrentPage:       "settings",
+	}
+	encodedSignals, _ := json.Marshal(pageSignals)
+
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Signals: encodedSignals,
+		Views: []templ.Component{
+			views.Import(),
+			views.HeaderIcon("settings"),
+		},
+	})
+}
+
+// ImportPreviewSSE validates the chosen file against the column mapping and renders a preview of every row. The
+// mapping is guessed from the column headings when a new file is chosen or the record type changes.
+func (h *Handlers) ImportPreviewSSE(w http.ResponseWriter, r *http.Request) {
+	signals, file, uploaded, ok := h.readImport(w, r)
+	if !ok {
+		return
+	}
+
+	mapping := importMapping(signals.ImportMapping)
+	guess := uploaded || r.URL.Query().Has("guess")
+	if guess {
+		fields, err := h.Queries.ListCustomFields(r.Context(), signals.ImportEntity)
+		if err != nil {
+			slog.Error("Failed to load custom fields for import", "err", err)
+		}
+		mapping = importer.Guess(file.Headers, importer.Targets(signals.ImportEntity, fields))
+	}
+
+	preview, err := importer.Build(r.Context(), h.Queries, signals.ImportEntity, file, mapping)
+	if err != nil && preview.Targets == nil {
+		slog.Error("Failed to build import preview", "err", err)
+		h.Notify(NotifyError, "Import Error", "An error occurred while checking the file.", w, r)
+		return
+	}
+
+	var patch []byte
+	if guess {
+		patch = importMappingSignals(signals.ImportToken, file, mapping)
+	}
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Signals: patch,
+		Views: []templ.Component{
+			views.ImportPreview(preview, err),
+		},
+	})
+}
+
+// ImportCommitSSE creates a record for every valid row of the chosen file in a single transaction. Rows with errors
+// and duplicates are skipped.
+func (h *Handlers) ImportCommitSSE(w http.ResponseWriter, r *http.Request) {
+	signals, file, _, ok := h.readImport(w, r)
+	if !ok {
+		return
+	}
+
+	preview, err := importer.Build(r.Context(), h.Queries, signals.ImportEntity, file, importMapping(signals.ImportMapping))
+	if err != nil {
+		h.Notify(NotifyError, "Import Not Ready", "Fix the column mapping before importing.", w, r)
+		return
+	}
+	valid, invalid, duplicates := preview.Counts()
+	if valid == 0 {
+		h.Notify(NotifyError, "Nothing To Import", "None of the rows in this file can be imported.", w, r)
+		return
+	}
+
+	result, err := importer.Commit(r.Context(), h.Store, h.Queries, preview)
+	if err != nil {
+		slog.Error("Import failed", "file", file.Name, "err", err)
+		h.Notify(NotifyError, "Import Failed", fmt.Sprintf("Nothing was imported: %v", err), w, r)
+		return
+	}
+	h.imports.remove(signals.ImportToken)
+
+	noun := utils.Pluralise(int64(result.Created), signals.ImportEntity, signals.ImportEntity+"s")
+	h.Notify(NotifySuccess, "Import Complete", fmt.Sprintf("%d %s imported from %s.", result.Created, noun, file.Name), w, r)
+	for i, id := range result.CustomerIDs {
+		h.publish(r, hub.Event{CustomerID: id, Navigation: i == len(result.CustomerIDs)-1})
+	}
+
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Signals: []byte(`{"importToken": "", "importFile": [], "importFileNames": []}`),
+		Views: []templ.Component{
+			views.ImportComplete(file.Name, noun, result.Created, invalid+duplicates),
+		},
+	})
+	h.renderCustomerNavigation(w, r)
+}
+
+// readImport reads the import signals along with the chosen file. A newly chosen file is parsed and stored, and
+// uploaded is set. On failure the error is shown and ok is false.
+func (h *Handlers) readImport(w http.ResponseWriter, r *http.Request) (signals importSignals, file importer.File, uploaded, ok bool) {
+	// base64 encoding makes the file a third larger, with some room left for the other signals
+	r.Body = http.MaxBytesReader(w, r.Body, importer.MaxFileSize*4/3+1<<20)
+	if err := datastar.ReadSignals(r, &signals); err != nil {
+		slog.Error("Error reading import signals", "err", err)
+		h.Notify(NotifyError, "Import Error", fmt.Sprintf("The file could not be read. Files must be smaller than %d MB.", importer.MaxFileSize>>20), w, r)
+		return signals, file, false, false
+	}
+
+	if len(signals.ImportFile) > 0 && signals.ImportFile[0] != "" {
+		data, err := utils.DecodeBase64Image(signals.ImportFile[0])
+		if err != nil {
+			slog.Error("Error decoding import file", "err", err)
+			h.Notify(NotifyError, "Import Error", "An error occurred while decoding the file.", w, r)
+			return signals, file, false, false
+		}
+		name := "upload.csv"
+		if len(signals.ImportFileNames) > 0 && signals.ImportFileNames[0] != "" {
+			name = signals.ImportFileNames[0]
+		}
+		file, err = importer.Read(name, data)
+		if err != nil {
+			h.Notify(NotifyError, "Invalid File", fmt.Sprintf("%s could not be imported: %v.", name, err), w, r)
+			return signals, file, false, false
+		}
+		signals.ImportToken = h.imports.add(file)
+		return signals, file, true, true
+	}
+
+	if signals.ImportToken == "" {
+		h.Notify(NotifyError, "No File Chosen", "Choose a CSV file to import.", w, r)
+		return signals, file, false, false
+	}
+	file, found := h.imports.get(signals.ImportToken)
+	if !found {
+		h.Notify(NotifyError, "Upload Expired", "Choose the file again to continue the import.", w, r)
+		return signals, file, false, false
+	}
+	return signals, file, false, true
+}
+
+// importMapping converts the mapping signals, keyed by column as c0, c1 and so on, to a mapping.
+func importMapping(signals map[string]string) importer.Mapping {
+	mapping := make(importer.Mapping)
+	for key, target := range signals {
+		if col, err := strconv.Atoi(strings.TrimPrefix(key, "c")); err == nil && strings.HasPrefix(key, "c") {
+			mapping[col] = target
+		}
+	}
+	return mapping
+}
+
+// importMappingSignals returns the signals for a guessed mapping, setting every column of the file so that choices
+// made for a previous file are replaced. The file itself is cleared in favour of its token.
+func importMappingSignals(token string, file importer.File, mapping importer.Mapping) []byte {
+	columns := make(map[string]string, len(file.Headers))
+	for i := range file.Headers {
+		columns[views.ImportColumnKey(i)] = mapping[i]
+	}
+	encoded, _ := json.Marshal(map[string]any{
+		"importToken":   token,
+		"importFile":    []string{},
+		"importMapping": columns,
+	})
+	return encoded
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/a-h/templ"
 	"github.com/go-chi/chi/v5"
 
+	"github.com/scottmckendry/beam/hub"
 	"github.com/scottmckendry/beam/ui/views"
 )
 
@@ -17,6 +18,7 @@ func (h *Handlers) RegisterInvoiceRoutes(r chi.Router) {
 }
 
 func (h *Handlers) InvoicesSSE(w http.ResponseWriter, r *http.Request) {
+	h.trackView(r, hub.View{Page: hub.PageInvoices})
 	pageSignals := utils.PageSignals{
 		HeaderTitle:       "Invoices",
 		HeaderDescription: "Manage invoices for all customers",
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/currency"
+	db "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	"github.com/scottmckendry/beam/margins"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// RegisterMarginRoutes registers the margin report routes on the given router.
+func (h *Handlers) RegisterMarginRoutes(r chi.Router) {
+	r.Get("/sse/margins", h.MarginsSSE)
+}
+
+// MarginsSSE renders the margin report of every customer.
+func (h *Handlers) MarginsSSE(w http.ResponseWriter, r *http.Request) {
+	report, err := h.marginReport(r.Context(), uuid.Nil)
+	if err != nil {
+		slog.Error("Failed to build margin report", "err", err)
+		h.Notify(NotifyError, "Report Error", "An error occurred while building the margin report.", w, r)
+		return
+	}
+	h.trackView(r, hub.View{Page: hub.PageMargins})
+
+	pageSignals := utils.PageSignals{
+		HeaderTitle:       "Margins",
+		HeaderDescription: "What resold subscriptions make over their vendor costs",
+		CurrentPage:       "margins",
+	}
+	encodedSignals, _ := json.Marshal(pageSignals)
+
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Signals: encodedSignals,
+		Views: []templ.Component{
+			views.Margins(report),
+			views.HeaderIcon("margins"),
+		},
+	})
+}
+
+// marginReport reports the margins of the active subscriptions of a customer, or of every customer when customerID
+// is uuid.Nil, in the reporting currency.
+func (h *Handlers) marginReport(ctx context.Context, customerID uuid.UUID) (margins.Report, error) {
+	conv, err := currency.Load(ctx, h.Queries, h.ReportingCurrency)
+	if err != nil {
+		return margins.Report{}, err
+	}
+	customers, err := h.Queries.ListCustomers(ctx)
+	if err != nil {
+		return margins.Report{}, fmt.Errorf("error loading customers: %w", err)
+	}
+	if customerID != uuid.Nil {
+		var only []db.Customer
+		for _, c := range customers {
+			if c.ID == customerID {
+				only = append(only, c)
+			}
+		}
+		customers = only
+	}
+	subscriptions, err := h.Queries.ListActiveSubscriptions(ctx)
+	if err != nil {
+		return margins.Report{}, fmt.Errorf("error loading active subscriptions: %w", err)
+	}
+	given, err := h.Queries.ListActiveSubscriptionDiscounts(ctx)
+	if err != nil {
+		return margins.Report{}, fmt.Errorf("error loading subscription discounts: %w", err)
+	}
+	return margins.Build(customers, subscriptions, given, conv, h.Clock()), nil
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/starfederation/datastar-go/datastar"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/filters"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// navSignals holds the customer navigation filter, which every datastar request carries as the nav signal.
+type navSignals struct {
+	Nav filters.Filter `json:"nav"`
+}
+
+// navFilterStore remembers the navigation filter chosen by each live update stream, so navigation refreshes made
+// by form submissions and live updates (neither of which carry signals) keep the session's filter.
+type navFilterStore struct {
+	mu      sync.Mutex
+	filters map[string]filters.Filter
+}
+
+func newNavFilterStore() *navFilterStore {
+	return &navFilterStore{filters: make(map[string]filters.Filter)}
+}
+
+// get returns the filter chosen by a stream, or the zero filter if it has not chosen one.
+func (s *navFilterStore) get(id string) filters.Filter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.filters[id]
+}
+
+// set records the filter chosen by a stream. Streams without an ID and zero filters are not stored.
+func (s *navFilterStore) set(id string, f filters.Filter) {
+	if id == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f.IsZero() {
+		delete(s.filters, id)
+		return
+	}
+	s.filters[id] = f
+}
+
+// remove forgets the filter chosen by a stream.
+func (s *navFilterStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.filters, id)
+}
+
+// RegisterNavigationRoutes registers the customer navigation filter routes on the given router.
+func (h *Handlers) RegisterNavigationRoutes(r chi.Router) {
+	r.Get("/sse/customer/nav", h.CustomerNavSSE)
+	r.Get("/sse/customer/nav/saved", h.CustomerNavSavedSSE)
+	r.Get("/sse/customer/nav/filters", h.CustomerNavFiltersSSE)
+}
+
+// CustomerNavFiltersSSE renders the navigation filter controls along with the navigation matching the session's filter
+func (h *Handlers) CustomerNavFiltersSSE(w http.ResponseWriter, r *http.Request) {
+	h.renderCustomerNavigation(w, r)
+}
+
+// CustomerNavSSE applies the tag, status and sort chosen in the navigation filter controls
+func (h *Handlers) CustomerNavSSE(w http.ResponseWriter, r *http.Request) {
+	var signals navSignals
+	if err := datastar.ReadSignals(r, &signals); err != nil {
+		slog.Error("Error reading navigation signals", "err", err)
+		h.Notify(NotifyError, "Filter Error", "An error occurred while reading the navigation filter.", w, r)
+		return
+	}
+	if err := signals.Nav.Validate(); err != nil {
+		slog.Error("Invalid navigation filter", "err", err)
+		h.Notify(NotifyError, "Filter Error", "The navigation filter is not valid.", w, r)
+		return
+	}
+
+	h.navFilters.set(streamID(r), signals.Nav)
+	h.renderCustomerNavigation(w, r)
+}
+
+// CustomerNavSavedSSE loads the conditions of the chosen saved filter into the navigation, or clears them
+func (h *Handlers) CustomerNavSavedSSE(w http.ResponseWriter, r *http.Request) {
+	var signals navSignals
+	if err := datastar.ReadSignals(r, &signals); err != nil {
+		slog.Error("Error reading navigation signals", "err", err)
+		h.Notify(NotifyError, "Filter Error", "An error occurred while reading the navigation filter.", w, r)
+		return
+	}
+
+	var f filters.Filter
+	if signals.Nav.Saved != "" {
+		id, err := uuid.Parse(signals.Nav.Saved)
+		if err != nil {
+			slog.Error("Invalid saved filter ID", "err", err)
+			h.Notify(NotifyError, "Filter Error", "The saved filter is not valid.", w, r)
+			return
+		}
+		saved, err := h.Queries.GetSavedFilter(r.Context(), id)
+		if err != nil {
+			slog.Error("GetSavedFilter failed", "saved_filter_id", id, "err", err)
+			h.Notify(NotifyError, "Filter Not Found", "The saved filter could not be found.", w, r)
+			return
+		}
+		f = filters.FromSaved(saved)
+	}
+
+	h.navFilters.set(streamID(r), f)
+	h.renderCustomerNavigation(w, r)
+}
+
+// renderCustomerNavigation renders the filter controls and navigation for the session's current filter.
+func (h *Handlers) renderCustomerNavigation(w http.ResponseWriter, r *http.Request) {
+	customers, err := h.navigationCustomers(r.Context(), streamID(r))
+	if err != nil {
+		slog.Error("Failed to load customers for navigation", "err", err)
+		h.Notify(NotifyError, "Navigation Error", "An error occurred while loading the customer navigation.", w, r)
+		return
+	}
+	filterControls, err := h.customerNavFilters(r.Context(), h.navFilters.get(streamID(r)))
+	if err != nil {
+		slog.Error("Failed to load navigation filters", "err", err)
+		h.Notify(NotifyError, "Navigation Error", "An error occurred while loading the navigation filters.", w, r)
+		return
+	}
+
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{filterControls, views.CustomerNavigation(customers)},
+	})
+}
+
+// customerNavFilters renders the navigation filter controls with the given filter selected.
+func (h *Handlers) customerNavFilters(ctx context.Context, f filters.Filter) (templ.Component, error) {
+	tags, err := h.Queries.ListTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := h.Queries.ListSavedFilters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return views.CustomerNavFilters(tags, saved, f), nil
+}
+
+// navigationCustomers lists the customers shown in a stream's navigation. A filter that can no longer be applied is
+// dropped and every customer is listed instead.
+func (h *Handlers) navigationCustomers(ctx context.Context, id string) ([]db.Customer, error) {
+	f := h.navFilters.get(id)
+	if f.IsZero() {
+		return h.Queries.ListCustomers(ctx)
+	}
+	customers, err := filters.Customers(ctx, h.Queries, f)
+	if err != nil {
+		slog.Warn("Dropping navigation filter that can no longer be applied", "stream", id, "err", err)
+		h.navFilters.remove(id)
+		return h.Queries.ListCustomers(ctx)
+	}
+	return customers, nil
+}
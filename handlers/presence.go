@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	middlewares "github.com/scottmckendry/beam/middleware"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// customerPresence renders who, other than the requesting user, is viewing or editing a customer
+func (h *Handlers) customerPresence(r *http.Request, customerID uuid.UUID) templ.Component {
+	user, _ := r.Context().Value(middlewares.UserKey).(string)
+	return views.CustomerPresence(h.Hub.Viewers(customerID, user))
+}
+
+// openEditForm records that the requesting session is opening an edit form and takes the record's advisory edit lock.
+// If another user already holds the lock a warning is rendered instead and false is returned; the warning offers to
+// open the form anyway by repeating the request at openURL with force set, which skips the check.
+func (h *Handlers) openEditForm(w http.ResponseWriter, r *http.Request, record, noun, openURL string, view hub.View) bool {
+	user, _ := r.Context().Value(middlewares.UserKey).(string)
+	if r.URL.Query().Get("force") != "true" {
+		if held, ok := h.Hub.LockHolder(record); ok && held.User != user {
+			utils.RenderSSE(w, r, utils.SSEOpts{
+				Signals: []byte(`{"_showEditLockWarning": true}`),
+				Views:   []templ.Component{views.EditLockWarning(noun, held, openURL+"?force=true")},
+			})
+			return false
+		}
+	}
+
+	h.trackView(r, view)
+	h.Hub.Acquire(record, streamID(r), user)
+	return true
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/catalogue"
+	"github.com/scottmckendry/beam/currency"
+	db "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/money"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// AddProductSSE adds a product to the catalogue from the settings page
+func (h *Handlers) AddProductSSE(w http.ResponseWriter, r *http.Request) {
+	params, err := catalogue.ProductParams(r.FormValue("sku"), r.FormValue("name"))
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Product", err.Error(), w, r)
+		return
+	}
+
+	product, err := h.Queries.CreateProduct(r.Context(), params)
+	if err != nil {
+		slog.Error("Error adding product", "sku", params.Sku, "err", err)
+		h.Notify(NotifyError, "Add Failed", fmt.Sprintf("The product could not be added. Is %s already in use?", params.Sku), w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Product Added", fmt.Sprintf("%s (%s) can now be sold. Set its prices next.", product.Name, product.Sku), w, r)
+	h.renderProducts(w, r)
+}
+
+// SetProductStatusSSE archives a product that is no longer sold, or restores an archived one
+func (h *Handlers) SetProductStatusSSE(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		slog.Error("Invalid product ID", "err", err)
+		h.Notify(NotifyError, "Invalid Product ID", "The product ID provided is not valid.", w, r)
+		return
+	}
+	status := chi.URLParam(r, "status")
+	if err := catalogue.CheckStatus(status); err != nil {
+		h.Notify(NotifyError, "Invalid Status", err.Error(), w, r)
+		return
+	}
+
+	product, err := h.Queries.SetProductStatus(r.Context(), db.SetProductStatusParams{Status: status, ID: id})
+	if err != nil {
+		slog.Error("Error changing product status", "product_id", id, "err", err)
+		h.Notify(NotifyError, "Update Failed", "An error occurred while changing the product status.", w, r)
+		return
+	}
+
+	if status == catalogue.Archived {
+		h.Notify(NotifySuccess, "Product Archived", fmt.Sprintf("%s can no longer be sold. Existing subscriptions keep it.", product.Name), w, r)
+	} else {
+		h.Notify(NotifySuccess, "Product Restored", fmt.Sprintf("%s can be sold again.", product.Name), w, r)
+	}
+	h.renderProducts(w, r)
+}
+
+// SetProductPriceSSE sets the default price of a product for a cadence and currency, replacing any it already had
+func (h *Handlers) SetProductPriceSSE(w http.ResponseWriter, r *http.Request) {
+	productID, err := uuid.Parse(r.FormValue("product"))
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Product", "Choose the product to price.", w, r)
+		return
+	}
+	price, err := catalogue.ParsePrice(r.FormValue("cadence"), r.FormValue("currency"), r.FormValue("unitprice"))
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Price", err.Error(), w, r)
+		return
+	}
+
+	_, err = h.Queries.SetProductPrice(r.Context(), db.SetProductPriceParams{
+		ProductID:      productID,
+		BillingCadence: price.Cadence,
+		Currency:       price.Currency,
+		UnitPrice:      price.UnitPrice,
+	})
+	if err != nil {
+		slog.Error("Error setting product price", "product_id", productID, "err", err)
+		h.Notify(NotifyError, "Update Failed", "An error occurred while setting the price.", w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Price Set", fmt.Sprintf("The %s price is now %s.", billing.Label(price.Cadence), money.New(price.UnitPrice, price.Currency)), w, r)
+	h.renderProducts(w, r)
+}
+
+// DeleteProductPriceSSE deletes a default price of a product
+func (h *Handlers) DeleteProductPriceSSE(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		slog.Error("Invalid product price ID", "err", err)
+		h.Notify(NotifyError, "Invalid Price ID", "The price ID provided is not valid.", w, r)
+		return
+	}
+
+	if _, err := h.Queries.DeleteProductPrice(r.Context(), id); err != nil {
+		slog.Error("Error deleting product price", "product_price_id", id, "err", err)
+		h.Notify(NotifyError, "Delete Failed", "An error occurred while deleting the price.", w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Price Deleted", "The price has been deleted. Customers with their own price keep it.", w, r)
+	h.renderProducts(w, r)
+}
+
+// renderProducts renders the product catalogue.
+func (h *Handlers) renderProducts(w http.ResponseWriter, r *http.Request) {
+	products, prices := h.productCatalogue(w, r)
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.ProductSettings(products, prices)},
+	})
+}
+
+// productCatalogue loads every product along with its default prices.
+func (h *Handlers) productCatalogue(w http.ResponseWriter, r *http.Request) ([]db.Product, []db.ProductPrice) {
+	products, err := h.Queries.ListProducts(r.Context())
+	if err != nil {
+		slog.Error("Failed to load products", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the products.", w, r)
+	}
+	prices, err := h.Queries.ListProductPrices(r.Context())
+	if err != nil {
+		slog.Error("Failed to load product prices", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the product prices.", w, r)
+	}
+	return products, prices
+}
+
+// SetCustomerProductPriceSSE sets the price of a product agreed with a customer, replacing any they already had for
+// the same cadence and currency
+func (h *Handlers) SetCustomerProductPriceSSE(w http.ResponseWriter, r *http.Request) {
+	customer, ok := h.getCustomerByID(w, r, "customerID")
+	if !ok {
+		return
+	}
+	productID, err := uuid.Parse(r.FormValue("product"))
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Product", "Choose the product to price.", w, r)
+		return
+	}
+	price, err := catalogue.ParsePrice(r.FormValue("cadence"), r.FormValue("currency"), r.FormValue("unitprice"))
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Price", err.Error(), w, r)
+		return
+	}
+
+	_, err = h.Queries.SetCustomerProductPrice(r.Context(), db.SetCustomerProductPriceParams{
+		CustomerID:     customer.ID,
+		ProductID:      productID,
+		BillingCadence: price.Cadence,
+		Currency:       price.Currency,
+		UnitPrice:      price.UnitPrice,
+	})
+	if err != nil {
+		slog.Error("Error setting customer price", "customerID", customer.ID, "product_id", productID, "err", err)
+		h.Notify(NotifyError, "Update Failed", "An error occurred while setting the price.", w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Price Set", fmt.Sprintf("The %s price for %s is now %s.", billing.Label(price.Cadence), customer.Name, money.New(price.UnitPrice, price.Currency)), w, r)
+	h.renderCustomerProductPrices(w, r, customer.ID, customer.Currency)
+}
+
+// DeleteCustomerProductPriceSSE deletes a price agreed with a customer, so that they pay the default price again
+func (h *Handlers) DeleteCustomerProductPriceSSE(w http.ResponseWriter, r *http.Request) {
+	customer, ok := h.getCustomerByID(w, r, "customerID")
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "priceID"))
+	if err != nil {
+		slog.Error("Invalid customer price ID", "err", err)
+		h.Notify(NotifyError, "Invalid Price ID", "The price ID provided is not valid.", w, r)
+		return
+	}
+
+	_, err = h.Queries.DeleteCustomerProductPrice(r.Context(), db.DeleteCustomerProductPriceParams{ID: id, CustomerID: customer.ID})
+	if err != nil {
+		slog.Error("Error deleting customer price", "customerID", customer.ID, "price_id", id, "err", err)
+		h.Notify(NotifyError, "Delete Failed", "An error occurred while deleting the price.", w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Price Deleted", fmt.Sprintf("%s pays the default price again.", customer.Name), w, r)
+	h.renderCustomerProductPrices(w, r, customer.ID, customer.Currency)
+}
+
+// renderCustomerProductPrices renders the prices agreed with a customer.
+func (h *Handlers) renderCustomerProductPrices(w http.ResponseWriter, r *http.Request, customerID uuid.UUID, customerCurrency string) {
+	products, err := h.Queries.ListProducts(r.Context())
+	if err != nil {
+		slog.Error("Failed to load products", "err", err)
+	}
+	overrides, err := h.Queries.ListCustomerProductPrices(r.Context(), customerID)
+	if err != nil {
+		slog.Error("Failed to load customer prices", "customerID", customerID, "err", err)
+	}
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.CustomerProductPrices(customerID.String(), customerCurrency, products, overrides)},
+	})
+}
+
+// SubscriptionProductSSE fills in the description and unit price of the product chosen on the subscription form,
+// using the price agreed with the customer for the cadence and currency entered when there is one. The description
+// is only replaced when it has not been typed by hand.
+func (h *Handlers) SubscriptionProductSSE(w http.ResponseWriter, r *http.Request) {
+	customerID, err := uuid.Parse(chi.URLParam(r, "customerID"))
+	if err != nil {
+		slog.Error("Invalid customer ID", "err", err)
+		return
+	}
+	productID, err := uuid.Parse(r.FormValue("productid"))
+	if err != nil {
+		// the subscription is no longer sold from the catalogue, so the entered values stand
+		utils.RenderSSE(w, r, utils.SSEOpts{Views: []templ.Component{views.ProductPriceSource("")}})
+		return
+	}
+
+	products, err := h.Queries.ListProducts(r.Context())
+	if err != nil {
+		slog.Error("Failed to load products", "err", err)
+		return
+	}
+	// a description that is blank or the name of a product was filled in rather than typed
+	var product db.Product
+	description := r.FormValue("description")
+	typed := description != ""
+	for _, p := range products {
+		if p.ID == productID {
+			product = p
+		}
+		if p.Name == description {
+			typed = false
+		}
+	}
+	if product.ID == uuid.Nil {
+		utils.RenderSSE(w, r, utils.SSEOpts{Views: []templ.Component{views.ProductPriceSource("The product no longer exists.")}})
+		return
+	}
+	signals := map[string]string{}
+	if !typed {
+		signals["_description"] = product.Name
+	}
+
+	cadence := r.FormValue("billingcadence")
+	code, err := currency.Parse(r.FormValue("currency"))
+	if err != nil {
+		code = currency.Default
+	}
+	unitPrice, source, found, err := catalogue.Load(r.Context(), h.Queries, customerID, productID, cadence, code)
+	if err != nil {
+		slog.Error("Failed to resolve product price", "customerID", customerID, "product_id", productID, "err", err)
+	}
+	message := fmt.Sprintf("%s has no %s price in %s, enter the unit price.", product.Name, billing.Label(cadence), code)
+	if found {
+		signals["_unitPrice"] = unitPrice.String()
+		message = fmt.Sprintf("Using the catalogue price of %s.", money.New(unitPrice, code))
+		if source == catalogue.SourceCustomer {
+			message = fmt.Sprintf("Using the price agreed with this customer of %s.", money.New(unitPrice, code))
+		}
+	}
+
+	patch, err := json.Marshal(signals)
+	if err != nil {
+		slog.Error("Failed to encode product signals", "err", err)
+		return
+	}
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Signals: patch,
+		Views:   []templ.Component{views.ProductPriceSource(message)},
+	})
+}
+
+// checkProduct checks the product a subscription is being saved with. Archived products can no longer be sold, but a
+// subscription that already has one keeps it. Subscriptions without a description are described by their product.
+func (h *Handlers) checkProduct(w http.ResponseWriter, r *http.Request, productID, current uuid.NullUUID, description *string) bool {
+	if !productID.Valid {
+		return true
+	}
+	product, err := h.Queries.GetProduct(r.Context(), productID.UUID)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Product", "The product no longer exists. Choose another.", w, r)
+		return false
+	}
+	if err != nil {
+		slog.Error("Failed to get product", "product_id", productID.UUID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		h.Notify(NotifyError, "Form Error", "An error occurred while loading the product.", w, r)
+		return false
+	}
+	if product.Status != catalogue.Active && productID != current {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Product", fmt.Sprintf("%s is archived and can no longer be sold.", product.Name), w, r)
+		return false
+	}
+	if *description == "" {
+		*description = product.Name
+	}
+	return true
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
 	"github.com/scottmckendry/beam/ui/views"
 )
 
@@ -21,6 +22,7 @@ func (h *Handlers) GetCustomerProjectsSSE(w http.ResponseWriter, r *http.Request
 	if !ok {
 		return
 	}
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: c.ID, Tab: hub.TabProjects})
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
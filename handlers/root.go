@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 
 	middlewares "github.com/scottmckendry/beam/middleware"
 	"github.com/scottmckendry/beam/ui/views"
@@ -41,5 +42,5 @@ func (h *Handlers) HandleRoot(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Admin middleware handles the admin check, so we can assume the user is authenticated here and has admin privileges.
-	views.Root(true, customers, user).Render(r.Context(), w)
+	views.Root(true, customers, user, uuid.NewString()).Render(r.Context(), w)
 }
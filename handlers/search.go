@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/starfederation/datastar-go/datastar"
+
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/search"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// searchSignals holds the header search input sent with each search request.
+type searchSignals struct {
+	Search string `json:"search"`
+}
+
+// RegisterSearchRoutes registers the global search route on the given router.
+func (h *Handlers) RegisterSearchRoutes(r chi.Router) {
+	r.Get("/sse/search", h.SearchSSE)
+}
+
+// SearchSSE renders ranked results for the header search input, which supports type: and status: qualifiers
+func (h *Handlers) SearchSSE(w http.ResponseWriter, r *http.Request) {
+	var signals searchSignals
+	if err := datastar.ReadSignals(r, &signals); err != nil {
+		slog.Error("Error reading search signals", "err", err)
+		h.Notify(NotifyError, "Search Error", "An error occurred while reading the search.", w, r)
+		return
+	}
+
+	input := strings.TrimSpace(signals.Search)
+	query := search.Parse(input)
+	if query.Empty() {
+		utils.RenderSSE(w, r, utils.SSEOpts{
+			Views: []templ.Component{views.SearchResults(input, nil)},
+		})
+		return
+	}
+
+	results, err := search.Run(r.Context(), h.Queries, query, search.Limit)
+	if err != nil {
+		slog.Error("Search failed", "query", input, "err", err)
+		h.Notify(NotifyError, "Search Error", "An error occurred while searching.", w, r)
+		return
+	}
+
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.SearchResults(input, results)},
+	})
+}
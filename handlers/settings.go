@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/filters"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// RegisterSettingsRoutes registers the settings page routes, where tags, saved filters, custom fields, app passwords, calendar feeds, exchange rates and products are managed, on the given router.
+func (h *Handlers) RegisterSettingsRoutes(r chi.Router) {
+	r.Get("/sse/settings", h.SettingsSSE)
+	r.Get("/sse/settings/tags/add", h.AddTagSSE)
+	r.Get("/sse/settings/tags/delete/{id}", h.DeleteTagSSE)
+	r.Get("/sse/settings/filters/add", h.AddSavedFilterSSE)
+	r.Get("/sse/settings/filters/delete/{id}", h.DeleteSavedFilterSSE)
+	r.Get("/sse/settings/fields/add", h.AddCustomFieldSSE)
+	r.Get("/sse/settings/fields/delete/{id}", h.DeleteCustomFieldSSE)
+	r.Get("/sse/settings/app-passwords/add", h.AddAppPasswordSSE)
+	r.Get("/sse/settings/app-passwords/delete/{id}", h.DeleteAppPasswordSSE)
+	r.Get("/sse/settings/calendar/create", h.CreateCalendarTokenSSE)
+	r.Get("/sse/settings/calendar/delete", h.DeleteCalendarTokenSSE)
+	r.Get("/sse/settings/rates/add", h.AddExchangeRateSSE)
+	r.Get("/sse/settings/rates/delete/{id}", h.DeleteExchangeRateSSE)
+	r.Get("/sse/settings/products/add", h.AddProductSSE)
+	r.Get("/sse/settings/products/{id}/status/{status}", h.SetProductStatusSSE)
+	r.Get("/sse/settings/products/prices/add", h.SetProductPriceSSE)
+	r.Get("/sse/settings/products/prices/delete/{id}", h.DeleteProductPriceSSE)
+	r.Get("/sse/settings/coupons/add", h.AddCouponSSE)
+	r.Get("/sse/settings/coupons/{id}/status/{status}", h.SetCouponStatusSSE)
+}
+
+// SettingsSSE renders the settings page via SSE
+func (h *Handlers) SettingsSSE(w http.ResponseWriter, r *http.Request) {
+	h.trackView(r, hub.View{Page: hub.PageSettings})
+	pageSignals := utils.PageSignals{
+		HeaderTitle:       "Settings",
+		HeaderDescription: "Manage tags, saved filters, custom fields, app passwords, calendar feeds and exchange rates",
+		CurrentPage:       "settings",
+	}
+	encodedSignals, _ := json.Marshal(pageSignals)
+
+	h.renderSettings(w, r, encodedSignals)
+}
+
+// AddTagSSE handles the submission of the add tag form
+func (h *Handlers) AddTagSSE(w http.ResponseWriter, r *http.Request) {
+	params, err := filters.TagParams(r.FormValue("name"), r.FormValue("colour"))
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Tag", err.Error(), w, r)
+		return
+	}
+
+	if _, err := h.Queries.CreateTag(r.Context(), params); err != nil {
+		slog.Error("Error adding tag", "name", params.Name, "err", err)
+		h.Notify(NotifyError, "Add Failed", fmt.Sprintf("A tag named %s could not be added. Tag names must be unique.", params.Name), w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Tag Added", fmt.Sprintf("%s can now be given to customers.", params.Name), w, r)
+	h.renderSettings(w, r, nil)
+	h.renderCustomerNavigation(w, r)
+}
+
+// DeleteTagSSE deletes a tag and removes it from every customer. Tags used by a saved filter cannot be deleted.
+func (h *Handlers) DeleteTagSSE(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		slog.Error("Invalid tag ID", "err", err)
+		h.Notify(NotifyError, "Invalid Tag ID", "The tag ID provided is not valid.", w, r)
+		return
+	}
+
+	inUse, err := h.Queries.CountSavedFiltersByTag(r.Context(), uuid.NullUUID{UUID: id, Valid: true})
+	if err != nil {
+		slog.Error("CountSavedFiltersByTag failed", "tag_id", id, "err", err)
+		h.Notify(NotifyError, "Delete Failed", "An error occurred while deleting the tag.", w, r)
+		return
+	}
+	if inUse > 0 {
+		h.Notify(NotifyError, "Tag In Use", "This tag is used by a saved filter. Delete the saved filter first.", w, r)
+		return
+	}
+
+	if err := h.Queries.DeleteCustomerTagsByTag(r.Context(), id); err != nil {
+		slog.Error("Error removing tag from customers", "tag_id", id, "err", err)
+		h.Notify(NotifyError, "Delete Failed", "An error occurred while removing the tag from customers.", w, r)
+		return
+	}
+	t, err := h.Queries.DeleteTag(r.Context(), id)
+	if err != nil {
+		slog.Error("Error deleting tag", "tag_id", id, "err", err)
+		h.Notify(NotifyError, "Delete Failed", "An error occurred while deleting the tag.", w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Tag Deleted", fmt.Sprintf("%s has been removed from every customer.", t.Name), w, r)
+	if f := h.navFilters.get(streamID(r)); f.Tag == id.String() {
+		h.navFilters.remove(streamID(r))
+	}
+	h.renderSettings(w, r, nil)
+	h.renderCustomerNavigation(w, r)
+}
+
+// AddSavedFilterSSE handles the submission of the save filter form
+func (h *Handlers) AddSavedFilterSSE(w http.ResponseWriter, r *http.Request) {
+	f := filters.Filter{
+		Tag:    r.FormValue("tag"),
+		Status: r.FormValue("status"),
+		From:   r.FormValue("from"),
+		To:     r.FormValue("to"),
+		Sort:   r.FormValue("sort"),
+	}
+	params, err := f.SaveParams(r.FormValue("name"))
+	if err != nil {
+		h.Notify(NotifyError, "Invalid Filter", err.Error(), w, r)
+		return
+	}
+
+	if _, err := h.Queries.CreateSavedFilter(r.Context(), params); err != nil {
+		slog.Error("Error saving filter", "name", params.Name, "err", err)
+		h.Notify(NotifyError, "Save Failed", fmt.Sprintf("A filter named %s could not be saved. Saved filter names must be unique.", params.Name), w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Filter Saved", fmt.Sprintf("%s is now available in the customer navigation.", params.Name), w, r)
+	h.renderSettings(w, r, nil)
+	h.renderCustomerNavigation(w, r)
+}
+
+// DeleteSavedFilterSSE deletes a saved filter. Sessions already using its conditions keep them.
+func (h *Handlers) DeleteSavedFilterSSE(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		slog.Error("Invalid saved filter ID", "err", err)
+		h.Notify(NotifyError, "Invalid Filter ID", "The saved filter ID provided is not valid.", w, r)
+		return
+	}
+
+	saved, err := h.Queries.DeleteSavedFilter(r.Context(), id)
+	if err != nil {
+		slog.Error("Error deleting saved filter", "saved_filter_id", id, "err", err)
+		h.Notify(NotifyError, "Delete Failed", "An error occurred while deleting the saved filter.", w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Filter Deleted", fmt.Sprintf("%s has been deleted.", saved.Name), w, r)
+	if f := h.navFilters.get(streamID(r)); f.Saved == id.String() {
+		f.Saved = ""
+		h.navFilters.set(streamID(r), f)
+	}
+	h.renderSettings(w, r, nil)
+	h.renderCustomerNavigation(w, r)
+}
+
+// renderSettings renders the settings page with the latest tags, saved filters, custom fields, app passwords, calendar feeds, exchange rates, products and coupons, along with any page signals.
+func (h *Handlers) renderSettings(w http.ResponseWriter, r *http.Request, signals []byte) {
+	tags, err := h.Queries.ListTags(r.Context())
+	if err != nil {
+		slog.Error("Failed to load tags", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the tags.", w, r)
+	}
+	saved, err := h.Queries.ListSavedFilters(r.Context())
+	if err != nil {
+		slog.Error("Failed to load saved filters", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the saved filters.", w, r)
+	}
+	fields, err := h.Queries.ListAllCustomFields(r.Context())
+	if err != nil {
+		slog.Error("Failed to load custom fields", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the custom fields.", w, r)
+	}
+	user, err := h.currentUser(r.Context())
+	if err != nil {
+		slog.Error("Failed to load the current user", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the app passwords.", w, r)
+	}
+	passwords, err := h.Queries.ListAppPasswordsByUser(r.Context(), user.ID)
+	if err != nil {
+		slog.Error("Failed to load app passwords", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the app passwords.", w, r)
+	}
+	token, customers := h.calendarSettings(w, r, user)
+	rates, err := h.Queries.ListExchangeRates(r.Context())
+	if err != nil {
+		slog.Error("Failed to load exchange rates", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the exchange rates.", w, r)
+	}
+	products, prices := h.productCatalogue(w, r)
+	coupons, err := h.Queries.ListCoupons(r.Context())
+	if err != nil {
+		slog.Error("Failed to load coupons", "err", err)
+		h.Notify(NotifyError, "Settings Error", "An error occurred while loading the coupons.", w, r)
+	}
+
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Signals: signals,
+		Views: []templ.Component{
+			views.Settings(views.SettingsProps{
+				Tags:              tags,
+				SavedFilters:      saved,
+				CustomFields:      fields,
+				User:              user,
+				AppPasswords:      passwords,
+				CalendarToken:     token,
+				Customers:         customers,
+				ReportingCurrency: h.ReportingCurrency,
+				ExchangeRates:     rates,
+				Products:          products,
+				ProductPrices:     prices,
+				Coupons:           coupons,
+			}),
+			views.HeaderIcon("settings"),
+		},
+	})
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/starfederation/datastar-go/datastar"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/discounts"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	middlewares "github.com/scottmckendry/beam/middleware"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// streamSignals identifies the live update stream of the browser session that sent a request.
+type streamSignals struct {
+	StreamID string `json:"streamId"`
+}
+
+// RegisterStreamRoutes registers the live update stream route on the given router.
+func (h *Handlers) RegisterStreamRoutes(r chi.Router) {
+	r.Get("/sse/stream", h.StreamSSE)
+}
+
+// StreamSSE holds open a long-lived SSE stream for a browser session. Changes published to the hub by other
+// sessions are re-rendered and patched into whatever the session is currently viewing. A heartbeat is sent on
+// an interval to keep the session's presence current and renew its edit locks; a failed heartbeat ends the stream.
+func (h *Handlers) StreamSSE(w http.ResponseWriter, r *http.Request) {
+	id := streamID(r)
+	if id == "" {
+		http.Error(w, "Missing stream ID", http.StatusBadRequest)
+		return
+	}
+	user, _ := r.Context().Value(middlewares.UserKey).(string)
+
+	sub, err := h.Hub.Subscribe(id, user)
+	if err != nil {
+		http.Error(w, "Stream ID in use", http.StatusConflict)
+		return
+	}
+	defer func() {
+		h.Hub.Unsubscribe(sub)
+		// a replacement stream with the same ID keeps the filter
+		if !h.Hub.Connected(id) {
+			h.navFilters.remove(id)
+		}
+	}()
+
+	// a reconnecting stream carries the navigation filter it had chosen as the nav signal
+	var nav navSignals
+	if err := datastar.ReadSignals(r, &nav); err == nil && nav.Nav.Validate() == nil {
+		h.navFilters.set(id, nav.Nav)
+	}
+
+	sse := datastar.NewSSE(w, r)
+	heartbeat := time.NewTicker(hub.HeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			h.Hub.Heartbeat(sub)
+			if err := h.pushHeartbeat(sse, sub); err != nil {
+				slog.Debug("Closing live update stream after failed heartbeat", "stream", id, "err", err)
+				return
+			}
+		case event := <-sub.Events():
+			if err := h.pushUpdate(sse, sub, event); err != nil {
+				slog.Error("Failed to push live update", "stream", id, "customer_id", event.CustomerID, "err", err)
+			}
+		}
+	}
+}
+
+// pushHeartbeat sends the heartbeat signal, along with the current presence when the subscriber is viewing a customer.
+func (h *Handlers) pushHeartbeat(sse *datastar.ServerSentEventGenerator, sub *hub.Subscriber) error {
+	opts := utils.SSEOpts{Signals: []byte(fmt.Sprintf(`{"_heartbeat": %d}`, time.Now().Unix()))}
+	if view := sub.View(); view.Page == hub.PageCustomer && view.CustomerID != uuid.Nil {
+		opts.Views = []templ.Component{views.CustomerPresence(h.Hub.Viewers(view.CustomerID, sub.User))}
+	}
+	return utils.PatchSSE(sse, opts)
+}
+
+// pushUpdate patches the parts of a subscriber's current view that are affected by an event.
+func (h *Handlers) pushUpdate(sse *datastar.ServerSentEventGenerator, sub *hub.Subscriber, event hub.Event) error {
+	ctx := sse.Context()
+	var opts utils.SSEOpts
+
+	if event.Presence {
+		if !event.Affects(sub.View()) {
+			return nil
+		}
+		return utils.PatchSSE(sse, utils.SSEOpts{
+			Views: []templ.Component{views.CustomerPresence(h.Hub.Viewers(event.CustomerID, sub.User))},
+		})
+	}
+
+	if event.Navigation {
+		customers, err := h.navigationCustomers(ctx, sub.ID)
+		if err != nil {
+			return fmt.Errorf("error loading customers for navigation: %w", err)
+		}
+		opts.Views = append(opts.Views, views.CustomerNavigation(customers))
+	}
+
+	view := sub.View()
+	if event.Affects(view) {
+		switch view.Page {
+		case hub.PageDashboard:
+			stats, err := h.Queries.GetDashboardStats(ctx)
+			if err != nil {
+				return fmt.Errorf("error loading dashboard stats: %w", err)
+			}
+			activities, err := h.Queries.GetRecentActivity(ctx)
+			if err != nil {
+				return fmt.Errorf("error loading recent activity: %w", err)
+			}
+			trials, err := h.Queries.ListTrialSubscriptions(ctx)
+			if err != nil {
+				return fmt.Errorf("error loading trials: %w", err)
+			}
+			opts.Views = append(opts.Views, views.DashboardStats(stats, h.recurringRevenue(ctx, uuid.Nil)), views.DashboardTrials(discounts.Ending(trials, h.Clock())), views.DashboardActivity(activities))
+		case hub.PageCustomer:
+			if view.Tab == hub.TabForm {
+				break
+			}
+			c, err := h.Queries.GetCustomer(ctx, view.CustomerID)
+			if errors.Is(err, sql.ErrNoRows) {
+				// the customer was deleted by another session, so move this one back to the dashboard
+				h.Hub.SetView(sub.ID, hub.View{Page: hub.PageDashboard})
+				opts.Signals = dashboardPageSignals()
+				opts.Views = append(opts.Views,
+					views.Dashboard(),
+					views.HeaderIcon("dashboard"),
+					views.InfoToast("Customer Deleted", "The customer you were viewing has been deleted by another user."),
+				)
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("error loading customer: %w", err)
+			}
+			tab, err := h.customerTabView(ctx, c, view.Tab)
+			if err != nil {
+				return err
+			}
+			tags, err := h.Queries.ListCustomerTags(ctx, c.ID)
+			if err != nil {
+				return fmt.Errorf("error loading customer tags: %w", err)
+			}
+			opts.Signals = buildCustomerPageSignals(c)
+			opts.Views = append(opts.Views, views.CustomerTags(tags))
+			if tab != nil {
+				opts.Views = append(opts.Views, tab)
+			}
+		case hub.PageMargins:
+			report, err := h.marginReport(ctx, uuid.Nil)
+			if err != nil {
+				return fmt.Errorf("error building margin report: %w", err)
+			}
+			opts.Views = append(opts.Views, views.Margins(report))
+		}
+	}
+
+	if opts.Signals == nil && len(opts.Views) == 0 {
+		return nil
+	}
+	return utils.PatchSSE(sse, opts)
+}
+
+// customerTabView renders the given customer tab with the latest data, returning nil for tabs that are not refreshed.
+func (h *Handlers) customerTabView(ctx context.Context, c db.GetCustomerRow, tab string) (templ.Component, error) {
+	switch tab {
+	case hub.TabOverview:
+		return views.CustomerOverview(c, h.customerSummary(ctx, c.ID)), nil
+	case hub.TabContacts:
+		contacts, err := h.Queries.ListContactsByCustomer(ctx, c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading contacts: %w", err)
+		}
+		return views.CustomerContacts(c, contacts, h.contactCustomFields(ctx, c.ID, contacts)), nil
+	case hub.TabSubscriptions:
+		subscriptions, err := h.Queries.ListSubscriptionsByCustomer(ctx, c.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading subscriptions: %w", err)
+		}
+		return views.CustomerSubscriptions(c, subscriptions, h.activeDiscounts(ctx), h.Clock()), nil
+	case hub.TabProjects:
+		return views.CustomerProjects(c), nil
+	case hub.TabActivity:
+		activities, err := h.Queries.ListActivityByCustomer(ctx, uuid.NullUUID{UUID: c.ID, Valid: true})
+		if err != nil {
+			return nil, fmt.Errorf("error loading activity: %w", err)
+		}
+		return views.CustomerActivity(c, activities), nil
+	}
+	return nil, nil
+}
+
+// trackView records what the session that sent the request is now viewing, so live updates can be targeted to it.
+func (h *Handlers) trackView(r *http.Request, view hub.View) {
+	if id := streamID(r); id != "" {
+		h.Hub.SetView(id, view)
+	}
+}
+
+// publish notifies other sessions of a change made by the request. The requesting session has already rendered
+// the change, so it is excluded unless the event names a different origin.
+func (h *Handlers) publish(r *http.Request, event hub.Event) {
+	if event.Origin == "" {
+		event.Origin = streamID(r)
+	}
+	h.Hub.Publish(event)
+}
+
+// streamID returns the live update stream ID of the session that sent the request. Form submissions carry it
+// as the streamid field; other datastar requests carry it as a signal in the query string. Request bodies are
+// never read here so POST handlers can still decode them. The ID is chosen by the browser, so it is scoped to
+// the signed-in user to stop one user's requests from reaching another user's stream.
+func streamID(r *http.Request) string {
+	query := r.URL.Query()
+	id := query.Get("streamid")
+	if id == "" {
+		var signals streamSignals
+		if raw := query.Get(datastar.DatastarKey); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &signals); err != nil {
+				return ""
+			}
+		}
+		id = signals.StreamID
+	}
+	if id == "" {
+		return ""
+	}
+	user, _ := r.Context().Value(middlewares.UserKey).(string)
+	return user + "/" + id
+}
@@ -1,15 +1,28 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/a-h/templ"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/currency"
 	db "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/discounts"
 	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	"github.com/scottmckendry/beam/lifecycle"
+	"github.com/scottmckendry/beam/margins"
+	"github.com/scottmckendry/beam/money"
+	"github.com/scottmckendry/beam/pricing"
 	"github.com/scottmckendry/beam/ui/views"
 )
 
@@ -21,21 +34,38 @@ func (h *Handlers) RegisterSubscriptionRoutes(r chi.Router) {
 	r.Get("/sse/customer/{customerID}/edit-subscription/{subscriptionID}", h.EditSubscriptionFormSSE)
 	r.Get("/sse/customer/{customerID}/edit-subscription-submit/{subscriptionID}", h.EditSubscriptionSubmitSSE)
 	r.Get("/sse/customer/{customerID}/delete-subscription/{subscriptionID}", h.DeleteSubscriptionSSE)
+	r.Get("/sse/customer/{customerID}/subscription-status/{subscriptionID}", h.ChangeSubscriptionStatusSSE)
+	r.Get("/sse/customer/{customerID}/subscription-status/{subscriptionID}/end-of-term", h.CancelSubscriptionAtEndOfTermSSE)
+	r.Get("/sse/customer/{customerID}/subscription-status/{subscriptionID}/withdraw/{changeID}", h.WithdrawSubscriptionStatusSSE)
+	r.Get("/sse/customer/{customerID}/subscription-discounts/{subscriptionID}/add", h.AddSubscriptionDiscountSSE)
+	r.Get("/sse/customer/{customerID}/subscription-discounts/{subscriptionID}/remove/{discountID}", h.RemoveSubscriptionDiscountSSE)
+	r.Get("/sse/customer/{customerID}/subscription-product", h.SubscriptionProductSSE)
+	r.Get("/sse/customer/{customerID}/prices/add", h.SetCustomerProductPriceSSE)
+	r.Get("/sse/customer/{customerID}/prices/delete/{priceID}", h.DeleteCustomerProductPriceSSE)
+	r.Get("/sse/subscription-preview", h.SubscriptionPreviewSSE)
 }
 
 // AddSubscriptionFormSSE renders the form to add a new subscription for a customer via SSE.
 func (h *Handlers) AddSubscriptionFormSSE(w http.ResponseWriter, r *http.Request) {
-	customerID := chi.URLParam(r, "customerID")
-	if customerID == "" {
-		slog.Error("No customerID provided in URL param")
-		w.WriteHeader(http.StatusBadRequest)
-		h.Notify(NotifyError, "Missing Customer ID", "No customer ID provided.", w, r)
+	// the customer's currency is the default for their new subscriptions
+	customer, ok := h.getCustomerByID(w, r, "customerID")
+	if !ok {
 		return
 	}
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: customer.ID, Tab: hub.TabForm})
+
+	products, err := h.Queries.ListProducts(r.Context())
+	if err != nil {
+		slog.Error("Failed to load products", "err", err)
+	}
+	overrides, err := h.Queries.ListCustomerProductPrices(r.Context(), customer.ID)
+	if err != nil {
+		slog.Error("Failed to load customer prices", "customerID", customer.ID, "err", err)
+	}
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.AddSubscription(customerID),
+			views.AddSubscription(customer.ID.String(), customer.Currency, products, overrides),
 		},
 	})
 }
@@ -58,11 +88,49 @@ func (h *Handlers) AddSubscriptionSubmitSSE(w http.ResponseWriter, r *http.Reque
 		h.Notify(NotifyError, "Form Error", "An error occurred while processing the form.", w, r)
 		return
 	}
+	if params.Currency, err = currency.Parse(params.Currency); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Currency", err.Error(), w, r)
+		return
+	}
+	if err := pricing.CheckQuantity(params.Quantity); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Seats", err.Error(), w, r)
+		return
+	}
+	if !h.checkProduct(w, r, params.ProductID, uuid.NullUUID{}, &params.Description) {
+		return
+	}
+	params.Amount = pricing.Amount(params.Quantity, params.UnitPrice)
+	schedule := billing.New(params.StartDate, params.BillingCadence, params.BillingInterval, params.BillingAnchorDay)
+	if err := schedule.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Billing Schedule", err.Error(), w, r)
+		return
+	}
+	params.BillingInterval, params.BillingAnchorDay = schedule.Columns()
+	if err := discounts.CheckTrial(params.StartDate, params.TrialEndsOn); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Free Trial", err.Error(), w, r)
+		return
+	}
+	if err := margins.CheckCost(params.CostAmount, params.CostCadence); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Vendor Cost", err.Error(), w, r)
+		return
+	}
+
+	if err := lifecycle.CheckInitial(params.Status); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Status", err.Error(), w, r)
+		return
+	}
 
 	// ensure the customer ID is set in the params
 	params.CustomerID = cid
+	params.CostChangedOn = margins.ChangedOn(db.Subscription{}, params.CostAmount, params.CostCadence, h.Clock())
 
-	_, err = h.Queries.CreateSubscription(r.Context(), params)
+	subscription, err := h.Queries.CreateSubscription(r.Context(), params)
 	if err != nil {
 		slog.Error("Error adding subscription", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -71,6 +139,15 @@ func (h *Handlers) AddSubscriptionSubmitSSE(w http.ResponseWriter, r *http.Reque
 	}
 
 	h.Notify(NotifySuccess, "Subscription added", "The subscription has been successfully added.", w, r)
+	al.LogSubscriptionAdded(r.Context(), h.Queries, subscription)
+	if err := h.lifecycle().Start(r.Context(), subscription); err != nil {
+		slog.Error("Failed to start status history", "subscriptionID", subscription.ID, "err", err)
+	}
+	if err := h.pricing().Start(r.Context(), subscription); err != nil {
+		slog.Error("Failed to start price history", "subscriptionID", subscription.ID, "err", err)
+	}
+	h.publish(r, hub.Event{CustomerID: cid})
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: cid, Tab: hub.TabSubscriptions})
 
 	// Refresh the subscription list for the customer
 	subscriptions, err := h.Queries.ListSubscriptionsByCustomer(r.Context(), cid)
@@ -90,7 +167,7 @@ func (h *Handlers) AddSubscriptionSubmitSSE(w http.ResponseWriter, r *http.Reque
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.CustomerSubscriptions(customer, subscriptions),
+			views.CustomerSubscriptions(customer, subscriptions, h.activeDiscounts(r.Context()), h.Clock()),
 		},
 	})
 }
@@ -101,6 +178,7 @@ func (h *Handlers) GetCustomerSubscriptionsSSE(w http.ResponseWriter, r *http.Re
 	if !ok {
 		return
 	}
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: c.ID, Tab: hub.TabSubscriptions})
 
 	subscriptions, err := h.Queries.ListSubscriptionsByCustomer(r.Context(), c.ID)
 	if err != nil {
@@ -111,7 +189,7 @@ func (h *Handlers) GetCustomerSubscriptionsSSE(w http.ResponseWriter, r *http.Re
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.CustomerSubscriptions(c, subscriptions),
+			views.CustomerSubscriptions(c, subscriptions, h.activeDiscounts(r.Context()), h.Clock()),
 			views.HeaderIcon("customer"),
 		},
 	})
@@ -143,14 +221,45 @@ func (h *Handlers) EditSubscriptionFormSSE(w http.ResponseWriter, r *http.Reques
 		h.Notify(NotifyError, "Failed to get subscription", "Could not fetch subscription details.", w, r)
 		return
 	}
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: sub.CustomerID, Tab: hub.TabForm})
+
+	changes, err := h.Queries.ListStatusChanges(r.Context(), sid)
+	if err != nil {
+		slog.Error("Failed to list status changes", "subscriptionID", subscriptionID, "err", err)
+	}
+	prices, err := h.Queries.ListSubscriptionPrices(r.Context(), sid)
+	if err != nil {
+		slog.Error("Failed to list subscription prices", "subscriptionID", subscriptionID, "err", err)
+	}
+	adjustments, err := h.Queries.ListSubscriptionAdjustments(r.Context(), sid)
+	if err != nil {
+		slog.Error("Failed to list subscription adjustments", "subscriptionID", subscriptionID, "err", err)
+	}
+	given, err := h.Queries.ListSubscriptionDiscounts(r.Context(), sid)
+	if err != nil {
+		slog.Error("Failed to list subscription discounts", "subscriptionID", subscriptionID, "err", err)
+	}
+	coupons, err := h.Queries.ListCoupons(r.Context())
+	if err != nil {
+		slog.Error("Failed to load coupons", "err", err)
+	}
+	products, err := h.Queries.ListProducts(r.Context())
+	if err != nil {
+		slog.Error("Failed to load products", "err", err)
+	}
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.EditSubscription(customerID, sub),
+			views.EditSubscription(customerID, sub, h.Clock(), products, changes, prices, adjustments, given, coupons),
 		},
 	})
 }
 
+// pricing returns a manager for amending the prices of subscriptions.
+func (h *Handlers) pricing() *pricing.Manager {
+	return pricing.New(h.Store, h.Queries, h.Clock)
+}
+
 // EditSubscriptionSubmitSSE handles the submission of the edit subscription form, updates the subscription, and refreshes the subscription list via SSE.
 func (h *Handlers) EditSubscriptionSubmitSSE(w http.ResponseWriter, r *http.Request) {
 	customerID := chi.URLParam(r, "customerID")
@@ -170,9 +279,79 @@ func (h *Handlers) EditSubscriptionSubmitSSE(w http.ResponseWriter, r *http.Requ
 		h.Notify(NotifyError, "Form Error", "An error occurred while processing the form.", w, r)
 		return
 	}
+	if params.Currency, err = currency.Parse(params.Currency); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Currency", err.Error(), w, r)
+		return
+	}
+	if err := pricing.CheckQuantity(params.Quantity); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Seats", err.Error(), w, r)
+		return
+	}
+	params.Amount = pricing.Amount(params.Quantity, params.UnitPrice)
+	schedule := billing.New(params.StartDate, params.BillingCadence, params.BillingInterval, params.BillingAnchorDay)
+	if err := schedule.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Billing Schedule", err.Error(), w, r)
+		return
+	}
+	params.BillingInterval, params.BillingAnchorDay = schedule.Columns()
+	if err := discounts.CheckTrial(params.StartDate, params.TrialEndsOn); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Free Trial", err.Error(), w, r)
+		return
+	}
+	if err := margins.CheckCost(params.CostAmount, params.CostCadence); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Vendor Cost", err.Error(), w, r)
+		return
+	}
 	params.ID = sid
 
-	_, err = h.Queries.UpdateSubscription(r.Context(), params)
+	// a change of price takes effect today unless it is backdated
+	amendment := pricing.Amendment{Note: r.FormValue("pricenote")}
+	if effective := r.FormValue("priceeffective"); effective != "" {
+		if amendment.Effective, err = time.Parse("2006-01-02", effective); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			h.Notify(NotifyError, "Invalid Date", "Choose the day the price change takes effect.", w, r)
+			return
+		}
+	}
+
+	// keep the previous version for the activity diff
+	before, err := h.Queries.GetSubscription(r.Context(), sid)
+	if err != nil {
+		slog.Error("Failed to get subscription", "subscriptionID", subscriptionID, "err", err)
+		w.WriteHeader(http.StatusNotFound)
+		h.Notify(NotifyError, "Failed to get subscription", "Could not fetch subscription details.", w, r)
+		return
+	}
+
+	if !h.checkProduct(w, r, params.ProductID, before.ProductID, &params.Description) {
+		return
+	}
+	params.CostChangedOn = margins.ChangedOn(before, params.CostAmount, params.CostCadence, h.Clock())
+
+	updated, adjustments, err := h.pricing().Update(r.Context(), before, params, amendment)
+	if errors.Is(err, pricing.ErrEffective) {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Price Change", err.Error(), w, r)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		// the subscription was saved by someone else after this form was loaded
+		h.renderConflict(w, r, views.ConflictProps{
+			TargetID:  "customer-tab-content",
+			Record:    "subscription",
+			Changes:   al.Diff(params, before),
+			SavedAt:   before.UpdatedAt.Time,
+			Version:   before.Version,
+			SubmitURL: fmt.Sprintf("/sse/customer/%s/edit-subscription-submit/%s", before.CustomerID, before.ID),
+			ReloadURL: fmt.Sprintf("/sse/customer/%s/edit-subscription/%s", before.CustomerID, before.ID),
+		})
+		return
+	}
 	if err != nil {
 		slog.Error("Error updating subscription", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -180,7 +359,14 @@ func (h *Handlers) EditSubscriptionSubmitSSE(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	h.Notify(NotifySuccess, "Subscription updated", "The subscription has been successfully updated.", w, r)
+	message := "The subscription has been successfully updated."
+	for _, a := range adjustments {
+		message += fmt.Sprintf(" %s is owed on the next invoice for the price change.", money.New(a.Amount, a.Currency))
+	}
+	h.Notify(NotifySuccess, "Subscription updated", message, w, r)
+	al.LogSubscriptionUpdated(r.Context(), h.Queries, before, updated)
+	h.publish(r, hub.Event{CustomerID: updated.CustomerID})
+	h.trackView(r, hub.View{Page: hub.PageCustomer, CustomerID: updated.CustomerID, Tab: hub.TabSubscriptions})
 
 	cid, err := uuid.Parse(customerID)
 	if err != nil {
@@ -207,7 +393,7 @@ func (h *Handlers) EditSubscriptionSubmitSSE(w http.ResponseWriter, r *http.Requ
 
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.CustomerSubscriptions(customer, subscriptions),
+			views.CustomerSubscriptions(customer, subscriptions, h.activeDiscounts(r.Context()), h.Clock()),
 		},
 	})
 }
@@ -239,7 +425,7 @@ func (h *Handlers) DeleteSubscriptionSSE(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	_, err = h.Queries.DeleteSubscription(r.Context(), sid)
+	deleted, err := h.Queries.DeleteSubscription(r.Context(), sid)
 	if err != nil {
 		slog.Error("Error deleting subscription", "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
@@ -248,6 +434,8 @@ func (h *Handlers) DeleteSubscriptionSSE(w http.ResponseWriter, r *http.Request)
 	}
 
 	h.Notify(NotifySuccess, "Subscription deleted", "The subscription has been successfully deleted.", w, r)
+	al.LogSubscriptionDeleted(r.Context(), h.Queries, deleted)
+	h.publish(r, hub.Event{CustomerID: deleted.CustomerID})
 
 	subscriptions, err := h.Queries.ListSubscriptionsByCustomer(r.Context(), cid)
 	if err != nil {
@@ -265,7 +453,22 @@ func (h *Handlers) DeleteSubscriptionSSE(w http.ResponseWriter, r *http.Request)
 	}
 	utils.RenderSSE(w, r, utils.SSEOpts{
 		Views: []templ.Component{
-			views.CustomerSubscriptions(customer, subscriptions),
+			views.CustomerSubscriptions(customer, subscriptions, h.activeDiscounts(r.Context()), h.Clock()),
 		},
 	})
 }
+
+// SubscriptionPreviewSSE previews the upcoming billing dates of the schedule entered on the subscription form as it
+// changes.
+func (h *Handlers) SubscriptionPreviewSSE(w http.ResponseWriter, r *http.Request) {
+	var params db.CreateSubscriptionParams
+	if err := utils.MapFormToStruct(r, &params); err != nil {
+		// half entered values are expected while typing, so there is nothing to preview yet
+		params = db.CreateSubscriptionParams{BillingCadence: r.FormValue("billingcadence")}
+	}
+	schedule := billing.New(params.StartDate, params.BillingCadence, params.BillingInterval, params.BillingAnchorDay)
+
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.BillingPreview(schedule, h.Clock())},
+	})
+}
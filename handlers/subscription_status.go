@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	db "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	"github.com/scottmckendry/beam/lifecycle"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// lifecycle returns a manager for changing the status of subscriptions.
+func (h *Handlers) lifecycle() *lifecycle.Manager {
+	return lifecycle.New(h.Store, h.Queries, h.Clock)
+}
+
+// ChangeSubscriptionStatusSSE makes or schedules a status change entered on the edit subscription page. Pausing can
+// be given a day to resume on, which is scheduled along with the pause.
+func (h *Handlers) ChangeSubscriptionStatusSSE(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.getSubscriptionByID(w, r, "subscriptionID")
+	if !ok {
+		return
+	}
+
+	effective, err := time.Parse("2006-01-02", r.FormValue("effective"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Date", "Choose the day the change takes effect.", w, r)
+		return
+	}
+	changes := []lifecycle.Change{{To: r.FormValue("status"), Effective: effective, Note: r.FormValue("note")}}
+	if resume := r.FormValue("resume"); resume != "" && changes[0].To == lifecycle.Paused {
+		resumeOn, err := time.Parse("2006-01-02", resume)
+		if err != nil || !resumeOn.After(effective) {
+			w.WriteHeader(http.StatusBadRequest)
+			h.Notify(NotifyError, "Invalid Date", "The subscription must resume after the day it is paused.", w, r)
+			return
+		}
+		changes = append(changes, lifecycle.Change{To: lifecycle.Active, Effective: resumeOn, Note: r.FormValue("note")})
+	}
+
+	h.scheduleStatusChanges(w, r, sub, changes...)
+}
+
+// CancelSubscriptionAtEndOfTermSSE schedules a subscription to be cancelled when its current term ends.
+func (h *Handlers) CancelSubscriptionAtEndOfTermSSE(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.getSubscriptionByID(w, r, "subscriptionID")
+	if !ok {
+		return
+	}
+
+	end := lifecycle.EndOfTerm(sub, h.Clock())
+	if end.IsZero() {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Unknown Term", fmt.Sprintf("The end of a %s term cannot be worked out. Choose the day to cancel on instead.", sub.Term), w, r)
+		return
+	}
+	h.scheduleStatusChanges(w, r, sub, lifecycle.Change{To: lifecycle.Cancelled, Effective: end, Note: "Cancelled at the end of the term"})
+}
+
+// scheduleStatusChanges makes or schedules status changes and re-renders the subscription's status.
+func (h *Handlers) scheduleStatusChanges(w http.ResponseWriter, r *http.Request, sub db.Subscription, changes ...lifecycle.Change) {
+	updated, err := h.lifecycle().Schedule(r.Context(), sub, changes...)
+	if errors.Is(err, lifecycle.ErrTransition) {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Status Change Not Allowed", err.Error(), w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("Error changing subscription status", "subscriptionID", sub.ID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		h.Notify(NotifyError, "Status Change Failed", "An error occurred while changing the status. Please try again.", w, r)
+		return
+	}
+
+	if updated.Status != sub.Status {
+		h.Notify(NotifySuccess, "Status Changed", fmt.Sprintf("%s is now %s.", updated.Description, updated.Status), w, r)
+	} else {
+		h.Notify(NotifySuccess, "Status Change Scheduled", fmt.Sprintf("%s will change to %s on %s.", updated.Description, changes[0].To, changes[0].Effective.Format("Jan 2, 2006")), w, r)
+	}
+	h.publish(r, hub.Event{CustomerID: updated.CustomerID})
+	h.renderSubscriptionStatus(w, r, updated)
+}
+
+// WithdrawSubscriptionStatusSSE withdraws a scheduled status change before it takes effect.
+func (h *Handlers) WithdrawSubscriptionStatusSSE(w http.ResponseWriter, r *http.Request) {
+	sub, ok := h.getSubscriptionByID(w, r, "subscriptionID")
+	if !ok {
+		return
+	}
+	changeID, err := uuid.Parse(chi.URLParam(r, "changeID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid Status Change", "The status change ID is invalid.", w, r)
+		return
+	}
+
+	err = h.lifecycle().Withdraw(r.Context(), sub, changeID)
+	switch {
+	case errors.Is(err, lifecycle.ErrTransition):
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Cannot Withdraw", err.Error(), w, r)
+		return
+	case errors.Is(err, sql.ErrNoRows):
+		w.WriteHeader(http.StatusNotFound)
+		h.Notify(NotifyError, "Cannot Withdraw", "The change has already been made or withdrawn.", w, r)
+	case err != nil:
+		slog.Error("Error withdrawing status change", "subscriptionID", sub.ID, "changeID", changeID, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		h.Notify(NotifyError, "Withdraw Failed", "An error occurred while withdrawing the change. Please try again.", w, r)
+		return
+	default:
+		h.Notify(NotifySuccess, "Change Withdrawn", "The scheduled status change has been withdrawn.", w, r)
+		h.publish(r, hub.Event{CustomerID: sub.CustomerID})
+	}
+	h.renderSubscriptionStatus(w, r, sub)
+}
+
+// renderSubscriptionStatus renders the status of a subscription along with its scheduled and past changes.
+func (h *Handlers) renderSubscriptionStatus(w http.ResponseWriter, r *http.Request, sub db.Subscription) {
+	changes, err := h.Queries.ListStatusChanges(r.Context(), sub.ID)
+	if err != nil {
+		slog.Error("Failed to list status changes", "subscriptionID", sub.ID, "err", err)
+	}
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Views: []templ.Component{views.SubscriptionStatus(sub.CustomerID.String(), sub, changes, h.Clock())},
+	})
+}
+
+// getSubscriptionByID parses the subscription ID from the URL and loads the subscription, notifying the user when
+// either fails.
+func (h *Handlers) getSubscriptionByID(w http.ResponseWriter, r *http.Request, idParam string) (db.Subscription, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, idParam))
+	if err != nil {
+		slog.Error("Invalid subscription ID", "err", err)
+		w.WriteHeader(http.StatusBadRequest)
+		h.Notify(NotifyError, "Invalid subscription ID", "The subscription ID is invalid.", w, r)
+		return db.Subscription{}, false
+	}
+	sub, err := h.Queries.GetSubscription(r.Context(), id)
+	if err != nil {
+		slog.Error("Failed to get subscription", "subscriptionID", id, "err", err)
+		w.WriteHeader(http.StatusNotFound)
+		h.Notify(NotifyError, "Failed to get subscription", "Could not fetch subscription details.", w, r)
+		return db.Subscription{}, false
+	}
+	return sub, true
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/google/uuid"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/filters"
+	"github.com/scottmckendry/beam/ui/views"
+)
+
+// customerTags renders the tags on a customer, or an empty list if they cannot be loaded
+func (h *Handlers) customerTags(r *http.Request, customerID uuid.UUID) templ.Component {
+	tags, err := h.Queries.ListCustomerTags(r.Context(), customerID)
+	if err != nil {
+		slog.Error("ListCustomerTags failed", "customer_id", customerID, "err", err)
+	}
+	return views.CustomerTags(tags)
+}
+
+// saveCustomerTags makes the tags ticked on a submitted customer form the customer's tags, logging each change.
+func (h *Handlers) saveCustomerTags(w http.ResponseWriter, r *http.Request, customerID uuid.UUID, customerName string) {
+	added, removed, err := filters.SetCustomerTags(r.Context(), h.Queries, customerID, r.Form["tags"])
+	for _, t := range added {
+		al.LogCustomerTagged(r.Context(), h.Queries, customerID, customerName, t.Name, "")
+	}
+	for _, t := range removed {
+		al.LogCustomerUntagged(r.Context(), h.Queries, customerID, customerName, t.Name, "")
+	}
+	if err != nil {
+		slog.Error("Error saving customer tags", "customer_id", customerID, "err", err)
+		h.Notify(NotifyError, "Tags Not Saved", "An error occurred while saving the customer's tags.", w, r)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/money"
 )
 
 // MapFormToStruct maps form values to a struct using field tags or field names.
@@ -40,6 +42,11 @@ func MapFormToStruct(r *http.Request, dest any) error {
 }
 
 func setFieldValue(field reflect.StructField, fieldValue reflect.Value, formValue string) error {
+	// Money amounts are integers underneath but are entered as decimals
+	if field.Type == reflect.TypeOf(money.Amount(0)) {
+		return setMoneyField(fieldValue, formValue)
+	}
+
 	// Handle basic types
 	switch field.Type.Kind() {
 	case reflect.String:
@@ -58,6 +65,8 @@ func setFieldValue(field reflect.StructField, fieldValue reflect.Value, formValu
 	switch field.Type {
 	case reflect.TypeOf(uuid.UUID{}):
 		return setUUIDField(fieldValue, formValue)
+	case reflect.TypeOf(uuid.NullUUID{}):
+		return setNullUUIDField(fieldValue, formValue)
 	case reflect.TypeOf(sql.NullString{}):
 		fieldValue.Set(reflect.ValueOf(sql.NullString{String: formValue, Valid: formValue != ""}))
 	case reflect.TypeOf(sql.NullBool{}):
@@ -71,6 +80,8 @@ func setFieldValue(field reflect.StructField, fieldValue reflect.Value, formValu
 		return setNullFloat64Field(fieldValue, formValue)
 	case reflect.TypeOf(time.Time{}):
 		return setTimeField(fieldValue, formValue)
+	case reflect.TypeOf(sql.NullTime{}):
+		return setNullTimeField(fieldValue, formValue)
 	}
 	return nil
 }
@@ -101,6 +112,15 @@ func setFloatField(fieldValue reflect.Value, formValue string) error {
 	return nil
 }
 
+func setMoneyField(fieldValue reflect.Value, formValue string) error {
+	var amount money.Amount
+	if err := amount.UnmarshalText([]byte(formValue)); err != nil {
+		return err
+	}
+	fieldValue.Set(reflect.ValueOf(amount))
+	return nil
+}
+
 func setUUIDField(fieldValue reflect.Value, formValue string) error {
 	if formValue == "" {
 		fieldValue.Set(reflect.Zero(fieldValue.Type()))
@@ -114,6 +134,19 @@ func setUUIDField(fieldValue reflect.Value, formValue string) error {
 	return nil
 }
 
+func setNullUUIDField(fieldValue reflect.Value, formValue string) error {
+	if formValue == "" {
+		fieldValue.Set(reflect.ValueOf(uuid.NullUUID{Valid: false}))
+		return nil
+	}
+	parsedUUID, err := uuid.Parse(formValue)
+	if err != nil {
+		return fmt.Errorf("invalid UUID: %v", err)
+	}
+	fieldValue.Set(reflect.ValueOf(uuid.NullUUID{UUID: parsedUUID, Valid: true}))
+	return nil
+}
+
 func setTimeField(fieldValue reflect.Value, formValue string) error {
 	if formValue == "" {
 		fieldValue.Set(reflect.Zero(fieldValue.Type()))
@@ -127,6 +160,19 @@ func setTimeField(fieldValue reflect.Value, formValue string) error {
 	return nil
 }
 
+func setNullTimeField(fieldValue reflect.Value, formValue string) error {
+	if formValue == "" {
+		fieldValue.Set(reflect.ValueOf(sql.NullTime{Valid: false}))
+		return nil
+	}
+	t, err := time.Parse("2006-01-02", formValue)
+	if err != nil {
+		return fmt.Errorf("invalid date format: %v", err)
+	}
+	fieldValue.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: true}))
+	return nil
+}
+
 func setNullInt64Field(fieldValue reflect.Value, formValue string) error {
 	if formValue == "" {
 		fieldValue.Set(reflect.ValueOf(sql.NullInt64{Valid: false}))
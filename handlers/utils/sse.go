@@ -18,23 +18,46 @@ type PageSignals struct {
 type SSEOpts struct {
 	Signals []byte
 	Views   []templ.Component
+	// Selector, when set, patches the views into the element with this ID rather than matching on the IDs of the views themselves.
+	Selector string
+	// Mode, when set, overrides how the views are patched, e.g. datastar.ElementPatchModeAppend for infinite scrolling.
+	Mode datastar.ElementPatchMode
 }
 
 // RenderSSE renders a collection of templ.Components to a Server-Sent Events (SSE) response.
 // Optionally accepts signals to patch into the SSE stream.
 func RenderSSE(w http.ResponseWriter, r *http.Request, opts SSEOpts) error {
+	return PatchSSE(datastar.NewSSE(w, r), opts)
+}
+
+// PatchSSE renders a collection of templ.Components to an existing SSE stream, allowing long-lived streams to send
+// several patches over the same connection.
+func PatchSSE(sse *datastar.ServerSentEventGenerator, opts SSEOpts) error {
 	buf := &bytes.Buffer{}
 	for _, view := range opts.Views {
-		if err := view.Render(r.Context(), buf); err != nil {
+		if err := view.Render(sse.Context(), buf); err != nil {
 			return fmt.Errorf("failed to render view: %w", err)
 		}
 	}
 
-	sse := datastar.NewSSE(w, r)
 	if opts.Signals != nil {
-		sse.PatchSignals(opts.Signals)
+		if err := sse.PatchSignals(opts.Signals); err != nil {
+			return fmt.Errorf("failed to patch signals: %w", err)
+		}
+	}
+	if len(opts.Views) == 0 {
+		return nil
 	}
 
-	sse.PatchElements(buf.String(), datastar.WithUseViewTransitions(true))
+	patchOpts := []datastar.PatchElementOption{datastar.WithUseViewTransitions(true)}
+	if opts.Selector != "" {
+		patchOpts = append(patchOpts, datastar.WithSelectorID(opts.Selector))
+	}
+	if opts.Mode != "" {
+		patchOpts = append(patchOpts, datastar.WithMode(opts.Mode))
+	}
+	if err := sse.PatchElements(buf.String(), patchOpts...); err != nil {
+		return fmt.Errorf("failed to patch elements: %w", err)
+	}
 	return nil
 }
@@ -10,6 +10,8 @@ import (
 	"github.com/google/uuid"
 	"strings"
 	"time"
+
+	"github.com/scottmckendry/beam/money"
 )
 
 func TestDecodeBase64Image(t *testing.T) {
@@ -170,11 +172,13 @@ func TestMapFormToStruct_Errors(t *testing.T) {
 		Float float64
 		UUID  uuid.UUID
 		Date  time.Time
+		Price money.Amount
 	}
 	cases := []struct {
 		field, value, wantErr string
 	}{
 		{"int", "notanint", "invalid integer value"},
+		{"price", "1.005", "invalid amount"},
 		{"float", "notafloat", "invalid float value"},
 		{"uuid", "notauuid", "invalid UUID"},
 		{"date", "notadate", "invalid date format"},
@@ -218,6 +222,69 @@ func TestMapFormToStruct_AllTypes(t *testing.T) {
 	}
 }
 
+func TestMapFormToStruct_Money(t *testing.T) {
+	form := url.Values{}
+	form.Set("amount", "1249.99")
+	r, _ := http.NewRequest("POST", "/", nil)
+	r.Form = form
+	var dest struct {
+		Amount money.Amount
+		Empty  money.Amount
+	}
+	if err := MapFormToStruct(r, &dest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dest.Amount != 124999 || dest.Empty != 0 {
+		t.Errorf("got %+v, want 124999 minor units and zero", dest)
+	}
+}
+
+func TestMapFormToStruct_NullUUID(t *testing.T) {
+	id := uuid.New()
+	form := url.Values{}
+	form.Set("productid", id.String())
+	r, _ := http.NewRequest("POST", "/", nil)
+	r.Form = form
+	var dest struct {
+		ProductID uuid.NullUUID
+		Empty     uuid.NullUUID
+	}
+	if err := MapFormToStruct(r, &dest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !dest.ProductID.Valid || dest.ProductID.UUID != id || dest.Empty.Valid {
+		t.Errorf("got %+v, want the product ID and an unset value", dest)
+	}
+
+	form.Set("productid", "notauuid")
+	if err := MapFormToStruct(r, &dest); err == nil || !strings.Contains(err.Error(), "invalid UUID") {
+		t.Errorf("got err %v, want an invalid UUID error", err)
+	}
+}
+
+func TestMapFormToStruct_NullTime(t *testing.T) {
+	form := url.Values{}
+	form.Set("trialendson", "2025-03-31")
+	r, _ := http.NewRequest("POST", "/", nil)
+	r.Form = form
+	var dest struct {
+		TrialEndsOn sql.NullTime
+		Empty       sql.NullTime
+	}
+	if err := MapFormToStruct(r, &dest); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	if !dest.TrialEndsOn.Valid || !dest.TrialEndsOn.Time.Equal(want) || dest.Empty.Valid {
+		t.Errorf("got %+v, want the trial end date and an unset value", dest)
+	}
+
+	form.Set("trialendson", "31/03/2025")
+	if err := MapFormToStruct(r, &dest); err == nil || !strings.Contains(err.Error(), "invalid date") {
+		t.Errorf("got err %v, want an invalid date error", err)
+	}
+}
+
 func TestMapFormToStruct(t *testing.T) {
 	id := uuid.New()
 	form := url.Values{}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/starfederation/datastar-go/datastar"
+
+	"github.com/scottmckendry/beam/handlers/utils"
+	"github.com/scottmckendry/beam/hub"
+	"github.com/scottmckendry/beam/ui/views"
+	"github.com/scottmckendry/beam/vcard"
+)
+
+// vcardSignals holds the vCard file chosen for import into a customer.
+type vcardSignals struct {
+	VCard      []string `json:"vcard"`
+	VCardNames []string `json:"vcardNames"`
+	StreamID   string   `json:"streamId"`
+}
+
+// RegisterVCardRoutes registers the vCard download and import routes on the given router.
+func (h *Handlers) RegisterVCardRoutes(r chi.Router) {
+	r.Get("/customer/{customerID}/contacts.vcf", h.ExportCustomerVCards)
+	r.Get("/contact/{contactID}.vcf", h.ExportContactVCard)
+	r.Post("/sse/customer/{customerID}/import-vcard", h.ImportVCardSSE)
+}
+
+// ExportCustomerVCards downloads every contact of a customer as a single vCard file
+func (h *Handlers) ExportCustomerVCards(w http.ResponseWriter, r *http.Request) {
+	customerID, err := uuid.Parse(chi.URLParam(r, "customerID"))
+	if err != nil {
+		http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+		return
+	}
+	customer, err := h.Queries.GetCustomer(r.Context(), customerID)
+	if err != nil {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	contacts, err := h.Queries.ListContactsByCustomer(r.Context(), customerID)
+	if err != nil {
+		slog.Error("ListContactsByCustomer failed", "customer_id", customerID, "err", err)
+		http.Error(w, "Export failed", http.StatusInternalServerError)
+		return
+	}
+
+	// cards are small enough to build in full, so failures can still be reported
+	var buf bytes.Buffer
+	for _, c := range contacts {
+		if err := vcard.Write(&buf, c, customer.Name); err != nil {
+			slog.Error("Failed to write vCard", "contact_id", c.ID, "err", err)
+			http.Error(w, "Export failed", http.StatusInternalServerError)
+			return
+		}
+	}
+	h.downloadVCard(w, customer.Name+" contacts", buf.Bytes())
+}
+
+// ExportContactVCard downloads a single contact as a vCard file
+func (h *Handlers) ExportContactVCard(w http.ResponseWriter, r *http.Request) {
+	contactID, err := uuid.Parse(chi.URLParam(r, "contactID"))
+	if err != nil {
+		http.Error(w, "Invalid contact ID", http.StatusBadRequest)
+		return
+	}
+	contact, err := h.Queries.GetContact(r.Context(), contactID)
+	if err != nil {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	customer, err := h.Queries.GetCustomer(r.Context(), contact.CustomerID)
+	if err != nil {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := vcard.Write(&buf, contact, customer.Name); err != nil {
+		slog.Error("Failed to write vCard", "contact_id", contact.ID, "err", err)
+		http.Error(w, "Export failed", http.StatusInternalServerError)
+		return
+	}
+	h.downloadVCard(w, contact.Name, buf.Bytes())
+}
+
+func (h *Handlers) downloadVCard(w http.ResponseWriter, name string, data []byte) {
+	w.Header().Set("Content-Type", vcard.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", vcard.Filename(name)))
+	w.Write(data)
+}
+
+// ImportVCardSSE imports the contacts in a vCard file into a customer, merging cards into existing contacts with the
+// same email address, and refreshes the contacts list.
+func (h *Handlers) ImportVCardSSE(w http.ResponseWriter, r *http.Request) {
+	c, ok := h.getCustomerByID(w, r, "customerID")
+	if !ok {
+		return
+	}
+
+	// base64 encoding makes the file a third larger, with some room left for the other signals
+	r.Body = http.MaxBytesReader(w, r.Body, vcard.MaxFileSize*4/3+1<<20)
+	var signals vcardSignals
+	if err := datastar.ReadSignals(r, &signals); err != nil {
+		slog.Error("Error reading vCard signals", "err", err)
+		h.Notify(NotifyError, "Import Error", fmt.Sprintf("The file could not be read. Files must be smaller than %d MB.", vcard.MaxFileSize>>20), w, r)
+		return
+	}
+	if len(signals.VCard) == 0 || signals.VCard[0] == "" {
+		h.Notify(NotifyError, "No File Chosen", "Choose a vCard file to import.", w, r)
+		return
+	}
+	name := "contacts.vcf"
+	if len(signals.VCardNames) > 0 && signals.VCardNames[0] != "" {
+		name = signals.VCardNames[0]
+	}
+
+	data, err := utils.DecodeBase64Image(signals.VCard[0])
+	if err != nil {
+		slog.Error("Error decoding vCard file", "err", err)
+		h.Notify(NotifyError, "Import Error", "An error occurred while decoding the file.", w, r)
+		return
+	}
+	cards, err := vcard.Parse(data)
+	if err != nil {
+		h.Notify(NotifyError, "Invalid File", fmt.Sprintf("%s could not be imported: %v.", name, err), w, r)
+		return
+	}
+
+	result, err := vcard.Import(r.Context(), h.Store, h.Queries, c.ID, cards, name)
+	if err != nil {
+		slog.Error("vCard import failed", "customer_id", c.ID, "file", name, "err", err)
+		h.Notify(NotifyError, "Import Failed", fmt.Sprintf("Nothing was imported: %v", err), w, r)
+		return
+	}
+
+	h.Notify(NotifySuccess, "Import Complete", fmt.Sprintf("%d %s added and %d merged from %s.",
+		result.Created, utils.Pluralise(int64(result.Created), "contact", "contacts"), result.Merged, name), w, r)
+	h.publish(r, hub.Event{CustomerID: c.ID, Origin: signals.StreamID})
+
+	contacts, err := h.Queries.ListContactsByCustomer(r.Context(), c.ID)
+	if err != nil {
+		slog.Error("ListContactsByCustomer failed", "customer_id", c.ID, "err", err)
+	}
+	utils.RenderSSE(w, r, utils.SSEOpts{
+		Signals: []byte(`{"vcard": [], "vcardNames": []}`),
+		Views: []templ.Component{
+			views.CustomerContacts(c, contacts, h.contactCustomFields(r.Context(), c.ID, contacts)),
+		},
+	})
+}
@@ -0,0 +1,249 @@
+// Package hub is an in-process publish/subscribe hub used to push live updates to every open browser
+// session. Each session holds a long-lived stream; the hub tracks what each stream is viewing so
+// changes only need to be re-rendered for the sessions that can see them.
+package hub
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// bufferSize is the number of events a subscriber can fall behind by before events are dropped.
+const bufferSize = 16
+
+// HeartbeatInterval is how often each stream sends a heartbeat, refreshing presence and renewing its edit locks.
+const HeartbeatInterval = 15 * time.Second
+
+// Pages a stream can be viewing.
+const (
+	PageDashboard = "dashboard"
+	PageCustomer  = "customer"
+	PageInvoices  = "invoices"
+	PageActivity  = "activity"
+	PageSettings  = "settings"
+	PageReport    = "report"
+	PageImport    = "import"
+	PageMargins   = "margins"
+)
+
+// Customer tabs a stream can be viewing. TabForm is used while an add or edit form is open so that
+// live updates never replace a form that is being filled in.
+const (
+	TabOverview      = "overview"
+	TabContacts      = "contacts"
+	TabSubscriptions = "subscriptions"
+	TabProjects      = "projects"
+	TabActivity      = "activity"
+	TabForm          = "form"
+)
+
+// View is the page a stream is currently showing.
+type View struct {
+	Page       string
+	CustomerID uuid.UUID
+	Tab        string
+}
+
+// Event describes a change that other sessions may need to see.
+type Event struct {
+	// CustomerID is the customer that changed, or whose contacts or subscriptions changed.
+	CustomerID uuid.UUID
+	// Navigation is set when the customer list itself changed (a customer was added, renamed, removed or given a new logo).
+	Navigation bool
+	// Origin is the stream of the session that made the change. It has already rendered the change so is skipped.
+	Origin string
+	// Presence is set when only who is viewing or editing the customer changed, rather than its data.
+	Presence bool
+}
+
+// Affects reports whether a stream showing view needs to re-render its page for the event.
+func (e Event) Affects(view View) bool {
+	if e.Presence {
+		return view.Page == PageCustomer && view.CustomerID == e.CustomerID
+	}
+	switch view.Page {
+	case PageDashboard, PageMargins:
+		return true
+	case PageCustomer:
+		return view.CustomerID == e.CustomerID
+	}
+	return false
+}
+
+// Subscriber is a single session stream.
+type Subscriber struct {
+	ID     string
+	User   string
+	events chan Event
+
+	mu   sync.Mutex
+	view View
+}
+
+// Events returns the channel of events published to the subscriber.
+func (s *Subscriber) Events() <-chan Event {
+	return s.events
+}
+
+// View returns what the subscriber is currently viewing.
+func (s *Subscriber) View() View {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.view
+}
+
+// Viewer is a user viewing a customer, and whether they have one of its forms open.
+type Viewer struct {
+	User    string
+	Editing bool
+}
+
+// Hub fans published events out to every subscriber and tracks advisory edit locks.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[string]*Subscriber
+
+	lockMu sync.Mutex
+	locks  map[string]Lock
+	now    func() time.Time
+}
+
+// New creates an empty hub.
+func New() *Hub {
+	return &Hub{
+		subs:  make(map[string]*Subscriber),
+		locks: make(map[string]Lock),
+		now:   time.Now,
+	}
+}
+
+// ErrStreamTaken is returned when subscribing with the ID of another user's stream.
+var ErrStreamTaken = errors.New("stream belongs to another user")
+
+// Subscribe registers a stream. Subscribing with an ID that is already connected replaces the old
+// subscription, which happens when a browser reconnects a dropped stream, but only for the same user.
+func (h *Hub) Subscribe(id, user string) (*Subscriber, error) {
+	sub := &Subscriber{ID: id, User: user, events: make(chan Event, bufferSize)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if old, ok := h.subs[id]; ok {
+		if old.User != user {
+			return nil, ErrStreamTaken
+		}
+		sub.view = old.View()
+	}
+	h.subs[id] = sub
+	return sub, nil
+}
+
+// Unsubscribe removes a stream, unless it has already been replaced by a newer subscription with the same ID.
+// The stream's edit locks are released and anyone viewing the same customer is told it has left.
+func (h *Hub) Unsubscribe(sub *Subscriber) {
+	h.mu.Lock()
+	current := h.subs[sub.ID] == sub
+	if current {
+		delete(h.subs, sub.ID)
+	}
+	h.mu.Unlock()
+	if !current {
+		return
+	}
+
+	h.releaseLocks(sub.ID)
+	if view := sub.View(); view.Page == PageCustomer {
+		h.Publish(Event{CustomerID: view.CustomerID, Presence: true, Origin: sub.ID})
+	}
+}
+
+// Connected reports whether a stream with the given ID is subscribed.
+func (h *Hub) Connected(id string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.subs[id]
+	return ok
+}
+
+// SetView records what a stream is showing. Changing view releases the stream's edit locks, and anyone viewing
+// the customers it left or joined is told. Views for streams that are not connected are ignored.
+func (h *Hub) SetView(id string, view View) {
+	h.mu.RLock()
+	sub, ok := h.subs[id]
+	h.mu.RUnlock()
+	if !ok {
+		return
+	}
+	sub.mu.Lock()
+	previous := sub.view
+	sub.view = view
+	sub.mu.Unlock()
+	if previous == view {
+		return
+	}
+
+	h.releaseLocks(id)
+	if previous.Page == PageCustomer {
+		h.Publish(Event{CustomerID: previous.CustomerID, Presence: true, Origin: id})
+	}
+	if view.Page == PageCustomer && view.CustomerID != previous.CustomerID {
+		h.Publish(Event{CustomerID: view.CustomerID, Presence: true, Origin: id})
+	}
+}
+
+// Heartbeat marks a stream as still connected, renewing any edit locks it holds.
+func (h *Hub) Heartbeat(sub *Subscriber) {
+	h.renewLocks(sub.ID)
+}
+
+// Viewers returns the users viewing a customer, other than the given user. Users with several sessions open on the
+// customer are listed once, as editing if any of those sessions has a form open.
+func (h *Hub) Viewers(customerID uuid.UUID, exclude string) []Viewer {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var viewers []Viewer
+	index := make(map[string]int)
+	for _, sub := range h.subs {
+		view := sub.View()
+		if sub.User == exclude || view.Page != PageCustomer || view.CustomerID != customerID {
+			continue
+		}
+		editing := view.Tab == TabForm
+		if i, ok := index[sub.User]; ok {
+			viewers[i].Editing = viewers[i].Editing || editing
+			continue
+		}
+		index[sub.User] = len(viewers)
+		viewers = append(viewers, Viewer{User: sub.User, Editing: editing})
+	}
+	sort.Slice(viewers, func(i, j int) bool { return viewers[i].User < viewers[j].User })
+	return viewers
+}
+
+// Publish delivers an event to every subscriber except its origin. Publishing never blocks: a subscriber
+// that has fallen too far behind misses the event and catches up on its next update or navigation.
+func (h *Hub) Publish(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for id, sub := range h.subs {
+		if id == event.Origin {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			slog.Warn("Dropping live update for slow stream", "stream", id)
+		}
+	}
+}
+
+// Subscribers returns the number of connected streams.
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
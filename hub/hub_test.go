@@ -0,0 +1,224 @@
+package hub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func mustSubscribe(t *testing.T, h *Hub, id, user string) *Subscriber {
+	t.Helper()
+	sub, err := h.Subscribe(id, user)
+	if err != nil {
+		t.Fatalf("Subscribe(%q, %q) failed: %v", id, user, err)
+	}
+	return sub
+}
+
+func TestPublish_SkipsOrigin(t *testing.T) {
+	h := New()
+	a := mustSubscribe(t, h, "a", "alice")
+	b := mustSubscribe(t, h, "b", "bob")
+
+	h.Publish(Event{CustomerID: uuid.New(), Origin: "a"})
+
+	select {
+	case <-a.Events():
+		t.Error("origin stream should not receive its own event")
+	default:
+	}
+	select {
+	case <-b.Events():
+	default:
+		t.Error("other streams should receive the event")
+	}
+}
+
+func TestPublish_DoesNotBlock(t *testing.T) {
+	h := New()
+	mustSubscribe(t, h, "slow", "alice")
+	for i := 0; i < bufferSize*2; i++ {
+		h.Publish(Event{})
+	}
+}
+
+func TestSubscribe_ReconnectKeepsView(t *testing.T) {
+	h := New()
+	old := mustSubscribe(t, h, "a", "alice")
+	view := View{Page: PageCustomer, CustomerID: uuid.New(), Tab: TabContacts}
+	h.SetView("a", view)
+
+	replacement := mustSubscribe(t, h, "a", "alice")
+	if replacement.View() != view {
+		t.Errorf("expected the view to survive a reconnect, got %+v", replacement.View())
+	}
+
+	// another user cannot take over the stream
+	if _, err := h.Subscribe("a", "mallory"); !errors.Is(err, ErrStreamTaken) {
+		t.Errorf("expected another user's subscription to be rejected, got %v", err)
+	}
+	if h.Subscribers() != 1 {
+		t.Errorf("expected the rejected subscription not to be added, got %d subscribers", h.Subscribers())
+	}
+
+	// the old stream closing must not remove its replacement
+	h.Unsubscribe(old)
+	if h.Subscribers() != 1 || !h.Connected("a") {
+		t.Errorf("expected the replacement to stay subscribed, got %d subscribers", h.Subscribers())
+	}
+	h.Unsubscribe(replacement)
+	if h.Subscribers() != 0 || h.Connected("a") {
+		t.Errorf("expected no subscribers, got %d", h.Subscribers())
+	}
+}
+
+func TestEvent_Affects(t *testing.T) {
+	customer := uuid.New()
+	event := Event{CustomerID: customer}
+	cases := []struct {
+		view View
+		want bool
+	}{
+		{View{Page: PageDashboard}, true},
+		{View{Page: PageCustomer, CustomerID: customer, Tab: TabContacts}, true},
+		{View{Page: PageCustomer, CustomerID: uuid.New(), Tab: TabContacts}, false},
+		{View{Page: PageMargins}, true},
+		{View{Page: PageInvoices}, false},
+	}
+	for _, tc := range cases {
+		if got := event.Affects(tc.view); got != tc.want {
+			t.Errorf("Affects(%+v) = %v, want %v", tc.view, got, tc.want)
+		}
+	}
+}
+
+func TestAcquire_ConflictsWithOtherUsers(t *testing.T) {
+	h := New()
+	record := CustomerRecord(uuid.New())
+
+	if _, ok := h.Acquire(record, "a", "alice"); !ok {
+		t.Fatal("expected the first lock to be acquired")
+	}
+	held, ok := h.Acquire(record, "b", "bob")
+	if ok {
+		t.Fatal("expected the lock to conflict with another user")
+	}
+	if held.User != "alice" {
+		t.Errorf("expected alice to hold the lock, got %q", held.User)
+	}
+	// the same user in another session is not warned about themselves
+	if _, ok := h.Acquire(record, "c", "alice"); !ok {
+		t.Error("expected the holder to re-acquire their own lock")
+	}
+}
+
+func TestAcquire_Expires(t *testing.T) {
+	h := New()
+	now := time.Now()
+	h.now = func() time.Time { return now }
+	record := ContactRecord(uuid.New())
+
+	h.Acquire(record, "a", "alice")
+	now = now.Add(LockTTL + time.Second)
+	if _, held := h.LockHolder(record); held {
+		t.Error("expected the lock to have expired")
+	}
+	if _, ok := h.Acquire(record, "b", "bob"); !ok {
+		t.Error("expected an expired lock to be taken over")
+	}
+}
+
+func TestHeartbeat_RenewsLocks(t *testing.T) {
+	h := New()
+	now := time.Now()
+	h.now = func() time.Time { return now }
+	sub := mustSubscribe(t, h, "a", "alice")
+	record := CustomerRecord(uuid.New())
+
+	h.Acquire(record, "a", "alice")
+	now = now.Add(LockTTL - time.Second)
+	h.Heartbeat(sub)
+	now = now.Add(LockTTL - time.Second)
+	if _, held := h.LockHolder(record); !held {
+		t.Error("expected the heartbeat to keep the lock alive")
+	}
+}
+
+func TestSetView_ReleasesLocks(t *testing.T) {
+	h := New()
+	customer := uuid.New()
+	record := CustomerRecord(customer)
+	mustSubscribe(t, h, "a", "alice")
+	h.SetView("a", View{Page: PageCustomer, CustomerID: customer, Tab: TabForm})
+	h.Acquire(record, "a", "alice")
+
+	h.SetView("a", View{Page: PageCustomer, CustomerID: customer, Tab: TabOverview})
+	if _, held := h.LockHolder(record); held {
+		t.Error("expected leaving the form to release the lock")
+	}
+}
+
+func TestUnsubscribe_ReleasesLocks(t *testing.T) {
+	h := New()
+	record := CustomerRecord(uuid.New())
+	sub := mustSubscribe(t, h, "a", "alice")
+	h.Acquire(record, "a", "alice")
+
+	h.Unsubscribe(sub)
+	if _, held := h.LockHolder(record); held {
+		t.Error("expected disconnecting to release the lock")
+	}
+}
+
+func TestViewers(t *testing.T) {
+	h := New()
+	customer := uuid.New()
+	mustSubscribe(t, h, "a", "alice")
+	mustSubscribe(t, h, "b1", "bob")
+	mustSubscribe(t, h, "b2", "bob")
+	mustSubscribe(t, h, "c", "carol")
+	h.SetView("a", View{Page: PageCustomer, CustomerID: customer, Tab: TabOverview})
+	h.SetView("b1", View{Page: PageCustomer, CustomerID: customer, Tab: TabContacts})
+	h.SetView("b2", View{Page: PageCustomer, CustomerID: customer, Tab: TabForm})
+	h.SetView("c", View{Page: PageCustomer, CustomerID: uuid.New(), Tab: TabOverview})
+
+	viewers := h.Viewers(customer, "alice")
+	if len(viewers) != 1 {
+		t.Fatalf("expected only bob to be listed, got %+v", viewers)
+	}
+	if viewers[0].User != "bob" || !viewers[0].Editing {
+		t.Errorf("expected bob to be editing, got %+v", viewers[0])
+	}
+}
+
+func TestSetView_PublishesPresence(t *testing.T) {
+	h := New()
+	customer := uuid.New()
+	a := mustSubscribe(t, h, "a", "alice")
+	mustSubscribe(t, h, "b", "bob")
+	h.SetView("a", View{Page: PageCustomer, CustomerID: customer, Tab: TabOverview})
+	drain(a)
+
+	h.SetView("b", View{Page: PageCustomer, CustomerID: customer, Tab: TabOverview})
+	select {
+	case event := <-a.Events():
+		if !event.Presence || event.CustomerID != customer {
+			t.Errorf("expected a presence event for the customer, got %+v", event)
+		}
+	default:
+		t.Error("expected alice to be told bob arrived")
+	}
+}
+
+// drain discards any events already queued for a subscriber.
+func drain(sub *Subscriber) {
+	for {
+		select {
+		case <-sub.Events():
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package hub
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LockTTL is how long an edit lock lasts without being renewed by its holder's heartbeat.
+const LockTTL = 2 * time.Minute
+
+// Lock is an advisory edit lock on a record. Locks never block a write; they only let other users know
+// someone else has the record open for editing.
+type Lock struct {
+	Record   string
+	User     string
+	StreamID string
+	Acquired time.Time
+	Expires  time.Time
+}
+
+// CustomerRecord returns the lock key for a customer.
+func CustomerRecord(id uuid.UUID) string {
+	return "customer:" + id.String()
+}
+
+// ContactRecord returns the lock key for a contact.
+func ContactRecord(id uuid.UUID) string {
+	return "contact:" + id.String()
+}
+
+// Acquire takes the edit lock on a record for a stream. If another user holds an unexpired lock on the record it is
+// returned with ok set to false. A user re-opening a record they already hold keeps their original acquired time.
+func (h *Hub) Acquire(record, streamID, user string) (Lock, bool) {
+	h.lockMu.Lock()
+	defer h.lockMu.Unlock()
+
+	now := h.now()
+	acquired := now
+	if held, ok := h.locks[record]; ok && now.Before(held.Expires) {
+		if held.User != user {
+			return held, false
+		}
+		acquired = held.Acquired
+	}
+	lock := Lock{Record: record, User: user, StreamID: streamID, Acquired: acquired, Expires: now.Add(LockTTL)}
+	h.locks[record] = lock
+	return lock, true
+}
+
+// LockHolder returns the unexpired lock on a record, if any.
+func (h *Hub) LockHolder(record string) (Lock, bool) {
+	h.lockMu.Lock()
+	defer h.lockMu.Unlock()
+
+	held, ok := h.locks[record]
+	if !ok {
+		return Lock{}, false
+	}
+	if !h.now().Before(held.Expires) {
+		delete(h.locks, record)
+		return Lock{}, false
+	}
+	return held, true
+}
+
+// renewLocks extends every lock held by a stream, called on each heartbeat.
+func (h *Hub) renewLocks(streamID string) {
+	h.lockMu.Lock()
+	defer h.lockMu.Unlock()
+
+	expires := h.now().Add(LockTTL)
+	for record, lock := range h.locks {
+		if lock.StreamID == streamID {
+			lock.Expires = expires
+			h.locks[record] = lock
+		}
+	}
+}
+
+// releaseLocks drops every lock held by a stream and prunes any that have expired.
+func (h *Hub) releaseLocks(streamID string) {
+	h.lockMu.Lock()
+	defer h.lockMu.Unlock()
+
+	now := h.now()
+	for record, lock := range h.locks {
+		if lock.StreamID == streamID || !now.Before(lock.Expires) {
+			delete(h.locks, record)
+		}
+	}
+}
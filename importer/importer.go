@@ -0,0 +1,543 @@
+// Package importer reads customers and contacts from CSV files. Columns are mapped to record fields, every row is
+// validated and checked for duplicates before anything is written, and the valid rows are then created in a single
+// transaction.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/currency"
+	"github.com/scottmckendry/beam/customfields"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/filters"
+)
+
+// Limits on the files accepted for import.
+const (
+	MaxFileSize = 5 << 20
+	MaxRows     = 5000
+)
+
+// Record fields that columns can be mapped to. Custom fields are mapped with customPrefix followed by the field ID.
+const (
+	FieldCustomer = "customer"
+	FieldName     = "name"
+	FieldStatus   = "status"
+	FieldEmail    = "email"
+	FieldPhone    = "phone"
+	FieldAddress  = "address"
+	FieldWebsite  = "website"
+	FieldRole     = "role"
+	FieldPrimary  = "primary"
+	FieldNotes    = "notes"
+	FieldCurrency = "currency"
+
+	customPrefix = "custom:"
+)
+
+// defaultStatus is given to imported customers without a status.
+const defaultStatus = "active"
+
+// aliases maps common alternative column headings, normalised, to the fields they usually hold in order of
+// preference, as a company column names the customer of a contact but is the name of a customer itself.
+var aliases = map[string][]string{
+	"company":      {FieldCustomer, FieldName},
+	"companyname":  {FieldCustomer, FieldName},
+	"organisation": {FieldCustomer, FieldName},
+	"organization": {FieldCustomer, FieldName},
+	"customername": {FieldCustomer, FieldName},
+	"fullname":     {FieldName},
+	"contactname":  {FieldName},
+	"emailaddress": {FieldEmail},
+	"mobile":       {FieldPhone},
+	"phonenumber":  {FieldPhone},
+	"url":          {FieldWebsite},
+	"title":        {FieldRole},
+	"jobtitle":     {FieldRole},
+	"comments":     {FieldNotes},
+}
+
+// File is a parsed CSV file. Every row has one value per header.
+type File struct {
+	Name    string
+	Headers []string
+	Rows    [][]string
+}
+
+// Read parses a CSV file, which must have a header row followed by at least one record.
+func Read(name string, data []byte) (File, error) {
+	if len(data) > MaxFileSize {
+		return File{}, fmt.Errorf("the file is larger than %d MB", MaxFileSize>>20)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // byte order mark added by spreadsheet programs
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	headers, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return File{}, errors.New("the file is empty")
+	}
+	if err != nil {
+		return File{}, fmt.Errorf("the file is not valid CSV: %w", err)
+	}
+	for i := range headers {
+		headers[i] = strings.TrimSpace(headers[i])
+	}
+
+	f := File{Name: name, Headers: headers}
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return File{}, fmt.Errorf("the file is not valid CSV: %w", err)
+		}
+		if isBlank(record) {
+			continue
+		}
+		if len(f.Rows) == MaxRows {
+			return File{}, fmt.Errorf("the file has more than %d rows", MaxRows)
+		}
+		row := make([]string, len(headers))
+		copy(row, record)
+		f.Rows = append(f.Rows, row)
+	}
+	if len(f.Rows) == 0 {
+		return File{}, errors.New("the file has no rows below the header")
+	}
+	return f, nil
+}
+
+// Target is a record field that a column can be mapped to.
+type Target struct {
+	Key      string
+	Label    string
+	Required bool
+	// Field is set for custom fields.
+	Field *db.CustomField
+}
+
+// Targets returns the fields that columns can be mapped to for entity, including its custom fields.
+func Targets(entity string, fields []db.CustomField) []Target {
+	var targets []Target
+	switch entity {
+	case customfields.EntityCustomer:
+		targets = []Target{
+			{Key: FieldName, Label: "Name", Required: true},
+			{Key: FieldStatus, Label: "Status"},
+			{Key: FieldEmail, Label: "Email"},
+			{Key: FieldPhone, Label: "Phone"},
+			{Key: FieldAddress, Label: "Address"},
+			{Key: FieldWebsite, Label: "Website"},
+			{Key: FieldCurrency, Label: "Currency"},
+			{Key: FieldNotes, Label: "Notes"},
+		}
+	case customfields.EntityContact:
+		targets = []Target{
+			{Key: FieldCustomer, Label: "Customer", Required: true},
+			{Key: FieldName, Label: "Name", Required: true},
+			{Key: FieldRole, Label: "Role"},
+			{Key: FieldEmail, Label: "Email"},
+			{Key: FieldPhone, Label: "Phone"},
+			{Key: FieldPrimary, Label: "Primary contact"},
+			{Key: FieldNotes, Label: "Notes"},
+		}
+	}
+	for i := range fields {
+		targets = append(targets, Target{Key: customPrefix + fields[i].ID.String(), Label: fields[i].Name, Field: &fields[i]})
+	}
+	return targets
+}
+
+// Mapping assigns a target field to columns by their index. Columns that are not mapped are ignored.
+type Mapping map[int]string
+
+// Guess maps each column whose heading matches the key or label of a target. Remaining columns are then mapped by
+// common aliases. Each target is only mapped once.
+func Guess(headers []string, targets []Target) Mapping {
+	m := make(Mapping)
+	used := make(map[string]bool)
+	assign := func(col int, key string) bool {
+		if used[key] || !slices.ContainsFunc(targets, func(t Target) bool { return t.Key == key }) {
+			return false
+		}
+		m[col] = key
+		used[key] = true
+		return true
+	}
+	for i, h := range headers {
+		heading := normalise(h)
+		for _, t := range targets {
+			if heading == normalise(t.Key) || heading == normalise(t.Label) {
+				if assign(i, t.Key) {
+					break
+				}
+			}
+		}
+	}
+	for i, h := range headers {
+		if m[i] != "" {
+			continue
+		}
+		for _, key := range aliases[normalise(h)] {
+			if assign(i, key) {
+				break
+			}
+		}
+	}
+	return m
+}
+
+// Validate checks that the mapping only uses known targets, maps each at most once and includes every required
+// target.
+func (m Mapping) Validate(headers []string, targets []Target) error {
+	var errs []error
+	mapped := make(map[string]int)
+	for i := range headers {
+		key := m[i]
+		if key == "" {
+			continue
+		}
+		if !slices.ContainsFunc(targets, func(t Target) bool { return t.Key == key }) {
+			errs = append(errs, fmt.Errorf("column %s is mapped to an unknown field", headers[i]))
+			continue
+		}
+		mapped[key]++
+	}
+	for _, t := range targets {
+		if mapped[t.Key] > 1 {
+			errs = append(errs, fmt.Errorf("%s is mapped to more than one column", t.Label))
+		}
+		if t.Required && mapped[t.Key] == 0 {
+			errs = append(errs, fmt.Errorf("a column must be mapped to %s", t.Label))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Row is a validated row of the file.
+type Row struct {
+	// Line is the line of the file the row came from, counting the header as line 1.
+	Line int
+	// Values are the normalised values of the row by target key.
+	Values map[string]string
+	// CustomerID is the existing customer a contact row belongs to.
+	CustomerID uuid.UUID
+	Errors     []string
+	// Duplicate describes the existing record, or earlier row, that the row duplicates.
+	Duplicate string
+}
+
+// Valid reports whether the row will be imported.
+func (r Row) Valid() bool {
+	return len(r.Errors) == 0 && r.Duplicate == ""
+}
+
+// Preview is the outcome of validating every row of a file against a mapping, before anything is imported.
+type Preview struct {
+	Entity  string
+	File    File
+	Targets []Target
+	Mapping Mapping
+	Rows    []Row
+}
+
+// Counts returns the number of rows that will be imported, that have errors, and that duplicate other records.
+func (p Preview) Counts() (valid, invalid, duplicates int) {
+	for _, r := range p.Rows {
+		switch {
+		case len(r.Errors) > 0:
+			invalid++
+		case r.Duplicate != "":
+			duplicates++
+		default:
+			valid++
+		}
+	}
+	return valid, invalid, duplicates
+}
+
+// Build validates every row of a file as entity records using mapping. An error is returned when the mapping itself
+// is unusable, in which case no rows are validated.
+func Build(ctx context.Context, queries *db.Queries, entity string, file File, mapping Mapping) (Preview, error) {
+	if !slices.Contains(customfields.Entities, entity) {
+		return Preview{}, fmt.Errorf("invalid record type %q", entity)
+	}
+	fields, err := queries.ListCustomFields(ctx, entity)
+	if err != nil {
+		return Preview{}, fmt.Errorf("error loading custom fields: %w", err)
+	}
+	p := Preview{Entity: entity, File: file, Targets: Targets(entity, fields), Mapping: mapping}
+	if err := mapping.Validate(file.Headers, p.Targets); err != nil {
+		return p, err
+	}
+
+	existing, err := loadExisting(ctx, queries, entity)
+	if err != nil {
+		return p, err
+	}
+	for i, record := range file.Rows {
+		row := Row{Line: i + 2, Values: make(map[string]string)}
+		for col, key := range mapping {
+			if key != "" && col < len(record) {
+				row.Values[key] = strings.TrimSpace(record[col])
+			}
+		}
+		p.validate(&row, existing)
+		existing.check(&row, entity)
+		p.Rows = append(p.Rows, row)
+	}
+	return p, nil
+}
+
+// validate normalises the values of a row, recording any that are invalid.
+func (p Preview) validate(row *Row, existing *existing) {
+	for _, t := range p.Targets {
+		value := row.Values[t.Key]
+		if value == "" {
+			if t.Required {
+				row.Errors = append(row.Errors, fmt.Sprintf("%s is required", t.Label))
+			}
+			continue
+		}
+		switch {
+		case t.Field != nil:
+			normalised, err := customfields.Normalise(*t.Field, value)
+			if err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+			row.Values[t.Key] = normalised
+		case t.Key == FieldStatus:
+			status := strings.ToLower(value)
+			if !slices.Contains(filters.Statuses, status) {
+				row.Errors = append(row.Errors, fmt.Sprintf("Status must be one of %s", strings.Join(filters.Statuses, ", ")))
+			}
+			row.Values[t.Key] = status
+		case t.Key == FieldCurrency:
+			code, err := currency.Parse(value)
+			if err != nil {
+				row.Errors = append(row.Errors, err.Error())
+			}
+			row.Values[t.Key] = code
+		case t.Key == FieldEmail:
+			addr, err := mail.ParseAddress(value)
+			if err != nil {
+				row.Errors = append(row.Errors, fmt.Sprintf("%s is not a valid email address", value))
+				continue
+			}
+			row.Values[t.Key] = addr.Address
+		case t.Key == FieldPrimary:
+			primary, ok := parseBool(value)
+			if !ok {
+				row.Errors = append(row.Errors, "Primary contact must be yes or no")
+			}
+			row.Values[t.Key] = primary
+		case t.Key == FieldCustomer:
+			id, ok := existing.customers[strings.ToLower(value)]
+			if !ok {
+				row.Errors = append(row.Errors, fmt.Sprintf("No customer named %s", value))
+			}
+			row.CustomerID = id
+		}
+	}
+	if p.Entity == customfields.EntityCustomer && row.Values[FieldStatus] == "" {
+		row.Values[FieldStatus] = defaultStatus
+	}
+	if p.Entity == customfields.EntityCustomer && row.Values[FieldCurrency] == "" {
+		row.Values[FieldCurrency] = currency.Default
+	}
+}
+
+// existing tracks the names and emails already taken, both by stored records and by earlier rows of the file.
+type existing struct {
+	// customers holds the ID of each customer by lower case name.
+	customers map[string]uuid.UUID
+	// names and emails describe who holds each lower case name and email. Contact names are only unique within a
+	// customer so are prefixed with its ID.
+	names  map[string]string
+	emails map[string]string
+}
+
+func loadExisting(ctx context.Context, queries *db.Queries, entity string) (*existing, error) {
+	e := &existing{customers: make(map[string]uuid.UUID), names: make(map[string]string), emails: make(map[string]string)}
+	customers, err := queries.ListCustomers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading customers: %w", err)
+	}
+	for _, c := range customers {
+		e.customers[strings.ToLower(c.Name)] = c.ID
+		if entity == customfields.EntityCustomer {
+			e.take(c.Name, c.Email.String, "customer "+c.Name)
+		}
+	}
+	if entity == customfields.EntityContact {
+		contacts, err := queries.ListContacts(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error loading contacts: %w", err)
+		}
+		for _, c := range contacts {
+			e.take(c.CustomerID.String()+c.Name, c.Email.String, "contact "+c.Name)
+		}
+	}
+	return e, nil
+}
+
+// check flags a row that shares a name or email with an existing record or an earlier row, then takes its name and
+// email for the rows that follow.
+func (e *existing) check(row *Row, entity string) {
+	name := row.Values[FieldName]
+	if entity == customfields.EntityContact {
+		name = row.CustomerID.String() + name
+	}
+	email := row.Values[FieldEmail]
+	if by := e.names[strings.ToLower(name)]; by != "" && row.Values[FieldName] != "" {
+		row.Duplicate = "Same name as " + by
+	} else if by := e.emails[strings.ToLower(email)]; by != "" && email != "" {
+		row.Duplicate = "Same email as " + by
+	}
+	if row.Duplicate == "" {
+		e.take(name, email, fmt.Sprintf("line %d", row.Line))
+	}
+}
+
+func (e *existing) take(name, email, by string) {
+	if name != "" {
+		e.names[strings.ToLower(name)] = by
+	}
+	if email != "" {
+		e.emails[strings.ToLower(email)] = by
+	}
+}
+
+// Result summarises a committed import.
+type Result struct {
+	Created int
+	// CustomerIDs are the customers that were created, or that contacts were added to.
+	CustomerIDs []uuid.UUID
+}
+
+// Commit creates a record for every valid row of a preview in a single transaction, logging each one. Nothing is
+// created if any row fails.
+func Commit(ctx context.Context, store *sql.DB, queries *db.Queries, p Preview) (Result, error) {
+	var result Result
+	tx, err := store.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	for _, row := range p.Rows {
+		if !row.Valid() {
+			continue
+		}
+		var recordID, customerID uuid.UUID
+		switch p.Entity {
+		case customfields.EntityCustomer:
+			customer, err := qtx.CreateCustomer(ctx, db.CreateCustomerParams{
+				Name:     row.Values[FieldName],
+				Status:   row.Values[FieldStatus],
+				Email:    nullable(row.Values[FieldEmail]),
+				Phone:    nullable(row.Values[FieldPhone]),
+				Address:  nullable(row.Values[FieldAddress]),
+				Website:  nullable(row.Values[FieldWebsite]),
+				Notes:    nullable(row.Values[FieldNotes]),
+				Currency: row.Values[FieldCurrency],
+			})
+			if err != nil {
+				return Result{}, fmt.Errorf("error creating the customer on line %d: %w", row.Line, err)
+			}
+			al.LogCustomerImported(ctx, qtx, customer, p.File.Name)
+			recordID, customerID = customer.ID, customer.ID
+		case customfields.EntityContact:
+			primary := row.Values[FieldPrimary] == "true"
+			contact, err := qtx.CreateContact(ctx, db.CreateContactParams{
+				CustomerID: row.CustomerID,
+				Name:       row.Values[FieldName],
+				Role:       nullable(row.Values[FieldRole]),
+				Email:      nullable(row.Values[FieldEmail]),
+				Phone:      nullable(row.Values[FieldPhone]),
+				IsPrimary:  sql.NullBool{Bool: primary, Valid: true},
+				Notes:      nullable(row.Values[FieldNotes]),
+			})
+			if err != nil {
+				return Result{}, fmt.Errorf("error creating the contact on line %d: %w", row.Line, err)
+			}
+			if primary {
+				if err := qtx.UnsetOtherPrimaryContacts(ctx, db.UnsetOtherPrimaryContactsParams{CustomerID: contact.CustomerID, ID: contact.ID}); err != nil {
+					return Result{}, fmt.Errorf("error setting the primary contact on line %d: %w", row.Line, err)
+				}
+			}
+			al.LogContactImported(ctx, qtx, contact.CustomerID, contact.Name, p.File.Name)
+			recordID, customerID = contact.ID, contact.CustomerID
+		}
+
+		for _, t := range p.Targets {
+			if value := row.Values[t.Key]; t.Field != nil && value != "" {
+				if err := qtx.UpsertCustomFieldValue(ctx, db.UpsertCustomFieldValueParams{FieldID: t.Field.ID, RecordID: recordID, Value: value}); err != nil {
+					return Result{}, fmt.Errorf("error saving %s on line %d: %w", t.Label, row.Line, err)
+				}
+			}
+		}
+
+		result.Created++
+		if !slices.Contains(result.CustomerIDs, customerID) {
+			result.CustomerIDs = append(result.CustomerIDs, customerID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("error committing import: %w", err)
+	}
+	return result, nil
+}
+
+// normalise reduces a column heading or field label to lower case letters and digits for matching.
+func normalise(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// parseBool reads the yes or no values spreadsheets commonly use, returning "true" or "false".
+func parseBool(s string) (string, bool) {
+	switch strings.ToLower(s) {
+	case "yes", "y", "true", "1", "x":
+		return "true", true
+	case "no", "n", "false", "0":
+		return "false", true
+	}
+	return "", false
+}
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func nullable(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,190 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/customfields"
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+)
+
+func TestRead(t *testing.T) {
+	f, err := Read("people.csv", []byte("\xef\xbb\xbfName, Email\nAcme,info@acme.test\n\n,\nGlobex\n"))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if len(f.Headers) != 2 || f.Headers[0] != "Name" || f.Headers[1] != "Email" {
+		t.Errorf("expected trimmed headers without the byte order mark, got %q", f.Headers)
+	}
+	if len(f.Rows) != 2 || len(f.Rows[1]) != 2 || f.Rows[1][0] != "Globex" {
+		t.Errorf("expected blank rows skipped and short rows padded, got %q", f.Rows)
+	}
+
+	for name, data := range map[string]string{
+		"empty":       "",
+		"header only": "Name,Email\n",
+		"bad quotes":  "Name\n\"Acme\n",
+	} {
+		if _, err := Read(name, []byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestGuess(t *testing.T) {
+	tier := sqlc.CustomField{ID: uuid.New(), Name: "Tier", FieldType: customfields.TypeText}
+	targets := Targets(customfields.EntityContact, []sqlc.CustomField{tier})
+	got := Guess([]string{"Company", "Full Name", "Job Title", "E-mail", "tier", "Name", "Favourite colour"}, targets)
+	// an exact heading wins over an alias, and unrecognised columns are skipped
+	want := []string{FieldCustomer, "", FieldRole, FieldEmail, customPrefix + tier.ID.String(), FieldName, ""}
+	for col, key := range want {
+		if got[col] != key {
+			t.Errorf("column %d: got %q, want %q", col, got[col], key)
+		}
+	}
+	if customers := Guess([]string{"Company"}, Targets(customfields.EntityCustomer, nil)); customers[0] != FieldName {
+		t.Errorf("expected a company column to name customers, got %v", customers)
+	}
+}
+
+func TestMappingValidate(t *testing.T) {
+	headers := []string{"A", "B", "C"}
+	targets := Targets(customfields.EntityContact, nil)
+	tests := []struct {
+		name    string
+		mapping Mapping
+		wantErr bool
+	}{
+		{"complete", Mapping{0: FieldCustomer, 1: FieldName}, false},
+		{"missing customer", Mapping{1: FieldName}, true},
+		{"mapped twice", Mapping{0: FieldCustomer, 1: FieldName, 2: FieldName}, true},
+		{"unknown field", Mapping{0: FieldCustomer, 1: FieldName, 2: FieldAddress}, true},
+		{"beyond the file", Mapping{0: FieldCustomer, 1: FieldName, 3: FieldName}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.mapping.Validate(headers, targets); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildAndCommit_Integration(t *testing.T) {
+	store, queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// a unique word per run keeps rows from earlier runs against the same database out of the way
+	word := "zq" + strings.ReplaceAll(uuid.NewString(), "-", "")[:10]
+
+	existing, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{
+		Name:   "Existing " + word,
+		Status: "active",
+		Email:  sql.NullString{String: "existing@" + word + ".test", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+
+	customers, err := Read("customers.csv", []byte(fmt.Sprintf(
+		"Name,Status,Email\n"+
+			"Alpha %[1]s,,alpha@%[1]s.test\n"+
+			"existing %[1]s,active,\n"+
+			"Beta %[1]s,prospect,EXISTING@%[1]s.test\n"+
+			"Gamma %[1]s,archived,not an email\n"+
+			"alpha %[1]s,active,\n"+
+			",inactive,\n",
+		word)))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	preview, err := Build(ctx, queries, customfields.EntityCustomer, customers, Guess(customers.Headers, Targets(customfields.EntityCustomer, nil)))
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if valid, invalid, duplicates := preview.Counts(); valid != 1 || invalid != 2 || duplicates != 3 {
+		t.Fatalf("expected 1 valid, 2 invalid and 3 duplicate rows, got %d, %d and %d: %+v", valid, invalid, duplicates, preview.Rows)
+	}
+	if row := preview.Rows[0]; row.Values[FieldStatus] != defaultStatus || row.Values[FieldEmail] != "alpha@"+word+".test" {
+		t.Errorf("expected the default status and a clean email, got %v", row.Values)
+	}
+	if row := preview.Rows[4]; row.Duplicate != "Same name as line 2" {
+		t.Errorf("expected a duplicate of an earlier row, got %q", row.Duplicate)
+	}
+	if row := preview.Rows[3]; len(row.Errors) != 2 {
+		t.Errorf("expected errors for the status and email, got %v", row.Errors)
+	}
+
+	result, err := Commit(ctx, store, queries, preview)
+	if err != nil || result.Created != 1 || len(result.CustomerIDs) != 1 {
+		t.Fatalf("expected one customer to be created, got %+v (%v)", result, err)
+	}
+	alpha, err := queries.GetCustomer(ctx, result.CustomerIDs[0])
+	if err != nil || alpha.Name != "Alpha "+word {
+		t.Fatalf("expected the imported customer, got %+v (%v)", alpha, err)
+	}
+	activity, err := queries.ListActivityByCustomer(ctx, uuid.NullUUID{UUID: alpha.ID, Valid: true})
+	if err != nil || len(activity) != 1 || activity[0].Action != "customer_imported" {
+		t.Errorf("expected an import activity entry, got %+v (%v)", activity, err)
+	}
+
+	field, err := queries.CreateCustomField(ctx, sqlc.CreateCustomFieldParams{Entity: customfields.EntityContact, Name: "Team " + word, FieldType: customfields.TypeText})
+	if err != nil {
+		t.Fatalf("CreateCustomField failed: %v", err)
+	}
+	defer queries.DeleteCustomField(ctx, field.ID)
+	contacts, err := Read("contacts.csv", []byte(fmt.Sprintf(
+		"Customer,Name,Primary,Team %[1]s\n"+
+			"alpha %[1]s,Jane,yes,Finance\n"+
+			"Existing %[1]s,Jane,no,\n"+
+			"Missing %[1]s,Joe,,\n"+
+			"Alpha %[1]s,Jane,,\n",
+		word)))
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	fields, _ := queries.ListCustomFields(ctx, customfields.EntityContact)
+	preview, err = Build(ctx, queries, customfields.EntityContact, contacts, Guess(contacts.Headers, Targets(customfields.EntityContact, fields)))
+	if err != nil {
+		t.Fatalf("Build failed: %v", err)
+	}
+	if valid, invalid, duplicates := preview.Counts(); valid != 2 || invalid != 1 || duplicates != 1 {
+		t.Fatalf("expected 2 valid, 1 invalid and 1 duplicate rows, got %d, %d and %d: %+v", valid, invalid, duplicates, preview.Rows)
+	}
+	result, err = Commit(ctx, store, queries, preview)
+	if err != nil || result.Created != 2 || len(result.CustomerIDs) != 2 {
+		t.Fatalf("expected two contacts for two customers, got %+v (%v)", result, err)
+	}
+	imported, err := queries.ListContactsByCustomer(ctx, alpha.ID)
+	if err != nil || len(imported) != 1 || !imported[0].IsPrimary.Bool {
+		t.Fatalf("expected a primary contact for the imported customer, got %+v (%v)", imported, err)
+	}
+	values, err := customfields.Load(ctx, queries, customfields.EntityContact, imported[0].ID)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	for _, v := range values {
+		if v.Field.ID == field.ID && v.Value != "Finance" {
+			t.Errorf("expected the custom field to be imported, got %q", v.Value)
+		}
+	}
+	if others, _ := queries.ListContactsByCustomer(ctx, existing.ID); len(others) != 1 {
+		t.Errorf("expected a contact with the same name under another customer to be imported, got %d", len(others))
+	}
+}
+
+func setupTestDB(t *testing.T) (*sql.DB, *sqlc.Queries, func()) {
+	os.MkdirAll("data", 0755)
+	dbConn, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	cleanup := func() { dbConn.Close() }
+	return dbConn, queries, cleanup
+}
@@ -0,0 +1,345 @@
+// Package lifecycle moves subscriptions between statuses. Only the transitions listed in transitions are allowed, and
+// each change either takes effect straight away or is scheduled for a later day, such as pausing from the first of
+// November and resuming from the first of February, when Apply makes it. Every change is kept as the status history of
+// its subscription and recorded in the activity log.
+package lifecycle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// Statuses of a subscription.
+const (
+	Active    = "active"
+	Paused    = "paused"
+	Cancelled = "cancelled"
+)
+
+// Statuses lists every status, in the order they are offered.
+var Statuses = []string{Active, Paused, Cancelled}
+
+// Initial lists the statuses a subscription can be added with.
+var Initial = []string{Active, Paused}
+
+// transitions lists the statuses each status can change to. Cancelling is final, so a cancelled subscription is
+// replaced with a new one rather than revived.
+var transitions = map[string][]string{
+	Active: {Paused, Cancelled},
+	Paused: {Active, Cancelled},
+}
+
+// ErrTransition is returned for status changes that are not allowed.
+var ErrTransition = errors.New("status change not allowed")
+
+// Check returns an error when a subscription cannot change from one status to another.
+func Check(from, to string) error {
+	switch {
+	case slices.Contains(transitions[from], to):
+		return nil
+	case from == to:
+		return fmt.Errorf("%w, the subscription is already %s", ErrTransition, to)
+	case from == Cancelled:
+		return fmt.Errorf("%w, cancelled subscriptions cannot be changed", ErrTransition)
+	}
+	return fmt.Errorf("%w from %s to %s", ErrTransition, from, to)
+}
+
+// Next lists the statuses a subscription can change to from a status.
+func Next(status string) []string {
+	return slices.Clone(transitions[status])
+}
+
+// CheckInitial returns an error when a subscription cannot be added with a status.
+func CheckInitial(status string) error {
+	if !slices.Contains(Initial, status) {
+		return fmt.Errorf("%w, new subscriptions must be active or paused", ErrTransition)
+	}
+	return nil
+}
+
+// Change is a status change to make.
+type Change struct {
+	To string
+	// Effective is the day the change takes effect. Changes effective today or earlier are made straight away.
+	Effective time.Time
+	Note      string
+}
+
+// Manager makes status changes.
+type Manager struct {
+	store   *sql.DB
+	queries *db.Queries
+	now     func() time.Time
+}
+
+// New creates a manager. The store is needed to make each change in a single transaction, and now tells which
+// scheduled changes have come due.
+func New(store *sql.DB, queries *db.Queries, now func() time.Time) *Manager {
+	return &Manager{store: store, queries: queries, now: now}
+}
+
+// Start records the status a new subscription was added with as the beginning of its history.
+func (m *Manager) Start(ctx context.Context, sub db.Subscription) error {
+	_, err := m.queries.CreateStatusChange(ctx, db.CreateStatusChangeParams{
+		SubscriptionID: sub.ID,
+		ToStatus:       sub.Status,
+		EffectiveDate:  billing.Day(sub.StartDate),
+		CreatedBy:      actor(ctx),
+		AppliedAt:      sql.NullTime{Time: m.now().UTC(), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("error recording initial status: %w", err)
+	}
+	return nil
+}
+
+// Schedule makes or schedules changes to the status of a subscription, after checking that each is allowed following
+// the changes already pending. Either every change is scheduled or none are. Changes that are already effective are
+// then made, and the subscription is returned as it is afterwards.
+func (m *Manager) Schedule(ctx context.Context, sub db.Subscription, changes ...Change) (db.Subscription, error) {
+	for _, c := range changes {
+		if c.Effective.IsZero() {
+			return sub, fmt.Errorf("status changes need an effective date")
+		}
+	}
+
+	// the changes are checked inside the transaction, so that two schedules made at once cannot both pass against the
+	// same pending changes
+	tx, err := m.store.BeginTx(ctx, nil)
+	if err != nil {
+		return sub, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := m.queries.WithTx(tx)
+
+	current, err := qtx.GetSubscription(ctx, sub.ID)
+	if err != nil {
+		return sub, fmt.Errorf("error loading subscription: %w", err)
+	}
+	planned, err := pending(ctx, qtx, sub.ID)
+	if err != nil {
+		return sub, err
+	}
+	for _, c := range changes {
+		planned = append(planned, db.SubscriptionStatusChange{ToStatus: c.To, EffectiveDate: billing.Day(c.Effective)})
+	}
+	if err := walk(current.Status, planned); err != nil {
+		return sub, err
+	}
+
+	var scheduled []db.SubscriptionStatusChange
+	for _, c := range changes {
+		change, err := qtx.CreateStatusChange(ctx, db.CreateStatusChangeParams{
+			SubscriptionID: sub.ID,
+			ToStatus:       c.To,
+			EffectiveDate:  billing.Day(c.Effective),
+			Note:           sql.NullString{String: c.Note, Valid: c.Note != ""},
+			CreatedBy:      actor(ctx),
+		})
+		if err != nil {
+			return sub, fmt.Errorf("error scheduling status change: %w", err)
+		}
+		scheduled = append(scheduled, change)
+	}
+	if err := tx.Commit(); err != nil {
+		return sub, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	for _, change := range scheduled {
+		if m.due(change) {
+			continue
+		}
+		al.LogSubscriptionStatusScheduled(ctx, m.queries, sub, change.ToStatus, change.EffectiveDate)
+	}
+	if _, err := m.apply(ctx, sub.ID); err != nil {
+		return sub, err
+	}
+	return m.queries.GetSubscription(ctx, sub.ID)
+}
+
+// Withdraw removes a pending status change, as long as the changes after it are still allowed without it.
+func (m *Manager) Withdraw(ctx context.Context, sub db.Subscription, changeID uuid.UUID) error {
+	tx, err := m.store.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := m.queries.WithTx(tx)
+
+	current, err := qtx.GetSubscription(ctx, sub.ID)
+	if err != nil {
+		return fmt.Errorf("error loading subscription: %w", err)
+	}
+	changes, err := pending(ctx, qtx, sub.ID)
+	if err != nil {
+		return err
+	}
+	remaining := slices.DeleteFunc(changes, func(c db.SubscriptionStatusChange) bool { return c.ID == changeID })
+	if err := walk(current.Status, remaining); err != nil {
+		return fmt.Errorf("later changes depend on this one, withdraw them first: %w", err)
+	}
+
+	change, err := qtx.WithdrawStatusChange(ctx, db.WithdrawStatusChangeParams{ID: changeID, SubscriptionID: sub.ID})
+	if err != nil {
+		return fmt.Errorf("error withdrawing status change: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	al.LogSubscriptionStatusWithdrawn(ctx, m.queries, sub, change.ToStatus, change.EffectiveDate)
+	return nil
+}
+
+// Apply makes every scheduled change that has come due, in the order they take effect. A change that is no longer
+// allowed, such as resuming a subscription that was cancelled in the meantime, is skipped and kept with the reason.
+func (m *Manager) Apply(ctx context.Context) (int, error) {
+	return m.apply(ctx, uuid.Nil)
+}
+
+// apply makes the due changes of a subscription, or of every subscription when subscriptionID is uuid.Nil.
+func (m *Manager) apply(ctx context.Context, subscriptionID uuid.UUID) (int, error) {
+	pending, err := m.queries.ListPendingStatusChanges(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error loading pending status changes: %w", err)
+	}
+	sortChanges(pending)
+
+	applied := 0
+	var errs []error
+	for _, change := range pending {
+		if !m.due(change) || (subscriptionID != uuid.Nil && change.SubscriptionID != subscriptionID) {
+			continue
+		}
+		ok, err := m.make(ctx, change)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			applied++
+		}
+	}
+	if applied > 0 {
+		slog.Info("Applied subscription status changes", "count", applied)
+	}
+	return applied, errors.Join(errs...)
+}
+
+// make makes a single change in a transaction, reporting whether it was applied rather than skipped.
+func (m *Manager) make(ctx context.Context, change db.SubscriptionStatusChange) (bool, error) {
+	tx, err := m.store.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := m.queries.WithTx(tx)
+
+	complete := db.CompleteStatusChangeParams{ID: change.ID, AppliedAt: sql.NullTime{Time: m.now().UTC(), Valid: true}}
+	before, err := qtx.GetSubscription(ctx, change.SubscriptionID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		complete.SkippedReason = sql.NullString{String: "the subscription has been deleted", Valid: true}
+	case err != nil:
+		return false, fmt.Errorf("error loading subscription %s: %w", change.SubscriptionID, err)
+	default:
+		complete.FromStatus = sql.NullString{String: before.Status, Valid: true}
+		if err := Check(before.Status, change.ToStatus); err != nil {
+			complete.SkippedReason = sql.NullString{String: err.Error(), Valid: true}
+		}
+	}
+
+	var after db.Subscription
+	if !complete.SkippedReason.Valid {
+		// status changes leave the version alone so that they do not conflict with edits to the rest of the subscription
+		after, err = qtx.SetSubscriptionStatus(ctx, db.SetSubscriptionStatusParams{Status: change.ToStatus, ID: change.SubscriptionID})
+		if err != nil {
+			return false, fmt.Errorf("error changing status of subscription %s: %w", change.SubscriptionID, err)
+		}
+	}
+	if err := qtx.CompleteStatusChange(ctx, complete); err != nil {
+		return false, fmt.Errorf("error completing status change: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	if complete.SkippedReason.Valid {
+		slog.Warn("Skipped subscription status change", "subscription_id", change.SubscriptionID, "to", change.ToStatus, "reason", complete.SkippedReason.String)
+		if before.ID != uuid.Nil {
+			al.LogSubscriptionStatusSkipped(ctx, m.queries, before, change.ToStatus, complete.SkippedReason.String)
+		}
+		return false, nil
+	}
+	al.LogSubscriptionStatusChanged(ctx, m.queries, before, after, change.EffectiveDate)
+	return true, nil
+}
+
+// pending lists the changes of a subscription still to be made, in the order they take effect.
+func pending(ctx context.Context, queries *db.Queries, subscriptionID uuid.UUID) ([]db.SubscriptionStatusChange, error) {
+	all, err := queries.ListPendingStatusChanges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading pending status changes: %w", err)
+	}
+	var pending []db.SubscriptionStatusChange
+	for _, c := range all {
+		if c.SubscriptionID == subscriptionID {
+			pending = append(pending, c)
+		}
+	}
+	sortChanges(pending)
+	return pending, nil
+}
+
+// due reports whether a change takes effect on or before today. Both sides are cut to a day first, so that a server
+// away from UTC makes changes on the day they are dated rather than when that day starts in UTC.
+func (m *Manager) due(change db.SubscriptionStatusChange) bool {
+	return !billing.Day(change.EffectiveDate).After(billing.Day(m.now()))
+}
+
+// walk checks that changes can be made one after another, in the order they take effect, from a status.
+func walk(status string, changes []db.SubscriptionStatusChange) error {
+	changes = slices.Clone(changes)
+	sortChanges(changes)
+	for _, c := range changes {
+		if err := Check(status, c.ToStatus); err != nil {
+			return fmt.Errorf("%w (the change on %s)", err, c.EffectiveDate.Format("Jan 2, 2006"))
+		}
+		status = c.ToStatus
+	}
+	return nil
+}
+
+// sortChanges orders changes by the day they take effect. Changes on the same day keep their order, which is the
+// order they were scheduled in.
+func sortChanges(changes []db.SubscriptionStatusChange) {
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].EffectiveDate.Before(changes[j].EffectiveDate) })
+}
+
+func actor(ctx context.Context) sql.NullString {
+	user := al.Actor(ctx)
+	return sql.NullString{String: user, Valid: user != ""}
+}
+
+// EndOfTerm returns the day the current term of a subscription ends, which is its end date when it has one still to
+// come and otherwise the next anniversary of its start by its term. It is the zero time when the term is not known.
+func EndOfTerm(sub db.Subscription, now time.Time) time.Time {
+	today := billing.Day(now)
+	if sub.EndDate.Valid && !billing.Day(sub.EndDate.Time).Before(today) {
+		return billing.Day(sub.EndDate.Time)
+	}
+	// a term ending today has already been entered into, so the next one is after today
+	return billing.Schedule{Start: sub.StartDate, Cadence: sub.Term}.Next(today.AddDate(0, 0, 1))
+}
@@ -0,0 +1,183 @@
+package lifecycle
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+)
+
+func date(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		from, to string
+		allowed  bool
+	}{
+		{Active, Paused, true},
+		{Active, Cancelled, true},
+		{Paused, Active, true},
+		{Paused, Cancelled, true},
+		{Active, Active, false},
+		{Cancelled, Active, false},
+		{Cancelled, Paused, false},
+		{Active, "archived", false},
+	}
+	for _, tt := range tests {
+		err := Check(tt.from, tt.to)
+		if (err == nil) != tt.allowed || (err != nil && !errors.Is(err, ErrTransition)) {
+			t.Errorf("Check(%q, %q) = %v, want allowed %v", tt.from, tt.to, err, tt.allowed)
+		}
+	}
+	if CheckInitial(Cancelled) == nil || CheckInitial(Paused) != nil {
+		t.Error("expected subscriptions to be added active or paused only")
+	}
+}
+
+func TestEndOfTerm(t *testing.T) {
+	sub := sqlc.Subscription{StartDate: date(2025, 3, 15), Term: "yearly"}
+	if got := EndOfTerm(sub, date(2026, 3, 15)); !got.Equal(date(2027, 3, 15)) {
+		t.Errorf("expected the term entered today to end next year, got %s", got)
+	}
+	sub.Term = "monthly"
+	if got := EndOfTerm(sub, date(2026, 3, 20)); !got.Equal(date(2026, 4, 15)) {
+		t.Errorf("expected the monthly term to end on the 15th, got %s", got)
+	}
+	sub.EndDate = sql.NullTime{Time: date(2026, 4, 1), Valid: true}
+	if got := EndOfTerm(sub, date(2026, 3, 20)); !got.Equal(date(2026, 4, 1)) {
+		t.Errorf("expected the end date, got %s", got)
+	}
+}
+
+func TestDue(t *testing.T) {
+	change := sqlc.SubscriptionStatusChange{ToStatus: Paused, EffectiveDate: date(2026, 11, 1)}
+	tests := []struct {
+		name string
+		now  time.Time
+		due  bool
+	}{
+		// early on Nov 1 in Auckland is still Oct 31 in UTC
+		{"morning of the day ahead of UTC", time.Date(2026, 11, 1, 8, 0, 0, 0, time.FixedZone("NZDT", 13*60*60)), true},
+		{"evening before ahead of UTC", time.Date(2026, 10, 31, 23, 0, 0, 0, time.FixedZone("NZDT", 13*60*60)), false},
+		// late on Oct 31 in Honolulu is already Nov 1 in UTC
+		{"evening before behind UTC", time.Date(2026, 10, 31, 20, 0, 0, 0, time.FixedZone("HST", -10*60*60)), false},
+		{"evening of the day behind UTC", time.Date(2026, 11, 1, 20, 0, 0, 0, time.FixedZone("HST", -10*60*60)), true},
+	}
+	for _, tt := range tests {
+		m := &Manager{now: func() time.Time { return tt.now }}
+		if got := m.due(change); got != tt.due {
+			t.Errorf("%s: expected due to be %v, got %v", tt.name, tt.due, got)
+		}
+	}
+}
+
+func TestScheduleAndApply(t *testing.T) {
+	os.MkdirAll("data", 0755)
+	store, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "Lifecycle", Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	sub, err := queries.CreateSubscription(ctx, sqlc.CreateSubscriptionParams{
+		CustomerID:     customer.ID,
+		Description:    "Hosting",
+		Amount:         1000,
+		Quantity:       1,
+		UnitPrice:      1000,
+		Term:           "yearly",
+		BillingCadence: "monthly",
+		Status:         Active,
+		StartDate:      date(2026, 1, 1),
+		Currency:       "NZD",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	now := date(2026, 10, 20)
+	m := New(store, queries, func() time.Time { return now })
+	if err := m.Start(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+
+	// pause from the first of November and resume from the first of February
+	sub, err = m.Schedule(ctx, sub,
+		Change{To: Paused, Effective: date(2026, 11, 1), Note: "Seasonal"},
+		Change{To: Active, Effective: date(2027, 2, 1)},
+	)
+	if err != nil || sub.Status != Active {
+		t.Fatalf("expected the subscription to stay active until November, got %q (%v)", sub.Status, err)
+	}
+	if _, err := m.Schedule(ctx, sub, Change{To: Cancelled, Effective: date(2026, 12, 1)}); !errors.Is(err, ErrTransition) {
+		t.Errorf("expected cancelling before the scheduled resume to be rejected, got %v", err)
+	}
+	changes, _ := pending(ctx, queries, sub.ID)
+	if len(changes) != 2 {
+		t.Fatalf("expected two pending changes, got %d", len(changes))
+	}
+	if err := m.Withdraw(ctx, sub, changes[0].ID); !errors.Is(err, ErrTransition) {
+		t.Errorf("expected withdrawing the pause while the resume depends on it to be rejected, got %v", err)
+	}
+
+	now = date(2026, 11, 2)
+	if n, err := m.Apply(ctx); err != nil || n != 1 {
+		t.Fatalf("expected one change to be applied, got %d (%v)", n, err)
+	}
+	if sub, _ = queries.GetSubscription(ctx, sub.ID); sub.Status != Paused {
+		t.Fatalf("expected the subscription to be paused, got %q", sub.Status)
+	}
+	now = date(2027, 2, 1)
+	if n, _ := m.Apply(ctx); n != 1 {
+		t.Fatalf("expected the resume to be applied, got %d", n)
+	}
+	if sub, _ = queries.GetSubscription(ctx, sub.ID); sub.Status != Active || sub.Version != 1 {
+		t.Fatalf("expected an active subscription with its version unchanged, got %q version %d", sub.Status, sub.Version)
+	}
+
+	// changes effective today are made straight away
+	if sub, err = m.Schedule(ctx, sub, Change{To: Cancelled, Effective: now}); err != nil || sub.Status != Cancelled {
+		t.Fatalf("expected the subscription to be cancelled straight away, got %q (%v)", sub.Status, err)
+	}
+
+	history, err := queries.ListStatusChanges(ctx, sub.ID)
+	if err != nil || len(history) != 4 {
+		t.Fatalf("expected four changes in the history, got %d (%v)", len(history), err)
+	}
+	if history[0].ToStatus != Cancelled || history[0].FromStatus.String != Active || !history[0].AppliedAt.Valid {
+		t.Errorf("unexpected latest change %+v", history[0])
+	}
+	if history[3].FromStatus.Valid || history[3].ToStatus != Active {
+		t.Errorf("expected the history to start with the initial status, got %+v", history[3])
+	}
+
+	// a change that is no longer allowed when it comes due is skipped
+	if _, err := queries.CreateStatusChange(ctx, sqlc.CreateStatusChangeParams{SubscriptionID: sub.ID, ToStatus: Paused, EffectiveDate: now}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := m.Apply(ctx); err != nil || n != 0 {
+		t.Fatalf("expected nothing to be applied, got %d (%v)", n, err)
+	}
+	history, _ = queries.ListStatusChanges(ctx, sub.ID)
+	skipped := 0
+	for _, c := range history {
+		if c.SkippedReason.Valid {
+			skipped++
+		}
+	}
+	if sub, _ = queries.GetSubscription(ctx, sub.ID); sub.Status != Cancelled || skipped != 1 {
+		t.Errorf("expected the pause to be skipped, got %q with %d skipped", sub.Status, skipped)
+	}
+}
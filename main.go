@@ -1,19 +1,26 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
 	"github.com/lmittmann/tint"
 
+	"github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/currency"
 	"github.com/scottmckendry/beam/db"
 	"github.com/scottmckendry/beam/handlers"
+	"github.com/scottmckendry/beam/lifecycle"
 	middlewares "github.com/scottmckendry/beam/middleware"
 	"github.com/scottmckendry/beam/oauth"
+	"github.com/scottmckendry/beam/retention"
+	"github.com/scottmckendry/beam/scheduler"
 )
 
 func main() {
@@ -27,8 +34,47 @@ func main() {
 	}
 	defer dbConn.Close()
 
+	ctx := context.Background()
+
+	// add activity logged before the hash chain existed (or left unsealed by a crash) to the chain
+	if n, err := activitylog.Seal(ctx, queries); err != nil {
+		slog.Error("Failed to seal activity log", "err", err)
+	} else if n > 0 {
+		slog.Info("Sealed activity log entries", "count", n)
+	}
+
+	// Background jobs
+	rules, err := retention.LoadRules(os.Getenv("RETENTION_RULES"))
+	if err != nil {
+		slog.Error("Invalid retention rules", "err", err)
+		os.Exit(1)
+	}
+	if len(rules) > 0 {
+		engine := retention.New(dbConn, queries, rules)
+		go scheduler.Every(ctx, "retention", envDuration("RETENTION_INTERVAL", 24*time.Hour), func(ctx context.Context) error {
+			_, err := engine.Run(ctx)
+			return err
+		})
+	} else {
+		slog.Info("Retention is off, set RETENTION_RULES to enable it")
+	}
+
+	// make scheduled subscription status changes as they come due
+	statusChanges := lifecycle.New(dbConn, queries, time.Now)
+	go scheduler.Every(ctx, "lifecycle", envDuration("LIFECYCLE_INTERVAL", time.Hour), func(ctx context.Context) error {
+		_, err := statusChanges.Apply(ctx)
+		return err
+	})
+
 	auth := oauth.New(queries)
-	h := handlers.New(queries, auth)
+	auth.OnSignIn = func(ctx context.Context, githubID string) {
+		activitylog.LogUserSignedIn(ctx, queries, githubID)
+	}
+	h := handlers.New(dbConn, queries, auth)
+	if h.ReportingCurrency, err = currency.Parse(os.Getenv("REPORTING_CURRENCY")); err != nil {
+		slog.Error("Invalid reporting currency", "err", err)
+		os.Exit(1)
+	}
 
 	r := chi.NewRouter()
 
@@ -42,6 +88,8 @@ func main() {
 	// Public routes
 	r.Get("/login", h.HandleLogin)
 	r.Get("/logout", h.HandleLogout)
+	h.RegisterCardDAVRoutes(r)
+	h.RegisterCalendarRoutes(r)
 
 	// Static file server for public assets
 	r.Handle("/public/*", http.StripPrefix("/public/", http.FileServer(http.Dir("public"))))
@@ -56,11 +104,21 @@ func main() {
 			admin.Use(middlewares.Admin(auth))
 			h.RegisterRootRoutes(admin)
 			h.RegisterInvoiceRoutes(admin)
+			h.RegisterMarginRoutes(admin)
 			h.RegisterDashboardRoutes(admin)
 			h.RegisterCustomerRoutes(admin)
 			h.RegisterContactRoutes(admin)
 			h.RegisterSubscriptionRoutes(admin)
 			h.RegisterProjectRoutes(admin)
+			h.RegisterActivityRoutes(admin)
+			h.RegisterSearchRoutes(admin)
+			h.RegisterNavigationRoutes(admin)
+			h.RegisterSettingsRoutes(admin)
+			h.RegisterFilterRoutes(admin)
+			h.RegisterImportRoutes(admin)
+			h.RegisterExportRoutes(admin)
+			h.RegisterVCardRoutes(admin)
+			h.RegisterStreamRoutes(admin)
 		})
 
 		// Final catch-all for authenticated routes
@@ -90,3 +148,17 @@ func initLogger() {
 	slog.SetDefault(newLogger(true))
 	slog.Info("Logger initialized", "format", os.Getenv("LOG_FORMAT"))
 }
+
+// envDuration reads a Go duration from the environment, falling back to def when unset or invalid.
+func envDuration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Invalid duration in environment, using default", "key", key, "value", value, "default", def)
+		return def
+	}
+	return d
+}
@@ -0,0 +1,236 @@
+// Package margins works out what resold subscriptions make over what their vendors charge for them. The cost of a
+// subscription is in its own currency and charged on a cadence of its own, so prices and costs are compared as
+// monthly amounts, letting a licence bought yearly be set against a subscription billed monthly. Subscriptions without
+// a cost cadence have no cost recorded and are left out of margins, rather than being counted as pure profit.
+package margins
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/currency"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/discounts"
+	"github.com/scottmckendry/beam/lifecycle"
+	"github.com/scottmckendry/beam/money"
+)
+
+// Cadences lists the cadences a vendor can charge on, in the order they are offered. Vendors do not charge on custom
+// cadences.
+var Cadences = []string{billing.Weekly, billing.Monthly, billing.Quarterly, billing.SemiAnnual, billing.Yearly}
+
+// CheckCost returns an error for a cost that cannot be recorded. A blank cadence records no cost, so it needs an
+// amount of nothing.
+func CheckCost(amount money.Amount, cadence sql.NullString) error {
+	if amount < 0 {
+		return fmt.Errorf("a cost cannot be less than nothing")
+	}
+	if !cadence.Valid {
+		if amount != 0 {
+			return fmt.Errorf("choose how often the vendor charges the cost")
+		}
+		return nil
+	}
+	if !slices.Contains(Cadences, cadence.String) {
+		return fmt.Errorf("invalid cost cadence %q, expected one of %s", cadence.String, strings.Join(Cadences, ", "))
+	}
+	return nil
+}
+
+// ChangedOn returns the day to record that the cost of a subscription last changed, which is the day of now when the
+// amount or cadence differs from before and otherwise the day already recorded. New subscriptions are passed with a
+// zero before.
+func ChangedOn(before db.Subscription, amount money.Amount, cadence sql.NullString, now time.Time) sql.NullTime {
+	if amount == before.CostAmount && cadence == before.CostCadence {
+		return before.CostChangedOn
+	}
+	if !cadence.Valid {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: billing.Day(now), Valid: true}
+}
+
+// Costed reports whether a subscription has a cost recorded.
+func Costed(sub db.Subscription) bool {
+	return sub.CostCadence.Valid
+}
+
+// MonthlyCost returns what the vendor of a subscription charges for it each month.
+func MonthlyCost(sub db.Subscription) money.Amount {
+	if !Costed(sub) {
+		return 0
+	}
+	return billing.Schedule{Cadence: sub.CostCadence.String}.Monthly(sub.CostAmount)
+}
+
+// Margin is what is made each month, as revenue less cost in a single currency.
+type Margin struct {
+	Revenue  money.Amount
+	Cost     money.Amount
+	Currency string
+}
+
+// Profit returns the revenue left over after the cost, which is negative when the cost is more.
+func (m Margin) Profit() money.Amount {
+	return m.Revenue - m.Cost
+}
+
+// Percent returns the profit as a percentage of the revenue, or zero when there is no revenue.
+func (m Margin) Percent() float64 {
+	if m.Revenue == 0 {
+		return 0
+	}
+	return float64(m.Profit()) / float64(m.Revenue) * 100
+}
+
+// Label describes the margin, such as "NZ$30.00 a month (30.0%)".
+func (m Margin) Label() string {
+	if m.Revenue == 0 {
+		return fmt.Sprintf("%s a month", money.New(m.Profit(), m.Currency))
+	}
+	return fmt.Sprintf("%s a month (%.1f%%)", money.New(m.Profit(), m.Currency), m.Percent())
+}
+
+// Of returns the margin of a subscription on a day, from its monthly recurring revenue after its trial and discounts.
+// Discounts of other subscriptions are ignored, so the discounts of many subscriptions can be passed together.
+func Of(sub db.Subscription, given []db.SubscriptionDiscount, on time.Time) Margin {
+	return Margin{Revenue: discounts.Monthly(sub, given, on), Cost: MonthlyCost(sub), Currency: sub.Currency}
+}
+
+// Renewal is what a subscription renews at when its current term ends, as monthly amounts.
+type Renewal struct {
+	Subscription db.Subscription
+	On           time.Time
+	Price        money.Amount
+	Cost         money.Amount
+}
+
+// RenewalOf returns what a subscription with a cost renews at, after the discounts that still cover the day it
+// renews. It is false for subscriptions without a cost, and for those with an end date, which do not renew.
+func RenewalOf(sub db.Subscription, given []db.SubscriptionDiscount, now time.Time) (Renewal, bool) {
+	if !Costed(sub) || sub.EndDate.Valid {
+		return Renewal{}, false
+	}
+	schedule := billing.FromSubscription(sub)
+	on := lifecycle.EndOfTerm(sub, now)
+	if on.IsZero() {
+		// subscriptions without a known term are renewed by each bill
+		on = schedule.Next(now)
+	}
+	if on.IsZero() {
+		return Renewal{}, false
+	}
+	return Renewal{
+		Subscription: sub,
+		On:           on,
+		Price:        schedule.Monthly(discounts.Charge(sub, given, on)),
+		Cost:         MonthlyCost(sub),
+	}, true
+}
+
+// Covered reports whether the renewal price covers the cost.
+func (r Renewal) Covered() bool {
+	return r.Price >= r.Cost
+}
+
+// Describe explains a renewal that does not cover its cost, such as "Hosting renews on Mar 1, 2027 at NZ$90.00 a
+// month, below its vendor cost of NZ$100.00 a month since Oct 1, 2026".
+func (r Renewal) Describe() string {
+	message := fmt.Sprintf("%s renews on %s at %s a month, below its vendor cost of %s a month", r.Subscription.Description, r.On.Format("Jan 2, 2006"), money.New(r.Price, r.Subscription.Currency), money.New(r.Cost, r.Subscription.Currency))
+	if r.Subscription.CostChangedOn.Valid {
+		message += " since " + r.Subscription.CostChangedOn.Time.Format("Jan 2, 2006")
+	}
+	return message
+}
+
+// Row is the margin of a customer's active subscriptions with a cost, in the reporting currency.
+type Row struct {
+	Customer      db.Customer
+	Subscriptions int
+	Margin        Margin
+	// Missing lists the currencies left out of the margin for want of an exchange rate.
+	Missing []string
+	// Flagged lists the subscriptions whose renewal price no longer covers their cost.
+	Flagged []Renewal
+}
+
+// Report is the margin of every customer with active subscriptions that have a cost.
+type Report struct {
+	// Rows are the customers, those with flagged renewals first and then the lowest margin first.
+	Rows    []Row
+	Total   Margin
+	Missing []string
+	// Uncosted is the number of active subscriptions without a cost, which are left out of the report.
+	Uncosted int
+	Flagged  []Renewal
+}
+
+// Build reports the margins of the customers' active subscriptions on the day of now, converting each into the
+// converter's currency.
+func Build(customers []db.Customer, subscriptions []db.Subscription, given []db.SubscriptionDiscount, conv *currency.Converter, now time.Time) Report {
+	report := Report{Total: Margin{Currency: conv.Currency()}}
+	byID := make(map[uuid.UUID]db.Customer, len(customers))
+	for _, c := range customers {
+		byID[c.ID] = c
+	}
+	rows := make(map[uuid.UUID]int)
+	for _, s := range subscriptions {
+		c, known := byID[s.CustomerID]
+		if !known || s.Status != lifecycle.Active {
+			continue
+		}
+		if !Costed(s) {
+			report.Uncosted++
+			continue
+		}
+		i, ok := rows[c.ID]
+		if !ok {
+			i = len(report.Rows)
+			rows[c.ID] = i
+			report.Rows = append(report.Rows, Row{Customer: c, Margin: Margin{Currency: conv.Currency()}})
+		}
+		row := &report.Rows[i]
+		row.Subscriptions++
+		m := Of(s, given, now)
+		revenue, err := conv.Convert(m.Revenue, s.Currency, now)
+		if err == nil {
+			var cost money.Amount
+			cost, err = conv.Convert(m.Cost, s.Currency, now)
+			row.Margin.Revenue += revenue
+			row.Margin.Cost += cost
+		}
+		if err != nil && !slices.Contains(row.Missing, s.Currency) {
+			row.Missing = append(row.Missing, s.Currency)
+		}
+		if r, ok := RenewalOf(s, given, now); ok && !r.Covered() {
+			row.Flagged = append(row.Flagged, r)
+		}
+	}
+
+	for _, row := range report.Rows {
+		report.Total.Revenue += row.Margin.Revenue
+		report.Total.Cost += row.Margin.Cost
+		for _, code := range row.Missing {
+			if !slices.Contains(report.Missing, code) {
+				report.Missing = append(report.Missing, code)
+			}
+		}
+		report.Flagged = append(report.Flagged, row.Flagged...)
+	}
+	slices.SortStableFunc(report.Flagged, func(a, b Renewal) int { return a.On.Compare(b.On) })
+	sort.SliceStable(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if (len(a.Flagged) > 0) != (len(b.Flagged) > 0) {
+			return len(a.Flagged) > 0
+		}
+		return a.Margin.Percent() < b.Margin.Percent()
+	})
+	return report
+}
@@ -0,0 +1,201 @@
+package margins
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/currency"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/discounts"
+	"github.com/scottmckendry/beam/lifecycle"
+	"github.com/scottmckendry/beam/money"
+)
+
+func date(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func cadence(c string) sql.NullString {
+	return sql.NullString{String: c, Valid: true}
+}
+
+// resold returns an active NZD subscription billed monthly on a yearly term from Jan 1, 2026.
+func resold(customerID uuid.UUID, amount, cost money.Amount, costCadence string) sqlc.Subscription {
+	return sqlc.Subscription{
+		ID:             uuid.New(),
+		CustomerID:     customerID,
+		Description:    "Licences",
+		Amount:         amount,
+		Currency:       "NZD",
+		Term:           billing.Yearly,
+		BillingCadence: billing.Monthly,
+		Status:         lifecycle.Active,
+		StartDate:      date(2026, 1, 1),
+		CostAmount:     cost,
+		CostCadence:    sql.NullString{String: costCadence, Valid: costCadence != ""},
+	}
+}
+
+func TestCheckCost(t *testing.T) {
+	if err := CheckCost(0, sql.NullString{}); err != nil {
+		t.Errorf("expected no cost to be allowed, got %v", err)
+	}
+	if err := CheckCost(1200, cadence(billing.Yearly)); err != nil {
+		t.Errorf("expected a yearly cost to be allowed, got %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		amount  money.Amount
+		cadence sql.NullString
+	}{
+		{"amount without a cadence", 100, sql.NullString{}},
+		{"negative amount", -100, cadence(billing.Monthly)},
+		{"custom cadence", 100, cadence(billing.Custom)},
+		{"unknown cadence", 100, cadence("daily")},
+	}
+	for _, tt := range tests {
+		if err := CheckCost(tt.amount, tt.cadence); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func TestChangedOn(t *testing.T) {
+	now := date(2026, 10, 19).Add(15 * time.Hour)
+	before := resold(uuid.New(), 10000, 5000, billing.Monthly)
+	before.CostChangedOn = sql.NullTime{Time: date(2026, 1, 1), Valid: true}
+
+	if got := ChangedOn(before, 5000, cadence(billing.Monthly), now); !got.Time.Equal(date(2026, 1, 1)) {
+		t.Errorf("expected an unchanged cost to keep its day, got %v", got)
+	}
+	if got := ChangedOn(before, 6000, cadence(billing.Monthly), now); !got.Valid || !got.Time.Equal(date(2026, 10, 19)) {
+		t.Errorf("expected a changed amount to be stamped today, got %v", got)
+	}
+	if got := ChangedOn(before, 5000, cadence(billing.Yearly), now); !got.Time.Equal(date(2026, 10, 19)) {
+		t.Errorf("expected a changed cadence to be stamped today, got %v", got)
+	}
+	if got := ChangedOn(before, 0, sql.NullString{}, now); got.Valid {
+		t.Errorf("expected a removed cost to clear the day, got %v", got)
+	}
+	if got := ChangedOn(sqlc.Subscription{}, 5000, cadence(billing.Monthly), now); !got.Valid {
+		t.Errorf("expected a new subscription with a cost to be stamped today")
+	}
+	if got := ChangedOn(sqlc.Subscription{}, 0, sql.NullString{}, now); got.Valid {
+		t.Errorf("expected a new subscription without a cost to have no day, got %v", got)
+	}
+}
+
+func TestOf(t *testing.T) {
+	now := date(2026, 10, 19)
+	sub := resold(uuid.New(), 10000, 84000, billing.Yearly)
+	m := Of(sub, nil, now)
+	if m.Revenue != 10000 || m.Cost != 7000 || m.Profit() != 3000 || m.Percent() != 30 {
+		t.Errorf("expected NZ$30.00 of NZ$100.00 a month, got %+v", m)
+	}
+	if !strings.HasPrefix(m.Label(), "NZ$30.00 a month (30.0%)") {
+		t.Errorf("unexpected label %q", m.Label())
+	}
+
+	// discounts come off the revenue but not the cost
+	given := []sqlc.SubscriptionDiscount{{SubscriptionID: sub.ID, Kind: discounts.Percent, Percent: 50, StartsOn: date(2026, 1, 1)}}
+	if m := Of(sub, given, now); m.Profit() != -2000 || m.Percent() != -40 {
+		t.Errorf("expected a discounted subscription to lose NZ$20.00 a month, got %+v", m)
+	}
+
+	// weekly costs are charged 52 times a year
+	if cost := MonthlyCost(resold(uuid.New(), 0, 1200, billing.Weekly)); cost != 5200 {
+		t.Errorf("expected a weekly cost of NZ$12.00 to come to NZ$52.00 a month, got %d", cost)
+	}
+	if cost := MonthlyCost(resold(uuid.New(), 10000, 0, "")); cost != 0 {
+		t.Errorf("expected no cost without a cadence, got %d", cost)
+	}
+}
+
+func TestRenewalOf(t *testing.T) {
+	now := date(2026, 10, 19)
+	sub := resold(uuid.New(), 10000, 11000, billing.Monthly)
+	sub.CostChangedOn = sql.NullTime{Time: date(2026, 10, 1), Valid: true}
+
+	r, ok := RenewalOf(sub, nil, now)
+	if !ok || !r.On.Equal(date(2027, 1, 1)) || r.Price != 10000 || r.Cost != 11000 || r.Covered() {
+		t.Fatalf("expected a renewal on Jan 1, 2027 below cost, got %+v (%v)", r, ok)
+	}
+	if !strings.Contains(r.Describe(), "since Oct 1, 2026") {
+		t.Errorf("expected the description to say when the cost changed, got %q", r.Describe())
+	}
+
+	// a discount that ends before the renewal is not taken off the renewal price
+	sub.CostAmount = 9000
+	given := []sqlc.SubscriptionDiscount{{SubscriptionID: sub.ID, Kind: discounts.Percent, Percent: 20, StartsOn: date(2026, 1, 1), EndsOn: sql.NullTime{Time: date(2026, 12, 31), Valid: true}}}
+	if r, _ := RenewalOf(sub, given, now); !r.Covered() {
+		t.Errorf("expected the renewal price to cover the cost once the discount ends, got %+v", r)
+	}
+	given[0].EndsOn = sql.NullTime{}
+	if r, _ := RenewalOf(sub, given, now); r.Covered() {
+		t.Errorf("expected a lasting discount to take the renewal price below cost, got %+v", r)
+	}
+
+	sub.EndDate = sql.NullTime{Time: date(2026, 12, 31), Valid: true}
+	if _, ok := RenewalOf(sub, nil, now); ok {
+		t.Errorf("expected a subscription with an end date not to renew")
+	}
+	if _, ok := RenewalOf(resold(uuid.New(), 10000, 0, ""), nil, now); ok {
+		t.Errorf("expected a subscription without a cost not to be checked")
+	}
+}
+
+func TestBuild(t *testing.T) {
+	now := date(2026, 10, 19)
+	healthy := sqlc.Customer{ID: uuid.New(), Name: "Healthy"}
+	thin := sqlc.Customer{ID: uuid.New(), Name: "Thin"}
+	losing := sqlc.Customer{ID: uuid.New(), Name: "Losing"}
+	abroad := sqlc.Customer{ID: uuid.New(), Name: "Abroad"}
+
+	paused := resold(healthy.ID, 10000, 1000, billing.Monthly)
+	paused.Status = lifecycle.Paused
+	usd := resold(abroad.ID, 10000, 5000, billing.Monthly)
+	usd.Currency = "USD"
+	subscriptions := []sqlc.Subscription{
+		resold(healthy.ID, 10000, 5000, billing.Monthly),
+		resold(healthy.ID, 20000, 0, ""),
+		paused,
+		resold(thin.ID, 10000, 9000, billing.Monthly),
+		resold(losing.ID, 10000, 12000, billing.Monthly),
+		usd,
+		resold(uuid.New(), 10000, 5000, billing.Monthly),
+	}
+	conv := currency.NewConverter(nil, "NZD")
+
+	report := Build([]sqlc.Customer{healthy, thin, losing, abroad}, subscriptions, nil, conv, now)
+	if len(report.Rows) != 4 {
+		t.Fatalf("expected a row for each customer with a cost, got %d", len(report.Rows))
+	}
+	var names []string
+	for _, row := range report.Rows {
+		names = append(names, row.Customer.Name)
+	}
+	if strings.Join(names, ",") != "Losing,Abroad,Thin,Healthy" {
+		t.Errorf("expected flagged customers first and then the lowest margin, got %v", names)
+	}
+	if len(report.Rows[0].Flagged) != 1 || len(report.Flagged) != 1 {
+		t.Errorf("expected the losing customer to be flagged, got %+v", report.Rows[0].Flagged)
+	}
+	if report.Uncosted != 1 {
+		t.Errorf("expected one subscription without a cost, got %d", report.Uncosted)
+	}
+	if report.Total.Revenue != 30000 || report.Total.Cost != 26000 {
+		t.Errorf("expected NZ$300.00 of revenue against NZ$260.00 of cost, got %+v", report.Total)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != "USD" || len(report.Rows[1].Missing) != 1 {
+		t.Errorf("expected USD to be left out for want of a rate, got %v", report.Missing)
+	}
+	if healthy := report.Rows[3]; healthy.Subscriptions != 1 || healthy.Margin.Percent() != 50 {
+		t.Errorf("expected only the active subscription with a cost to count, got %+v", healthy)
+	}
+}
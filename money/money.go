@@ -0,0 +1,179 @@
+// Package money holds amounts of money exactly, as a whole number of minor units such as cents, rather than as
+// floating point numbers that cannot represent most decimal fractions and drift when summed. Every supported currency
+// has two decimal places. Amounts are only rounded where a calculation can produce a fraction of a minor unit, such as
+// converting between currencies, and are then rounded to the nearest minor unit with halves rounded away from zero.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is an amount of money in minor units.
+type Amount int64
+
+// places is the number of decimal places of every currency, and scale the number of minor units in a major one.
+const (
+	places = 2
+	scale  = 100
+)
+
+// ErrInvalid is returned when an amount cannot be parsed.
+var ErrInvalid = errors.New("invalid amount")
+
+// Parse reads a decimal amount such as "1249.99" or "-5". Amounts with more decimal places than a currency has are
+// rejected rather than rounded, so that what was entered is exactly what is stored.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(strings.TrimPrefix(s, "-"), ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("%w %q", ErrInvalid, s)
+	}
+	if len(fraction) > places {
+		return 0, fmt.Errorf("%w %q, expected at most %d decimal places", ErrInvalid, s, places)
+	}
+	if !digits(whole) || !digits(fraction) {
+		return 0, fmt.Errorf("%w %q", ErrInvalid, s)
+	}
+
+	major := int64(0)
+	if whole != "" {
+		var err error
+		if major, err = strconv.ParseInt(whole, 10, 64); err != nil || major > math.MaxInt64/scale-1 {
+			return 0, fmt.Errorf("%w %q, it is too large", ErrInvalid, s)
+		}
+	}
+	minor := int64(0)
+	if fraction != "" {
+		minor, _ = strconv.ParseInt(fraction+strings.Repeat("0", places-len(fraction)), 10, 64)
+	}
+	a := Amount(major*scale + minor)
+	if negative {
+		a = -a
+	}
+	return a, nil
+}
+
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromMajor converts an amount in major units, such as dollars, rounding it to the nearest minor unit.
+func FromMajor(f float64) Amount {
+	return Amount(math.Round(f * scale))
+}
+
+// Major returns the amount in major units. It is for display and calculations that round their result back with
+// FromMajor, not for sums.
+func (a Amount) Major() float64 {
+	return float64(a) / scale
+}
+
+// Mul multiplies the amount by a factor such as an exchange rate, rounding the result to the nearest minor unit.
+func (a Amount) Mul(factor float64) Amount {
+	return Amount(math.Round(float64(a) * factor))
+}
+
+// String formats the amount as a plain decimal, such as "1249.99".
+func (a Amount) String() string {
+	sign := ""
+	if a < 0 {
+		sign, a = "-", -a
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, int64(a)/scale, places, int64(a)%scale)
+}
+
+// UnmarshalText parses a decimal amount, so that amounts can be read from forms.
+func (a *Amount) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*a = 0
+		return nil
+	}
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// MarshalJSON encodes the amount as an exact decimal number.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON decodes a decimal number.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	return a.UnmarshalText([]byte(strings.Trim(string(data), `"`)))
+}
+
+// Value stores the amount as an integer number of minor units.
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+// Scan reads an amount stored in minor units.
+func (a *Amount) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		*a = Amount(v)
+	case float64:
+		// SQLite hands back whole numbers stored in NUMERIC columns as REAL when they came from a calculation
+		*a = Amount(math.Round(v))
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	case nil:
+		*a = 0
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+	return nil
+}
+
+func (a *Amount) scanString(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into money.Amount: %w", s, err)
+	}
+	*a = Amount(math.Round(f))
+	return nil
+}
+
+// Money is an amount in a currency.
+type Money struct {
+	Amount   Amount
+	Currency string
+}
+
+// New returns an amount in a currency.
+func New(amount Amount, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// symbols tell apart the dollars of each supported currency.
+var symbols = map[string]string{"NZD": "NZ$", "AUD": "A$", "USD": "US$"}
+
+// String formats the amount with the symbol of its currency, or its code for currencies without one.
+func (m Money) String() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	if symbol, ok := symbols[m.Currency]; ok {
+		return sign + symbol + amount.String()
+	}
+	return strings.TrimSpace(sign + amount.String() + " " + m.Currency)
+}
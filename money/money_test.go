@@ -0,0 +1,102 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{"1249.99", 124999, false},
+		{" 5 ", 500, false},
+		{"0.5", 50, false},
+		{".05", 5, false},
+		{"-20.10", -2010, false},
+		{"1.005", 0, true},
+		{"1e3", 0, true},
+		{"12.3.4", 0, true},
+		{"-", 0, true},
+		{"", 0, true},
+		{"99999999999999999999", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q) = %v, %v, want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSumIsExact(t *testing.T) {
+	// ten amounts of 0.10 add up to 0.9999999999999999 as floats
+	var total Amount
+	for range 10 {
+		total += FromMajor(0.1)
+	}
+	if total.String() != "1.00" {
+		t.Errorf("expected 1.00, got %s", total)
+	}
+}
+
+func TestMul(t *testing.T) {
+	tests := []struct {
+		amount Amount
+		factor float64
+		want   Amount
+	}{
+		{1000, 1.5, 1500},
+		{333, 1.5, 500},
+		{-333, 1.5, -500},
+		{100, 1.0 / 3, 33},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Mul(tt.factor); got != tt.want {
+			t.Errorf("%v.Mul(%v) = %v, want %v", tt.amount, tt.factor, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{New(123450, "NZD"), "NZ$1234.50"},
+		{New(-2000, "USD"), "-US$20.00"},
+		{New(-5, "AUD"), "-A$0.05"},
+		{New(300, "EUR"), "3.00 EUR"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	for _, src := range []any{int64(1999), float64(1999), []byte("1999"), "1999.0"} {
+		var a Amount
+		if err := a.Scan(src); err != nil || a != 1999 {
+			t.Errorf("Scan(%#v) = %v, %v", src, a, err)
+		}
+	}
+	var a Amount
+	if err := a.Scan(true); err == nil {
+		t.Error("expected an error scanning a bool")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	encoded, err := json.Marshal(struct{ Amount Amount }{995})
+	if err != nil || string(encoded) != `{"Amount":9.95}` {
+		t.Fatalf("Marshal = %s, %v", encoded, err)
+	}
+	var decoded struct{ Amount Amount }
+	if err := json.Unmarshal(encoded, &decoded); err != nil || decoded.Amount != 995 {
+		t.Errorf("Unmarshal = %+v, %v", decoded, err)
+	}
+}
@@ -23,6 +23,8 @@ type OAuth struct {
 	OauthConfig  *oauth2.Config
 	SecureCookie *securecookie.SecureCookie
 	DB           *db.Queries
+	// OnSignIn, when set, is called with the GitHub login of each user that signs in successfully.
+	OnSignIn func(ctx context.Context, githubID string)
 }
 
 // New creates a new OAuthEnv instance using environment variables for configuration.
@@ -148,6 +150,9 @@ func (env *OAuth) githubCallbackHandler() http.HandlerFunc {
 			GithubID: user.ID,
 		})
 		env.SetSignedCookie(w, "user_name", user.ID)
+		if env.OnSignIn != nil {
+			env.OnSignIn(ctx, user.ID)
+		}
 		http.SetCookie(
 			w,
 			&http.Cookie{Name: "oauth_token", Value: token.AccessToken, Path: "/", HttpOnly: true},
@@ -0,0 +1,215 @@
+// Package pricing keeps the price history of subscriptions, which are priced per seat. Changing the seats, unit price
+// or currency of a subscription is an amendment, which starts a new price period on the day it takes effect instead of
+// overwriting the old price. When that day falls part way through a billing period already billed at the old price,
+// the difference is prorated by day and kept as a charge or credit owed on the next invoice.
+package pricing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/billing"
+	"github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/money"
+)
+
+// ErrEffective is returned for amendments that cannot take effect on the day given.
+var ErrEffective = errors.New("invalid effective date")
+
+// ErrQuantity is returned for subscriptions without at least one seat.
+var ErrQuantity = errors.New("a subscription needs at least one seat")
+
+// Amount is what a number of seats at a unit price comes to.
+func Amount(quantity int64, unitPrice money.Amount) money.Amount {
+	return unitPrice * money.Amount(quantity)
+}
+
+// CheckQuantity returns ErrQuantity when a subscription would have no seats.
+func CheckQuantity(quantity int64) error {
+	if quantity < 1 {
+		return ErrQuantity
+	}
+	return nil
+}
+
+// Amendment is when and why the price of a subscription changes.
+type Amendment struct {
+	// Effective is the day the new price takes effect, which can be backdated but not later than today. The zero time
+	// means today.
+	Effective time.Time
+	Note      string
+}
+
+// Manager records price periods.
+type Manager struct {
+	store   *sql.DB
+	queries *db.Queries
+	now     func() time.Time
+}
+
+// New creates a manager. The store is needed to save an amendment along with its adjustments in a single transaction,
+// and now tells which billing periods have been billed.
+func New(store *sql.DB, queries *db.Queries, now func() time.Time) *Manager {
+	return &Manager{store: store, queries: queries, now: now}
+}
+
+// Start records the price a new subscription was added with as the beginning of its history.
+func (m *Manager) Start(ctx context.Context, sub db.Subscription) error {
+	_, err := m.queries.CreateSubscriptionPrice(ctx, db.CreateSubscriptionPriceParams{
+		SubscriptionID: sub.ID,
+		Amount:         sub.Amount,
+		Currency:       sub.Currency,
+		EffectiveFrom:  billing.Day(sub.StartDate),
+		CreatedBy:      actor(ctx),
+		Quantity:       sub.Quantity,
+		UnitPrice:      sub.UnitPrice,
+	})
+	if err != nil {
+		return fmt.Errorf("error recording initial price: %w", err)
+	}
+	return nil
+}
+
+// Update saves an edit of a subscription, amending its price when the seats, unit price or currency changed. The edit,
+// the new price period and any adjustments are saved together or not at all. Like UpdateSubscription, it returns
+// sql.ErrNoRows when the subscription was saved by someone else since before was loaded.
+func (m *Manager) Update(ctx context.Context, before db.Subscription, params db.UpdateSubscriptionParams, amendment Amendment) (db.Subscription, []db.SubscriptionAdjustment, error) {
+	if params.Quantity == before.Quantity && params.UnitPrice == before.UnitPrice && params.Currency == before.Currency {
+		updated, err := m.queries.UpdateSubscription(ctx, params)
+		return updated, nil, err
+	}
+	effective, err := m.effective(ctx, before, amendment.Effective)
+	if err != nil {
+		return before, nil, err
+	}
+
+	tx, err := m.store.BeginTx(ctx, nil)
+	if err != nil {
+		return before, nil, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := m.queries.WithTx(tx)
+
+	updated, err := qtx.UpdateSubscription(ctx, params)
+	if err != nil {
+		return before, nil, err
+	}
+	price, err := qtx.CreateSubscriptionPrice(ctx, db.CreateSubscriptionPriceParams{
+		SubscriptionID: updated.ID,
+		Amount:         updated.Amount,
+		Currency:       updated.Currency,
+		EffectiveFrom:  effective,
+		Note:           sql.NullString{String: amendment.Note, Valid: amendment.Note != ""},
+		CreatedBy:      actor(ctx),
+		Quantity:       updated.Quantity,
+		UnitPrice:      updated.UnitPrice,
+	})
+	if err != nil {
+		return before, nil, fmt.Errorf("error recording price: %w", err)
+	}
+	var adjustments []db.SubscriptionAdjustment
+	for _, params := range Adjustments(before, price, m.now()) {
+		adjustment, err := qtx.CreateSubscriptionAdjustment(ctx, params)
+		if err != nil {
+			return before, nil, fmt.Errorf("error recording adjustment: %w", err)
+		}
+		adjustments = append(adjustments, adjustment)
+	}
+	if err := tx.Commit(); err != nil {
+		return before, nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	al.LogSubscriptionRepriced(ctx, m.queries, before, updated, effective, adjustments)
+	return updated, adjustments, nil
+}
+
+// effective returns the day an amendment takes effect. It cannot be later than today, so that the amount on the
+// subscription is always its current price, nor earlier than the current price period, so that periods follow one
+// another. A price that has not taken effect yet, such as that of a subscription starting next month, is replaced
+// from the same day instead.
+func (m *Manager) effective(ctx context.Context, sub db.Subscription, effective time.Time) (time.Time, error) {
+	today := billing.Day(m.now())
+	if effective.IsZero() {
+		effective = today
+	}
+	effective = billing.Day(effective)
+	if effective.After(today) {
+		return effective, fmt.Errorf("%w, price changes take effect today or earlier", ErrEffective)
+	}
+	if start := billing.Day(sub.StartDate); effective.Before(start) {
+		effective = start
+	}
+
+	prices, err := m.queries.ListSubscriptionPrices(ctx, sub.ID)
+	if err != nil {
+		return effective, fmt.Errorf("error loading price history: %w", err)
+	}
+	if len(prices) == 0 {
+		return effective, nil
+	}
+	current := billing.Day(prices[0].EffectiveFrom)
+	switch {
+	case !effective.Before(current):
+		return effective, nil
+	case current.After(today):
+		return current, nil
+	}
+	return effective, fmt.Errorf("%w, the price last changed on %s and later changes take effect on or after it", ErrEffective, current.Format("Jan 2, 2006"))
+}
+
+// Adjustments works out what is owed for the billing periods of a subscription that were billed at its old price by
+// now but fall on or after the day a new price took effect. A change of amount is a single charge or credit for the
+// difference, so a seat added half way through a period is charged for half of it. A change of currency credits the
+// old price and charges the new one, as amounts in different currencies cannot be netted off.
+func Adjustments(before db.Subscription, price db.SubscriptionPrice, now time.Time) []db.CreateSubscriptionAdjustmentParams {
+	p := billing.FromSubscription(before).Prorate(price.EffectiveFrom, now)
+	if p.To.IsZero() {
+		return nil
+	}
+	old := money.New(before.Amount, before.Currency)
+	updated := money.New(price.Amount, price.Currency)
+	period := fmt.Sprintf("from %s until %s", p.From.Format("Jan 2, 2006"), p.To.Format("Jan 2, 2006"))
+	adjustment := func(amount money.Amount, currency, description string) db.CreateSubscriptionAdjustmentParams {
+		return db.CreateSubscriptionAdjustmentParams{
+			SubscriptionID: before.ID,
+			PriceID:        price.ID,
+			Amount:         amount,
+			Currency:       currency,
+			Description:    description,
+			PeriodStart:    p.From,
+			PeriodEnd:      p.To,
+		}
+	}
+
+	var adjustments []db.CreateSubscriptionAdjustmentParams
+	if old.Currency == updated.Currency {
+		if amount := (updated.Amount - old.Amount).Mul(p.Fraction); amount != 0 {
+			adjustments = append(adjustments, adjustment(amount, updated.Currency, fmt.Sprintf("Prorated %s for the change from %s to %s, %s", kind(amount), old, updated, period)))
+		}
+		return adjustments
+	}
+	if amount := -old.Amount.Mul(p.Fraction); amount != 0 {
+		adjustments = append(adjustments, adjustment(amount, old.Currency, fmt.Sprintf("Prorated credit of the old price of %s, %s", old, period)))
+	}
+	if amount := updated.Amount.Mul(p.Fraction); amount != 0 {
+		adjustments = append(adjustments, adjustment(amount, updated.Currency, fmt.Sprintf("Prorated charge of the new price of %s, %s", updated, period)))
+	}
+	return adjustments
+}
+
+// kind names an adjustment as a charge or a credit.
+func kind(amount money.Amount) string {
+	if amount < 0 {
+		return "credit"
+	}
+	return "charge"
+}
+
+func actor(ctx context.Context) sql.NullString {
+	user := al.Actor(ctx)
+	return sql.NullString{String: user, Valid: user != ""}
+}
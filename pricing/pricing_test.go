@@ -0,0 +1,131 @@
+package pricing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+	"github.com/scottmckendry/beam/money"
+)
+
+func date(year int, month time.Month, d int) time.Time {
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestAmount(t *testing.T) {
+	if got := Amount(3, 1250); got != 3750 {
+		t.Errorf("expected three seats at 12.50 to come to 37.50, got %s", got)
+	}
+	if CheckQuantity(0) == nil || CheckQuantity(1) != nil {
+		t.Error("expected subscriptions to need at least one seat")
+	}
+}
+
+func TestAdjustments(t *testing.T) {
+	before := sqlc.Subscription{Amount: 3000, Currency: "NZD", StartDate: date(2026, 1, 1), BillingCadence: "monthly"}
+	now := date(2026, 4, 16)
+
+	// half of April at NZ$45 instead of NZ$30
+	got := Adjustments(before, sqlc.SubscriptionPrice{Amount: 4500, Currency: "NZD", EffectiveFrom: now}, now)
+	if len(got) != 1 || got[0].Amount != 750 || !got[0].PeriodEnd.Equal(date(2026, 5, 1)) {
+		t.Errorf("expected a charge of 7.50 until May, got %+v", got)
+	}
+	got = Adjustments(before, sqlc.SubscriptionPrice{Amount: 1500, Currency: "NZD", EffectiveFrom: now}, now)
+	if len(got) != 1 || got[0].Amount != -750 {
+		t.Errorf("expected a credit of 7.50, got %+v", got)
+	}
+	got = Adjustments(before, sqlc.SubscriptionPrice{Amount: 4000, Currency: "AUD", EffectiveFrom: now}, now)
+	if len(got) != 2 || got[0].Amount != -1500 || got[0].Currency != "NZD" || got[1].Amount != 2000 || got[1].Currency != "AUD" {
+		t.Errorf("expected a credit in NZD and a charge in AUD, got %+v", got)
+	}
+
+	// nothing has been billed before a subscription starts
+	before.StartDate = date(2026, 6, 1)
+	if got := Adjustments(before, sqlc.SubscriptionPrice{Amount: 4500, Currency: "NZD", EffectiveFrom: now}, now); len(got) != 0 {
+		t.Errorf("expected no adjustments, got %+v", got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	os.MkdirAll("data", 0755)
+	store, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "Pricing", Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	sub, err := queries.CreateSubscription(ctx, sqlc.CreateSubscriptionParams{
+		CustomerID:     customer.ID,
+		Description:    "Seats",
+		Amount:         3000,
+		Quantity:       2,
+		UnitPrice:      1500,
+		Term:           "yearly",
+		BillingCadence: "monthly",
+		Status:         "active",
+		StartDate:      date(2026, 1, 1),
+		Currency:       "NZD",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	now := date(2026, 4, 16)
+	m := New(store, queries, func() time.Time { return now })
+	if err := m.Start(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+
+	params := sqlc.UpdateSubscriptionParams{
+		ID:             sub.ID,
+		Description:    sub.Description,
+		Amount:         4500,
+		Quantity:       3,
+		UnitPrice:      1500,
+		Term:           sub.Term,
+		BillingCadence: sub.BillingCadence,
+		StartDate:      sub.StartDate,
+		Currency:       sub.Currency,
+		Version:        sub.Version,
+	}
+	if _, _, err := m.Update(ctx, sub, params, Amendment{Effective: date(2026, 5, 1)}); !errors.Is(err, ErrEffective) {
+		t.Errorf("expected a price change after today to be rejected, got %v", err)
+	}
+	// a third seat added half way through April
+	updated, adjustments, err := m.Update(ctx, sub, params, Amendment{Note: "Added a seat"})
+	if err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if updated.Amount != 4500 || updated.Quantity != 3 || len(adjustments) != 1 || adjustments[0].Amount != money.Amount(750) {
+		t.Fatalf("expected the new price with a charge of 7.50, got %s with %+v", updated.Amount, adjustments)
+	}
+
+	// an amendment cannot be backdated before the price it replaces
+	params.Amount, params.Quantity, params.Version = 3000, 2, updated.Version
+	if _, _, err := m.Update(ctx, updated, params, Amendment{Effective: date(2026, 4, 1)}); !errors.Is(err, ErrEffective) {
+		t.Errorf("expected backdating before the last change to be rejected, got %v", err)
+	}
+	// nor saved over a newer version
+	params.Version = sub.Version
+	if _, _, err := m.Update(ctx, updated, params, Amendment{}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected a stale version to be rejected, got %v", err)
+	}
+
+	prices, err := queries.ListSubscriptionPrices(ctx, sub.ID)
+	if err != nil || len(prices) != 2 {
+		t.Fatalf("expected two prices, got %d (%v)", len(prices), err)
+	}
+	if prices[0].Amount != 4500 || !prices[0].EffectiveFrom.Equal(now) || prices[0].Note.String != "Added a seat" || prices[1].Quantity != 2 {
+		t.Errorf("unexpected price history %+v", prices)
+	}
+}
@@ -0,0 +1,232 @@
+// Package retention enforces data retention rules, purging old soft-deleted records
+// and anonymising old activity while keeping the activity history itself intact.
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// uploadsDir is the only location retention will delete files from.
+const uploadsDir = "public/uploads/"
+
+// RuleResult records what a single rule changed during a run.
+type RuleResult struct {
+	Rule     string           `json:"rule"`
+	Cutoff   time.Time        `json:"cutoff"`
+	Affected int64            `json:"affected"`
+	Details  map[string]int64 `json:"details,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// Report summarises a retention run. Every run is persisted to the retention_runs table.
+type Report struct {
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	Results    []RuleResult `json:"results"`
+}
+
+// Affected returns the total number of records changed across all rules.
+func (r Report) Affected() int64 {
+	var total int64
+	for _, result := range r.Results {
+		total += result.Affected
+	}
+	return total
+}
+
+// Engine applies retention rules against the database.
+type Engine struct {
+	store   *sql.DB
+	queries *db.Queries
+	rules   []Rule
+	now     func() time.Time
+}
+
+// New creates a retention engine for the given rules. The store is needed to run each rule in its own transaction.
+func New(store *sql.DB, queries *db.Queries, rules []Rule) *Engine {
+	return &Engine{store: store, queries: queries, rules: rules, now: time.Now}
+}
+
+// Rules returns the rules enforced by the engine.
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// Run applies every rule once and records the outcome. A failing rule is rolled back and reported
+// without stopping the remaining rules; the returned error joins all rule failures.
+func (e *Engine) Run(ctx context.Context) (Report, error) {
+	report := Report{StartedAt: e.now().UTC()}
+	var errs []error
+	for _, rule := range e.rules {
+		cutoff := report.StartedAt.Add(-rule.After)
+		result, files, err := e.apply(ctx, rule, cutoff)
+		if err != nil {
+			result.Error = err.Error()
+			errs = append(errs, fmt.Errorf("%s: %w", rule, err))
+		}
+		report.Results = append(report.Results, result)
+		removeUploads(files)
+	}
+	report.FinishedAt = e.now().UTC()
+
+	encoded, err := json.Marshal(report)
+	if err != nil {
+		return report, fmt.Errorf("failed to encode retention report: %w", err)
+	}
+	if _, err := e.queries.CreateRetentionRun(ctx, db.CreateRetentionRunParams{
+		StartedAt:  report.StartedAt,
+		FinishedAt: report.FinishedAt,
+		Report:     string(encoded),
+	}); err != nil {
+		errs = append(errs, fmt.Errorf("failed to record retention run: %w", err))
+	}
+
+	slog.Info("Retention run finished", "rules", len(e.rules), "affected", report.Affected(), "failed", len(errs))
+	return report, errors.Join(errs...)
+}
+
+// apply enforces a single rule inside a transaction, returning any uploaded files
+// that belonged to purged records so they can be removed once the purge has committed.
+func (e *Engine) apply(ctx context.Context, rule Rule, cutoff time.Time) (RuleResult, []string, error) {
+	result := RuleResult{Rule: rule.String(), Cutoff: cutoff}
+	if err := rule.validate(); err != nil {
+		return result, nil, err
+	}
+
+	tx, err := e.store.BeginTx(ctx, nil)
+	if err != nil {
+		return result, nil, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := e.queries.WithTx(tx)
+
+	var files []string
+	switch rule.Target {
+	case TargetContacts:
+		contacts, err := qtx.PurgeDeletedContacts(ctx, cutoff)
+		if err != nil {
+			return result, nil, err
+		}
+		for _, c := range contacts {
+			files = appendUpload(files, c.Avatar)
+		}
+		result.Affected = int64(len(contacts))
+	case TargetSubscriptions:
+		if _, err := qtx.PurgeStatusChangesOfDeletedSubscriptions(ctx, cutoff); err != nil {
+			return result, nil, err
+		}
+		if _, err := qtx.PurgeAdjustmentsOfDeletedSubscriptions(ctx, cutoff); err != nil {
+			return result, nil, err
+		}
+		if _, err := qtx.PurgePricesOfDeletedSubscriptions(ctx, cutoff); err != nil {
+			return result, nil, err
+		}
+		if _, err := qtx.PurgeDiscountsOfDeletedSubscriptions(ctx, cutoff); err != nil {
+			return result, nil, err
+		}
+		n, err := qtx.PurgeDeletedSubscriptions(ctx, cutoff)
+		if err != nil {
+			return result, nil, err
+		}
+		result.Affected = n
+	case TargetCustomers:
+		// children first, the customer foreign keys do not cascade
+		contacts, err := qtx.PurgeContactsOfDeletedCustomers(ctx, cutoff)
+		if err != nil {
+			return result, nil, err
+		}
+		statusChanges, err := qtx.PurgeStatusChangesOfDeletedCustomers(ctx, cutoff)
+		if err != nil {
+			return result, nil, err
+		}
+		adjustments, err := qtx.PurgeAdjustmentsOfDeletedCustomers(ctx, cutoff)
+		if err != nil {
+			return result, nil, err
+		}
+		prices, err := qtx.PurgePricesOfDeletedCustomers(ctx, cutoff)
+		if err != nil {
+			return result, nil, err
+		}
+		discounts, err := qtx.PurgeDiscountsOfDeletedCustomers(ctx, cutoff)
+		if err != nil {
+			return result, nil, err
+		}
+		productPrices, err := qtx.PurgeProductPricesOfDeletedCustomers(ctx, cutoff)
+		if err != nil {
+			return result, nil, err
+		}
+		subscriptions, err := qtx.PurgeSubscriptionsOfDeletedCustomers(ctx, cutoff)
+		if err != nil {
+			return result, nil, err
+		}
+		customers, err := qtx.PurgeDeletedCustomers(ctx, cutoff)
+		if err != nil {
+			return result, nil, err
+		}
+		for _, c := range contacts {
+			files = appendUpload(files, c.Avatar)
+		}
+		for _, c := range customers {
+			files = appendUpload(files, c.Logo)
+		}
+		result.Affected = int64(len(customers))
+		result.Details = map[string]int64{
+			"contacts":       int64(len(contacts)),
+			"subscriptions":  subscriptions,
+			"status_changes": statusChanges,
+			"prices":         prices,
+			"adjustments":    adjustments,
+			"discounts":      discounts,
+			"product_prices": productPrices,
+		}
+	case TargetActivity:
+		n, err := qtx.AnonymiseActivity(ctx, db.AnonymiseActivityParams{
+			Description: activitylog.AnonymisedDescription,
+			KeptAction:  activitylog.ActionActivityAnonymised,
+			Cutoff:      cutoff,
+		})
+		if err != nil {
+			return result, nil, err
+		}
+		// the chain only accepts anonymised entries that a sealed record of the anonymisation covers
+		if n > 0 {
+			if err := activitylog.LogActivityAnonymised(ctx, qtx, cutoff, n); err != nil {
+				return result, nil, err
+			}
+		}
+		result.Affected = n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return result, nil, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return result, files, nil
+}
+
+// appendUpload adds path to files if it points inside the uploads directory.
+func appendUpload(files []string, path sql.NullString) []string {
+	if !path.Valid || !strings.HasPrefix(path.String, uploadsDir) || strings.Contains(path.String, "..") {
+		return files
+	}
+	return append(files, path.String)
+}
+
+// removeUploads deletes files left behind by purged records.
+func removeUploads(files []string) {
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("Error deleting purged upload", "file", file, "err", err)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+)
+
+func setupTestDB(t *testing.T) (*sql.DB, *sqlc.Queries, func()) {
+	os.MkdirAll("data", 0755)
+	dbConn, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	cleanup := func() { dbConn.Close() }
+	return dbConn, queries, cleanup
+}
+
+func createTestCustomer(t *testing.T, queries *sqlc.Queries) sqlc.Customer {
+	customer, err := queries.CreateCustomer(context.Background(), sqlc.CreateCustomerParams{
+		Name:   "Retention Customer",
+		Status: "active",
+	})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	return customer
+}
+
+func countRows(t *testing.T, store *sql.DB, query string, args ...any) int {
+	var n int
+	if err := store.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("count query failed: %v", err)
+	}
+	return n
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("contacts:purge:90d, activity:anonymise:2y,subscriptions:purge:720h")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Rule{
+		{Target: TargetContacts, Action: ActionPurge, After: 90 * day},
+		{Target: TargetActivity, Action: ActionAnonymise, After: 2 * year},
+		{Target: TargetSubscriptions, Action: ActionPurge, After: 30 * day},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("got %d rules, want %d", len(rules), len(want))
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rule %d: got %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+}
+
+func TestParseRules_Errors(t *testing.T) {
+	cases := []string{
+		"contacts:purge",
+		"widgets:purge:90d",
+		"activity:purge:90d",
+		"contacts:anonymise:90d",
+		"contacts:purge:ninety",
+		"contacts:purge:0d",
+	}
+	for _, tc := range cases {
+		if _, err := ParseRules(tc); err == nil {
+			t.Errorf("ParseRules(%q): expected error", tc)
+		}
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	for _, value := range []string{"", "  ", "off", "None"} {
+		rules, err := LoadRules(value)
+		if err != nil || rules != nil {
+			t.Errorf("%q: got %v, %v, want retention disabled", value, rules, err)
+		}
+	}
+	rules, err := LoadRules("contacts:purge:90d")
+	if err != nil || len(rules) != 1 {
+		t.Errorf("configured rules: got %v, %v, want one rule", rules, err)
+	}
+}
+
+func TestRule_String(t *testing.T) {
+	rules := []Rule{
+		{Target: TargetContacts, Action: ActionPurge, After: 90 * day},
+		{Target: TargetActivity, Action: ActionAnonymise, After: 2 * year},
+	}
+	for _, rule := range rules {
+		parsed, err := ParseRules(rule.String())
+		if err != nil || len(parsed) != 1 || parsed[0] != rule {
+			t.Errorf("round trip of %q: got %v, %v", rule.String(), parsed, err)
+		}
+	}
+}
+
+func TestRun_PurgeContacts_Integration(t *testing.T) {
+	store, queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	customer := createTestCustomer(t, queries)
+	oldContact, err := queries.CreateContact(ctx, sqlc.CreateContactParams{CustomerID: customer.ID, Name: "Old Contact"})
+	if err != nil {
+		t.Fatalf("CreateContact failed: %v", err)
+	}
+	recentContact, err := queries.CreateContact(ctx, sqlc.CreateContactParams{CustomerID: customer.ID, Name: "Recent Contact"})
+	if err != nil {
+		t.Fatalf("CreateContact failed: %v", err)
+	}
+	activitylog.LogContactDeleted(ctx, queries, customer.ID, oldContact.Name)
+	queries.DeleteContact(ctx, oldContact.ID)
+	queries.DeleteContact(ctx, recentContact.ID)
+	store.Exec("UPDATE contacts SET deleted_at = datetime('now', '-100 days') WHERE id = ?", oldContact.ID)
+
+	engine := New(store, queries, []Rule{{Target: TargetContacts, Action: ActionPurge, After: 90 * day}})
+	report, err := engine.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(report.Results) != 1 || report.Results[0].Affected < 1 {
+		t.Errorf("expected purged contacts in report, got %+v", report)
+	}
+	if n := countRows(t, store, "SELECT COUNT(*) FROM contacts WHERE id = ?", oldContact.ID); n != 0 {
+		t.Error("old soft-deleted contact was not purged")
+	}
+	if n := countRows(t, store, "SELECT COUNT(*) FROM contacts WHERE id = ?", recentContact.ID); n != 1 {
+		t.Error("recently deleted contact should be kept")
+	}
+	if n := countRows(t, store, "SELECT COUNT(*) FROM activity_log WHERE customer_id = ?", customer.ID); n == 0 {
+		t.Error("activity history should survive the purge")
+	}
+
+	runs, err := queries.ListRetentionRuns(ctx, 1)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("expected a recorded retention run, got %v, %v", runs, err)
+	}
+}
+
+func TestRun_PurgeCustomers_Integration(t *testing.T) {
+	store, queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	customer := createTestCustomer(t, queries)
+	if _, err := queries.CreateContact(ctx, sqlc.CreateContactParams{CustomerID: customer.ID, Name: "Contact"}); err != nil {
+		t.Fatalf("CreateContact failed: %v", err)
+	}
+	if _, err := queries.CreateSubscription(ctx, sqlc.CreateSubscriptionParams{
+		CustomerID:     customer.ID,
+		Description:    "Hosting",
+		Amount:         10,
+		Quantity:       1,
+		UnitPrice:      10,
+		Term:           "monthly",
+		BillingCadence: "monthly",
+		Status:         "active",
+		StartDate:      time.Now(),
+	}); err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+	deleted, _ := queries.DeleteCustomer(ctx, customer.ID)
+	activitylog.LogCustomerDeleted(ctx, queries, deleted)
+	store.Exec("UPDATE customers SET deleted_at = datetime('now', '-400 days') WHERE id = ?", customer.ID)
+
+	engine := New(store, queries, []Rule{{Target: TargetCustomers, Action: ActionPurge, After: year}})
+	report, err := engine.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	result := report.Results[0]
+	if result.Affected < 1 || result.Details["contacts"] < 1 || result.Details["subscriptions"] < 1 {
+		t.Errorf("expected customer, contacts and subscriptions in report, got %+v", result)
+	}
+	if n := countRows(t, store, "SELECT COUNT(*) FROM customers WHERE id = ?", customer.ID); n != 0 {
+		t.Error("customer was not purged")
+	}
+	if n := countRows(t, store, "SELECT COUNT(*) FROM activity_log WHERE customer_id = ?", customer.ID); n == 0 {
+		t.Error("activity history should survive the customer purge")
+	}
+}
+
+func TestRun_AnonymiseActivity_Integration(t *testing.T) {
+	store, queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	customer := createTestCustomer(t, queries)
+	activitylog.LogCustomerCreated(ctx, queries, customer)
+	store.Exec("UPDATE activity_log SET created_at = datetime('now', '-3 years') WHERE customer_id = ?", customer.ID)
+
+	engine := New(store, queries, []Rule{{Target: TargetActivity, Action: ActionAnonymise, After: 2 * year}})
+	if _, err := engine.Run(ctx); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	query := "SELECT COUNT(*) FROM activity_log WHERE customer_id = ? AND anonymised_at IS NOT NULL AND description NOT LIKE '%Retention Customer%'"
+	if n := countRows(t, store, query, customer.ID); n != 1 {
+		t.Errorf("expected the old entry to be anonymised, got %d", n)
+	}
+	// the anonymisation is recorded in the chain, and the record itself is kept
+	records := "SELECT COUNT(*) FROM activity_log WHERE action = ? AND anonymised_at IS NULL AND seq IS NOT NULL"
+	if n := countRows(t, store, records, activitylog.ActionActivityAnonymised); n == 0 {
+		t.Errorf("expected the anonymisation to be recorded in the activity chain")
+	}
+
+	// a second run must not touch already anonymised entries
+	report, err := engine.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if report.Affected() != 0 {
+		t.Errorf("second run affected %d entries, want 0", report.Affected())
+	}
+}
@@ -0,0 +1,143 @@
+package retention
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Target is the kind of record a retention rule applies to.
+type Target string
+
+const (
+	TargetCustomers     Target = "customers"
+	TargetContacts      Target = "contacts"
+	TargetSubscriptions Target = "subscriptions"
+	TargetActivity      Target = "activity"
+)
+
+// Action is what a retention rule does to records older than its age.
+type Action string
+
+const (
+	ActionPurge     Action = "purge"
+	ActionAnonymise Action = "anonymise"
+)
+
+// supportedRules lists the target/action combinations the engine knows how to enforce.
+// Activity can only be anonymised: purging it would break the history the log exists to keep.
+var supportedRules = map[Target]Action{
+	TargetCustomers:     ActionPurge,
+	TargetContacts:      ActionPurge,
+	TargetSubscriptions: ActionPurge,
+	TargetActivity:      ActionAnonymise,
+}
+
+// Rule describes a single retention policy, e.g. "purge soft-deleted contacts after 90 days".
+// For purge rules the age is measured from deleted_at, for anonymise rules from created_at.
+type Rule struct {
+	Target Target
+	Action Action
+	After  time.Duration
+}
+
+// String returns the rule in the same target:action:age form accepted by ParseRules.
+func (r Rule) String() string {
+	return fmt.Sprintf("%s:%s:%s", r.Target, r.Action, formatAge(r.After))
+}
+
+// LoadRules parses a RETENTION_RULES style value. Retention deletes data, so it is off until rules
+// are configured: an empty value, "off" or "none" yields no rules.
+func LoadRules(value string) ([]Rule, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "off", "none":
+		return nil, nil
+	}
+	return ParseRules(value)
+}
+
+// ParseRules parses a comma separated list of target:action:age rules,
+// e.g. "contacts:purge:90d,activity:anonymise:2y".
+func ParseRules(value string) ([]Rule, error) {
+	var rules []Rule
+	for _, raw := range strings.Split(value, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		parts := strings.Split(raw, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid retention rule %q: expected target:action:age", raw)
+		}
+		age, err := parseAge(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention rule %q: %w", raw, err)
+		}
+		rule := Rule{
+			Target: Target(strings.ToLower(parts[0])),
+			Action: Action(strings.ToLower(parts[1])),
+			After:  age,
+		}
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("invalid retention rule %q: %w", raw, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// validate checks that the engine supports the rule's target and action.
+func (r Rule) validate() error {
+	action, ok := supportedRules[r.Target]
+	if !ok {
+		return fmt.Errorf("unknown target %q", r.Target)
+	}
+	if action != r.Action {
+		return fmt.Errorf("%s can only be %sd, not %sd", r.Target, action, r.Action)
+	}
+	if r.After <= 0 {
+		return fmt.Errorf("age must be positive")
+	}
+	return nil
+}
+
+const (
+	day  = 24 * time.Hour
+	week = 7 * day
+	year = 365 * day
+)
+
+// parseAge parses ages such as "90d", "6w" or "2y", falling back to Go duration syntax ("720h").
+func parseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("missing age")
+	}
+	units := map[byte]time.Duration{'d': day, 'w': week, 'y': year}
+	if unit, ok := units[s[len(s)-1]]; ok {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+// formatAge renders an age using the largest whole unit understood by parseAge.
+func formatAge(d time.Duration) string {
+	switch {
+	case d%year == 0:
+		return fmt.Sprintf("%dy", d/year)
+	case d%week == 0:
+		return fmt.Sprintf("%dw", d/week)
+	case d%day == 0:
+		return fmt.Sprintf("%dd", d/day)
+	}
+	return d.String()
+}
@@ -0,0 +1,47 @@
+// Package scheduler runs background jobs on a fixed interval for the lifetime of the server.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job is a unit of background work. Returned errors are logged and do not stop the schedule.
+type Job func(ctx context.Context) error
+
+// Every runs job immediately and then once per interval until ctx is cancelled.
+// Runs never overlap; a run that takes longer than the interval delays the next one.
+func Every(ctx context.Context, name string, interval time.Duration, job Job) {
+	if interval <= 0 {
+		slog.Warn("Scheduled job disabled, interval must be positive", "job", name, "interval", interval)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		run(ctx, name, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run executes a single job run, logging its outcome and recovering from panics so the schedule survives.
+func run(ctx context.Context, name string, job Job) {
+	start := time.Now()
+	defer func() {
+		if recov := recover(); recov != nil {
+			slog.Error("Scheduled job panicked", "job", name, "panic", recov)
+		}
+	}()
+	if err := job(ctx); err != nil {
+		slog.Error("Scheduled job failed", "job", name, "err", err, "duration_ms", time.Since(start).Milliseconds())
+		return
+	}
+	slog.Debug("Scheduled job finished", "job", name, "duration_ms", time.Since(start).Milliseconds())
+}
@@ -0,0 +1,240 @@
+// Package search parses global search input and runs it against the full-text search index.
+package search
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// Limit is the maximum number of results returned for a search.
+const Limit = 20
+
+// Record types held in the search index, also accepted by the type qualifier.
+const (
+	TypeCustomer     = "customer"
+	TypeContact      = "contact"
+	TypeSubscription = "subscription"
+)
+
+// highlightStart and highlightEnd wrap matched terms in titles and snippets returned by the index. Control characters
+// are used rather than markup so the text can be escaped as usual when rendered.
+const (
+	highlightStart = '\x02'
+	highlightEnd   = '\x03'
+)
+
+// Query is parsed search input. Qualifiers narrow the results and the remaining terms are matched against the index.
+type Query struct {
+	// Terms are matched as prefixes, all of which must appear. A quoted term is matched as a phrase.
+	Terms []string
+	// Type limits results to one record type, from the type: qualifier.
+	Type string
+	// Status limits results to records with the given status, from the status: qualifier.
+	Status string
+}
+
+// Result is a single search match.
+type Result struct {
+	Type         string
+	ID           uuid.UUID
+	CustomerID   uuid.UUID
+	CustomerName string
+	Status       string
+	// Title is the record name, or description for subscriptions, with matched terms highlighted.
+	Title []Segment
+	// Snippet is an excerpt of the other searchable fields around the best match.
+	Snippet []Segment
+}
+
+// Segment is a run of result text that is either entirely inside or outside a highlighted match.
+type Segment struct {
+	Text  string
+	Match bool
+}
+
+// Parse splits search input into terms and qualifiers. Terms are separated by whitespace unless quoted, and
+// qualifiers take the form key:value. Unrecognised qualifiers are kept as ordinary terms.
+func Parse(input string) Query {
+	var q Query
+	for _, token := range tokenise(input) {
+		if token.quoted {
+			q.Terms = append(q.Terms, token.text)
+			continue
+		}
+		key, value, ok := strings.Cut(token.text, ":")
+		switch {
+		case ok && strings.EqualFold(key, "type") && value != "":
+			q.Type = normaliseType(value)
+		case ok && strings.EqualFold(key, "status") && value != "":
+			q.Status = strings.ToLower(value)
+		default:
+			q.Terms = append(q.Terms, token.text)
+		}
+	}
+	return q
+}
+
+// Empty reports whether the query has neither terms nor qualifiers.
+func (q Query) Empty() bool {
+	return len(q.Terms) == 0 && q.Type == "" && q.Status == ""
+}
+
+// Match builds the FTS5 match expression for the query terms. Every term is quoted so that operators and
+// punctuation typed by the user are treated as text, and matched as a prefix so results update while typing.
+func (q Query) Match() string {
+	parts := make([]string, 0, len(q.Terms))
+	for _, term := range q.Terms {
+		if !hasWordCharacters(term) {
+			continue
+		}
+		parts = append(parts, `"`+strings.ReplaceAll(term, `"`, `""`)+`"*`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Run executes the query against the search index, returning at most limit results ranked by relevance. A query with
+// qualifiers but no terms lists matching records by title instead.
+func Run(ctx context.Context, queries *db.Queries, q Query, limit int64) ([]Result, error) {
+	if q.Empty() {
+		return nil, nil
+	}
+	recordType := nullString(q.Type)
+	status := nullString(q.Status)
+
+	match := q.Match()
+	if match == "" {
+		if len(q.Terms) > 0 {
+			// only punctuation was typed, which cannot match anything in the index
+			return nil, nil
+		}
+		rows, err := queries.BrowseSearchIndex(ctx, db.BrowseSearchIndexParams{
+			RecordType:  recordType,
+			Status:      status,
+			ResultLimit: limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		results := make([]Result, 0, len(rows))
+		for _, row := range rows {
+			results = append(results, newResult(db.SearchRecordsRow(row)))
+		}
+		return results, nil
+	}
+
+	rows, err := queries.SearchRecords(ctx, db.SearchRecordsParams{
+		Match:       match,
+		RecordType:  recordType,
+		Status:      status,
+		ResultLimit: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(rows))
+	for _, row := range rows {
+		results = append(results, newResult(row))
+	}
+	return results, nil
+}
+
+// Highlight splits text returned by the index into plain and matched segments.
+func Highlight(text string) []Segment {
+	var segments []Segment
+	var curr strings.Builder
+	match := false
+	flush := func() {
+		if curr.Len() > 0 {
+			segments = append(segments, Segment{Text: curr.String(), Match: match})
+			curr.Reset()
+		}
+	}
+	for _, r := range text {
+		switch r {
+		case highlightStart:
+			flush()
+			match = true
+		case highlightEnd:
+			flush()
+			match = false
+		default:
+			curr.WriteRune(r)
+		}
+	}
+	flush()
+	return segments
+}
+
+// newResult converts an index row into a result.
+func newResult(row db.SearchRecordsRow) Result {
+	return Result{
+		Type:         row.RecordType,
+		ID:           row.RecordID,
+		CustomerID:   row.CustomerID,
+		CustomerName: row.CustomerName,
+		Status:       row.Status.String,
+		Title:        Highlight(row.Title),
+		Snippet:      Highlight(strings.TrimSpace(row.Snippet)),
+	}
+}
+
+// token is a search term or qualifier as typed.
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenise splits input on whitespace, keeping double quoted phrases together. An unterminated quote runs to the
+// end of the input, as it would while the user is still typing.
+func tokenise(input string) []token {
+	var tokens []token
+	var curr strings.Builder
+	quoted := false
+	flush := func(wasQuoted bool) {
+		if text := strings.TrimSpace(curr.String()); text != "" {
+			tokens = append(tokens, token{text: text, quoted: wasQuoted})
+		}
+		curr.Reset()
+	}
+	for _, r := range input {
+		switch {
+		case r == '"':
+			flush(quoted)
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			flush(false)
+		default:
+			curr.WriteRune(r)
+		}
+	}
+	flush(quoted)
+	return tokens
+}
+
+// normaliseType maps the value of a type qualifier to a record type, accepting plurals such as type:contacts.
+func normaliseType(value string) string {
+	value = strings.ToLower(value)
+	switch value {
+	case "customers", "contacts", "subscriptions":
+		return strings.TrimSuffix(value, "s")
+	case "sub", "subs":
+		return TypeSubscription
+	}
+	return value
+}
+
+// hasWordCharacters reports whether a term contains anything the index tokeniser would keep.
+func hasWordCharacters(term string) bool {
+	return strings.IndexFunc(term, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsNumber(r) }) >= 0
+}
+
+// nullString converts an empty string to a NULL query parameter.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
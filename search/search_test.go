@@ -0,0 +1,183 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Query
+	}{
+		{"", Query{}},
+		{"acme", Query{Terms: []string{"acme"}}},
+		{"  acme   corp ", Query{Terms: []string{"acme", "corp"}}},
+		{`"acme corp" hosting`, Query{Terms: []string{"acme corp", "hosting"}}},
+		{`"acme co`, Query{Terms: []string{"acme co"}}},
+		{"status:Inactive acme", Query{Terms: []string{"acme"}, Status: "inactive"}},
+		{"type:contacts jane", Query{Terms: []string{"jane"}, Type: TypeContact}},
+		{"type:sub", Query{Type: TypeSubscription}},
+		{"type: jane", Query{Terms: []string{"type:", "jane"}}},
+		{"https://example.com", Query{Terms: []string{"https://example.com"}}},
+	}
+	for _, tt := range tests {
+		if got := Parse(tt.input); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestQueryMatch(t *testing.T) {
+	tests := []struct {
+		query Query
+		want  string
+	}{
+		{Query{}, ""},
+		{Query{Terms: []string{"acme"}}, `"acme"*`},
+		{Query{Terms: []string{"acme corp", "host"}}, `"acme corp"* "host"*`},
+		{Query{Terms: []string{`say "hi"`}}, `"say ""hi"""*`},
+		{Query{Terms: []string{"OR", "-", "NEAR("}}, `"OR"* "NEAR("*`},
+	}
+	for _, tt := range tests {
+		if got := tt.query.Match(); got != tt.want {
+			t.Errorf("Match(%+v) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("Hello \x02wor\x03ld and \x02more\x03")
+	want := []Segment{
+		{Text: "Hello "},
+		{Text: "wor", Match: true},
+		{Text: "ld and "},
+		{Text: "more", Match: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Highlight = %+v, want %+v", got, want)
+	}
+	if got := Highlight(""); len(got) != 0 {
+		t.Errorf("expected no segments for empty text, got %+v", got)
+	}
+}
+
+func setupTestDB(t *testing.T) (*sqlc.Queries, func()) {
+	os.MkdirAll("data", 0755)
+	dbConn, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	cleanup := func() { dbConn.Close() }
+	return queries, cleanup
+}
+
+// runIDs runs a search and returns the IDs of the results.
+func runIDs(t *testing.T, queries *sqlc.Queries, input string) []uuid.UUID {
+	results, err := Run(context.Background(), queries, Parse(input), Limit)
+	if err != nil {
+		t.Fatalf("Run(%q) failed: %v", input, err)
+	}
+	ids := make([]uuid.UUID, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestRun_IndexFollowsChanges_Integration(t *testing.T) {
+	queries, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// a unique word per run keeps results from earlier runs against the same database out of the way
+	word := "zq" + strings.ReplaceAll(uuid.NewString(), "-", "")[:10]
+
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{
+		Name:   "Search " + word,
+		Status: "inactive",
+		Notes:  sql.NullString{String: "Prefers email about the " + word + " migration", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	contact, err := queries.CreateContact(ctx, sqlc.CreateContactParams{
+		CustomerID: customer.ID,
+		Name:       "Jane " + word,
+		Role:       sql.NullString{String: "Finance", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateContact failed: %v", err)
+	}
+	subscription, err := queries.CreateSubscription(ctx, sqlc.CreateSubscriptionParams{
+		CustomerID:     customer.ID,
+		Description:    word + " hosting",
+		Amount:         10,
+		Quantity:       1,
+		UnitPrice:      10,
+		Term:           "monthly",
+		BillingCadence: "monthly",
+		Status:         "active",
+		StartDate:      time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription failed: %v", err)
+	}
+
+	if ids := runIDs(t, queries, word[:6]); len(ids) != 3 {
+		t.Fatalf("expected the customer, contact and subscription for a prefix search, got %v", ids)
+	}
+	if ids := runIDs(t, queries, word+" type:contact"); !reflect.DeepEqual(ids, []uuid.UUID{contact.ID}) {
+		t.Errorf("expected only the contact for type:contact, got %v", ids)
+	}
+	if ids := runIDs(t, queries, word+" status:inactive"); !reflect.DeepEqual(ids, []uuid.UUID{customer.ID}) {
+		t.Errorf("expected only the customer for status:inactive, got %v", ids)
+	}
+
+	results, err := Run(ctx, queries, Parse("migration "+word+" type:customer"), Limit)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("expected one result for a notes search, got %v (%v)", results, err)
+	}
+	highlighted := false
+	for _, segment := range results[0].Snippet {
+		highlighted = highlighted || segment.Match && segment.Text == "migration"
+	}
+	if !highlighted {
+		t.Errorf("expected the snippet to highlight the matched note, got %+v", results[0].Snippet)
+	}
+
+	// updates replace the indexed text
+	if _, err := queries.UpdateContact(ctx, sqlc.UpdateContactParams{ID: contact.ID, Version: contact.Version, Name: "Renamed " + word}); err != nil {
+		t.Fatalf("UpdateContact failed: %v", err)
+	}
+	if ids := runIDs(t, queries, "jane "+word); len(ids) != 0 {
+		t.Errorf("expected the old contact name to be removed from the index, got %v", ids)
+	}
+	if ids := runIDs(t, queries, "renamed "+word); !reflect.DeepEqual(ids, []uuid.UUID{contact.ID}) {
+		t.Errorf("expected the new contact name to be indexed, got %v", ids)
+	}
+
+	// soft deletes remove the record, and deleting the customer hides everything beneath it
+	if _, err := queries.DeleteSubscription(ctx, subscription.ID); err != nil {
+		t.Fatalf("DeleteSubscription failed: %v", err)
+	}
+	if ids := runIDs(t, queries, word+" type:subscription"); len(ids) != 0 {
+		t.Errorf("expected the deleted subscription to be removed from the index, got %v", ids)
+	}
+	if _, err := queries.DeleteCustomer(ctx, customer.ID); err != nil {
+		t.Fatalf("DeleteCustomer failed: %v", err)
+	}
+	if ids := runIDs(t, queries, word); len(ids) != 0 {
+		t.Errorf("expected no results once the customer is deleted, got %v", ids)
+	}
+}
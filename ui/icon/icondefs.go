@@ -31,7 +31,9 @@ var Phone = Icon("phone")
 var Plus = Icon("plus")
 var Rss = Icon("rss")
 var Search = Icon("search")
+var Settings = Icon("settings")
 var Sun = Icon("sun")
+var Tag = Icon("tag")
 var Trash2 = Icon("trash-2")
 var TrendingDown = Icon("trending-down")
 var TrendingUp = Icon("trending-up")
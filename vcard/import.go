@@ -0,0 +1,174 @@
+package vcard
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+
+	al "github.com/scottmckendry/beam/activitylog"
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// avatarDir is where the photos of imported cards are saved, matching avatars uploaded by hand.
+var avatarDir = "public/uploads/avatars"
+
+// photoExtensions maps the photo types that can be saved as avatars to their file extension.
+var photoExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Result counts the contacts created and merged by an import.
+type Result struct {
+	Created int
+	Merged  int
+}
+
+// Import adds cards to a customer in a single transaction. A card with the same email address as one of the
+// customer's contacts, ignoring case, is merged into that contact: the fields the card has replace the contact's,
+// and those it lacks are kept. Other cards are added as new contacts. Photos are saved as avatars.
+func Import(ctx context.Context, store *sql.DB, queries *db.Queries, customerID uuid.UUID, cards []Card, fileName string) (Result, error) {
+	var result Result
+	existing, err := queries.ListContactsByCustomer(ctx, customerID)
+	if err != nil {
+		return result, fmt.Errorf("error loading contacts: %w", err)
+	}
+	byEmail := make(map[string]db.Contact)
+	for _, c := range existing {
+		if email := strings.ToLower(c.Email.String); email != "" {
+			if _, seen := byEmail[email]; !seen {
+				byEmail[email] = c
+			}
+		}
+	}
+
+	tx, err := store.BeginTx(ctx, nil)
+	if err != nil {
+		return result, fmt.Errorf("error beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+	qtx := queries.WithTx(tx)
+
+	// avatars are written as the import runs, so they are removed again if it fails, while the avatars they replace
+	// are only removed once it succeeds
+	var written, replaced []string
+	committed := false
+	defer func() {
+		if !committed {
+			removeFiles(written)
+		}
+	}()
+
+	for i, card := range cards {
+		email := strings.ToLower(card.Email)
+		contact, merge := byEmail[email]
+		if email == "" {
+			merge = false
+		}
+
+		if merge {
+			before := contact
+			contact, err = qtx.UpdateContact(ctx, db.UpdateContactParams{
+				Name:      card.Name,
+				Role:      keep(contact.Role, card.Role),
+				Email:     contact.Email,
+				Phone:     keep(contact.Phone, card.Phone),
+				IsPrimary: contact.IsPrimary,
+				Notes:     keep(contact.Notes, card.Notes),
+				ID:        contact.ID,
+				Version:   contact.Version,
+			})
+			if err != nil {
+				return Result{}, fmt.Errorf("error merging card %d into %s: %w", i+1, before.Name, err)
+			}
+			if contact, err = savePhoto(ctx, qtx, contact, card, &written); err != nil {
+				return Result{}, fmt.Errorf("error saving the photo of card %d: %w", i+1, err)
+			}
+			if before.Avatar.Valid && before.Avatar != contact.Avatar {
+				replaced = append(replaced, before.Avatar.String)
+			}
+			al.LogContactMerged(ctx, qtx, before, contact, fileName)
+			result.Merged++
+		} else {
+			contact, err = qtx.CreateContact(ctx, db.CreateContactParams{
+				CustomerID: customerID,
+				Name:       card.Name,
+				Role:       nullable(card.Role),
+				Email:      nullable(card.Email),
+				Phone:      nullable(card.Phone),
+				IsPrimary:  sql.NullBool{Valid: true},
+				Notes:      nullable(card.Notes),
+			})
+			if err != nil {
+				return Result{}, fmt.Errorf("error creating the contact for card %d: %w", i+1, err)
+			}
+			if contact, err = savePhoto(ctx, qtx, contact, card, &written); err != nil {
+				return Result{}, fmt.Errorf("error saving the photo of card %d: %w", i+1, err)
+			}
+			al.LogContactImported(ctx, qtx, customerID, contact.Name, fileName)
+			result.Created++
+		}
+		// later cards with the same email merge into this contact rather than adding another
+		if email != "" {
+			byEmail[email] = contact
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("error committing import: %w", err)
+	}
+	committed = true
+	removeFiles(replaced)
+	return result, nil
+}
+
+// savePhoto saves the photo of a card as the contact's avatar, returning the contact with its new avatar. Contacts
+// are returned unchanged when the card has no photo of a type that can be shown.
+func savePhoto(ctx context.Context, queries *db.Queries, contact db.Contact, card Card, written *[]string) (db.Contact, error) {
+	ext, ok := photoExtensions[card.PhotoType]
+	if len(card.Photo) == 0 || !ok {
+		return contact, nil
+	}
+	path := filepath.ToSlash(filepath.Join(avatarDir, contact.ID.String()+ext))
+	if err := os.WriteFile(path, card.Photo, 0644); err != nil {
+		return contact, err
+	}
+	if path != contact.Avatar.String {
+		*written = append(*written, path)
+	}
+	contact.Avatar = sql.NullString{String: path, Valid: true}
+	err := queries.UpdateContactAvatar(ctx, db.UpdateContactAvatarParams{ID: contact.ID, Avatar: contact.Avatar})
+	return contact, err
+}
+
+// removeFiles removes avatar files, logging rather than returning failures as the import itself has been decided.
+func removeFiles(paths []string) {
+	for _, path := range paths {
+		if !strings.HasPrefix(path, avatarDir) || strings.Contains(path, "..") {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Error("Error removing avatar file", "file", path, "err", err)
+		}
+	}
+}
+
+// keep returns value when set, or current otherwise.
+func keep(current sql.NullString, value string) sql.NullString {
+	if value == "" {
+		return current
+	}
+	return nullable(value)
+}
+
+func nullable(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
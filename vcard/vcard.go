@@ -0,0 +1,386 @@
+// Package vcard writes contacts out as vCard 4.0 for phones and mail clients, and reads vCard files back in so that
+// contacts can be imported into a customer.
+package vcard
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/scottmckendry/beam/db/sqlc"
+)
+
+// ContentType is the content type vCard files are served as.
+const ContentType = "text/vcard; charset=utf-8"
+
+// MaxFileSize is the largest vCard file that can be imported. Cards often carry photos, so this is more generous
+// than the CSV import.
+const MaxFileSize = 10 << 20
+
+// uploadsDir is the only location avatars are read from when writing cards.
+const uploadsDir = "public/uploads/"
+
+// lineLength is the most octets on a line before it is folded, as RFC 6350 recommends.
+const lineLength = 75
+
+// Card is a contact read from a vCard file. Fields missing from the card are empty.
+type Card struct {
+	Name  string
+	Role  string
+	Email string
+	Phone string
+	Notes string
+	// Photo is the embedded photo, if any, and PhotoType its content type.
+	Photo     []byte
+	PhotoType string
+}
+
+// Write writes a contact as a vCard, with their customer as the organisation and their avatar embedded as a data URI.
+func Write(w io.Writer, contact db.Contact, customerName string) error {
+	var buf bytes.Buffer
+	line := func(name, value string) {
+		fold(&buf, name+":"+value)
+	}
+
+	line("BEGIN", "VCARD")
+	line("VERSION", "4.0")
+	line("UID", "urn:uuid:"+contact.ID.String())
+	line("FN", escape(contact.Name))
+	line("N", structuredName(contact.Name))
+	if customerName != "" {
+		line("ORG", escape(customerName))
+	}
+	if contact.Role.String != "" {
+		line("TITLE", escape(contact.Role.String))
+	}
+	if contact.Email.String != "" {
+		line("EMAIL;TYPE=work", escape(contact.Email.String))
+	}
+	if contact.Phone.String != "" {
+		// numbers are free text in Beam, so they are kept as text rather than converted to a tel: URI
+		line("TEL;VALUE=text;TYPE=work", escape(contact.Phone.String))
+	}
+	if contact.Notes.String != "" {
+		line("NOTE", escape(contact.Notes.String))
+	}
+	if photo := avatarURI(contact.Avatar.String); photo != "" {
+		line("PHOTO", photo)
+	}
+	if contact.UpdatedAt.Valid {
+		line("REV", contact.UpdatedAt.Time.UTC().Format("20060102T150405Z"))
+	}
+	line("END", "VCARD")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// structuredName splits a full name into the family and given names of the N property, treating the last word as
+// the family name.
+func structuredName(name string) string {
+	words := strings.Fields(name)
+	if len(words) < 2 {
+		return escape(name) + ";;;;"
+	}
+	family := words[len(words)-1]
+	given := strings.Join(words[:len(words)-1], " ")
+	return escape(family) + ";" + escape(given) + ";;;"
+}
+
+// avatarURI reads an uploaded avatar and encodes it as a data URI. Missing files and paths outside the uploads
+// directory give an empty string, leaving the photo out.
+func avatarURI(path string) string {
+	if !strings.HasPrefix(path, uploadsDir) || strings.Contains(path, "..") {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// fold writes a content line, folding it onto continuation lines that start with a space so that no line is longer
+// than lineLength octets. Lines are only broken between characters, never inside one.
+func fold(buf *bytes.Buffer, line string) {
+	limit := lineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space of a continuation counts towards its length
+		limit = lineLength - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+func unescape(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(value[i])
+			}
+			continue
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// property is a single content line of a card.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads every card in a vCard file. Versions 2.1, 3.0 and 4.0 are read, taking the first email address and
+// phone number of each card. Cards without a name are an error, as a contact needs one.
+func Parse(data []byte) ([]Card, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	var cards []Card
+	var current *Card
+	var n string
+	for i, raw := range unfold(data) {
+		line := i + 1
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		p, ok := parseProperty(raw)
+		if !ok {
+			return nil, fmt.Errorf("property %d is not a vCard property", line)
+		}
+
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VCARD"):
+			if current != nil {
+				return nil, fmt.Errorf("card %d is not ended", len(cards)+1)
+			}
+			current, n = &Card{}, ""
+		case p.name == "END" && strings.EqualFold(p.value, "VCARD"):
+			if current == nil {
+				return nil, fmt.Errorf("property %d ends a card that was not begun", line)
+			}
+			if current.Name == "" {
+				current.Name = nameFromN(n)
+			}
+			if current.Name == "" {
+				return nil, fmt.Errorf("card %d has no name", len(cards)+1)
+			}
+			cards = append(cards, *current)
+			current = nil
+		case current == nil:
+			return nil, fmt.Errorf("property %d is outside of a card", line)
+		default:
+			if err := current.set(p, &n); err != nil {
+				return nil, fmt.Errorf("card %d: %w", len(cards)+1, err)
+			}
+		}
+	}
+	if current != nil {
+		return nil, fmt.Errorf("card %d is not ended", len(cards)+1)
+	}
+	if len(cards) == 0 {
+		return nil, fmt.Errorf("the file has no cards")
+	}
+	return cards, nil
+}
+
+// set reads a property into the card, keeping the first of each. The structured name is kept in n, to be used when
+// the card has no formatted name.
+func (c *Card) set(p property, n *string) error {
+	switch p.name {
+	case "FN":
+		if c.Name == "" {
+			c.Name = strings.TrimSpace(unescape(p.value))
+		}
+	case "N":
+		*n = p.value
+	case "TITLE", "ROLE":
+		if c.Role == "" {
+			c.Role = strings.TrimSpace(unescape(p.value))
+		}
+	case "EMAIL":
+		if c.Email == "" {
+			c.Email = strings.TrimSpace(strings.TrimPrefix(unescape(p.value), "mailto:"))
+		}
+	case "TEL":
+		if c.Phone == "" {
+			c.Phone = strings.TrimSpace(strings.TrimPrefix(unescape(p.value), "tel:"))
+		}
+	case "NOTE":
+		if c.Notes == "" {
+			c.Notes = strings.TrimSpace(unescape(p.value))
+		}
+	case "PHOTO":
+		if c.Photo == nil {
+			photo, contentType, err := decodePhoto(p)
+			if err != nil {
+				return err
+			}
+			c.Photo, c.PhotoType = photo, contentType
+		}
+	}
+	return nil
+}
+
+// decodePhoto decodes an embedded photo, either a version 4.0 data URI or a version 2.1 or 3.0 base64 value. Photos
+// linked by URL are skipped.
+func decodePhoto(p property) ([]byte, string, error) {
+	value, contentType := p.value, ""
+	if rest, ok := strings.CutPrefix(value, "data:"); ok {
+		meta, encoded, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(meta, ";base64") {
+			return nil, "", nil
+		}
+		value, contentType = encoded, strings.TrimSuffix(meta, ";base64")
+	} else if encoding := strings.ToLower(p.params["ENCODING"]); encoding == "b" || encoding == "base64" {
+		contentType = "image/" + strings.ToLower(p.params["TYPE"])
+	} else {
+		return nil, "", nil
+	}
+
+	photo, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+	if err != nil {
+		return nil, "", fmt.Errorf("the photo could not be decoded: %w", err)
+	}
+	if !strings.HasPrefix(contentType, "image/") || contentType == "image/" {
+		contentType = http.DetectContentType(photo)
+	}
+	return photo, contentType, nil
+}
+
+// nameFromN builds a full name from the given and family names of a structured name.
+func nameFromN(n string) string {
+	parts := splitUnescaped(n, ';')
+	var words []string
+	for _, i := range []int{3, 1, 2, 0, 4} {
+		if i < len(parts) && strings.TrimSpace(parts[i]) != "" {
+			words = append(words, strings.TrimSpace(unescape(parts[i])))
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// unfold joins continuation lines, which start with a space or tab, onto the line before them.
+func unfold(data []byte) []string {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), MaxFileSize)
+	for scanner.Scan() {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t")) {
+			lines[len(lines)-1] += text[1:]
+			continue
+		}
+		lines = append(lines, text)
+	}
+	return lines
+}
+
+// parseProperty splits a content line into its name, parameters and value. Group prefixes such as item1. are
+// dropped, and bare version 2.1 parameters such as ;WORK are kept as TYPE.
+func parseProperty(line string) (property, bool) {
+	head, value, ok := cutUnquoted(line, ':')
+	if !ok {
+		return property{}, false
+	}
+	parts := splitUnescaped(head, ';')
+	name := strings.ToUpper(strings.TrimSpace(parts[0]))
+	if _, after, grouped := strings.Cut(name, "."); grouped {
+		name = after
+	}
+	if name == "" {
+		return property{}, false
+	}
+
+	params := make(map[string]string)
+	for _, param := range parts[1:] {
+		key, val, found := strings.Cut(param, "=")
+		if !found {
+			key, val = "TYPE", param
+		}
+		params[strings.ToUpper(key)] = strings.Trim(val, `"`)
+	}
+	return property{name: name, params: params, value: value}, true
+}
+
+// cutUnquoted cuts s around the first sep outside of double quotes, as parameter values may be quoted.
+func cutUnquoted(s string, sep byte) (string, string, bool) {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				return s[:i], s[i+1:], true
+			}
+		}
+	}
+	return s, "", false
+}
+
+// splitUnescaped splits s around every sep that is not escaped with a backslash.
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// Filename returns a safe file name for a card or set of cards, e.g. "jane-smith.vcf".
+func Filename(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		slug = "contacts"
+	}
+	return slug + ".vcf"
+}
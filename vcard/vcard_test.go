@@ -0,0 +1,162 @@
+package vcard
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/scottmckendry/beam/db"
+	sqlc "github.com/scottmckendry/beam/db/sqlc"
+)
+
+// pixel is a 1x1 PNG.
+var pixel = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89\x00\x00\x00\rIDATx\x9cc\xf8\x0f\x00\x00\x01\x01\x00\x05\x18\xd8N\x00\x00\x00\x00IEND\xaeB`\x82")
+
+func TestWriteAndParse(t *testing.T) {
+	os.MkdirAll("public/uploads/avatars", 0755)
+	defer os.RemoveAll("public")
+	contact := sqlc.Contact{
+		ID:     uuid.New(),
+		Name:   "Jane van der Berg",
+		Role:   sql.NullString{String: "Head of Finance, APAC", Valid: true},
+		Email:  sql.NullString{String: "jane@acme.test", Valid: true},
+		Phone:  sql.NullString{String: "+64 21 123 4567", Valid: true},
+		Notes:  sql.NullString{String: "Prefers email; never calls.\nSecond line with ünïcödé " + strings.Repeat("long ", 20), Valid: true},
+		Avatar: sql.NullString{String: "public/uploads/avatars/jane.png", Valid: true},
+	}
+	if err := os.WriteFile(contact.Avatar.String, pixel, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, contact, "Acme; Ltd"); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	for _, line := range strings.Split(buf.String(), "\r\n") {
+		if len(line) > lineLength {
+			t.Errorf("line longer than %d octets: %q", lineLength, line)
+		}
+	}
+	for _, want := range []string{"VERSION:4.0", "N:Berg;Jane van der;;;", "ORG:Acme\\; Ltd", "PHOTO:data:image/png;base64,"} {
+		if !strings.Contains(strings.ReplaceAll(buf.String(), "\r\n ", ""), want) {
+			t.Errorf("expected %q in\n%s", want, buf.String())
+		}
+	}
+
+	cards, err := Parse(buf.Bytes())
+	if err != nil || len(cards) != 1 {
+		t.Fatalf("expected one card, got %v (%v)", cards, err)
+	}
+	got := cards[0]
+	if got.Name != contact.Name || got.Role != contact.Role.String || got.Email != contact.Email.String || got.Phone != contact.Phone.String {
+		t.Errorf("expected the contact back, got %+v", got)
+	}
+	if got.Notes != strings.TrimSpace(contact.Notes.String) {
+		t.Errorf("expected the notes back intact, got %q", got.Notes)
+	}
+	if !bytes.Equal(got.Photo, pixel) || got.PhotoType != "image/png" {
+		t.Errorf("expected the avatar back, got %d bytes of %q", len(got.Photo), got.PhotoType)
+	}
+}
+
+func TestParse(t *testing.T) {
+	cards, err := Parse([]byte("BEGIN:VCARD\nVERSION:3.0\nN:Smith;John;;Dr;\nitem1.EMAIL;type=INTERNET:john@globex.test\nTEL;WORK:555 1234\nPHOTO;ENCODING=b;TYPE=PNG:iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlE\n QVR4nGP4DwAAAQEABRjYTgAAAABJRU5ErkJggg==\nEND:VCARD\nBEGIN:VCARD\nVERSION:2.1\nFN:Ann Lee\nEND:VCARD\n"))
+	if err != nil || len(cards) != 2 {
+		t.Fatalf("expected two cards, got %v (%v)", cards, err)
+	}
+	if c := cards[0]; c.Name != "Dr John Smith" || c.Email != "john@globex.test" || c.Phone != "555 1234" || !bytes.Equal(c.Photo, pixel) {
+		t.Errorf("expected a card read from its structured name, got %+v", c)
+	}
+	if cards[1].Name != "Ann Lee" {
+		t.Errorf("expected the second card, got %+v", cards[1])
+	}
+
+	for name, data := range map[string]string{
+		"empty":     "",
+		"unended":   "BEGIN:VCARD\nFN:Jane\n",
+		"nameless":  "BEGIN:VCARD\nEMAIL:jane@acme.test\nEND:VCARD\n",
+		"outside":   "FN:Jane\n",
+		"bad photo": "BEGIN:VCARD\nFN:Jane\nPHOTO:data:image/png;base64,!!!\nEND:VCARD\n",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestFilename(t *testing.T) {
+	for name, want := range map[string]string{
+		"Jane Smith":         "jane-smith.vcf",
+		"Acme, Ltd contacts": "acme-ltd-contacts.vcf",
+		"../..":              "contacts.vcf",
+	} {
+		if got := Filename(name); got != want {
+			t.Errorf("Filename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestImport_Integration(t *testing.T) {
+	os.MkdirAll("data", 0755)
+	store, queries, err := db.InitialiseDB()
+	if err != nil {
+		t.Fatalf("InitialiseDB failed: %v", err)
+	}
+	defer store.Close()
+	ctx := context.Background()
+	avatarDir = t.TempDir()
+
+	customer, err := queries.CreateCustomer(ctx, sqlc.CreateCustomerParams{Name: "vCard " + uuid.NewString(), Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateCustomer failed: %v", err)
+	}
+	existing, err := queries.CreateContact(ctx, sqlc.CreateContactParams{
+		CustomerID: customer.ID,
+		Name:       "Jane",
+		Email:      sql.NullString{String: "Jane@Acme.test", Valid: true},
+		Phone:      sql.NullString{String: "555 0000", Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateContact failed: %v", err)
+	}
+
+	result, err := Import(ctx, store, queries, customer.ID, []Card{
+		{Name: "Jane Smith", Email: "jane@acme.test", Role: "CFO", Photo: pixel, PhotoType: "image/png"},
+		{Name: "Joe Bloggs", Email: "joe@acme.test"},
+		{Name: "Joseph Bloggs", Email: "JOE@acme.test", Notes: "Goes by Joe"},
+	}, "team.vcf")
+	if err != nil || result.Created != 1 || result.Merged != 2 {
+		t.Fatalf("expected one contact created and two merged, got %+v (%v)", result, err)
+	}
+
+	merged, err := queries.GetContact(ctx, existing.ID)
+	if err != nil {
+		t.Fatalf("GetContact failed: %v", err)
+	}
+	if merged.Name != "Jane Smith" || merged.Role.String != "CFO" || merged.Phone.String != "555 0000" || merged.Email.String != "Jane@Acme.test" {
+		t.Errorf("expected the card merged over the contact, keeping what it lacks, got %+v", merged)
+	}
+	if data, err := os.ReadFile(merged.Avatar.String); err != nil || !bytes.Equal(data, pixel) {
+		t.Errorf("expected the photo saved as the avatar, got %q (%v)", merged.Avatar.String, err)
+	}
+
+	contacts, _ := queries.ListContactsByCustomer(ctx, customer.ID)
+	if len(contacts) != 2 {
+		t.Fatalf("expected two contacts, got %d", len(contacts))
+	}
+	for _, c := range contacts {
+		if c.ID != existing.ID && (c.Name != "Joseph Bloggs" || c.Notes.String != "Goes by Joe") {
+			t.Errorf("expected a later card with the same email to merge into the new contact, got %+v", c)
+		}
+	}
+
+	activity, _ := queries.ListActivityByCustomer(ctx, uuid.NullUUID{UUID: customer.ID, Valid: true})
+	if len(activity) != 3 {
+		t.Errorf("expected an entry for the import and each merge, got %d", len(activity))
+	}
+}